@@ -0,0 +1,92 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mirkoboehm/shelldoc/pkg/capture"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/spf13/cobra"
+)
+
+// sessionOptions contains the flags of the commands that record a shell session as Markdown
+type sessionOptions struct {
+	shellName  string
+	outputFile string
+	prompt     string
+	width      int
+}
+
+// addFlags adds the flags of the session options to cmd
+func (options *sessionOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVarP(&options.shellName, "shell", "s", "", "The shell to invoke (default: $SHELL)")
+	cmd.Flags().StringVarP(&options.outputFile, "output", "o", "", "Write the Markdown to the specified file instead of stdout")
+	cmd.Flags().StringVar(&options.prompt, "prompt", capture.DefaultPrompt, "The prompt written before the commands, $ or >")
+	cmd.Flags().IntVar(&options.width, "width", capture.DefaultWidth, "Wrap commands longer than this with continuation backslashes, 0 disables wrapping")
+}
+
+// newCaptureCmd creates the capture command
+func newCaptureCmd() *cobra.Command {
+	options := &sessionOptions{}
+	captureCmd := &cobra.Command{
+		Use:   "capture [SCRIPT]",
+		Short: "Record a shell session as Markdown code blocks",
+		Long: `Capture executes commands and writes them with their actual output as
+Markdown code blocks that can be tested with shelldoc. The commands are read
+from the specified script, or interactively from the terminal. Every line is
+one command, empty lines start a new code block.`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeCapture(options, args)
+		},
+	}
+	options.addFlags(captureCmd)
+	return captureCmd
+}
+
+func executeCapture(options *sessionOptions, args []string) error {
+	if err := capture.ValidatePrompt(options.prompt); err != nil {
+		return err
+	}
+	shellpath, err := shell.DetectShell(options.shellName)
+	if err != nil {
+		return err
+	}
+	sh, err := shell.StartShell(shellpath)
+	if err != nil {
+		return fmt.Errorf("unable to start shell: %v", err)
+	}
+	defer sh.Exit()
+	var input io.Reader = os.Stdin
+	var prompt io.Writer
+	if len(args) > 0 {
+		file, err := os.Open(args[0])
+		if err != nil {
+			return fmt.Errorf("unable to open script: %v", err)
+		}
+		defer file.Close()
+		input = file
+	} else if info, err := os.Stdin.Stat(); err == nil && info.Mode()&os.ModeCharDevice != 0 {
+		// interactive session, show a prompt and the output of the commands
+		fmt.Fprintln(os.Stderr, "Enter commands, an empty line starts a new code block, Ctrl+D ends the session.")
+		prompt = os.Stderr
+	}
+	session := capture.NewSession(&sh)
+	session.Prompt, session.Width = options.prompt, options.width
+	if err := session.Replay(input, prompt); err != nil {
+		return err
+	}
+	output := os.Stdout
+	if len(options.outputFile) > 0 {
+		if output, err = os.Create(options.outputFile); err != nil {
+			return fmt.Errorf("unable to open output file for writing: %v", err)
+		}
+		defer output.Close()
+	}
+	return session.WriteMarkdown(output)
+}
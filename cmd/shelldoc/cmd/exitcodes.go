@@ -0,0 +1,39 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/mirkoboehm/shelldoc/pkg/exitcode"
+	"github.com/spf13/cobra"
+)
+
+// newExitCodesCmd creates the exit-codes command
+func newExitCodesCmd() *cobra.Command {
+	var printJSON bool
+	exitCodesCmd := &cobra.Command{
+		Use:   "exit-codes",
+		Short: "Print the exit codes of shelldoc and their meaning",
+		Long: `Exit-codes prints the exit codes of shelldoc and their meaning. The exit codes
+are stable, so that wrapper scripts and CI steps can rely on them.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if printJSON {
+				encoder := json.NewEncoder(os.Stdout)
+				encoder.SetIndent("", "  ")
+				return encoder.Encode(exitcode.Codes())
+			}
+			for _, code := range exitcode.Codes() {
+				fmt.Printf("%d  %-8s %s\n", code.Value, code.Name, code.Description)
+			}
+			return nil
+		},
+	}
+	exitCodesCmd.Flags().BoolVar(&printJSON, "json", false, "Print the exit codes in JSON format")
+	return exitCodesCmd
+}
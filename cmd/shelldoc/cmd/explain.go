@@ -0,0 +1,69 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/report"
+	"github.com/spf13/cobra"
+)
+
+// newExplainCmd creates the explain command
+func newExplainCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "explain REPORT TEST",
+		Short: "Show everything needed to triage a test from a JUnitXML report",
+		Long: `Explain reads a report written by "run --xml" and prints the full context of a
+test: its result, the code block in the Markdown source with line numbers, the
+attributes in effect, the differences between the expected response and the
+actual output, and the shell and settings the file was executed with.
+
+TEST is the ID of a test, a file and line like README.md:42, or a file, which
+explains all tests in the file that failed or could not be executed. Run it in
+the directory "run" was executed in, so that the Markdown files are found.`,
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeExplain(args[0], args[1])
+		},
+	}
+}
+
+func executeExplain(path, test string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("unable to open report: %v", err)
+	}
+	defer file.Close()
+	suites, err := junitxml.Read(file)
+	if err != nil {
+		return fmt.Errorf("unable to read report %s: %v", path, err)
+	}
+	matches, err := report.FindTests(suites, test)
+	if err != nil {
+		return err
+	}
+	for index, match := range matches {
+		if index > 0 {
+			fmt.Println()
+		}
+		source := match.TestCase.File
+		if len(source) == 0 {
+			source = match.Suite.Name
+		}
+		var data []byte
+		if !strings.Contains(source, "://") {
+			data, _ = ioutil.ReadFile(source) // the source is shown as not available
+		}
+		if err := report.Explain(os.Stdout, match, data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
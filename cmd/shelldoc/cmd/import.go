@@ -0,0 +1,66 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mirkoboehm/shelldoc/pkg/capture"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/spf13/cobra"
+)
+
+// newImportCmd creates the import command
+func newImportCmd() *cobra.Command {
+	options := &sessionOptions{}
+	importCmd := &cobra.Command{
+		Use:   "import SCRIPT",
+		Short: "Convert a shell script into a Markdown documentation test",
+		Long: `Import executes a shell script command by command and writes a Markdown
+skeleton with one interaction per command. Comments become prose, and the
+actual output of the commands is recorded as the expected response. This
+is a fast way to migrate script-based smoke tests to documentation tests.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeImport(options, args[0])
+		},
+	}
+	options.addFlags(importCmd)
+	return importCmd
+}
+
+func executeImport(options *sessionOptions, script string) error {
+	file, err := os.Open(script)
+	if err != nil {
+		return fmt.Errorf("unable to open script: %v", err)
+	}
+	defer file.Close()
+	if err := capture.ValidatePrompt(options.prompt); err != nil {
+		return err
+	}
+	shellpath, err := shell.DetectShell(options.shellName)
+	if err != nil {
+		return err
+	}
+	sh, err := shell.StartShell(shellpath)
+	if err != nil {
+		return fmt.Errorf("unable to start shell: %v", err)
+	}
+	defer sh.Exit()
+	session := capture.NewSession(&sh)
+	session.Prompt, session.Width = options.prompt, options.width
+	if err := session.Import(file); err != nil {
+		return err
+	}
+	output := os.Stdout
+	if len(options.outputFile) > 0 {
+		if output, err = os.Create(options.outputFile); err != nil {
+			return fmt.Errorf("unable to open output file for writing: %v", err)
+		}
+		defer output.Close()
+	}
+	return session.WriteMarkdown(output)
+}
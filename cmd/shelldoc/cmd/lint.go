@@ -0,0 +1,57 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mirkoboehm/shelldoc/pkg/exitcode"
+	"github.com/mirkoboehm/shelldoc/pkg/lint"
+	"github.com/mirkoboehm/shelldoc/pkg/run"
+	"github.com/spf13/cobra"
+)
+
+// newLintCmd creates the lint command
+func newLintCmd() *cobra.Command {
+	// duplicatesMinimum is the number of documents a command has to occur in to be reported as a duplicate
+	var duplicatesMinimum int
+	lintCmd := &cobra.Command{
+		Use:   "lint",
+		Short: "Check Markdown files for problems with the documentation tests",
+		Long: `Lint parses Markdown input files and reports problems with the documentation
+tests in them, like unknown or invalid attributes, without executing anything.
+With --duplicates, it also lists commands that are repeated across documents.`,
+		Args: cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			hasErrors := false
+			duplicates := lint.NewDuplicates()
+			for _, file := range args {
+				data, err := run.ReadInput([]string{file})
+				if err != nil {
+					return err
+				}
+				for _, finding := range lint.Lint(data) {
+					fmt.Printf("%s:%v\n", file, finding)
+					hasErrors = hasErrors || finding.Severity == lint.SeverityError
+				}
+				duplicates.Add(file, data)
+			}
+			if duplicatesMinimum > 0 {
+				for _, duplicate := range duplicates.Report(duplicatesMinimum) {
+					fmt.Printf("duplicate command in %d documents: %s\n", duplicate.Documents(), duplicate.Command)
+					for _, location := range duplicate.Locations {
+						fmt.Printf("  %v\n", location)
+					}
+				}
+			}
+			if hasErrors {
+				return exitWith(exitcode.Failure)
+			}
+			return nil
+		},
+	}
+	lintCmd.Flags().IntVar(&duplicatesMinimum, "duplicates", 0, "List the commands that occur identically in at least the specified number of documents, for example to move repeated setup steps into shared snippets (0: no report)")
+	return lintCmd
+}
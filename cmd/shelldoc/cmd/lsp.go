@@ -0,0 +1,42 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/mirkoboehm/shelldoc/pkg/lsp"
+	"github.com/mirkoboehm/shelldoc/pkg/run"
+	"github.com/spf13/cobra"
+)
+
+// newLspCmd creates the lsp command
+func newLspCmd() *cobra.Command {
+	var executeOnSave bool
+	var options run.Options
+	lspCmd := &cobra.Command{
+		Use:   "lsp",
+		Short: "Run a language server that reports shelldoc diagnostics to editors",
+		Long: `Lsp runs a minimal language server on stdin and stdout. It publishes
+diagnostics for the Markdown files opened in the editor, so that problems
+with the documentation tests are shown inline while writing documentation.
+The lint findings are updated whenever the document changes. Optionally,
+the document is executed when it is saved, and failed tests are reported.`,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			server := lsp.NewServer(os.Stdin, os.Stdout)
+			server.ExecuteOnSave = executeOnSave
+			server.Options = options
+			return server.Serve()
+		},
+	}
+	lspCmd.Flags().BoolVarP(&executeOnSave, "execute-on-save", "e", false, "Execute the document when it is saved and report failed tests")
+	lspCmd.Flags().StringVarP(&options.ShellName, "shell", "s", "", "The shell to invoke (default: $SHELL)")
+	lspCmd.Flags().BoolVar(&options.ShellStrict, "shell-strict", false, "Execute the commands in strict mode (set -euo pipefail), so that failing commands, failing pipelines and unset variables are reported")
+	lspCmd.Flags().StringVarP(&options.ConfigFile, "config", "c", "", "Read settings from the specified configuration file (JSON)")
+	lspCmd.Flags().StringVarP(&options.Profile, "profile", "p", "", "Use the shell, environment and other settings of the named profile in the configuration file")
+	lspCmd.Flags().StringVar(&options.Workdir, "workdir", "", "Execute the commands in the specified working directory, or in a temporary directory for every document that is removed afterwards (tmp)")
+	lspCmd.Flags().StringVar(&options.PolicyFile, "policy", "", "Read a policy file of allow and deny rules (regular expressions) that decide which commands may be executed")
+	return lspCmd
+}
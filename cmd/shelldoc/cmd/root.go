@@ -6,40 +6,91 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"os"
 
+	"github.com/mirkoboehm/shelldoc/pkg/exitcode"
 	"github.com/spf13/cobra"
 )
 
-var verbose bool
+// rootOptions contains the values of the flags of the root command, which apply to all commands
+type rootOptions struct {
+	verbose bool
+}
 
-// rootCmd represents the base command when called without any subcommands
-var rootCmd = &cobra.Command{
-	Use:   "shelldoc",
-	Short: "shelldoc tests Unix shell commands in Markdown documentation",
-	Long: `Markdown is widely used for documentation and README.md files that explain how
+// NewRootCmd creates the base command with all child commands. Every call creates new commands with their own flag
+// values, so that the commands can be executed several times in one process.
+func NewRootCmd() *cobra.Command {
+	options := &rootOptions{}
+	rootCmd := &cobra.Command{
+		Use:   "shelldoc",
+		Short: "shelldoc tests Unix shell commands in Markdown documentation",
+		Long: `Markdown is widely used for documentation and README.md files that explain how
 to use or build some software. Such documentation often contains shell commands that
 explain how to build a software or how to run it. To make sure the documentation is a
 ccurate and up-to-date, it should be automatically tested. shelldoc tests Unix shell
 commands in Markdown files and reports the results.`,
+		PersistentPreRun: func(cmd *cobra.Command, args []string) {
+			// the arguments are valid, errors of the command are not caused by the usage
+			cmd.SilenceUsage = true
+			initLogging(options.verbose)
+		},
+		SilenceErrors: true,
+	}
+	rootCmd.PersistentFlags().BoolVarP(&options.verbose, "verbose", "v", false, "Enable diagnostic log output")
+	rootCmd.AddCommand(
+		newCaptureCmd(),
+		newExitCodesCmd(),
+		newExplainCmd(),
+		newImportCmd(),
+		newLintCmd(),
+		newLspCmd(),
+		newRunCmd(options),
+		newTrendsCmd(),
+		newVerifyCmd(),
+		newVersionCmd(),
+	)
+	return rootCmd
+}
+
+// exitCodeError ends a command with a non-zero exit code after the command has reported the results
+type exitCodeError int
+
+func (code exitCodeError) Error() string {
+	return fmt.Sprintf("exit code %d", int(code))
 }
 
-// Execute adds all child commands to the root command and sets flags appropriately.
-func Execute() {
-	if err := rootCmd.Execute(); err != nil {
-		fmt.Println(err)
-		os.Exit(1)
+// exitWith returns the error that ends a command with the exit code, or nil for exitcode.Success
+func exitWith(code int) error {
+	if code == exitcode.Success {
+		return nil
 	}
+	return exitCodeError(code)
 }
 
-func init() {
-	cobra.OnInitialize(initLogging)
-	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Enable diagnostic log output")
+// Execute creates the root command, executes it with the command line arguments and returns the exit code.
+func Execute() int {
+	return execute(NewRootCmd())
 }
 
-func initLogging() {
+// execute executes the command and returns the exit code. The log output is restored afterwards, since the verbose
+// flag changes it for the duration of the command.
+func execute(rootCmd *cobra.Command) int {
+	defer restoreLogging(log.Writer(), log.Flags(), log.Prefix())
+	err := rootCmd.Execute()
+	if err == nil {
+		return exitcode.Success
+	}
+	if code, ok := err.(exitCodeError); ok {
+		return int(code)
+	}
+	fmt.Fprintln(os.Stderr, err)
+	return exitcode.Error
+}
+
+func initLogging(verbose bool) {
 	// verbose essentially enables or disables log output:
 	if verbose {
 		log.SetOutput(os.Stderr)
@@ -49,3 +100,9 @@ func initLogging() {
 	log.SetFlags(0)
 	log.SetPrefix("Note: ")
 }
+
+func restoreLogging(output io.Writer, flags int, prefix string) {
+	log.SetOutput(output)
+	log.SetFlags(flags)
+	log.SetPrefix(prefix)
+}
@@ -0,0 +1,33 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+package cmd
+
+import (
+	"log"
+	"testing"
+
+	"github.com/mirkoboehm/shelldoc/pkg/exitcode"
+	"github.com/stretchr/testify/require"
+)
+
+// executeArgs executes a new root command with the specified arguments and returns the exit code
+func executeArgs(args ...string) int {
+	rootCmd := NewRootCmd()
+	rootCmd.SetArgs(args)
+	return execute(rootCmd)
+}
+
+func TestExecuteTwice(t *testing.T) {
+	output, flags, prefix := log.Writer(), log.Flags(), log.Prefix()
+	require.Equal(t, exitcode.Failure, executeArgs("run", "--verbose", "--fail", "../../../pkg/tokenizer/samples/failnomatch.md"),
+		"The exit code of the run command is returned")
+	require.Equal(t, exitcode.Success, executeArgs("run", "../../../pkg/tokenizer/samples/helloworld.md"),
+		"The flags of the first run do not affect the second run")
+	require.True(t, output == log.Writer(), "The log output is restored after the command")
+	require.Equal(t, flags, log.Flags())
+	require.Equal(t, prefix, log.Prefix())
+	require.Equal(t, exitcode.Error, executeArgs("lint", "does-not-exist.md"), "Errors of commands are returned")
+	require.Equal(t, exitcode.Error, executeArgs("lint"), "Invalid arguments are errors")
+}
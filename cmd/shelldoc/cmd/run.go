@@ -5,32 +5,86 @@
 package cmd
 
 import (
-	"os"
+	"fmt"
 
 	"github.com/mirkoboehm/shelldoc/pkg/run"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
 	"github.com/spf13/cobra"
 )
 
-var context run.Context
-
-// runCmd represents the run command
-var runCmd = &cobra.Command{
-	Use:   "run",
-	Short: "Execute a Markdown file as a documentation test",
-	Long: `Run parses a Markdown input file, detects the code blocks in it,
+// newRunCmd creates the run command. The diagnostic output is enabled by the verbose flag of the root command.
+func newRunCmd(root *rootOptions) *cobra.Command {
+	var options run.Options
+	runCmd := &cobra.Command{
+		Use:   "run",
+		Short: "Execute a Markdown file as a documentation test",
+		Long: `Run parses a Markdown input file, detects the code blocks in it,
 executes them and compares their output with the content of the code block.`,
-	Run: executeRun,
-}
-
-func init() {
-	runCmd.Flags().StringVarP(&context.ShellName, "shell", "s", "", "The shell to invoke (default: $SHELL)")
-	runCmd.Flags().BoolVarP(&context.FailureStops, "fail", "f", false, "Stop on the first failure")
-	runCmd.Flags().StringVarP(&context.XMLOutputFile, "xml", "x", "", "Write results to the specified output file in JUnitXML format")
-	runCmd.Flags().BoolVarP(&context.ReplaceDots, "replace-dots-in-xml-classname", "d", true, "When using filenames as classnames, replace dots with a unicode circle")
-	rootCmd.AddCommand(runCmd)
-}
-
-func executeRun(cmd *cobra.Command, args []string) {
-	context.Files = args
-	os.Exit(context.ExecuteFiles())
+		RunE: func(cmd *cobra.Command, args []string) error {
+			context := run.NewContext(run.WithOptions(options), run.WithVerbose(root.verbose), run.WithFiles(args...),
+				run.WithInterrupts())
+			returnCode, err := context.ExecuteFiles()
+			if err != nil {
+				fmt.Println(err) // log may be disabled (see "verbose")
+			}
+			return exitWith(returnCode)
+		},
+	}
+	runCmd.Flags().StringVarP(&options.ShellName, "shell", "s", "", "The shell to invoke (default: $SHELL)")
+	runCmd.Flags().StringSliceVar(&options.ShellCandidates, "shell-fallback", shell.DefaultCandidates, "The shells that are tried in order if no shell is specified and $SHELL is not usable")
+	runCmd.Flags().BoolVar(&options.CleanStartup, "clean-startup", false, "Start the shell without reading the profile and rc files of the user (bash --noprofile --norc, zsh -f)")
+	runCmd.Flags().BoolVar(&options.ShellStrict, "shell-strict", false, "Execute the commands in strict mode (set -euo pipefail), so that failing commands, failing pipelines and unset variables are reported")
+	runCmd.Flags().StringVarP(&options.ConfigFile, "config", "c", "", "Read settings from the specified configuration file (JSON)")
+	runCmd.Flags().StringVarP(&options.Profile, "profile", "p", "", "Use the shell, environment and other settings of the named profile in the configuration file")
+	runCmd.Flags().BoolVarP(&options.FailureStops, "fail", "f", false, "Stop on the first failure")
+	runCmd.Flags().StringSliceVar(&options.Tolerate, "tolerate", nil, "Report failures of the specified categories (like timeout or policy-violation), but do not fail because of them, can be repeated")
+	runCmd.Flags().StringArrayVar(&options.WarningPatterns, "warning-pattern", nil, "Count the lines of output that match the regular expression as warnings, like \"(?i)deprecated\", can be repeated")
+	runCmd.Flags().IntVar(&options.WarningBudget, "warning-budget", -1, "Fail if the output of all commands contains more than the specified number of warnings (negative: use the budget of the configuration file, or no limit)")
+	runCmd.Flags().BoolVar(&options.Advisory, "advisory", false, "Report failed tests as stale documentation warnings, but always exit with exit code 0")
+	runCmd.Flags().BoolVar(&options.Idempotent, "verify-idempotent", false, "Execute each file a second time and warn about commands that behave differently, for example because they only work on a pristine machine")
+	runCmd.Flags().StringVar(&options.Section, "section", "", "Only execute the code blocks in the section with the specified heading, including its subsections")
+	runCmd.Flags().Int64Var(&options.Seed, "seed", 0, "Replay the random seed of a previous run, which is exported to the commands as $SHELLDOC_SEED (0: use a random seed)")
+	runCmd.Flags().DurationVar(&options.Delay, "delay", 0, "Pause for the specified duration (like 500ms) between commands, for example for rate-limited services")
+	runCmd.Flags().StringArrayVarP(&options.Env, "env", "e", nil, "Set an environment variable (NAME=VALUE, or NAME to pass on the value from the environment) for the shell, can be repeated")
+	runCmd.Flags().StringArrayVar(&options.EnvFiles, "env-file", nil, "Read environment variables for the shell from a file with NAME=VALUE lines, can be repeated")
+	runCmd.Flags().StringVar(&options.Workdir, "workdir", "", "Execute the commands in the specified working directory, or in a temporary directory for every file that is removed afterwards (tmp)")
+	runCmd.Flags().StringVar(&options.FixturesDir, "fixtures", "", "Execute every file in its own temporary working directory that contains a copy of the specified directory, which is copied per file")
+	runCmd.Flags().StringVar(&options.DockerImage, "docker", "", "Execute the commands in a new container of the specified image, which is removed afterwards (the shell defaults to /bin/sh)")
+	runCmd.Flags().StringVar(&options.EnterCommand, "enter", "", "Start the shell through a command that enters a development environment, like \"nix develop --command\"")
+	runCmd.Flags().StringVar(&options.SSHDestination, "ssh", "", "Execute the commands on the specified remote machine (like user@host) over SSH (the shell defaults to /bin/sh)")
+	runCmd.Flags().BoolVar(&options.Tmux, "tmux", false, "Execute the commands in a tmux session, which gives them a real terminal, for example to test full-screen tools")
+	runCmd.Flags().StringVar(&options.TerminalSize, "terminal-size", "80x24", "The size of the terminal of the tmux session in columns and lines")
+	runCmd.Flags().BoolVarP(&options.DryRun, "dry-run", "n", false, "List the commands without executing them")
+	runCmd.Flags().BoolVar(&options.CheckSyntax, "check-syntax", false, "Check the syntax of the commands using the no-exec mode of the shell, without executing them (implies --dry-run)")
+	runCmd.Flags().StringVarP(&options.XMLOutputFile, "xml", "x", "", "Write results to the specified output file in JUnitXML format")
+	runCmd.Flags().BoolVar(&options.XMLAppend, "xml-append", false, "Append the results to the test suites in an existing XML output file")
+	runCmd.Flags().StringVar(&options.XMLOutputDir, "xml-dir", "", "Write the results of every input file to its own file in JUnitXML format in the specified directory")
+	runCmd.Flags().StringVar(&options.HTMLOutputFile, "html", "", "Write results to the specified output file as an HTML report")
+	runCmd.Flags().StringVar(&options.JSONOutputFile, "json", "", "Write results to the specified output file in JSON format (see pkg/report/report.schema.json)")
+	runCmd.Flags().StringVar(&options.SummaryFile, "summary-md", "", "Write a summary of the results in Markdown format to the specified file, for example $GITHUB_STEP_SUMMARY")
+	runCmd.Flags().StringVar(&options.SourceURL, "source-url", "", "Link the commands in the HTML report to their source using the URL template, {file} and {line} are replaced")
+	runCmd.Flags().StringArrayVar(&options.Properties, "property", nil, "Attach a property (key=value) like a build number to every test suite in the reports, can be repeated")
+	runCmd.Flags().StringVar(&options.HistoryDir, "history", "", "Store the results in the specified history directory, see the trends command")
+	runCmd.Flags().StringVar(&options.PatchFile, "write-patch", "", "Write a patch to the specified file that updates mismatched expected responses to the actual output")
+	runCmd.Flags().BoolVar(&options.Update, "update", false, "Rewrite mismatched expected responses in the input files with the actual output, like go test -update")
+	runCmd.Flags().BoolVar(&options.ResourceUsage, "resource-usage", false, "Measure the wall time, CPU time and memory used by every command")
+	runCmd.Flags().StringVar(&options.TranscriptFile, "transcript", "", "Record the raw shell session with timestamps in the specified file")
+	runCmd.Flags().StringVar(&options.CastFile, "record-cast", "", "Record the executed commands and their output as an asciinema recording in the specified file")
+	runCmd.Flags().StringVar(&options.Locale, "locale", "", "The language of the results in the console output (en or de, auto: from $LC_ALL, $LC_MESSAGES or $LANG), the reports are always in English")
+	runCmd.Flags().IntVar(&options.ElideWidth, "width", 0, "Elide the commands and expected responses in the console output to the specified width (0: fit the terminal, -1: never elide, verbose output is never elided)")
+	runCmd.Flags().BoolVarP(&options.ReplaceDots, "replace-dots-in-xml-classname", "d", true, "When using filenames as classnames, replace dots with a unicode circle")
+	runCmd.Flags().IntVar(&options.MaxFailures, "max-failures", 0, "Skip the remaining tests after the specified number of failures across all files (0: no limit)")
+	runCmd.Flags().Float64Var(&options.TimeoutFactor, "timeout-multiplier", 0, "Scale all timeouts and time budgets by the specified factor (default: $SHELLDOC_TIMEOUT_MULTIPLIER or 1)")
+	runCmd.Flags().Float64Var(&options.BudgetWarning, "budget-warning", 0.8, "Warn about a command that is still running when the specified fraction of the time budget of a file is used (0: no warning)")
+	runCmd.Flags().StringVar(&options.QuarantineFile, "quarantine", "", "Read a list of known-flaky files and commands (file or file:ID) whose failures do not affect the exit code")
+	runCmd.Flags().StringVar(&options.PolicyFile, "policy", "", "Read a policy file of allow and deny rules (regular expressions) that decide which commands may be executed")
+	runCmd.Flags().StringVar(&options.BaselineFile, "baseline", "", "Only fail on failures that are not listed in the baseline file (create it using --update-baseline)")
+	runCmd.Flags().BoolVar(&options.UpdateBaseline, "update-baseline", false, "Accept all failures of this run and write them to the baseline file, which is created if it does not exist")
+	runCmd.Flags().StringVar(&options.DefaultExitCode, "default-exit-code", "", "The expected exit code of commands without shelldocexitcode or shelldocwhatever attribute (any, 0 or nonzero, default 0)")
+	runCmd.Flags().BoolVar(&options.NormalizePaths, "normalize-paths", false, "Compare output with normalized path separators, and the temporary directory replaced by <TMPDIR>")
+	runCmd.Flags().StringVar(&options.DirectoryMode, "working-directory", run.DirectoryKeep, "Check the working directory after every code block (keep: no check, warn: warn if it changed, reset: change back)")
+	runCmd.Flags().StringVar(&options.Isolation, "isolate", run.IsolateFile, "Start a fresh shell for every file, code block or command (file, block or interaction)")
+	runCmd.Flags().BoolVarP(&options.ResolveIncludes, "resolve-includes", "i", false, "Resolve MkDocs snippets, Hugo include shortcodes and Sphinx literalinclude directives before testing")
+	runCmd.Flags().StringVar(&options.StdinName, "stdin-name", run.DefaultStdinName, "The name of the input read from stdin (no input files or \"-\") in the results")
+	return runCmd
 }
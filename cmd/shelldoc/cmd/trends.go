@@ -0,0 +1,52 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/mirkoboehm/shelldoc/pkg/history"
+	"github.com/spf13/cobra"
+)
+
+// newTrendsCmd creates the trends command
+func newTrendsCmd() *cobra.Command {
+	options := history.DefaultOptions()
+	trendsCmd := &cobra.Command{
+		Use:   "trends DIRECTORY",
+		Short: "Show newly flaky tests and duration regressions from the results history",
+		Long: `Trends analyzes the results stored in a history directory by "run --history"
+and reports documentation tests that recently started to fail intermittently,
+and tests that took considerably longer in the last run than they used to.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return executeTrends(args[0], options)
+		},
+	}
+	trendsCmd.Flags().IntVar(&options.Window, "window", options.Window, "The number of recent runs that are checked for flaky tests")
+	trendsCmd.Flags().Float64Var(&options.Slowdown, "slowdown", options.Slowdown, "Report tests that took longer than their median duration times this factor")
+	trendsCmd.Flags().Float64Var(&options.MinDuration, "min-duration", options.MinDuration, "Ignore tests that took less than the specified number of seconds")
+	return trendsCmd
+}
+
+func executeTrends(directory string, options history.Options) error {
+	runs, err := history.Read(directory)
+	if err != nil {
+		return err
+	}
+	trends := history.Analyze(runs, options)
+	fmt.Printf("SHELLDOC: %d runs in the history\n", trends.Runs)
+	fmt.Printf("Newly flaky tests (last %d runs): %d\n", options.Window, len(trends.Flaky))
+	for _, flaky := range trends.Flaky {
+		fmt.Printf("  %s:%d: %s (%s): %d failures in %d runs\n", flaky.Test.File, flaky.Test.Line, flaky.Test.Command,
+			flaky.Test.ID, flaky.Failures, flaky.Runs)
+	}
+	fmt.Printf("Duration regressions: %d\n", len(trends.Regressions))
+	for _, regression := range trends.Regressions {
+		fmt.Printf("  %s:%d: %s (%s): %.3fs, median %.3fs\n", regression.Test.File, regression.Test.Line,
+			regression.Test.Command, regression.Test.ID, regression.Duration, regression.Median)
+	}
+	return nil
+}
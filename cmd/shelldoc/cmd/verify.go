@@ -0,0 +1,66 @@
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: GPL-3.0
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mirkoboehm/shelldoc/pkg/exitcode"
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/report"
+	"github.com/spf13/cobra"
+)
+
+// newVerifyCmd creates the verify-report command
+func newVerifyCmd() *cobra.Command {
+	thresholds := report.DefaultThresholds()
+	verifyCmd := &cobra.Command{
+		Use:   "verify-report FILE",
+		Short: "Check the results in a JUnitXML report against thresholds",
+		Long: `Verify-report reads a report written by "run --xml" and checks the number of
+failures, errors, skipped tests and tests against thresholds. The exit code is 0
+if all thresholds are met, and 1 otherwise. This separates executing the
+documentation tests from gating on their results, for example in pipelines
+that allow the test job to fail and evaluate the results later.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			violations, err := executeVerify(args[0], thresholds)
+			if err != nil {
+				return err
+			}
+			if len(violations) > 0 {
+				return exitWith(exitcode.Failure)
+			}
+			return nil
+		},
+	}
+	verifyCmd.Flags().IntVar(&thresholds.MaxFailures, "max-failures", thresholds.MaxFailures, "The maximum number of failed tests (-1: no limit)")
+	verifyCmd.Flags().IntVar(&thresholds.MaxErrors, "max-errors", thresholds.MaxErrors, "The maximum number of tests that could not be executed (-1: no limit)")
+	verifyCmd.Flags().IntVar(&thresholds.MaxSkipped, "max-skipped", thresholds.MaxSkipped, "The maximum number of skipped tests (-1: no limit)")
+	verifyCmd.Flags().StringSliceVar(&thresholds.IgnoreCategories, "ignore-category", nil, "Do not count failures and errors of the specified categories (like timeout), can be repeated")
+	verifyCmd.Flags().IntVar(&thresholds.MinTests, "min-tests", thresholds.MinTests, "The minimum number of tests in the report (-1: no minimum)")
+	return verifyCmd
+}
+
+func executeVerify(path string, thresholds report.Thresholds) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open report: %v", err)
+	}
+	defer file.Close()
+	suites, err := junitxml.Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read report %s: %v", path, err)
+	}
+	violations := report.Verify(suites, thresholds)
+	for _, violation := range violations {
+		fmt.Printf("SHELLDOC: %s: %s\n", path, violation)
+	}
+	if len(violations) == 0 {
+		fmt.Printf("SHELLDOC: %s: all thresholds met\n", path)
+	}
+	return violations, nil
+}
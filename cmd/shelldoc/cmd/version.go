@@ -11,15 +11,14 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var versionCmd = &cobra.Command{
-	Use:   "version",
-	Short: "Print the shelldoc version",
-	Long:  `Print the shelldoc version.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Println(version.Version())
-	},
-}
-
-func init() {
-	rootCmd.AddCommand(versionCmd)
+// newVersionCmd creates the version command
+func newVersionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Print the shelldoc version",
+		Long:  `Print the shelldoc version.`,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Println(version.Version())
+		},
+	}
 }
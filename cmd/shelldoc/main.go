@@ -4,8 +4,12 @@
 
 package main
 
-import "github.com/mirkoboehm/shelldoc/cmd"
+import (
+	"os"
+
+	"github.com/mirkoboehm/shelldoc/cmd/shelldoc/cmd"
+)
 
 func main() {
-	cmd.Execute()
+	os.Exit(cmd.Execute())
 }
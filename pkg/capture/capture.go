@@ -0,0 +1,124 @@
+package capture
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+)
+
+// Entry is a command that was executed during a capture session, with its output and exit code
+type Entry struct {
+	Cmd      string
+	Output   []string
+	ExitCode int
+}
+
+// Block is a sequence of entries that is written as one code block, with optional prose before it
+type Block struct {
+	Prose   []string
+	Entries []Entry
+}
+
+// DefaultPrompt is written before the commands in the code blocks, unless the Prompt of the session is changed
+const DefaultPrompt = "$"
+
+// DefaultWidth is the usual width of a terminal, for wrapping long commands
+const DefaultWidth = 80
+
+// Session executes commands in a shell and records them in code blocks
+type Session struct {
+	Blocks []Block
+	// Prompt is written before the commands in the code blocks, $ or >
+	Prompt string
+	// Width is the length of the lines at which long commands are wrapped with continuation backslashes, 0 disables
+	// wrapping
+	Width int
+	shell *shell.Shell
+}
+
+// NewSession creates a capture session that executes commands in the specified shell
+func NewSession(sh *shell.Shell) *Session {
+	return &Session{Prompt: DefaultPrompt, Width: DefaultWidth, shell: sh}
+}
+
+// ValidatePrompt returns an error if commands written after the prompt would not be recognized in code blocks
+func ValidatePrompt(prompt string) error {
+	if prompt != "$" && prompt != ">" {
+		return fmt.Errorf("the prompt needs to be $ or >, got \"%s\"", prompt)
+	}
+	return nil
+}
+
+// Execute runs the command and records it in the current code block
+func (session *Session) Execute(command string) (Entry, error) {
+	output, rc, err := session.shell.ExecuteCommand(command)
+	if err != nil {
+		return Entry{}, fmt.Errorf("unable to execute command \"%s\": %v", command, err)
+	}
+	entry := Entry{command, output, rc}
+	if len(session.Blocks) == 0 {
+		session.Blocks = append(session.Blocks, Block{})
+	}
+	current := &session.Blocks[len(session.Blocks)-1]
+	current.Entries = append(current.Entries, entry)
+	return entry, nil
+}
+
+// EndBlock finishes the current code block, the following commands are recorded in a new one
+func (session *Session) EndBlock() {
+	if len(session.Blocks) > 0 && len(session.Blocks[len(session.Blocks)-1].Entries) > 0 {
+		session.Blocks = append(session.Blocks, Block{})
+	}
+}
+
+// AddProse adds a line of prose that is written before the next code block
+func (session *Session) AddProse(line string) {
+	session.EndBlock()
+	if len(session.Blocks) == 0 {
+		session.Blocks = append(session.Blocks, Block{})
+	}
+	current := &session.Blocks[len(session.Blocks)-1]
+	current.Prose = append(current.Prose, line)
+}
+
+// Replay reads commands line by line and executes them. Empty lines end the current code block. If prompt is not
+// nil, a prompt is written to it before every command, and the output of the command after it.
+func (session *Session) Replay(input io.Reader, prompt io.Writer) error {
+	scanner := bufio.NewScanner(input)
+	for {
+		if prompt != nil {
+			fmt.Fprint(prompt, session.Prompt+" ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		command := strings.TrimSpace(scanner.Text())
+		if len(command) == 0 {
+			session.EndBlock()
+			continue
+		}
+		entry, err := session.Execute(command)
+		if err != nil {
+			return err
+		}
+		if prompt != nil {
+			for _, line := range entry.Output {
+				fmt.Fprintln(prompt, line)
+			}
+		}
+	}
+	if prompt != nil {
+		fmt.Fprintln(prompt)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read commands: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package capture
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCapture(t *testing.T) {
+	shellpath, err := shell.DetectShell("")
+	require.NoError(t, err, "A shell is needed to capture commands")
+	sh, err := shell.StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer sh.Exit()
+	session := NewSession(&sh)
+	script := "export GREETING=Hello\necho $GREETING\n\necho one && echo && echo three\n(exit 3)\necho done\n"
+	require.NoError(t, session.Replay(strings.NewReader(script), nil), "Replaying the script should work")
+	var markdown bytes.Buffer
+	require.NoError(t, session.WriteMarkdown(&markdown), "Writing Markdown should work")
+	expected := "```shell\n$ export GREETING=Hello\n$ echo $GREETING\nHello\n```\n\n" +
+		"```shell\n$ echo one && echo && echo three\none\n...\n```\n\n" +
+		"```shell {shelldocexitcode=3}\n$ (exit 3)\n```\n\n" +
+		"```shell\n$ echo done\ndone\n```\n"
+	require.Equal(t, expected, markdown.String(), "The session is written as fenced code blocks")
+
+	// the generated Markdown is a valid documentation test:
+	visitor := tokenizer.NewInteractionVisitor()
+	tokenizer.Tokenize(markdown.Bytes(), visitor)
+	require.Equal(t, 5, len(visitor.Interactions), "All commands are read back")
+	for _, interaction := range visitor.Interactions {
+		require.NoError(t, interaction.Execute(&sh))
+		require.False(t, interaction.HasFailure(), "The captured command %s passes", interaction.Cmd)
+	}
+}
+
+func TestImport(t *testing.T) {
+	shellpath, err := shell.DetectShell("")
+	require.NoError(t, err, "A shell is needed to import scripts")
+	sh, err := shell.StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer sh.Exit()
+	script, err := os.Open("samples/smoke.sh")
+	require.NoError(t, err, "Unable to open sample script")
+	defer script.Close()
+	session := NewSession(&sh)
+	require.NoError(t, session.Import(script), "Importing the script should work")
+	var markdown bytes.Buffer
+	require.NoError(t, session.WriteMarkdown(&markdown), "Writing Markdown should work")
+	require.Equal(t, "Greet the world.\nThe greeting is stored in a variable.\n\n"+
+		"```shell\n$ export GREETING=Hello\n$ echo $GREETING World\nHello World\n```\n\n"+
+		"Files that do not exist are not directories.\n\n"+
+		"```shell {shelldocexitcode=1}\n$ test -d /does/not/exist\n```\n", markdown.String(),
+		"Comments become prose, commands are executed and recorded with their output")
+}
+
+func TestWrapCommand(t *testing.T) {
+	require.Equal(t, []string{"echo short"}, wrapCommand("echo short", 20))
+	require.Equal(t, []string{"docker run \\", "--name demo \\", "hello-world"}, wrapCommand("docker run --name demo hello-world", 16))
+	require.Equal(t, []string{"echo \\", "'a quoted text' \\", "b"}, wrapCommand("echo 'a quoted text' b", 10),
+		"Quoted text is not split, even if it is longer than the width")
+	require.Equal(t, []string{"echo a \\", "# a comment"}, wrapCommand("echo a # a comment", 8), "Comments are not split")
+	require.Equal(t, []string{"echo a b c"}, wrapCommand("echo a b c", 0), "A width of 0 disables wrapping")
+}
+
+func TestCaptureWidth(t *testing.T) {
+	shellpath, err := shell.DetectShell("")
+	require.NoError(t, err, "A shell is needed to capture commands")
+	sh, err := shell.StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer sh.Exit()
+	session := NewSession(&sh)
+	require.Equal(t, DefaultWidth, session.Width, "Long commands are wrapped by default")
+	session.Prompt, session.Width = ">", 24
+	require.NoError(t, session.Replay(strings.NewReader("printf '%s %s\\n' Hello \"shelldoc World\"\n"), nil))
+	var markdown bytes.Buffer
+	require.NoError(t, session.WriteMarkdown(&markdown), "Writing Markdown should work")
+	require.Equal(t, "```shell\n> printf '%s %s\\n' \\\n  Hello \"shelldoc World\"\nHello shelldoc World\n```\n", markdown.String(),
+		"Long commands are wrapped with continuation backslashes")
+	visitor := tokenizer.NewInteractionVisitor()
+	tokenizer.Tokenize(markdown.Bytes(), visitor)
+	require.Len(t, visitor.Interactions, 1, "The wrapped command is read back as one command")
+	require.NoError(t, visitor.Interactions[0].Execute(&sh))
+	require.False(t, visitor.Interactions[0].HasFailure(), "The wrapped command passes")
+	require.Error(t, ValidatePrompt("#"), "Commands after other prompts are not recognized")
+}
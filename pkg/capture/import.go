@@ -0,0 +1,59 @@
+package capture
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// setErrexitEx matches set commands that enable exiting on errors, which would terminate the shell used for testing
+const setErrexitEx = `^set\s+(-[A-Za-z]*e[A-Za-z]*|-o\s+errexit)\b`
+
+// Import reads a shell script and executes its commands. Comments become prose before the following code block,
+// empty lines end the current code block. The interpreter line (#!) and commands that enable exiting on errors
+// (set -e) are skipped, since the exit code of every command is verified anyway. Lines ending with a backslash are
+// joined with the following line.
+func (session *Session) Import(script io.Reader) error {
+	setErrexitRx := regexp.MustCompile(setErrexitEx)
+	scanner := bufio.NewScanner(script)
+	var command strings.Builder
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if strings.HasSuffix(line, "\\") {
+			command.WriteString(strings.TrimSpace(strings.TrimSuffix(line, "\\")) + " ")
+			continue
+		}
+		if command.Len() > 0 {
+			command.WriteString(line)
+			line = command.String()
+			command.Reset()
+		}
+		switch {
+		case lineNumber == 1 && strings.HasPrefix(line, "#!"):
+			continue
+		case len(line) == 0:
+			session.EndBlock()
+		case strings.HasPrefix(line, "#"):
+			session.AddProse(strings.TrimSpace(strings.TrimLeft(line, "#")))
+		case setErrexitRx.MatchString(line):
+			continue
+		default:
+			if _, err := session.Execute(line); err != nil {
+				return err
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("unable to read script: %v", err)
+	}
+	if command.Len() > 0 {
+		return fmt.Errorf("the script ends with a line continuation")
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package capture
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// WriteMarkdown writes the recorded code blocks as fenced Markdown code blocks, each preceded by its prose. Commands with a non-zero exit code
+// are written in code blocks of their own with the shelldocexitcode attribute. The commands are written after the
+// prompt of the session, and long commands are wrapped at its width like in a terminal session.
+func (session *Session) WriteMarkdown(writer io.Writer) error {
+	first := true
+	separate := func() error {
+		if first {
+			first = false
+			return nil
+		}
+		_, err := fmt.Fprintln(writer)
+		return err
+	}
+	for _, block := range session.Blocks {
+		if len(block.Prose) > 0 {
+			if err := separate(); err != nil {
+				return err
+			}
+			if _, err := fmt.Fprintln(writer, strings.Join(block.Prose, "\n")); err != nil {
+				return err
+			}
+		}
+		var codeblocks [][]Entry
+		var current []Entry
+		for _, entry := range block.Entries {
+			if entry.ExitCode != 0 {
+				if len(current) > 0 {
+					codeblocks = append(codeblocks, current)
+				}
+				codeblocks = append(codeblocks, []Entry{entry})
+				current = nil
+				continue
+			}
+			current = append(current, entry)
+		}
+		if len(current) > 0 {
+			codeblocks = append(codeblocks, current)
+		}
+		for _, entries := range codeblocks {
+			if err := separate(); err != nil {
+				return err
+			}
+			if err := session.writeCodeBlock(writer, entries); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeCodeBlock writes the entries as one fenced code block
+func (session *Session) writeCodeBlock(writer io.Writer, entries []Entry) error {
+	prompt := session.Prompt
+	if len(prompt) == 0 {
+		prompt = DefaultPrompt
+	}
+	var text strings.Builder
+	text.WriteString("```shell")
+	if rc := entries[0].ExitCode; rc != 0 {
+		fmt.Fprintf(&text, " {%s=%d}", tokenizer.ExitCodeOption, rc)
+	}
+	text.WriteString("\n")
+	// the continuation lines are indented to the command, so that they are not read as new commands
+	indentation := strings.Repeat(" ", len(prompt)+1)
+	for _, entry := range entries {
+		for index, line := range wrapCommand(entry.Cmd, session.Width-len(indentation)) {
+			if index == 0 {
+				fmt.Fprintf(&text, "%s %s\n", prompt, line)
+			} else {
+				fmt.Fprintf(&text, "%s%s\n", indentation, line)
+			}
+		}
+		for _, line := range Response(entry.Output) {
+			fmt.Fprintf(&text, "%s\n", line)
+		}
+	}
+	text.WriteString("```\n")
+	_, err := io.WriteString(writer, text.String())
+	return err
+}
+
+// Response returns the expected response for the output of a command. The response is cut off with an ellipsis at
+// the first line that would not be read back as written, like empty lines, lines with surrounding white space,
+// lines that look like commands, or the closing fence of the code block.
+func Response(output []string) []string {
+	var response []string
+	for _, line := range output {
+		trimmed := strings.TrimSpace(line)
+		if len(trimmed) == 0 || trimmed != line || trimmed == "..." || strings.HasPrefix(trimmed, "```") ||
+			strings.HasPrefix(trimmed, "$") || strings.HasPrefix(trimmed, ">") {
+			response = append(response, "...")
+			break
+		}
+		response = append(response, line)
+	}
+	return response
+}
@@ -0,0 +1,11 @@
+#!/bin/sh
+set -euo pipefail
+
+# Greet the world.
+# The greeting is stored in a variable.
+export GREETING=Hello
+echo $GREETING \
+  World
+
+# Files that do not exist are not directories.
+test -d /does/not/exist
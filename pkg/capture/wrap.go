@@ -0,0 +1,71 @@
+package capture
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import "strings"
+
+// splitWords splits a command into words at white space outside of quotes. Escaped characters and quoted text are
+// kept as they are. A comment is kept as one word, since it cannot be continued on the next line.
+func splitWords(command string) []string {
+	var words []string
+	var word strings.Builder
+	var quote rune
+	escaped := false
+	for index, char := range command {
+		switch {
+		case escaped:
+			escaped = false
+		case char == '\\' && quote != '\'':
+			escaped = true
+		case quote != 0:
+			if char == quote {
+				quote = 0
+			}
+		case char == '\'' || char == '"':
+			quote = char
+		case char == '#' && word.Len() == 0:
+			return append(words, command[index:])
+		case char == ' ' || char == '\t':
+			if word.Len() > 0 {
+				words = append(words, word.String())
+				word.Reset()
+			}
+			continue
+		}
+		word.WriteRune(char)
+	}
+	if word.Len() > 0 {
+		words = append(words, word.String())
+	}
+	return words
+}
+
+// wrapCommand splits a command that is longer than width into lines that end with a continuation backslash, like a
+// shell user would type it. The command is split at white space outside of quotes. Words that are longer than the
+// width are not split, and commands that span several lines already are not wrapped.
+func wrapCommand(command string, width int) []string {
+	if width <= 0 || len(command) <= width || strings.Contains(command, "\n") {
+		return []string{command}
+	}
+	const continuation = " \\"
+	var lines []string
+	var current string
+	words := splitWords(command)
+	for index, word := range words {
+		length := len(current) + 1 + len(word)
+		if index < len(words)-1 {
+			length += len(continuation) // the line will be continued
+		}
+		if len(current) > 0 && length > width {
+			lines = append(lines, current+continuation)
+			current = ""
+		}
+		if len(current) > 0 {
+			current += " "
+		}
+		current += word
+	}
+	return append(lines, current)
+}
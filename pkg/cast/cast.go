@@ -0,0 +1,90 @@
+package cast
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// Timing of the simulated terminal session, in seconds.
+const (
+	typingDelay   = 0.05
+	enterDelay    = 0.3
+	readingPause  = 1.0
+	defaultWidth  = 80
+	defaultHeight = 24
+	prompt        = "$ "
+)
+
+// header is the first line of an asciinema v2 recording
+type header struct {
+	Version   int    `json:"version"`
+	Width     int    `json:"width"`
+	Height    int    `json:"height"`
+	Timestamp int64  `json:"timestamp"`
+	Title     string `json:"title,omitempty"`
+}
+
+// Recorder writes executed interactions as an asciinema v2 recording (https://docs.asciinema.org/manual/asciicast/v2/).
+// The commands are typed at a steady pace, the output appears after the time the command took to execute.
+type Recorder struct {
+	writer io.Writer
+	clock  float64
+}
+
+// NewRecorder writes the header of a recording with the specified title to writer and returns the recorder.
+func NewRecorder(writer io.Writer, title string) (*Recorder, error) {
+	data, err := json.Marshal(header{2, defaultWidth, defaultHeight, time.Now().Unix(), title})
+	if err != nil {
+		return nil, fmt.Errorf("unable to encode recording header: %v", err)
+	}
+	if _, err := fmt.Fprintf(writer, "%s\n", data); err != nil {
+		return nil, fmt.Errorf("unable to write recording header: %v", err)
+	}
+	return &Recorder{writer: writer}, nil
+}
+
+// Record adds a command, its output and the time it took to execute to the recording.
+// Nothing happens if the recorder is nil.
+func (recorder *Recorder) Record(command string, output []string, duration time.Duration) error {
+	if recorder == nil {
+		return nil
+	}
+	if err := recorder.event(0, prompt); err != nil {
+		return err
+	}
+	for _, char := range command {
+		if err := recorder.event(typingDelay, string(char)); err != nil {
+			return err
+		}
+	}
+	if err := recorder.event(enterDelay, "\r\n"); err != nil {
+		return err
+	}
+	if len(output) > 0 {
+		if err := recorder.event(duration.Seconds(), strings.Join(output, "\r\n")+"\r\n"); err != nil {
+			return err
+		}
+	}
+	recorder.clock += readingPause
+	return nil
+}
+
+// event writes an output event delay seconds after the previous one
+func (recorder *Recorder) event(delay float64, data string) error {
+	recorder.clock += delay
+	line, err := json.Marshal([]interface{}{recorder.clock, "o", data})
+	if err != nil {
+		return fmt.Errorf("unable to encode recording event: %v", err)
+	}
+	if _, err := fmt.Fprintf(recorder.writer, "%s\n", line); err != nil {
+		return fmt.Errorf("unable to write recording event: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,33 @@
+package cast
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecording(t *testing.T) {
+	var buffer bytes.Buffer
+	recorder, err := NewRecorder(&buffer, "Hello World")
+	require.NoError(t, err, "Writing the header should work")
+	require.NoError(t, recorder.Record("echo Hi", []string{"Hi"}, 2*time.Second), "Recording an interaction should work")
+	lines := strings.Split(strings.TrimSpace(buffer.String()), "\n")
+	var head header
+	require.NoError(t, json.Unmarshal([]byte(lines[0]), &head), "The header is a JSON object")
+	require.Equal(t, 2, head.Version, "This is an asciinema v2 recording")
+	require.Equal(t, "Hello World", head.Title)
+	// the prompt, seven typed characters, enter, the output
+	require.Len(t, lines, 1+1+7+1+1, "Every typed character is an event")
+	var last []interface{}
+	require.NoError(t, json.Unmarshal([]byte(lines[len(lines)-1]), &last), "Events are JSON arrays")
+	require.Equal(t, "Hi\r\n", last[2], "The output uses terminal line endings")
+	require.InDelta(t, 7*typingDelay+enterDelay+2, last[0], 0.001, "The output appears after the command took to execute")
+}
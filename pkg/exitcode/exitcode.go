@@ -0,0 +1,43 @@
+package exitcode
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+// The exit codes of shelldoc. They are part of its interface, wrapper scripts and CI steps may rely on them, so the
+// values and their meaning do not change.
+const (
+	// Success means that all tests passed, or that the command completed successfully
+	Success = 0
+	// Failure means that at least one test failed, or that the checked results or documents have problems
+	Failure = 1
+	// Error means that shelldoc could not do its job, for example because of invalid arguments, unreadable input
+	// files or a shell that could not be started
+	Error = 2
+)
+
+// Code describes an exit code of shelldoc
+type Code struct {
+	Value       int    `json:"code"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+}
+
+// Codes returns the exit codes of shelldoc, ordered by value
+func Codes() []Code {
+	return []Code{
+		{Success, "success", "all tests passed, or the command completed successfully"},
+		{Failure, "failure", "at least one test failed, or the checked results or documents have problems"},
+		{Error, "error", "shelldoc could not do its job, for example because of invalid arguments, unreadable input files or a shell that could not be started"},
+	}
+}
+
+// Name returns the name of an exit code, like failure, or an empty string for unknown exit codes
+func Name(value int) string {
+	for _, code := range Codes() {
+		if code.Value == value {
+			return code.Name
+		}
+	}
+	return ""
+}
@@ -0,0 +1,21 @@
+package exitcode
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCodes(t *testing.T) {
+	require.Equal(t, []int{0, 1, 2}, []int{Success, Failure, Error}, "The exit codes are stable")
+	for index, code := range Codes() {
+		require.Equal(t, index, code.Value, "The exit codes are ordered by value")
+		require.NotEmpty(t, code.Description, "Every exit code is documented")
+	}
+	require.Equal(t, "failure", Name(Failure))
+	require.Empty(t, Name(42), "Unknown exit codes have no name")
+}
@@ -0,0 +1,113 @@
+package history
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+const (
+	// StatusSuccess marks a test that passed
+	StatusSuccess = "success"
+	// StatusFailure marks a test that failed
+	StatusFailure = "failure"
+	// StatusError marks a test that could not be executed
+	StatusError = "error"
+	// StatusSkipped marks a test that was skipped
+	StatusSkipped = "skipped"
+)
+
+// Test contains the result of one test in a run
+type Test struct {
+	// ID is the stable identifier of the test, see tokenizer.AssignIDs
+	ID       string  `json:"id"`
+	File     string  `json:"file"`
+	Line     int     `json:"line,omitempty"`
+	Command  string  `json:"command"`
+	Status   string  `json:"status"`
+	Duration float64 `json:"duration"`
+}
+
+// Run contains the results of one shelldoc run
+type Run struct {
+	Time  time.Time `json:"time"`
+	Tests []Test    `json:"tests"`
+}
+
+// NewRun creates the history record of the test results
+func NewRun(when time.Time, suites junitxml.JUnitTestSuites) Run {
+	run := Run{Time: when.UTC()}
+	for _, suite := range suites.Suites {
+		for _, testcase := range suite.TestCases {
+			test := Test{ID: testcase.ID, File: testcase.File, Line: testcase.Line, Command: testcase.Name,
+				Status: StatusSuccess}
+			if len(test.File) == 0 {
+				test.File = suite.Name
+			}
+			switch {
+			case testcase.Failure != nil:
+				test.Status = StatusFailure
+			case testcase.Error != nil:
+				test.Status = StatusError
+			case testcase.SkipMessage != nil:
+				test.Status = StatusSkipped
+			}
+			test.Duration, _ = strconv.ParseFloat(testcase.Time, 64)
+			run.Tests = append(run.Tests, test)
+		}
+	}
+	return run
+}
+
+// Write stores the run as a JSON file in the history directory, which is created if needed
+func Write(directory string, run Run) error {
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return fmt.Errorf("unable to create history directory: %v", err)
+	}
+	data, err := json.MarshalIndent(run, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode history: %v", err)
+	}
+	name := filepath.Join(directory, run.Time.Format("20060102T150405.000000000Z")+".json")
+	if err := ioutil.WriteFile(name, data, 0644); err != nil {
+		return fmt.Errorf("unable to write history: %v", err)
+	}
+	return nil
+}
+
+// Read returns the runs stored in the history directory, oldest first
+func Read(directory string) ([]Run, error) {
+	entries, err := ioutil.ReadDir(directory)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read history directory: %v", err)
+	}
+	var runs []Run
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		data, err := ioutil.ReadFile(filepath.Join(directory, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("unable to read history: %v", err)
+		}
+		var run Run
+		if err := json.Unmarshal(data, &run); err != nil {
+			return nil, fmt.Errorf("unable to parse history file %s: %v", entry.Name(), err)
+		}
+		runs = append(runs, run)
+	}
+	sort.SliceStable(runs, func(i, j int) bool { return runs[i].Time.Before(runs[j].Time) })
+	return runs, nil
+}
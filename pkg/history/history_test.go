@@ -0,0 +1,62 @@
+package history
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteAndRead(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-history-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	suite := junitxml.JUnitTestSuite{Name: "README.md"}
+	suite.RegisterTestCase(junitxml.JUnitTestCase{Name: "echo Hello", ID: "a", File: "README.md", Line: 19, Time: "0.250"})
+	failed := junitxml.JUnitTestCase{Name: "echo No", ID: "b", File: "README.md", Line: 23}
+	failed.RegisterFailure("FAILURE", "FAIL (mismatch)", "")
+	suite.RegisterTestCase(failed)
+	suites := junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{suite}}
+	start := time.Date(2023, 5, 1, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, Write(directory, NewRun(start.Add(time.Hour), suites)))
+	require.NoError(t, Write(directory, NewRun(start, suites)))
+	runs, err := Read(directory)
+	require.NoError(t, err, "Reading the history should work")
+	require.Len(t, runs, 2)
+	require.Equal(t, start, runs[0].Time, "The runs are sorted by time")
+	require.Equal(t, Test{ID: "a", File: "README.md", Line: 19, Command: "echo Hello", Status: StatusSuccess, Duration: 0.25},
+		runs[0].Tests[0])
+	require.Equal(t, StatusFailure, runs[1].Tests[1].Status)
+}
+
+func TestAnalyze(t *testing.T) {
+	var runs []Run
+	for index := 0; index < 6; index++ {
+		stable := Test{ID: "stable", Status: StatusSuccess, Duration: 1}
+		flaky := Test{ID: "flaky", Status: StatusSuccess, Duration: 1}
+		broken := Test{ID: "broken", Status: StatusFailure}
+		if index == 5 {
+			stable.Duration = 2 // the last run is much slower
+		}
+		if index == 4 {
+			flaky.Status = StatusFailure
+			broken.Status = StatusSuccess
+		}
+		runs = append(runs, Run{Tests: []Test{stable, flaky, broken}})
+	}
+	options := DefaultOptions()
+	options.Window = 3
+	trends := Analyze(runs, options)
+	require.Equal(t, 6, trends.Runs)
+	require.Len(t, trends.Flaky, 1, "Tests that failed before are not newly flaky")
+	require.Equal(t, Flaky{Test: runs[5].Tests[1], Failures: 1, Runs: 3}, trends.Flaky[0])
+	require.Equal(t, []Regression{{Test: runs[5].Tests[0], Duration: 2, Median: 1}}, trends.Regressions)
+	require.Empty(t, Analyze(nil, options).Flaky, "An empty history has no trends")
+}
@@ -0,0 +1,130 @@
+package history
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"sort"
+)
+
+// Options control how the history is analyzed
+type Options struct {
+	// Window is the number of recent runs that are checked for flaky tests
+	Window int
+	// Slowdown is the factor by which the duration of a test has to exceed its median duration to be a regression
+	Slowdown float64
+	// MinDuration is the duration in seconds below which tests are not checked for regressions
+	MinDuration float64
+}
+
+// DefaultOptions returns the default settings for the analysis
+func DefaultOptions() Options {
+	return Options{Window: 10, Slowdown: 1.5, MinDuration: 0.1}
+}
+
+// Flaky describes a test that both passed and failed in the recent runs, but never failed before
+type Flaky struct {
+	Test     Test
+	Failures int
+	Runs     int
+}
+
+// Regression describes a test that took considerably longer in the last run than it used to
+type Regression struct {
+	Test     Test
+	Duration float64
+	Median   float64
+}
+
+// Trends contains the results of the analysis of the history
+type Trends struct {
+	Runs        int
+	Flaky       []Flaky
+	Regressions []Regression
+}
+
+// testKey identifies a test across runs, using the file and command if the test has no ID
+func testKey(test Test) string {
+	if len(test.ID) > 0 {
+		return test.ID
+	}
+	return test.File + "\x00" + test.Command
+}
+
+// Analyze finds newly flaky tests and duration regressions in the runs, which are expected oldest first
+func Analyze(runs []Run, options Options) Trends {
+	trends := Trends{Runs: len(runs)}
+	if len(runs) == 0 {
+		return trends
+	}
+	recent := len(runs) - options.Window
+	if recent < 0 {
+		recent = 0
+	}
+	type record struct {
+		latest           Test
+		failedBefore     bool
+		passed, failures int
+		runs             int
+		durations        []float64
+	}
+	records := map[string]*record{}
+	var keys []string
+	for index, run := range runs {
+		for _, test := range run.Tests {
+			key := testKey(test)
+			entry, ok := records[key]
+			if !ok {
+				entry = &record{}
+				records[key] = entry
+				keys = append(keys, key)
+			}
+			entry.latest = test
+			failed := test.Status == StatusFailure || test.Status == StatusError
+			if index < recent {
+				entry.failedBefore = entry.failedBefore || failed
+			} else if test.Status != StatusSkipped {
+				entry.runs++
+				if failed {
+					entry.failures++
+				} else {
+					entry.passed++
+				}
+			}
+			if test.Status == StatusSuccess && index < len(runs)-1 {
+				entry.durations = append(entry.durations, test.Duration)
+			}
+		}
+	}
+	last := map[string]bool{}
+	for _, test := range runs[len(runs)-1].Tests {
+		last[testKey(test)] = test.Status == StatusSuccess
+	}
+	for _, key := range keys {
+		entry := records[key]
+		if entry.failures > 0 && entry.passed > 0 && !entry.failedBefore {
+			trends.Flaky = append(trends.Flaky, Flaky{Test: entry.latest, Failures: entry.failures, Runs: entry.runs})
+		}
+		if !last[key] || len(entry.durations) == 0 {
+			continue
+		}
+		median := median(entry.durations)
+		duration := entry.latest.Duration
+		if duration >= options.MinDuration && duration > median*options.Slowdown {
+			trends.Regressions = append(trends.Regressions, Regression{Test: entry.latest, Duration: duration, Median: median})
+		}
+	}
+	return trends
+}
+
+// median returns the median of the values
+func median(values []float64) float64 {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	middle := len(sorted) / 2
+	if len(sorted)%2 == 0 {
+		return (sorted[middle-1] + sorted[middle]) / 2
+	}
+	return sorted[middle]
+}
@@ -0,0 +1,154 @@
+package include
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// maxDepth limits how deeply included files may include other files
+const maxDepth = 8
+
+var (
+	// MkDocs snippets, single line form: --8<-- "path/to/file.md"
+	snippetLineRx = regexp.MustCompile(`^\s*-{1,}8<-{1,}\s+["']([^"']+)["']\s*$`)
+	// MkDocs snippets, block form: a line containing only --8<-- opens and closes a list of files
+	snippetBlockRx = regexp.MustCompile(`^\s*-{1,}8<-{1,}\s*$`)
+	// Hugo shortcodes: {{< include "file" >}}, {{% readfile file="file" %}}
+	shortcodeRx = regexp.MustCompile(`^\s*\{\{[<%]\s*(?:include|readfile)\s+(?:file=)?["']([^"']+)["']\s*[>%]\}\}\s*$`)
+	// Sphinx/MyST literalinclude directive in a fenced code block: ```{literalinclude} path
+	literalIncludeRx = regexp.MustCompile("^(\\s*)(`{3,}|~{3,})\\s*\\{literalinclude\\}\\s+(\\S+)\\s*$")
+	// Sphinx literalinclude directive in reStructuredText style: .. literalinclude:: path
+	rstLiteralIncludeRx = regexp.MustCompile(`^(\s*)\.\.\s+literalinclude::\s+(\S+)\s*$`)
+	// literalinclude options, for example :language: shell
+	directiveOptionRx = regexp.MustCompile(`^\s*:([a-z-]+):\s*(.*)$`)
+)
+
+// Resolve replaces MkDocs snippet, Hugo shortcode and Sphinx literalinclude statements (MyST fences and
+// reStructuredText directives) in data with the content of the referenced files. Relative paths are resolved against basedir.
+func Resolve(data []byte, basedir string) ([]byte, error) {
+	lines, err := resolveLines(strings.Split(string(data), "\n"), basedir, 0)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.Join(lines, "\n")), nil
+}
+
+func resolveLines(lines []string, basedir string, depth int) ([]string, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("includes nested more than %d levels deep, recursive include?", maxDepth)
+	}
+	var result []string
+	for index := 0; index < len(lines); index++ {
+		line := lines[index]
+		if match := snippetLineRx.FindStringSubmatch(line); match != nil {
+			content, err := readIncluded(match[1], basedir, depth)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, content...)
+		} else if match := shortcodeRx.FindStringSubmatch(line); match != nil {
+			content, err := readIncluded(match[1], basedir, depth)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, content...)
+		} else if snippetBlockRx.MatchString(line) {
+			end := index + 1
+			for ; end < len(lines) && !snippetBlockRx.MatchString(lines[end]); end++ {
+			}
+			if end == len(lines) {
+				return nil, fmt.Errorf("unterminated snippet block: %s", line)
+			}
+			for _, filename := range lines[index+1 : end] {
+				filename = strings.TrimSpace(filename)
+				if len(filename) == 0 {
+					continue
+				}
+				content, err := readIncluded(filename, basedir, depth)
+				if err != nil {
+					return nil, err
+				}
+				result = append(result, content...)
+			}
+			index = end
+		} else if match := literalIncludeRx.FindStringSubmatch(line); match != nil {
+			indent, fence, filename := match[1], match[2], match[3]
+			language := ""
+			end := index + 1
+			for ; end < len(lines) && strings.TrimSpace(lines[end]) != fence; end++ {
+				if option := directiveOptionRx.FindStringSubmatch(lines[end]); option != nil && option[1] == "language" {
+					language = option[2]
+				}
+			}
+			if end == len(lines) {
+				return nil, fmt.Errorf("unterminated literalinclude block: %s", line)
+			}
+			content, err := literalInclude(indent, fence, language, filename, basedir)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, content...)
+			index = end
+		} else if match := rstLiteralIncludeRx.FindStringSubmatch(line); match != nil {
+			// the options are indented lines directly after the directive
+			indent, filename := match[1], match[2]
+			language := ""
+			end := index + 1
+			for ; end < len(lines); end++ {
+				option := directiveOptionRx.FindStringSubmatch(lines[end])
+				if option == nil {
+					break
+				}
+				if option[1] == "language" {
+					language = option[2]
+				}
+			}
+			content, err := literalInclude(indent, "```", language, filename, basedir)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, content...)
+			index = end - 1
+		} else {
+			result = append(result, line)
+		}
+	}
+	return result, nil
+}
+
+// literalInclude returns the content of the specified file as a fenced code block. Literal includes are not
+// resolved recursively.
+func literalInclude(indent, fence, language, filename, basedir string) ([]string, error) {
+	content, err := ioutil.ReadFile(includePath(filename, basedir))
+	if err != nil {
+		return nil, fmt.Errorf("unable to read included file %s: %v", filename, err)
+	}
+	result := []string{indent + fence + language}
+	result = append(result, strings.Split(strings.TrimRight(string(content), "\n"), "\n")...)
+	return append(result, indent+fence), nil
+}
+
+// readIncluded reads the specified file and resolves the includes in it
+func readIncluded(filename string, basedir string, depth int) ([]string, error) {
+	path := includePath(filename, basedir)
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read included file %s: %v", filename, err)
+	}
+	lines := strings.Split(strings.TrimRight(string(content), "\n"), "\n")
+	return resolveLines(lines, filepath.Dir(path), depth+1)
+}
+
+func includePath(filename string, basedir string) string {
+	if filepath.IsAbs(filename) {
+		return filename
+	}
+	return filepath.Join(basedir, filename)
+}
@@ -0,0 +1,40 @@
+package include
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveSnippets(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/snippets.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	resolved, err := Resolve(data, "samples")
+	require.NoError(t, err, "Resolving the includes in the sample should work")
+	text := string(resolved)
+	require.Contains(t, text, "    $ echo Hello\n    Hello", "The MkDocs snippet is included")
+	require.Contains(t, text, "    $ echo World\n    World", "The Hugo shortcode and the nested snippet block are included")
+	require.Contains(t, text, "```shell\n> echo Greetings\nGreetings\n```", "The literalinclude is turned into a fenced code block")
+	require.Contains(t, text, "reStructuredText style:\n\n```shell\n> echo Greetings\nGreetings\n```\n\nThe end.",
+		"The reStructuredText literalinclude directive and its options are replaced by a fenced code block")
+	require.NotContains(t, text, "literalinclude", "No literalinclude directives remain")
+	require.False(t, strings.Contains(text, "8<"), "No snippet markers remain")
+}
+
+func TestResolveRecursive(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/recursive.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	_, err = Resolve(data, "samples")
+	require.Error(t, err, "A file including itself should be reported")
+}
+
+func TestResolveMissingFile(t *testing.T) {
+	_, err := Resolve([]byte("--8<-- \"does-not-exist.md\"\n"), "samples")
+	require.Error(t, err, "Including a file that does not exist is an error")
+}
@@ -0,0 +1,109 @@
+package junitxml
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"encoding/xml"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// The Jenkins schema allows only a fixed set of attributes and elements in test cases. The metadata of the test
+// cases is therefore written as properties of their test suite, named testcase.N.KEY with N counting the test
+// cases from 1, and moved back into the test cases when a document is read.
+const testCasePrefix = "testcase."
+
+// propertyPrefix marks the properties of a test case in the metadata, like testcase.1.property.command
+const propertyPrefix = "property."
+
+// metadata returns the metadata of the test case as properties of its test suite
+func (testcase *JUnitTestCase) metadata(number int) []JUnitProperty {
+	prefix := fmt.Sprintf("%s%d.", testCasePrefix, number)
+	var properties []JUnitProperty
+	if len(testcase.ID) > 0 {
+		properties = append(properties, JUnitProperty{prefix + "id", testcase.ID})
+	}
+	if len(testcase.File) > 0 {
+		properties = append(properties, JUnitProperty{prefix + "file", testcase.File})
+	}
+	if testcase.Line > 0 {
+		properties = append(properties, JUnitProperty{prefix + "line", strconv.Itoa(testcase.Line)})
+	}
+	if testcase.Properties != nil {
+		for _, property := range testcase.Properties.Properties {
+			properties = append(properties, JUnitProperty{prefix + propertyPrefix + property.Name, property.Value})
+		}
+	}
+	return properties
+}
+
+// setMetadata sets the metadata value with the specified key, and returns false if the key is unknown
+func (testcase *JUnitTestCase) setMetadata(key, value string) bool {
+	switch key {
+	case "id":
+		testcase.ID = value
+	case "file":
+		testcase.File = value
+	case "line":
+		line, err := strconv.Atoi(value)
+		if err != nil {
+			return false
+		}
+		testcase.Line = line
+	default:
+		if !strings.HasPrefix(key, propertyPrefix) {
+			return false
+		}
+		testcase.AddProperty(strings.TrimPrefix(key, propertyPrefix), value)
+	}
+	return true
+}
+
+// plainTestSuite is encoded and decoded without the metadata handling of JUnitTestSuite
+type plainTestSuite JUnitTestSuite
+
+// MarshalXML writes the test suite, with the metadata of its test cases added to its properties.
+func (suite JUnitTestSuite) MarshalXML(encoder *xml.Encoder, start xml.StartElement) error {
+	encoded := plainTestSuite(suite)
+	encoded.Properties = append([]JUnitProperty(nil), suite.Properties...)
+	for index := range suite.TestCases {
+		encoded.Properties = append(encoded.Properties, suite.TestCases[index].metadata(index+1)...)
+	}
+	return encoder.EncodeElement(encoded, start)
+}
+
+// UnmarshalXML reads the test suite, and moves the metadata of its test cases from its properties back into them.
+func (suite *JUnitTestSuite) UnmarshalXML(decoder *xml.Decoder, start xml.StartElement) error {
+	var decoded plainTestSuite
+	if err := decoder.DecodeElement(&decoded, &start); err != nil {
+		return err
+	}
+	*suite = JUnitTestSuite(decoded)
+	var properties []JUnitProperty
+	for _, property := range suite.Properties {
+		if !suite.restoreMetadata(property) {
+			properties = append(properties, property)
+		}
+	}
+	suite.Properties = properties
+	return nil
+}
+
+// restoreMetadata moves a metadata property into its test case, and returns false if it is a regular property
+func (suite *JUnitTestSuite) restoreMetadata(property JUnitProperty) bool {
+	if !strings.HasPrefix(property.Name, testCasePrefix) {
+		return false
+	}
+	fields := strings.SplitN(strings.TrimPrefix(property.Name, testCasePrefix), ".", 2)
+	if len(fields) != 2 {
+		return false
+	}
+	number, err := strconv.Atoi(fields[0])
+	if err != nil || number < 1 || number > len(suite.TestCases) {
+		return false
+	}
+	return suite.TestCases[number-1].setMetadata(fields[1], property.Value)
+}
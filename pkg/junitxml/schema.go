@@ -8,13 +8,18 @@ package junitxml
 import (
 	"encoding/xml"
 	"fmt"
+	"strconv"
 	"time"
 )
 
 // JUnitTestSuites is a collection of JUnit test suites.
 type JUnitTestSuites struct {
-	XMLName xml.Name `xml:"testsuites"`
-	Suites  []JUnitTestSuite
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr,omitempty"`
+	Suites   []JUnitTestSuite `xml:"testsuite"`
 }
 
 // JUnitTestSuite is a single JUnit test suite which may contain many
@@ -24,10 +29,11 @@ type JUnitTestSuite struct {
 	Tests      int             `xml:"tests,attr"`
 	Failures   int             `xml:"failures,attr"`
 	Errors     int             `xml:"errors,attr"`
+	Skipped    int             `xml:"skipped,attr"`
 	Time       string          `xml:"time,attr"`
 	Name       string          `xml:"name,attr"`
 	Properties []JUnitProperty `xml:"properties>property,omitempty"`
-	TestCases  []JUnitTestCase
+	TestCases  []JUnitTestCase `xml:"testcase"`
 }
 
 // JUnitTestCase is a single test case with its result.
@@ -35,15 +41,28 @@ type JUnitTestCase struct {
 	XMLName     xml.Name          `xml:"testcase"`
 	Classname   string            `xml:"classname,attr"`
 	Name        string            `xml:"name,attr"`
+	ID          string            `xml:"-"`
+	File        string            `xml:"-"`
+	Line        int               `xml:"-"`
 	Time        string            `xml:"time,attr"`
+	Properties  *JUnitProperties  `xml:"-"`
 	SkipMessage *JUnitSkipMessage `xml:"skipped,omitempty"`
 	Failure     *JUnitFailure     `xml:"failure,omitempty"`
 	Error       *JUnitError       `xml:"error,omitempty"`
+	SystemOut   string            `xml:"system-out,omitempty"`
+	SystemErr   string            `xml:"system-err,omitempty"`
 }
 
 // JUnitSkipMessage contains the reason why a testcase was skipped.
+// The Jenkins schema does not allow attributes on the skipped element, so the message is stored as character data.
 type JUnitSkipMessage struct {
-	Message string `xml:"message,attr"`
+	Message string `xml:",chardata"`
+}
+
+// JUnitProperties contains the properties of a test case. The Jenkins schema does not allow properties in test cases,
+// they are written as properties of the test suite, see JUnitTestSuite.MarshalXML.
+type JUnitProperties struct {
+	Properties []JUnitProperty `xml:"property"`
 }
 
 // JUnitProperty represents a key/value pair used to define properties.
@@ -82,6 +101,27 @@ func (suite *JUnitTestSuite) AddProperty(key, value string) {
 	suite.Properties = append(suite.Properties, prop)
 }
 
+// AddProperty adds a property to the properties of the test case.
+func (testcase *JUnitTestCase) AddProperty(key, value string) {
+	if testcase.Properties == nil {
+		testcase.Properties = &JUnitProperties{}
+	}
+	testcase.Properties.Properties = append(testcase.Properties.Properties, JUnitProperty{key, value})
+}
+
+// Property returns the value of the property of the test case with the specified name, and whether it exists.
+func (testcase *JUnitTestCase) Property(key string) (string, bool) {
+	if testcase.Properties == nil {
+		return "", false
+	}
+	for _, property := range testcase.Properties.Properties {
+		if property.Name == key {
+			return property.Value, true
+		}
+	}
+	return "", false
+}
+
 // TestCount returns the number of test cases in the test suite.
 func (suite *JUnitTestSuite) TestCount() int {
 	return len(suite.TestCases)
@@ -107,11 +147,16 @@ func (testcase *JUnitTestCase) RegisterError(errortype string, message string, c
 	testcase.Error = junitError
 }
 
+// RegisterSkipped marks a test case as skipped.
+func (testcase *JUnitTestCase) RegisterSkipped(message string) {
+	testcase.SkipMessage = &JUnitSkipMessage{Message: message}
+}
+
 // SuccessCount returns the number of successfully executed test cases in the test suite.
 func (suite *JUnitTestSuite) SuccessCount() int {
 	counter := 0
 	for _, testcase := range suite.TestCases {
-		if testcase.Failure == nil && testcase.Error == nil {
+		if testcase.Failure == nil && testcase.Error == nil && testcase.SkipMessage == nil {
 			counter++
 		}
 	}
@@ -140,6 +185,17 @@ func (suite *JUnitTestSuite) ErrorCount() int {
 	return counter
 }
 
+// SkippedCount returns the number of test cases that have not been executed.
+func (suite *JUnitTestSuite) SkippedCount() int {
+	counter := 0
+	for _, testcase := range suite.TestCases {
+		if testcase.SkipMessage != nil {
+			counter++
+		}
+	}
+	return counter
+}
+
 // RegisterTestCase registers a test case with the test suite. The test count increments.
 func (suite *JUnitTestSuite) RegisterTestCase(testcase JUnitTestCase) {
 	suite.Tests++
@@ -151,7 +207,24 @@ func (suite *JUnitTestSuite) RegisterTestCase(testcase JUnitTestCase) {
 		suite.Failures++
 	} else if testcase.Error != nil {
 		suite.Errors++
+	} else if testcase.SkipMessage != nil {
+		suite.Skipped++
+	}
+}
+
+// UpdateTotals recomputes the test, failure and error counts and the time of all test suites.
+func (testsuites *JUnitTestSuites) UpdateTotals() {
+	testsuites.Tests, testsuites.Failures, testsuites.Errors = 0, 0, 0
+	var seconds float64
+	for _, suite := range testsuites.Suites {
+		testsuites.Tests += suite.Tests
+		testsuites.Failures += suite.Failures
+		testsuites.Errors += suite.Errors
+		if value, err := strconv.ParseFloat(suite.Time, 64); err == nil {
+			seconds += value
+		}
 	}
+	testsuites.Time = FormatTime(time.Duration(seconds * float64(time.Second)))
 }
 
 // RegisterElapsedTime saves the elapsed time  in string format.
@@ -6,7 +6,9 @@ import (
 	"io"
 )
 
+// Write writes the test suites as a JUnitXML document, with updated totals.
 func (testsuites JUnitTestSuites) Write(w io.Writer) error {
+	testsuites.UpdateTotals()
 	io.WriteString(w, "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n")
 	enc := xml.NewEncoder(w)
 	enc.Indent("", "\t")
@@ -16,3 +18,12 @@ func (testsuites JUnitTestSuites) Write(w io.Writer) error {
 	io.WriteString(w, "\n")
 	return nil
 }
+
+// Read reads a JUnitXML document, for example to append more test suites to it.
+func Read(r io.Reader) (JUnitTestSuites, error) {
+	var testsuites JUnitTestSuites
+	if err := xml.NewDecoder(r).Decode(&testsuites); err != nil {
+		return JUnitTestSuites{}, fmt.Errorf("unable to read XML document: %v", err)
+	}
+	return testsuites, nil
+}
@@ -1,11 +1,13 @@
 package junitxml
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -69,6 +71,9 @@ func TestOneTestSuite(t *testing.T) {
 	testCase := JUnitTestCase{
 		Classname: "README.md",
 		Name:      "ls -l",
+		ID:        "0123456789ab",
+		File:      "README.md",
+		Line:      42,
 		Time:      FormatTime(51345000),
 		Failure: &JUnitFailure{
 			Message:  "Failed",
@@ -76,7 +81,16 @@ func TestOneTestSuite(t *testing.T) {
 			Contents: "(the test output)",
 		},
 	}
+	testCase.AddProperty("command", "ls -l")
+	testCase.AddProperty("expected", "total 0\n...")
+	testCase.SystemOut = "(the test output)"
 	ts.TestCases = append(ts.TestCases, testCase)
+	skippedCase := JUnitTestCase{
+		Classname: "README.md",
+		Name:      "rm -rf /",
+	}
+	skippedCase.RegisterSkipped("SKIPPED (not a good idea)")
+	ts.RegisterTestCase(skippedCase)
 	testsuites.Suites = append(testsuites.Suites, ts)
 
 	// The rest should be data/table driven...:
@@ -89,3 +103,34 @@ func TestOneTestSuite(t *testing.T) {
 	// Verify it is schema compliant.
 	require.NoError(t, validateXMLFile(file.Name()), "XML document fails to validate")
 }
+
+func TestTestCaseMetadata(t *testing.T) {
+	suite := JUnitTestSuite{Name: "README.md"}
+	suite.AddProperty("shell", "/bin/sh")
+	testcase := JUnitTestCase{Classname: "README.md", Name: "ls", ID: "0123456789ab", File: "README.md", Line: 42}
+	testcase.AddProperty("exit-code", "0")
+	suite.RegisterTestCase(testcase)
+	suite.RegisterTestCase(JUnitTestCase{Classname: "README.md", Name: "pwd"})
+	var buffer bytes.Buffer
+	require.NoError(t, JUnitTestSuites{Suites: []JUnitTestSuite{suite}}.Write(&buffer))
+	require.Contains(t, buffer.String(), `<property name="testcase.1.id" value="0123456789ab"></property>`,
+		"The ID is written as a property of the test suite")
+	require.Contains(t, buffer.String(), `<property name="testcase.1.line" value="42"></property>`,
+		"The location is written as properties of the test suite")
+	require.NotContains(t, buffer.String(), ` id="`, "The Jenkins schema does not allow an id attribute")
+	require.Contains(t, buffer.String(), `<property name="testcase.1.property.exit-code" value="0"></property>`,
+		"The properties of the test case are written as properties of the test suite")
+	require.NotContains(t, buffer.String(), ` line="`, "The Jenkins schema does not allow a line attribute")
+	require.Equal(t, 1, strings.Count(buffer.String(), "<properties>"), "Only the test suite has properties")
+	read, err := Read(&buffer)
+	require.NoError(t, err)
+	require.Equal(t, "0123456789ab", read.Suites[0].TestCases[0].ID, "The ID is moved back into the test case")
+	require.Equal(t, "README.md", read.Suites[0].TestCases[0].File, "The location is moved back into the test case")
+	require.Equal(t, 42, read.Suites[0].TestCases[0].Line)
+	exitCode, ok := read.Suites[0].TestCases[0].Property("exit-code")
+	require.True(t, ok, "The properties are moved back into the test case")
+	require.Equal(t, "0", exitCode)
+	require.Nil(t, read.Suites[0].TestCases[1].Properties)
+	require.Empty(t, read.Suites[0].TestCases[1].ID)
+	require.Equal(t, []JUnitProperty{{"shell", "/bin/sh"}}, read.Suites[0].Properties)
+}
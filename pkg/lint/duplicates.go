@@ -0,0 +1,86 @@
+package lint
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// Location is the position of a command in a document
+type Location struct {
+	File string
+	Line int
+}
+
+func (location Location) String() string {
+	return fmt.Sprintf("%s:%d", location.File, location.Line)
+}
+
+// Duplicate is a command that occurs identically in several documents. Repeated setup steps are candidates for a
+// shared include or a named code block (shelldocdefine).
+type Duplicate struct {
+	Command   string
+	Locations []Location
+}
+
+// Documents returns the number of documents the command occurs in
+func (duplicate Duplicate) Documents() int {
+	files := make(map[string]bool)
+	for _, location := range duplicate.Locations {
+		files[location.File] = true
+	}
+	return len(files)
+}
+
+// Duplicates finds the commands that occur in at least minimum documents. Commands are compared after collapsing
+// white space. The documents are processed in order, the duplicates are sorted by the number of documents they occur
+// in, most frequent first.
+type Duplicates struct {
+	commands map[string]*Duplicate
+	order    []string
+}
+
+// NewDuplicates creates an empty duplicate analysis
+func NewDuplicates() *Duplicates {
+	return &Duplicates{commands: make(map[string]*Duplicate)}
+}
+
+// Add tokenizes the document data and records the locations of its commands
+func (duplicates *Duplicates) Add(file string, data []byte) {
+	visitor := tokenizer.NewInteractionVisitor()
+	tokenizer.Tokenize(data, visitor)
+	for _, interaction := range visitor.Interactions {
+		if _, ok := interaction.VerifiedFile(); ok {
+			continue // the code block contains the contents of a file, not a command
+		}
+		command := strings.Join(strings.Fields(interaction.Cmd), " ")
+		if len(command) == 0 {
+			continue
+		}
+		duplicate, ok := duplicates.commands[command]
+		if !ok {
+			duplicate = &Duplicate{Command: command}
+			duplicates.commands[command] = duplicate
+			duplicates.order = append(duplicates.order, command)
+		}
+		duplicate.Locations = append(duplicate.Locations, Location{file, interaction.Line})
+	}
+}
+
+// Report returns the commands that occur in at least minimum documents
+func (duplicates *Duplicates) Report(minimum int) []Duplicate {
+	var result []Duplicate
+	for _, command := range duplicates.order {
+		if duplicate := duplicates.commands[command]; duplicate.Documents() >= minimum {
+			result = append(result, *duplicate)
+		}
+	}
+	sort.SliceStable(result, func(i, j int) bool { return result[i].Documents() > result[j].Documents() })
+	return result
+}
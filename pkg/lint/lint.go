@@ -0,0 +1,149 @@
+package lint
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+const (
+	// SeverityWarning marks findings that are likely mistakes, but do not prevent testing
+	SeverityWarning = "warning"
+	// SeverityError marks findings that will cause the interaction to fail with an error
+	SeverityError = "error"
+)
+
+// Finding describes a problem found in the documentation
+type Finding struct {
+	// Line is the line number of the affected command, starting at 1 (0 if unknown)
+	Line     int
+	Severity string
+	Message  string
+}
+
+func (finding Finding) String() string {
+	return fmt.Sprintf("%d: %s: %s", finding.Line, finding.Severity, finding.Message)
+}
+
+// Lint tokenizes data and checks the interactions and the code blocks without interactions in it for problems.
+func Lint(data []byte) []Finding {
+	visitor := tokenizer.NewInteractionVisitor()
+	tokenizer.Tokenize(data, visitor)
+	findings := LintInteractions(visitor.Interactions)
+	for _, block := range visitor.Skipped {
+		findings = append(findings, lintSkippedBlock(block)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings
+}
+
+// LintInteractions checks the interactions for problems. The findings are sorted by line.
+func LintInteractions(interactions []*tokenizer.Interaction) []Finding {
+	var findings []Finding
+	for _, interaction := range interactions {
+		findings = append(findings, lintInteraction(interaction)...)
+	}
+	sort.SliceStable(findings, func(i, j int) bool { return findings[i].Line < findings[j].Line })
+	return findings
+}
+
+func lintInteraction(interaction *tokenizer.Interaction) []Finding {
+	var findings []Finding
+	report := func(severity string, format string, args ...interface{}) {
+		findings = append(findings, Finding{interaction.Line, severity, fmt.Sprintf(format, args...)})
+	}
+	var keys []string
+	for key := range interaction.Attributes {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if !isKnownAttribute(key) {
+			report(SeverityWarning, "unknown attribute %s", key)
+		}
+	}
+	if value, ok := interaction.Attributes[tokenizer.ExitCodeOption]; ok {
+		if err := tokenizer.ValidateExitCodes(value); err != nil {
+			report(SeverityError, "%v", err)
+		}
+		if _, ok := interaction.Attributes[tokenizer.ExitCodeWhatever]; ok {
+			report(SeverityWarning, "%s is ignored because %s is specified", tokenizer.ExitCodeOption, tokenizer.ExitCodeWhatever)
+		}
+	}
+	if value, ok := interaction.Attributes[tokenizer.VarsOption]; ok {
+		if _, err := tokenizer.ParseVars(value); err != nil {
+			report(SeverityError, "%v", err)
+		}
+	}
+	if _, err := tokenizer.ParseNormalizers(interaction.Attributes[tokenizer.NormalizeOption]); err != nil {
+		report(SeverityError, "%v", err)
+	}
+	if _, err := tokenizer.ParseResponders(interaction.Attributes[tokenizer.RespondOption]); err != nil {
+		report(SeverityError, "%v", err)
+	}
+	if _, err := interaction.Delay(0); err != nil {
+		report(SeverityError, "%v", err)
+	}
+	if value, ok := interaction.Attributes[tokenizer.WaitForOption]; ok && len(strings.TrimSpace(value)) == 0 {
+		report(SeverityError, "%s needs a command", tokenizer.WaitForOption)
+	}
+	if _, err := interaction.WaitTimeout(); err != nil {
+		report(SeverityError, "%v", err)
+	}
+	if _, err := interaction.Timeout(); err != nil {
+		report(SeverityError, "%v", err)
+	}
+	if value, ok := interaction.Attributes[tokenizer.BackgroundOption]; ok && len(strings.TrimSpace(value)) == 0 {
+		report(SeverityError, "%s needs a name", tokenizer.BackgroundOption)
+	}
+	if value, ok := interaction.Attributes[tokenizer.CleanupOption]; ok && len(strings.TrimSpace(value)) == 0 {
+		report(SeverityError, "%s needs a command", tokenizer.CleanupOption)
+	}
+	if err := interaction.ValidatePatterns(); err != nil {
+		report(SeverityError, "%v", err)
+	}
+	if name, ok := interaction.VerifiedFile(); ok && len(name) == 0 {
+		report(SeverityError, "%s needs a file name", tokenizer.VerifyFileOption)
+	}
+	if name, ok := interaction.Attributes[tokenizer.UserOption]; ok && len(strings.TrimSpace(name)) == 0 {
+		report(SeverityError, "%s needs a user name", tokenizer.UserOption)
+	}
+	for index, line := range interaction.Response {
+		if strings.TrimSpace(line) == "..." && index < len(interaction.Response)-1 {
+			report(SeverityWarning, "the expected response after the ellipsis is ignored")
+			break
+		}
+	}
+	return findings
+}
+
+// lintSkippedBlock checks a code block that does not contain any commands. Attributes on such a block have no
+// effect, which usually means that the trigger characters ($ or >) are missing. Invalid directives are reported as
+// errors.
+func lintSkippedBlock(block tokenizer.SkippedBlock) []Finding {
+	if block.Reason == tokenizer.SkipInvalidDirective {
+		return []Finding{{block.Line, SeverityError, fmt.Sprintf("invalid shelldoc directive \"%s\"", strings.Join(block.Content, " "))}}
+	}
+	if block.Reason != tokenizer.SkipNoCommands || len(block.Attributes) == 0 {
+		return nil
+	}
+	return []Finding{{block.Line, SeverityWarning, "code block with shelldoc attributes contains no commands ($ or > prefix)"}}
+}
+
+func isKnownAttribute(key string) bool {
+	if strings.HasPrefix(key, tokenizer.PropertyPrefix) && len(key) > len(tokenizer.PropertyPrefix) {
+		return true
+	}
+	for _, known := range tokenizer.KnownAttributes {
+		if key == known {
+			return true
+		}
+	}
+	return false
+}
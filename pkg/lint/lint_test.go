@@ -0,0 +1,137 @@
+package lint
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLintProblems(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/problems.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	findings := Lint(data)
+	require.Len(t, findings, 3, "There are three problems in the sample")
+	require.Equal(t, Finding{11, SeverityError, "argument to shelldocexitcode needs to be an exit code, a range like 1-125, any or nonzero, separated by commas, got \"one\""}, findings[1])
+	require.Equal(t, 11, findings[0].Line, "The unknown attribute is reported at the command")
+	require.Equal(t, SeverityWarning, findings[0].Severity, "Unknown attributes are warnings")
+	require.Equal(t, 16, findings[2].Line, "The ellipsis is reported at the command")
+}
+
+func TestLintClean(t *testing.T) {
+	data, err := ioutil.ReadFile("../tokenizer/samples/helloworld.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	require.Empty(t, Lint(data), "The hello world sample has no problems")
+}
+
+func TestLintVars(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/vars.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	require.Equal(t, []Finding{{4, SeverityError, "expected NAME=VALUE in shelldocvars, got \"NAME\""}}, Lint(data))
+}
+
+func TestLintProperties(t *testing.T) {
+	data, err := ioutil.ReadFile("../tokenizer/samples/properties.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	require.Empty(t, Lint(data), "Custom properties are known attributes")
+	require.Len(t, Lint([]byte("```shell {shelldocprop-}\n$ true\n```\n")), 1, "Properties need a name")
+}
+
+func TestLintNormalizers(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldocnormalize=timestamps,sizes}\n$ date\n```\n")))
+	findings := Lint([]byte("```shell {shelldocnormalize=uuids}\n$ uuidgen\n```\n"))
+	require.Len(t, findings, 1, "Unknown normalizers are reported")
+	require.Equal(t, SeverityError, findings[0].Severity)
+}
+
+func TestLintBlocksWithoutCommands(t *testing.T) {
+	data := []byte("# Title\n\n```shell {shelldocexitcode=1}\nfalse\n```\n\n```json\n{}\n```\n")
+	require.Equal(t, []Finding{{4, SeverityWarning, "code block with shelldoc attributes contains no commands ($ or > prefix)"}}, Lint(data))
+}
+
+func TestLintResponders(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldocrespond=\"'Continue\\?'=y\"}\n$ ./install.sh\n```\n")))
+	findings := Lint([]byte("```shell {shelldocrespond=Continue}\n$ ./install.sh\n```\n"))
+	require.Len(t, findings, 1, "Rules without a reply are reported")
+	require.Equal(t, SeverityError, findings[0].Severity)
+}
+
+func TestLintCleanup(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldoccleanup=\"docker rm -f demo\"}\n$ docker run --name demo hello-world\n```\n")))
+	require.Equal(t, []Finding{{2, SeverityError, "shelldoccleanup needs a command"}},
+		Lint([]byte("```shell {shelldoccleanup}\n$ docker run --name demo hello-world\n```\n")))
+}
+
+func TestLintDelay(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldocdelay=500ms}\n$ curl https://example.com\n```\n")))
+	require.Equal(t, []Finding{{2, SeverityError, "argument to shelldocdelay needs to be a duration like 500ms, got \"soon\""}},
+		Lint([]byte("```shell {shelldocdelay=soon}\n$ curl https://example.com\n```\n")))
+}
+
+func TestLintWaitFor(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldocwaitfor=\"curl -sf localhost:8080/health\" shelldocwaittimeout=10s}\n$ curl localhost:8080\n```\n")))
+	require.Equal(t, []Finding{
+		{2, SeverityError, "shelldocwaitfor needs a command"},
+		{2, SeverityError, "argument to shelldocwaittimeout needs to be a duration like 30s, got \"later\""}},
+		Lint([]byte("```shell {shelldocwaitfor shelldocwaittimeout=later}\n$ curl localhost:8080\n```\n")))
+}
+
+func TestLintBackground(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldocbackground=server}\n$ ./server\n```\n")))
+	require.Equal(t, []Finding{{2, SeverityError, "shelldocbackground needs a name"}},
+		Lint([]byte("```shell {shelldocbackground}\n$ ./server\n```\n")))
+}
+
+func TestLintVerifyFile(t *testing.T) {
+	require.Empty(t, Lint([]byte("```yaml {shelldocverifyfile=config.yaml}\nport: 8080\n```\n")))
+	require.Equal(t, []Finding{{1, SeverityError, "shelldocverifyfile needs a file name"}},
+		Lint([]byte("```yaml {shelldocverifyfile}\nport: 8080\n```\n")))
+}
+
+func TestLintRegex(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldocregex}\n$ ./create\nid: (?P<ID>\\w+)\n```\n")))
+	findings := Lint([]byte("```shell {shelldocregex}\n$ ./create\nid: (?P<ID\n```\n"))
+	require.Len(t, findings, 1)
+	require.Equal(t, SeverityError, findings[0].Severity, "Invalid patterns are errors")
+}
+
+func TestLintUser(t *testing.T) {
+	require.Empty(t, Lint([]byte("```shell {shelldocuser=nobody}\n$ id -un\nnobody\n```\n")))
+	require.Equal(t, []Finding{{2, SeverityError, "shelldocuser needs a user name"}},
+		Lint([]byte("```shell {shelldocuser}\n$ id -un\nnobody\n```\n")))
+}
+
+func TestLintDirectives(t *testing.T) {
+	require.Empty(t, Lint([]byte("<!-- shelldoc: timeout=30s -->\n\n```shell\n$ make\n```\n")))
+	require.Equal(t, []Finding{
+		{1, SeverityError, "invalid shelldoc directive \"timeout=soon\""},
+		{3, SeverityError, "invalid shelldoc directive \"frobnicate\""},
+		{6, SeverityError, "argument to shelldoctimeout needs to be a duration like 30s, got \"later\""}},
+		Lint([]byte("<!-- shelldoc: timeout=soon -->\n\n<!-- shelldoc: frobnicate -->\n\n```shell {shelldoctimeout=later}\n$ make\n```\n")))
+}
+
+func TestLintDefaults(t *testing.T) {
+	data, err := ioutil.ReadFile("../tokenizer/samples/defaults.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	require.Empty(t, Lint(data), "Default attributes are not reported for blocks without commands")
+}
+
+func TestDuplicates(t *testing.T) {
+	duplicates := NewDuplicates()
+	for _, file := range []string{"install.md", "tutorial.md", "upgrade.md"} {
+		data, err := ioutil.ReadFile("samples/duplicates/" + file)
+		require.NoError(t, err, "Unable to read sample data file")
+		duplicates.Add(file, data)
+	}
+	report := duplicates.Report(2)
+	require.Len(t, report, 2, "Commands in a single document are not duplicates")
+	require.Equal(t, "python3 -m venv .venv", report[0].Command, "The most frequent command is listed first")
+	require.Equal(t, 3, report[0].Documents())
+	require.Equal(t, Location{"tutorial.md", 6}, report[0].Locations[1])
+	require.Equal(t, ".venv/bin/pip install example", report[1].Command, "White space is collapsed")
+	require.Len(t, duplicates.Report(3), 1)
+}
@@ -0,0 +1,198 @@
+package lsp
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/lint"
+	"github.com/mirkoboehm/shelldoc/pkg/run"
+)
+
+// Server is a minimal language server that publishes shelldoc diagnostics for Markdown files.
+type Server struct {
+	// ExecuteOnSave enables executing the interactions of a document when it is saved
+	ExecuteOnSave bool
+	// Options are the settings the documents are executed with, like the shell, the configuration and the policy
+	Options   run.Options
+	documents map[string]string
+	reader    *bufio.Reader
+	writer    io.Writer
+}
+
+// NewServer creates a language server that communicates through the given streams.
+func NewServer(in io.Reader, out io.Writer) *Server {
+	return &Server{
+		documents: make(map[string]string),
+		reader:    bufio.NewReader(in),
+		writer:    out,
+	}
+}
+
+// Serve handles messages until the client sends the exit notification or the input is closed.
+func (server *Server) Serve() error {
+	for {
+		msg, err := readMessage(server.reader)
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if msg.Method == "exit" {
+			return nil
+		}
+		if err := server.handle(msg); err != nil {
+			return err
+		}
+	}
+}
+
+func (server *Server) handle(msg *message) error {
+	switch msg.Method {
+	case "initialize":
+		capabilities := map[string]interface{}{
+			"textDocumentSync": map[string]interface{}{
+				"openClose": true,
+				"change":    textDocumentSyncFull,
+				"save":      map[string]interface{}{"includeText": true},
+			},
+		}
+		return server.respond(msg, map[string]interface{}{
+			"capabilities": capabilities,
+			"serverInfo":   map[string]string{"name": "shelldoc"},
+		})
+	case "shutdown":
+		return server.respond(msg, json.RawMessage("null"))
+	case "textDocument/didOpen":
+		var params didOpenParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return fmt.Errorf("invalid didOpen parameters: %v", err)
+		}
+		server.documents[params.TextDocument.URI] = params.TextDocument.Text
+		return server.publish(params.TextDocument.URI, false)
+	case "textDocument/didChange":
+		var params didChangeParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return fmt.Errorf("invalid didChange parameters: %v", err)
+		}
+		if count := len(params.ContentChanges); count > 0 {
+			server.documents[params.TextDocument.URI] = params.ContentChanges[count-1].Text
+		}
+		return server.publish(params.TextDocument.URI, false)
+	case "textDocument/didSave":
+		var params didSaveParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return fmt.Errorf("invalid didSave parameters: %v", err)
+		}
+		if params.Text != nil {
+			server.documents[params.TextDocument.URI] = *params.Text
+		}
+		return server.publish(params.TextDocument.URI, server.ExecuteOnSave)
+	case "textDocument/didClose":
+		var params didCloseParams
+		if err := json.Unmarshal(msg.Params, &params); err != nil {
+			return fmt.Errorf("invalid didClose parameters: %v", err)
+		}
+		delete(server.documents, params.TextDocument.URI)
+		return server.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{params.TextDocument.URI, []diagnostic{}})
+	default:
+		if msg.ID != nil {
+			return writeMessage(server.writer, &message{ID: msg.ID, Error: &responseError{-32601, "method not found: " + msg.Method}})
+		}
+		return nil // notifications that are not understood are ignored
+	}
+}
+
+func (server *Server) respond(request *message, result interface{}) error {
+	return writeMessage(server.writer, &message{ID: request.ID, Result: result})
+}
+
+func (server *Server) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return fmt.Errorf("unable to encode notification: %v", err)
+	}
+	return writeMessage(server.writer, &message{Method: method, Params: data})
+}
+
+// publish sends the lint findings and optionally the execution results for the document to the client
+func (server *Server) publish(uri string, execute bool) error {
+	text := server.documents[uri]
+	lines := strings.Split(text, "\n")
+	diagnostics := []diagnostic{}
+	for _, finding := range lint.Lint([]byte(text)) {
+		severity := severityWarning
+		if finding.Severity == lint.SeverityError {
+			severity = severityError
+		}
+		diagnostics = append(diagnostics, newDiagnostic(lines, finding.Line, severity, finding.Message))
+	}
+	if execute {
+		results, err := server.execute(uri, text)
+		if err != nil {
+			results = []diagnostic{newDiagnostic(lines, 1, severityError, err.Error())}
+		}
+		diagnostics = append(diagnostics, results...)
+	}
+	return server.notify("textDocument/publishDiagnostics", publishDiagnosticsParams{uri, diagnostics})
+}
+
+// execute runs the interactions in text with the options of the server and returns diagnostics for the failed ones
+func (server *Server) execute(uri, text string) ([]diagnostic, error) {
+	// the progress of the run is reported on stdout, which carries the messages to the client
+	stdout := os.Stdout
+	os.Stdout = os.Stderr
+	defer func() { os.Stdout = stdout }()
+	context := run.NewContext(run.WithOptions(server.Options))
+	suite, err := context.ExecuteDocument(documentPath(uri), []byte(text))
+	if err != nil {
+		return nil, err
+	}
+	lines := strings.Split(text, "\n")
+	var diagnostics []diagnostic
+	for _, testcase := range suite.TestCases {
+		if testcase.Error != nil {
+			description := fmt.Sprintf("%s: %s", testcase.Error.Message, testcase.Error.Contents)
+			diagnostics = append(diagnostics, newDiagnostic(lines, testcase.Line, severityError, description))
+		}
+		if testcase.Failure != nil {
+			description := fmt.Sprintf("%s: %s", testcase.Failure.Message, testcase.Failure.Contents)
+			diagnostics = append(diagnostics, newDiagnostic(lines, testcase.Line, severityError, description))
+		}
+	}
+	return diagnostics, nil
+}
+
+// documentPath returns the path of the file of a document, so that relative includes and the results refer to it
+func documentPath(uri string) string {
+	if parsed, err := url.Parse(uri); err == nil && parsed.Scheme == "file" {
+		return parsed.Path
+	}
+	return uri
+}
+
+// newDiagnostic creates a diagnostic covering the given line (starting at 1)
+func newDiagnostic(lines []string, line int, severity int, message string) diagnostic {
+	if line < 1 {
+		line = 1
+	}
+	length := 0
+	if line <= len(lines) {
+		length = len(lines[line-1])
+	}
+	return diagnostic{
+		Range:    documentRange{position{line - 1, 0}, position{line - 1, length}},
+		Severity: severity,
+		Source:   "shelldoc",
+		Message:  message,
+	}
+}
@@ -0,0 +1,111 @@
+package lsp
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func request(t *testing.T, buffer *bytes.Buffer, id int, method string, params interface{}) {
+	data, err := json.Marshal(params)
+	require.NoError(t, err, "Encoding the parameters should work")
+	msg := &message{Method: method, Params: data}
+	if id > 0 {
+		raw := json.RawMessage(strconv.Itoa(id))
+		msg.ID = &raw
+	}
+	require.NoError(t, writeMessage(buffer, msg), "Writing the request should work")
+}
+
+func TestDiagnosticsOnOpen(t *testing.T) {
+	text, err := ioutil.ReadFile("../lint/samples/problems.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	var input bytes.Buffer
+	request(t, &input, 1, "initialize", map[string]interface{}{})
+	request(t, &input, 0, "initialized", map[string]interface{}{})
+	request(t, &input, 0, "textDocument/didOpen", didOpenParams{textDocumentItem{"file:///problems.md", string(text)}})
+	request(t, &input, 2, "shutdown", nil)
+	request(t, &input, 0, "exit", nil)
+	var output bytes.Buffer
+	server := NewServer(&input, &output)
+	require.NoError(t, server.Serve(), "The server should handle the session without errors")
+
+	reader := bufio.NewReader(&output)
+	initialized, err := readMessage(reader)
+	require.NoError(t, err, "The server responds to initialize")
+	require.NotNil(t, initialized.ID, "The initialize response carries the request ID")
+	notification, err := readMessage(reader)
+	require.NoError(t, err, "The server publishes diagnostics")
+	require.Equal(t, "textDocument/publishDiagnostics", notification.Method)
+	var params publishDiagnosticsParams
+	require.NoError(t, json.Unmarshal(notification.Params, &params), "The diagnostics are well-formed")
+	require.Equal(t, "file:///problems.md", params.URI)
+	require.Len(t, params.Diagnostics, 3, "There are three problems in the sample")
+	require.Equal(t, 10, params.Diagnostics[1].Range.Start.Line, "LSP line numbers start at zero")
+	require.Equal(t, severityError, params.Diagnostics[1].Severity, "The invalid exit code is an error")
+	shutdown, err := readMessage(reader)
+	require.NoError(t, err, "The server responds to shutdown")
+	require.NotNil(t, shutdown.ID, "The shutdown response carries the request ID")
+}
+
+func TestExecuteOnSaveSkipsBlocks(t *testing.T) {
+	text, err := ioutil.ReadFile("../tokenizer/samples/skip.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	content := string(text)
+	var input bytes.Buffer
+	request(t, &input, 0, "textDocument/didOpen", didOpenParams{textDocumentItem{"file:///skip.md", content}})
+	request(t, &input, 0, "textDocument/didSave", didSaveParams{textDocumentIdentifier{"file:///skip.md"}, &content})
+	request(t, &input, 0, "exit", nil)
+	var output bytes.Buffer
+	server := NewServer(&input, &output)
+	server.ExecuteOnSave = true
+	require.NoError(t, server.Serve(), "The server should handle the session without errors")
+
+	reader := bufio.NewReader(&output)
+	var opened, saved publishDiagnosticsParams
+	notification, err := readMessage(reader)
+	require.NoError(t, err, "The server publishes diagnostics when the document is opened")
+	require.NoError(t, json.Unmarshal(notification.Params, &opened))
+	notification, err = readMessage(reader)
+	require.NoError(t, err, "The server publishes diagnostics when the document is saved")
+	require.NoError(t, json.Unmarshal(notification.Params, &saved))
+	require.Equal(t, opened.Diagnostics, saved.Diagnostics, "The commands in skipped code blocks are not executed")
+}
+
+func TestExecuteOnSaveReportsErrors(t *testing.T) {
+	failing := "# Failing\n\n```shell\n$ echo Hello\nWorld\n```\n"
+	invalid := "# Invalid\n\n```shell {shelldocneeds=\"undefined\"}\n$ echo Hello\nHello\n```\n"
+	var input bytes.Buffer
+	request(t, &input, 0, "textDocument/didSave", didSaveParams{textDocumentIdentifier{"file:///failing.md"}, &failing})
+	request(t, &input, 0, "textDocument/didSave", didSaveParams{textDocumentIdentifier{"file:///invalid.md"}, &invalid})
+	request(t, &input, 0, "exit", nil)
+	var output bytes.Buffer
+	server := NewServer(&input, &output)
+	server.ExecuteOnSave = true
+	require.NoError(t, server.Serve(), "The server should handle the session without errors")
+
+	reader := bufio.NewReader(&output)
+	var params publishDiagnosticsParams
+	notification, err := readMessage(reader)
+	require.NoError(t, err, "The server publishes the results of the failing document")
+	require.NoError(t, json.Unmarshal(notification.Params, &params))
+	require.Len(t, params.Diagnostics, 1, "The mismatch is reported")
+	require.Equal(t, 3, params.Diagnostics[0].Range.Start.Line, "The diagnostic is shown at the failed command")
+	notification, err = readMessage(reader)
+	require.NoError(t, err, "The server publishes the results of the invalid document")
+	require.NoError(t, json.Unmarshal(notification.Params, &params))
+	messages := ""
+	for _, diagnostic := range params.Diagnostics {
+		messages += diagnostic.Message + "\n"
+	}
+	require.Contains(t, messages, "needs the undefined code block", "The code blocks of the document cannot be ordered")
+}
@@ -0,0 +1,107 @@
+package patch
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// contextLines is the number of unchanged lines shown around every change
+const contextLines = 3
+
+// Change replaces the lines starting at Line (starting at 1) with New. The number of replaced lines is len(Old).
+// If Old is empty, New is inserted before Line.
+type Change struct {
+	Line int
+	Old  []string
+	New  []string
+}
+
+// Unified returns a unified diff that applies the changes to the lines of the file at path. The changes must not
+// overlap. The diff can be applied with "git apply" or "patch -p1".
+func Unified(path string, lines []string, changes []Change) string {
+	if len(changes) == 0 {
+		return ""
+	}
+	changes = append([]Change(nil), changes...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Line < changes[j].Line })
+	// group changes that are close to each other into hunks
+	var hunks [][]Change
+	for index, change := range changes {
+		if index > 0 {
+			previous := changes[index-1]
+			if change.Line-(previous.Line+len(previous.Old)) <= 2*contextLines {
+				hunks[len(hunks)-1] = append(hunks[len(hunks)-1], change)
+				continue
+			}
+		}
+		hunks = append(hunks, []Change{change})
+	}
+	path = filepath.ToSlash(filepath.Clean(path))
+	var diff strings.Builder
+	fmt.Fprintf(&diff, "--- a/%s\n+++ b/%s\n", path, path)
+	delta := 0
+	for _, hunk := range hunks {
+		first, last := hunk[0], hunk[len(hunk)-1]
+		start := maxInt(1, first.Line-contextLines)
+		end := minInt(len(lines), last.Line+len(last.Old)-1+contextLines)
+		var body strings.Builder
+		cursor := start
+		removed, added := 0, 0
+		for _, change := range hunk {
+			for ; cursor < change.Line; cursor++ {
+				fmt.Fprintf(&body, " %s\n", lines[cursor-1])
+			}
+			for _, line := range lines[change.Line-1 : change.Line-1+len(change.Old)] {
+				fmt.Fprintf(&body, "-%s\n", line)
+			}
+			for _, line := range change.New {
+				fmt.Fprintf(&body, "+%s\n", line)
+			}
+			cursor += len(change.Old)
+			removed += len(change.Old)
+			added += len(change.New)
+		}
+		for ; cursor <= end; cursor++ {
+			fmt.Fprintf(&body, " %s\n", lines[cursor-1])
+		}
+		oldCount := end - start + 1
+		newCount := oldCount - removed + added
+		fmt.Fprintf(&diff, "@@ -%d,%d +%d,%d @@\n%s", start, oldCount, start+delta, newCount, body.String())
+		delta += added - removed
+	}
+	return diff.String()
+}
+
+// Apply returns the lines of a file with the changes applied. The changes must not overlap.
+func Apply(lines []string, changes []Change) []string {
+	changes = append([]Change(nil), changes...)
+	sort.SliceStable(changes, func(i, j int) bool { return changes[i].Line < changes[j].Line })
+	var result []string
+	cursor := 1
+	for _, change := range changes {
+		result = append(result, lines[cursor-1:change.Line-1]...)
+		result = append(result, change.New...)
+		cursor = change.Line + len(change.Old)
+	}
+	return append(result, lines[cursor-1:]...)
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
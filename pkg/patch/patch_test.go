@@ -0,0 +1,49 @@
+package patch
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnified(t *testing.T) {
+	lines := []string{"# Title", "", "    $ echo one", "    two", "", "text", "", "    $ echo three", "", "end"}
+	changes := []Change{
+		{Line: 9, New: []string{"    three"}},
+		{Line: 4, Old: []string{"    two"}, New: []string{"    one"}},
+	}
+	expected := "--- a/docs/README.md\n+++ b/docs/README.md\n" +
+		"@@ -1,10 +1,11 @@\n # Title\n \n     $ echo one\n-    two\n+    one\n \n text\n \n     $ echo three\n+    three\n \n end\n"
+	require.Equal(t, expected, Unified("docs/./README.md", lines, changes), "Close changes are combined in one hunk")
+	require.Empty(t, Unified("README.md", lines, nil), "Without changes, the diff is empty")
+}
+
+func TestUnifiedSeparateHunks(t *testing.T) {
+	var lines []string
+	for index := 1; index <= 20; index++ {
+		lines = append(lines, string(rune('a'+index-1)))
+	}
+	changes := []Change{
+		{Line: 2, Old: []string{"b"}, New: []string{"B", "B"}},
+		{Line: 18, Old: []string{"r"}, New: nil},
+	}
+	expected := "--- a/file.md\n+++ b/file.md\n" +
+		"@@ -1,5 +1,6 @@\n a\n-b\n+B\n+B\n c\n d\n e\n" +
+		"@@ -15,6 +16,5 @@\n o\n p\n q\n-r\n s\n t\n"
+	require.Equal(t, expected, Unified("file.md", lines, changes), "Distant changes are written in separate hunks")
+}
+
+func TestApply(t *testing.T) {
+	lines := []string{"# Title", "", "    $ echo one", "    two", "", "    $ echo three", "", "end"}
+	changes := []Change{
+		{Line: 7, New: []string{"    three"}},
+		{Line: 4, Old: []string{"    two"}, New: []string{"    one"}},
+	}
+	expected := []string{"# Title", "", "    $ echo one", "    one", "", "    $ echo three", "    three", "", "end"}
+	require.Equal(t, expected, Apply(lines, changes), "Lines are replaced and inserted")
+	require.Equal(t, lines, Apply(lines, nil), "Without changes, the lines are not modified")
+}
@@ -0,0 +1,254 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// explainContext is the number of source lines shown around a command that is not in a code block
+const explainContext = 3
+
+// Match identifies a test case in the results, together with the test suite of its file
+type Match struct {
+	Suite    *junitxml.JUnitTestSuite
+	TestCase *junitxml.JUnitTestCase
+}
+
+// FindTests returns the test cases selected by test, which is either the ID of a test case, a file and line
+// (README.md:42), or a file or classname. For a file, only the tests that failed or could not be executed are
+// returned.
+func FindTests(suites junitxml.JUnitTestSuites, test string) ([]Match, error) {
+	file, line := test, 0
+	if index := strings.LastIndex(test, ":"); index > 0 {
+		if number, err := strconv.Atoi(test[index+1:]); err == nil {
+			file, line = test[:index], number
+		}
+	}
+	var matches []Match
+	for suiteIndex := range suites.Suites {
+		suite := &suites.Suites[suiteIndex]
+		for index := range suite.TestCases {
+			testcase := &suite.TestCases[index]
+			inFile := testcase.File == file || testcase.Classname == file || (len(testcase.File) == 0 && suite.Name == file)
+			switch {
+			case testcase.ID == test:
+				return []Match{{suite, testcase}}, nil
+			case inFile && line > 0 && testcase.Line == line:
+				matches = append(matches, Match{suite, testcase})
+			case inFile && line == 0 && (testcase.Failure != nil || testcase.Error != nil):
+				matches = append(matches, Match{suite, testcase})
+			}
+		}
+	}
+	if len(matches) == 0 {
+		return nil, fmt.Errorf("no test %s found in the results", test)
+	}
+	return matches, nil
+}
+
+// Explain writes a triage view of a test case: the result, the code block in the Markdown source with line numbers,
+// the attributes in effect, the differences between the expected response and the actual output, and the shell and
+// settings the file was executed with. source contains the Markdown file of the test case, or nil if it is not
+// available.
+func Explain(writer io.Writer, match Match, source []byte) error {
+	var text strings.Builder
+	suite, testcase := match.Suite, match.TestCase
+	file := testcase.File
+	if len(file) == 0 {
+		file = suite.Name
+	}
+	fmt.Fprintf(&text, "SHELLDOC: %s:%d: %s\n", file, testcase.Line, testcase.Name)
+	fmt.Fprintf(&text, "ID: %s\n", testcase.ID)
+	switch {
+	case testcase.Failure != nil:
+		fmt.Fprintf(&text, "Result: %s [%s]\n", testcase.Failure.Message, testcase.Failure.Type)
+	case testcase.Error != nil:
+		fmt.Fprintf(&text, "Result: %s [%s]\n", testcase.Error.Message, testcase.Error.Type)
+		fmt.Fprintf(&text, "Error: %s\n", testcase.Error.Contents)
+	case testcase.SkipMessage != nil:
+		fmt.Fprintf(&text, "Result: %s\n", testcase.SkipMessage.Message)
+	default:
+		text.WriteString("Result: PASS\n")
+	}
+	properties := make(map[string]string)
+	if testcase.Properties != nil {
+		for _, property := range testcase.Properties.Properties {
+			properties[property.Name] = property.Value
+		}
+	}
+	text.WriteString("\nSource:\n")
+	if source == nil || testcase.Line < 1 {
+		text.WriteString("  (not available)\n")
+	} else {
+		text.WriteString(excerpt(strings.Split(strings.Replace(string(source), "\r\n", "\n", -1), "\n"), testcase.Line))
+	}
+	text.WriteString("\nAttributes:\n")
+	if attributes := properties["attributes"]; len(attributes) > 0 {
+		for _, attribute := range strings.Fields(attributes) {
+			fmt.Fprintf(&text, "  %s\n", attribute)
+		}
+	} else {
+		text.WriteString("  (none)\n")
+	}
+	if exitCode, ok := properties["exit-code"]; ok {
+		fmt.Fprintf(&text, "\nExit code: %s\n", exitCode)
+	}
+	text.WriteString("\nExpected response and output (- expected, + output):\n")
+	text.WriteString(diff(splitLines(properties["expected"]), splitLines(testcase.SystemOut)))
+	if expected, ok := properties["expected-errors"]; ok || len(testcase.SystemErr) > 0 {
+		text.WriteString("\nExpected error output and error output (- expected, + error output):\n")
+		text.WriteString(diff(splitLines(expected), splitLines(testcase.SystemErr)))
+	}
+	if screen, ok := properties["screen"]; ok {
+		fmt.Fprintf(&text, "\nFinal screen:\n%s\n", screen)
+	}
+	var names []string
+	for name := range properties {
+		switch name {
+		case "command", "expected", "expected-errors", "attributes", "exit-code", "screen":
+		default:
+			names = append(names, name)
+		}
+	}
+	if len(names) > 0 {
+		sort.Strings(names)
+		text.WriteString("\nProperties:\n")
+		for _, name := range names {
+			fmt.Fprintf(&text, "  %s: %s\n", name, properties[name])
+		}
+	}
+	text.WriteString("\nEnvironment:\n")
+	for _, property := range suite.Properties {
+		if strings.HasPrefix(property.Name, "resource-usage.") && property.Name != "resource-usage."+testcase.ID {
+			continue // the resources used by other tests
+		}
+		fmt.Fprintf(&text, "  %s: %s\n", property.Name, property.Value)
+	}
+	if _, err := io.WriteString(writer, text.String()); err != nil {
+		return fmt.Errorf("unable to write explanation: %v", err)
+	}
+	return nil
+}
+
+// splitLines splits text into lines, an empty text has no lines
+func splitLines(text string) []string {
+	if len(text) == 0 {
+		return nil
+	}
+	return strings.Split(text, "\n")
+}
+
+// excerpt returns the code block that contains line (starting at 1) with line numbers, the line is marked. If the
+// line is not in a code block, the surrounding lines are returned.
+func excerpt(lines []string, line int) string {
+	if line > len(lines) {
+		return "  (not available)\n"
+	}
+	isFence := func(text string) bool {
+		trimmed := strings.TrimSpace(text)
+		return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+	}
+	isIndented := func(text string) bool {
+		return strings.HasPrefix(text, "    ") || strings.HasPrefix(text, "\t")
+	}
+	var fences []int
+	for index := 0; index < line-1; index++ {
+		if isFence(lines[index]) {
+			fences = append(fences, index)
+		}
+	}
+	start, end := line-1, line
+	switch {
+	case len(fences)%2 == 1 || isFence(lines[line-1]):
+		// inside a fenced code block, or at the opening fence of a file verification, show the block with its fences
+		if len(fences)%2 == 1 {
+			start = fences[len(fences)-1]
+		}
+		for end < len(lines) && !isFence(lines[end]) {
+			end++
+		}
+		end = minInt(end+1, len(lines))
+	case isIndented(lines[line-1]):
+		for start > 0 && isIndented(lines[start-1]) {
+			start--
+		}
+		for end < len(lines) && isIndented(lines[end]) {
+			end++
+		}
+	default:
+		start = maxInt(0, line-1-explainContext)
+		end = minInt(len(lines), line+explainContext)
+	}
+	var text strings.Builder
+	width := len(strconv.Itoa(end))
+	for index := start; index < end; index++ {
+		marker := " "
+		if index == line-1 {
+			marker = ">"
+		}
+		fmt.Fprintf(&text, "  %s %*d | %s\n", marker, width, index+1, lines[index])
+	}
+	return text.String()
+}
+
+// diff compares the expected lines to the actual lines and returns the lines of both, marking the lines that are
+// only expected with - and the lines that are only in the output with +
+func diff(expected, actual []string) string {
+	// longest common subsequence, the responses are short
+	common := make([][]int, len(expected)+1)
+	for index := range common {
+		common[index] = make([]int, len(actual)+1)
+	}
+	for i := len(expected) - 1; i >= 0; i-- {
+		for j := len(actual) - 1; j >= 0; j-- {
+			if expected[i] == actual[j] {
+				common[i][j] = common[i+1][j+1] + 1
+			} else {
+				common[i][j] = maxInt(common[i+1][j], common[i][j+1])
+			}
+		}
+	}
+	var text strings.Builder
+	i, j := 0, 0
+	for i < len(expected) || j < len(actual) {
+		switch {
+		case i < len(expected) && j < len(actual) && expected[i] == actual[j]:
+			fmt.Fprintf(&text, "    %s\n", expected[i])
+			i++
+			j++
+		case i < len(expected) && (j == len(actual) || common[i+1][j] >= common[i][j+1]):
+			fmt.Fprintf(&text, "  - %s\n", expected[i])
+			i++
+		default:
+			fmt.Fprintf(&text, "  + %s\n", actual[j])
+			j++
+		}
+	}
+	if text.Len() == 0 {
+		text.WriteString("  (none)\n")
+	}
+	return text.String()
+}
+
+func maxInt(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
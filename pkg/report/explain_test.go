@@ -0,0 +1,56 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExplain(t *testing.T) {
+	suite := junitxml.JUnitTestSuite{Name: "README.md"}
+	suite.AddProperty("shell", "/bin/bash")
+	suite.AddProperty("resource-usage.other", "wall 1s")
+	suite.RegisterTestCase(junitxml.JUnitTestCase{Name: "echo Hello", ID: "passed", File: "README.md", Line: 3})
+	failed := junitxml.JUnitTestCase{Name: "echo one; echo three", ID: "failed", File: "README.md", Line: 7}
+	failed.RegisterFailure("FAILURE", "FAIL (mismatch)", "")
+	failed.AddProperty("expected", "one\ntwo")
+	failed.AddProperty("attributes", "shelldocexitcode=0")
+	failed.AddProperty("owner", "docs-team")
+	failed.SystemOut = "one\nthree"
+	suite.RegisterTestCase(failed)
+	suites := junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{suite}}
+
+	matches, err := FindTests(suites, "failed")
+	require.NoError(t, err)
+	require.Len(t, matches, 1)
+	require.Equal(t, "echo one; echo three", matches[0].TestCase.Name, "Tests are found by ID")
+	matches, err = FindTests(suites, "README.md:3")
+	require.NoError(t, err)
+	require.Equal(t, "passed", matches[0].TestCase.ID, "Tests are found by file and line")
+	matches, err = FindTests(suites, "README.md")
+	require.NoError(t, err)
+	require.Len(t, matches, 1, "For a file, the failed tests are found")
+	_, err = FindTests(suites, "missing")
+	require.Error(t, err, "Unknown tests are an error")
+
+	source := "# Example\n\n    $ echo Hello\n    Hello\n\n```shell {shelldocexitcode=0}\n$ echo one; echo three\none\ntwo\n```\n\nMore text.\n"
+	var text bytes.Buffer
+	require.NoError(t, Explain(&text, matches[0], []byte(source)))
+	require.Contains(t, text.String(), "Result: FAIL (mismatch) [FAILURE]\n")
+	require.Contains(t, text.String(), "     6 | ```shell {shelldocexitcode=0}\n  >  7 | $ echo one; echo three\n"+
+		"     8 | one\n     9 | two\n    10 | ```\n", "The code block is shown with line numbers")
+	require.Contains(t, text.String(), "Attributes:\n  shelldocexitcode=0\n")
+	require.Contains(t, text.String(), "    one\n  - two\n  + three\n", "The expected response is compared to the output")
+	require.Contains(t, text.String(), "Properties:\n  owner: docs-team\n")
+	require.Contains(t, text.String(), "Environment:\n  shell: /bin/bash\n", "The shell is shown")
+	require.NotContains(t, text.String(), "resource-usage.other", "Properties of other tests are not shown")
+	text.Reset()
+	require.NoError(t, Explain(&text, matches[0], nil))
+	require.Contains(t, text.String(), "Source:\n  (not available)\n", "Missing sources are reported")
+}
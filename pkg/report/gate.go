@@ -0,0 +1,83 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// Thresholds are the limits the results of a run are checked against. Negative values disable a check.
+type Thresholds struct {
+	// MaxFailures is the maximum number of failed tests
+	MaxFailures int
+	// MaxErrors is the maximum number of tests that could not be executed
+	MaxErrors int
+	// MaxSkipped is the maximum number of skipped tests
+	MaxSkipped int
+	// MinTests is the minimum number of tests, so that a run that silently tests nothing does not pass
+	MinTests int
+	// IgnoreCategories lists failure categories (like timeout) whose failures and errors are not counted
+	IgnoreCategories []string
+}
+
+// DefaultThresholds returns thresholds that accept no failures and no errors, like a regular run.
+func DefaultThresholds() Thresholds {
+	return Thresholds{MaxFailures: 0, MaxErrors: 0, MaxSkipped: -1, MinTests: -1}
+}
+
+// Verify checks the results against the thresholds and returns a description of every violation.
+func Verify(suites junitxml.JUnitTestSuites, thresholds Thresholds) []string {
+	tests, failures, errors, skipped := 0, 0, 0, 0
+	ignored := make(map[string]bool)
+	for _, category := range thresholds.IgnoreCategories {
+		ignored[category] = true
+	}
+	for _, suite := range suites.Suites {
+		tests += suite.TestCount()
+		failures += suite.FailureCount()
+		errors += suite.ErrorCount()
+		skipped += suite.SkippedCount()
+		for _, testcase := range suite.TestCases {
+			if !ignored[category(testcase)] {
+				continue
+			}
+			if testcase.Failure != nil {
+				failures--
+			}
+			if testcase.Error != nil {
+				errors--
+			}
+		}
+	}
+	var violations []string
+	if thresholds.MaxFailures >= 0 && failures > thresholds.MaxFailures {
+		violations = append(violations, fmt.Sprintf("%d failures, at most %d allowed", failures, thresholds.MaxFailures))
+	}
+	if thresholds.MaxErrors >= 0 && errors > thresholds.MaxErrors {
+		violations = append(violations, fmt.Sprintf("%d errors, at most %d allowed", errors, thresholds.MaxErrors))
+	}
+	if thresholds.MaxSkipped >= 0 && skipped > thresholds.MaxSkipped {
+		violations = append(violations, fmt.Sprintf("%d skipped tests, at most %d allowed", skipped, thresholds.MaxSkipped))
+	}
+	if thresholds.MinTests >= 0 && tests < thresholds.MinTests {
+		violations = append(violations, fmt.Sprintf("%d tests, at least %d required", tests, thresholds.MinTests))
+	}
+	return violations
+}
+
+// category returns the failure category of a test case, or an empty string if it has none
+func category(testcase junitxml.JUnitTestCase) string {
+	if testcase.Properties == nil {
+		return ""
+	}
+	for _, property := range testcase.Properties.Properties {
+		if property.Name == "category" {
+			return property.Value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,46 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"testing"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestVerify(t *testing.T) {
+	suite := junitxml.JUnitTestSuite{Name: "README.md"}
+	suite.RegisterTestCase(junitxml.JUnitTestCase{Name: "echo Hello"})
+	failed := junitxml.JUnitTestCase{Name: "false"}
+	failed.RegisterFailure("FAILURE", "FAIL (exit code)", "")
+	suite.RegisterTestCase(failed)
+	suites := junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{suite}}
+	require.Equal(t, []string{"1 failures, at most 0 allowed"}, Verify(suites, DefaultThresholds()))
+	thresholds := DefaultThresholds()
+	thresholds.MaxFailures = 1
+	require.Empty(t, Verify(suites, thresholds), "One failure is tolerated")
+	thresholds.MinTests = 10
+	require.Equal(t, []string{"2 tests, at least 10 required"}, Verify(suites, thresholds))
+}
+
+func TestVerifyIgnoreCategories(t *testing.T) {
+	suite := junitxml.JUnitTestSuite{Name: "README.md"}
+	timeout := junitxml.JUnitTestCase{Name: "sleep 600"}
+	timeout.AddProperty("category", "timeout")
+	timeout.RegisterError("ERROR", "ERROR (result not evaluated)", "")
+	suite.RegisterTestCase(timeout)
+	mismatch := junitxml.JUnitTestCase{Name: "echo Hello"}
+	mismatch.AddProperty("category", "output-mismatch")
+	mismatch.RegisterFailure("FAILURE", "FAIL (mismatch)", "")
+	suite.RegisterTestCase(mismatch)
+	suites := junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{suite}}
+	thresholds := DefaultThresholds()
+	thresholds.IgnoreCategories = []string{"timeout"}
+	require.Equal(t, []string{"1 failures, at most 0 allowed"}, Verify(suites, thresholds),
+		"Errors of ignored categories are not counted")
+	thresholds.IgnoreCategories = append(thresholds.IgnoreCategories, "output-mismatch")
+	require.Empty(t, Verify(suites, thresholds))
+}
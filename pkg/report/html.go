@@ -0,0 +1,143 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// SourceURL returns the link to the source line of a test case. The placeholders {file} and {line} in the template
+// are replaced with the path of the Markdown file and the line number of the command, for example
+// https://github.com/mirkoboehm/shelldoc/blob/master/{file}#L{line}. An empty template links to the file itself.
+func SourceURL(urlTemplate string, file string, line int) string {
+	file = filepath.ToSlash(filepath.Clean(file))
+	if len(urlTemplate) == 0 {
+		urlTemplate = "{file}"
+	}
+	return strings.NewReplacer("{file}", file, "{line}", strconv.Itoa(line)).Replace(urlTemplate)
+}
+
+// testCase is the representation of a test case in the report
+type testCase struct {
+	Command string
+	Source  string
+	URL     string
+	Status  string
+	Message string
+	Details string
+	Time    string
+	Expect  string
+	Output  string
+	Errors  string
+	Screen  string
+	RC      string
+}
+
+// testSuite is the representation of a test suite in the report
+type testSuite struct {
+	Name      string
+	Summary   string
+	TestCases []testCase
+	Skipped   []string
+}
+
+const page = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>shelldoc report</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; width: 100%; }
+th, td { border: 1px solid #ccc; padding: 4px; text-align: left; vertical-align: top; }
+.SUCCESS { color: #1a7f37; } .FAILURE, .ERROR { color: #cf222e; } .SKIPPED { color: #6e7781; }
+pre { margin: 0; white-space: pre-wrap; }
+</style>
+</head>
+<body>
+<h1>shelldoc report</h1>
+<p>{{.Summary}}</p>
+{{range .Suites}}<h2>{{.Name}}</h2>
+<p>{{.Summary}}</p>
+<table>
+<tr><th>Command</th><th>Source</th><th>Result</th><th>Time</th></tr>
+{{range .TestCases}}<tr class="{{.Status}}"><td><code>{{.Command}}</code></td><td><a href="{{.URL}}">{{.Source}}</a></td>` +
+	`<td>{{.Status}}{{if .Message}}: {{.Message}}{{end}}{{if .Details}}<pre>{{.Details}}</pre>{{end}}` +
+	`{{if .RC}}<details><summary>exit code {{.RC}}</summary><p>Expected:</p><pre>{{.Expect}}</pre>` +
+	`<p>Output:</p><pre>{{.Output}}</pre>{{if .Errors}}<p>Error output:</p><pre>{{.Errors}}</pre>{{end}}{{if .Screen}}<p>Final screen:</p><pre>{{.Screen}}</pre>{{end}}</details>{{end}}</td><td>{{.Time}}s</td></tr>
+{{end}}</table>
+{{if .Skipped}}<p>Code blocks that were not executed:</p>
+<ul>{{range .Skipped}}<li>{{.}}</li>{{end}}</ul>
+{{end}}{{end}}</body>
+</html>
+`
+
+var pageTemplate = template.Must(template.New("report").Parse(page))
+
+// WriteHTML writes the test results as an HTML report. Every test case links to its source line using the URL
+// template, see SourceURL.
+func WriteHTML(writer io.Writer, suites junitxml.JUnitTestSuites, urlTemplate string) error {
+	summary := func(tests, failures, errors, skipped int) string {
+		return fmt.Sprintf("%d tests - %d successful, %d failures, %d errors, %d skipped", tests,
+			tests-failures-errors-skipped, failures, errors, skipped)
+	}
+	var data struct {
+		Summary string
+		Suites  []testSuite
+	}
+	var tests, failures, errors, skipped int
+	for _, suite := range suites.Suites {
+		entry := testSuite{Name: suite.Name}
+		for _, property := range suite.Properties {
+			if strings.HasPrefix(property.Name, "skipped-block.") {
+				entry.Skipped = append(entry.Skipped, property.Value)
+			}
+		}
+		for _, test := range suite.TestCases {
+			file := test.File
+			if len(file) == 0 {
+				file = test.Classname
+			}
+			source := file
+			if test.Line > 0 {
+				source = fmt.Sprintf("%s:%d", file, test.Line)
+			}
+			item := testCase{Command: test.Name, Source: source, URL: SourceURL(urlTemplate, file, test.Line),
+				Status: "SUCCESS", Time: test.Time}
+			item.Expect, _ = test.Property("expected")
+			item.RC, _ = test.Property("exit-code")
+			item.Output = test.SystemOut
+			item.Errors = test.SystemErr
+			item.Screen, _ = test.Property("screen")
+			switch {
+			case test.Failure != nil:
+				item.Status, item.Message, item.Details = "FAILURE", test.Failure.Message, test.Failure.Contents
+			case test.Error != nil:
+				item.Status, item.Message, item.Details = "ERROR", test.Error.Message, test.Error.Contents
+			case test.SkipMessage != nil:
+				item.Status, item.Message = "SKIPPED", test.SkipMessage.Message
+			}
+			entry.TestCases = append(entry.TestCases, item)
+		}
+		entry.Summary = summary(suite.TestCount(), suite.FailureCount(), suite.ErrorCount(), suite.SkippedCount())
+		tests += suite.TestCount()
+		failures += suite.FailureCount()
+		errors += suite.ErrorCount()
+		skipped += suite.SkippedCount()
+		data.Suites = append(data.Suites, entry)
+	}
+	data.Summary = summary(tests, failures, errors, skipped)
+	if err := pageTemplate.Execute(writer, data); err != nil {
+		return fmt.Errorf("unable to write HTML report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSourceURL(t *testing.T) {
+	const github = "https://github.com/mirkoboehm/shelldoc/blob/master/{file}#L{line}"
+	require.Equal(t, "https://github.com/mirkoboehm/shelldoc/blob/master/docs/README.md#L42", SourceURL(github, "./docs/README.md", 42))
+	require.Equal(t, "docs/README.md", SourceURL("", "docs/README.md", 42), "Without template, the file is linked")
+}
+
+func TestWriteHTML(t *testing.T) {
+	suite := junitxml.JUnitTestSuite{Name: "README.md"}
+	suite.AddProperty("skipped-block.1", "line=7 block=1 language=json reason=no-commands")
+	suite.RegisterTestCase(junitxml.JUnitTestCase{Name: "echo Hello", File: "README.md", Line: 19})
+	failed := junitxml.JUnitTestCase{Name: "echo <b>", File: "README.md", Line: 23}
+	failed.RegisterFailure("FAILURE", "FAIL (mismatch)", "got: \"<b>\", want: \"<i>\"")
+	failed.AddProperty("expected", "<i>")
+	failed.AddProperty("exit-code", "0")
+	failed.SystemOut = "<b>"
+	suite.RegisterTestCase(failed)
+	redrawn := junitxml.JUnitTestCase{Name: "./progress", File: "README.md", Line: 27}
+	redrawn.RegisterFailure("FAILURE", "FAIL (mismatch)", "")
+	redrawn.AddProperty("exit-code", "0")
+	redrawn.AddProperty("screen", "100%")
+	redrawn.SystemOut = "10%\r100%"
+	redrawn.SystemErr = "warning: slow"
+	suite.RegisterTestCase(redrawn)
+	var html bytes.Buffer
+	err := WriteHTML(&html, junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{suite}}, "https://example.com/{file}#L{line}")
+	require.NoError(t, err, "Writing the report should work")
+	require.Contains(t, html.String(), `<a href="https://example.com/README.md#L23">README.md:23</a>`, "Test cases link to their source line")
+	require.Contains(t, html.String(), "<code>echo &lt;b&gt;</code>", "Commands are escaped")
+	require.Contains(t, html.String(), "<summary>exit code 0</summary><p>Expected:</p><pre>&lt;i&gt;</pre><p>Output:</p><pre>&lt;b&gt;</pre>",
+		"The report contains the expected response and the actual output")
+	require.Contains(t, html.String(), "<p>Error output:</p><pre>warning: slow</pre>", "The error output is shown")
+	require.Contains(t, html.String(), "<p>Final screen:</p><pre>100%</pre>", "The final screen of failed tests is shown")
+	require.Contains(t, html.String(), "<li>line=7 block=1 language=json reason=no-commands</li>", "Skipped code blocks are listed")
+	require.Contains(t, html.String(), "3 tests - 1 successful, 2 failures, 0 errors, 0 skipped", "The report contains a summary")
+}
@@ -0,0 +1,109 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// SchemaVersion is the version of the structure of the JSON report, see report.schema.json. The minor version is
+// incremented when fields are added, the major version when fields are removed, renamed or change their meaning.
+const SchemaVersion = "1.2"
+
+// jsonReport is the top-level object of the JSON report
+type jsonReport struct {
+	SchemaVersion string     `json:"schemaVersion"`
+	Summary       jsonCounts `json:"summary"`
+	Files         []jsonFile `json:"files"`
+}
+
+// jsonCounts contains the number of tests per result
+type jsonCounts struct {
+	Tests     int     `json:"tests"`
+	Successes int     `json:"successes"`
+	Failures  int     `json:"failures"`
+	Errors    int     `json:"errors"`
+	Skipped   int     `json:"skipped"`
+	Time      float64 `json:"time"`
+}
+
+// jsonFile contains the results of the tests in one Markdown file
+type jsonFile struct {
+	Name       string            `json:"name"`
+	Summary    jsonCounts        `json:"summary"`
+	Properties map[string]string `json:"properties"`
+	Tests      []jsonTest        `json:"tests"`
+}
+
+// jsonTest contains the result of one test
+type jsonTest struct {
+	ID         string            `json:"id"`
+	Command    string            `json:"command"`
+	File       string            `json:"file"`
+	Line       int               `json:"line"`
+	Status     string            `json:"status"`
+	Category   string            `json:"category"`
+	Message    string            `json:"message"`
+	Details    string            `json:"details"`
+	Time       float64           `json:"time"`
+	Output     string            `json:"output"`
+	Errors     string            `json:"errorOutput"`
+	Properties map[string]string `json:"properties"`
+}
+
+// WriteJSON writes the test results as a JSON report that conforms to report.schema.json.
+func WriteJSON(writer io.Writer, suites junitxml.JUnitTestSuites) error {
+	report := jsonReport{SchemaVersion: SchemaVersion, Files: []jsonFile{}}
+	for _, suite := range suites.Suites {
+		file := jsonFile{Name: suite.Name, Properties: map[string]string{}, Tests: []jsonTest{}}
+		for _, property := range suite.Properties {
+			file.Properties[property.Name] = property.Value
+		}
+		for _, testcase := range suite.TestCases {
+			test := jsonTest{ID: testcase.ID, Command: testcase.Name, File: testcase.File, Line: testcase.Line,
+				Status: "success", Output: testcase.SystemOut, Errors: testcase.SystemErr, Properties: map[string]string{}}
+			test.Time, _ = strconv.ParseFloat(testcase.Time, 64)
+			if len(test.File) == 0 {
+				test.File = suite.Name
+			}
+			if testcase.Properties != nil {
+				for _, property := range testcase.Properties.Properties {
+					test.Properties[property.Name] = property.Value
+				}
+			}
+			test.Category = test.Properties["category"]
+			switch {
+			case testcase.Failure != nil:
+				test.Status, test.Message, test.Details = "failure", testcase.Failure.Message, testcase.Failure.Contents
+			case testcase.Error != nil:
+				test.Status, test.Message, test.Details = "error", testcase.Error.Message, testcase.Error.Contents
+			case testcase.SkipMessage != nil:
+				test.Status, test.Message = "skipped", testcase.SkipMessage.Message
+			}
+			file.Tests = append(file.Tests, test)
+		}
+		file.Summary = jsonCounts{Tests: suite.TestCount(), Successes: suite.SuccessCount(), Failures: suite.FailureCount(),
+			Errors: suite.ErrorCount(), Skipped: suite.SkippedCount()}
+		file.Summary.Time, _ = strconv.ParseFloat(suite.Time, 64)
+		report.Summary.Tests += file.Summary.Tests
+		report.Summary.Successes += file.Summary.Successes
+		report.Summary.Failures += file.Summary.Failures
+		report.Summary.Errors += file.Summary.Errors
+		report.Summary.Skipped += file.Summary.Skipped
+		report.Summary.Time += file.Summary.Time
+		report.Files = append(report.Files, file)
+	}
+	encoder := json.NewEncoder(writer)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(report); err != nil {
+		return fmt.Errorf("unable to write JSON report: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteJSON(t *testing.T) {
+	suite := junitxml.JUnitTestSuite{Name: "README.md", Time: "0.500"}
+	suite.AddProperty("shelldoc-version", "v1.0")
+	passed := junitxml.JUnitTestCase{Name: "echo Hello", ID: "a", File: "README.md", Line: 19, Time: "0.250", SystemOut: "Hello"}
+	passed.AddProperty("exit-code", "0")
+	suite.RegisterTestCase(passed)
+	failed := junitxml.JUnitTestCase{Name: "echo No", Line: 23}
+	failed.AddProperty("category", "output-mismatch")
+	failed.RegisterFailure("FAILURE", "FAIL (mismatch)", `got: "No", want: "Yes"`)
+	suite.RegisterTestCase(failed)
+	var output bytes.Buffer
+	require.NoError(t, WriteJSON(&output, junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{suite}}))
+	var report map[string]interface{}
+	require.NoError(t, json.Unmarshal(output.Bytes(), &report), "The report is valid JSON")
+	require.Equal(t, SchemaVersion, report["schemaVersion"], "The report contains the schema version")
+
+	// the report contains exactly the fields required by the published schema
+	data, err := ioutil.ReadFile("report.schema.json")
+	require.NoError(t, err, "Unable to read the schema")
+	var schema struct {
+		Required []string `json:"required"`
+		Defs     map[string]struct {
+			Required []string `json:"required"`
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(data, &schema), "The schema is valid JSON")
+	keys := func(object interface{}) []string {
+		var result []string
+		for key := range object.(map[string]interface{}) {
+			result = append(result, key)
+		}
+		return result
+	}
+	file := report["files"].([]interface{})[0]
+	test := file.(map[string]interface{})["tests"].([]interface{})[1]
+	require.ElementsMatch(t, schema.Required, keys(report))
+	require.ElementsMatch(t, schema.Defs["counts"].Required, keys(report["summary"]))
+	require.ElementsMatch(t, schema.Defs["file"].Required, keys(file))
+	require.ElementsMatch(t, schema.Defs["test"].Required, keys(test))
+	require.Equal(t, "failure", test.(map[string]interface{})["status"])
+	require.Equal(t, "output-mismatch", test.(map[string]interface{})["category"], "The failure category is reported")
+	require.Equal(t, "README.md", test.(map[string]interface{})["file"], "Tests without a file use the suite name")
+}
@@ -0,0 +1,74 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// escapeCell makes text safe to use in the cell of a Markdown table
+func escapeCell(text string) string {
+	return strings.NewReplacer("|", `\|`, "\n", " ").Replace(text)
+}
+
+// codeSpan returns text as inline code, using a delimiter that does not occur in it
+func codeSpan(text string) string {
+	delimiter := "`"
+	for strings.Contains(text, delimiter) {
+		delimiter += "`"
+	}
+	return delimiter + " " + escapeCell(text) + " " + delimiter
+}
+
+// WriteMarkdownSummary writes a summary of the test results in Markdown format, for example for the job summaries
+// of CI systems. It contains a table with the results per file and lists the failed tests.
+func WriteMarkdownSummary(writer io.Writer, suites junitxml.JUnitTestSuites) error {
+	var text strings.Builder
+	text.WriteString("## shelldoc results\n\n")
+	text.WriteString("| File | Tests | Pass | Fail | Error | Skipped | Time |\n")
+	text.WriteString("|------|------:|-----:|-----:|------:|--------:|-----:|\n")
+	var tests, successes, failures, errors, skipped int
+	var elapsed float64
+	var problems []string
+	for _, suite := range suites.Suites {
+		seconds, _ := strconv.ParseFloat(suite.Time, 64)
+		fmt.Fprintf(&text, "| %s | %d | %d | %d | %d | %d | %.3fs |\n", escapeCell(suite.Name), suite.TestCount(),
+			suite.SuccessCount(), suite.FailureCount(), suite.ErrorCount(), suite.SkippedCount(), seconds)
+		tests += suite.TestCount()
+		successes += suite.SuccessCount()
+		failures += suite.FailureCount()
+		errors += suite.ErrorCount()
+		skipped += suite.SkippedCount()
+		elapsed += seconds
+		for _, testcase := range suite.TestCases {
+			message := ""
+			if testcase.Failure != nil {
+				message = testcase.Failure.Message
+			} else if testcase.Error != nil {
+				message = testcase.Error.Message
+			} else {
+				continue
+			}
+			file := testcase.File
+			if len(file) == 0 {
+				file = suite.Name
+			}
+			problems = append(problems, fmt.Sprintf("- %s:%d: %s %s\n", escapeCell(file), testcase.Line,
+				codeSpan(testcase.Name), escapeCell(message)))
+		}
+	}
+	fmt.Fprintf(&text, "| **Total** | %d | %d | %d | %d | %d | %.3fs |\n", tests, successes, failures, errors, skipped, elapsed)
+	if len(problems) > 0 {
+		text.WriteString("\n### Failed tests\n\n")
+		text.WriteString(strings.Join(problems, ""))
+	}
+	_, err := io.WriteString(writer, text.String())
+	return err
+}
@@ -0,0 +1,63 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteMarkdownSummary(t *testing.T) {
+	suite := junitxml.JUnitTestSuite{Name: "README.md", Time: "0.500"}
+	suite.RegisterTestCase(junitxml.JUnitTestCase{Name: "echo Hello", Line: 19})
+	failed := junitxml.JUnitTestCase{Name: "echo `No` | cat", Line: 23}
+	failed.RegisterFailure("FAILURE", "FAIL (mismatch)", `got: "No", want: "Yes"`)
+	suite.RegisterTestCase(failed)
+	var output bytes.Buffer
+	require.NoError(t, WriteMarkdownSummary(&output, junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{suite}}))
+	summary := output.String()
+	require.Contains(t, summary, "| README.md | 2 | 1 | 1 | 0 | 0 | 0.500s |", "The table contains a row per file.")
+	require.Contains(t, summary, "| **Total** | 2 | 1 | 1 | 0 | 0 | 0.500s |", "The table contains the totals.")
+	require.Contains(t, summary, "- README.md:23: `` echo `No` \\| cat `` FAIL (mismatch)", "Failed tests are listed.")
+}
+
+func TestWriteAll(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-report-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	suites := junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{{Name: "README.md"}}}
+	outputs := []Output{
+		{Format: "JSON", Path: filepath.Join(directory, "results.json"), Write: WriteJSON},
+		{Format: "Markdown summary", Path: filepath.Join(directory, "missing", "summary.md"), Write: WriteMarkdownSummary},
+		{Format: "Markdown summary", Path: filepath.Join(directory, "summary.md"), Write: WriteMarkdownSummary},
+	}
+	err = WriteAll(outputs, suites)
+	require.Error(t, err, "The output in the missing directory cannot be written.")
+	require.Contains(t, err.Error(), "Markdown summary", "The error names the format.")
+	for _, name := range []string{"results.json", "summary.md"} {
+		_, err := os.Stat(filepath.Join(directory, name))
+		require.NoError(t, err, "The other outputs are written anyway.")
+	}
+}
+
+func TestPrepareAll(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-report-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "missing", "summary.md")
+	outputs := []Output{{Format: "Markdown summary", Path: path, Write: WriteMarkdownSummary}}
+	require.NoError(t, PrepareAll(outputs), "The file and its directory are created.")
+	require.NoError(t, WriteAll(outputs, junitxml.JUnitTestSuites{}), "The prepared output can be written.")
+	outputs[0].Path = filepath.Join(path, "summary.md")
+	err = PrepareAll(outputs)
+	require.Error(t, err, "The output below a file cannot be written.")
+	require.Contains(t, err.Error(), "Markdown summary", "The error names the format.")
+}
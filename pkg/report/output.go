@@ -0,0 +1,81 @@
+package report
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// Writer writes the test results in one output format
+type Writer func(writer io.Writer, suites junitxml.JUnitTestSuites) error
+
+// Output is a report file in a specific format
+type Output struct {
+	// Format names the format in error messages, like XML or HTML
+	Format string
+	// Path is the file the report is written to
+	Path string
+	// Write writes the report
+	Write Writer
+}
+
+// WriteAll writes the test results to all outputs. An error in one output does not prevent the others from being
+// written, the errors are combined.
+func WriteAll(outputs []Output, suites junitxml.JUnitTestSuites) error {
+	var problems []string
+	for _, output := range outputs {
+		if err := output.write(suites); err != nil {
+			problems = append(problems, err.Error())
+		}
+	}
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// PrepareAll checks that all output files can be written before the tests are executed, so that a wrong path does
+// not waste a complete run. The files and their parent directories are created if they do not exist, existing files
+// are not modified.
+func PrepareAll(outputs []Output) error {
+	for _, output := range outputs {
+		if err := output.prepare(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prepare creates the output file and its parent directories if they do not exist
+func (output Output) prepare() error {
+	if err := os.MkdirAll(filepath.Dir(output.Path), 0755); err != nil {
+		return fmt.Errorf("unable to create the directory of the %s output file: %v", output.Format, err)
+	}
+	file, err := os.OpenFile(output.Path, os.O_WRONLY|os.O_CREATE, 0644)
+	if err != nil {
+		return fmt.Errorf("unable to open %s output file for writing: %v", output.Format, err)
+	}
+	return file.Close()
+}
+
+// write creates the output file and writes the test results to it
+func (output Output) write(suites junitxml.JUnitTestSuites) error {
+	file, err := os.Create(output.Path)
+	if err != nil {
+		return fmt.Errorf("unable to open %s output file for writing: %v", output.Format, err)
+	}
+	defer file.Close()
+	if err := output.Write(file, suites); err != nil {
+		return fmt.Errorf("error writing %s output file: %v", output.Format, err)
+	}
+	return nil
+}
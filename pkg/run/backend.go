@@ -0,0 +1,86 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+)
+
+// validateBackend checks that at most one way to start the shell is selected
+func validateBackend(options Options) error {
+	var selected []string
+	if len(options.DockerImage) > 0 {
+		selected = append(selected, "--docker")
+	}
+	if len(options.EnterCommand) > 0 {
+		selected = append(selected, "--enter")
+	}
+	if len(options.SSHDestination) > 0 {
+		selected = append(selected, "--ssh")
+	}
+	if options.Tmux {
+		selected = append(selected, "--tmux")
+		if _, _, err := parseTerminalSize(options.TerminalSize); err != nil {
+			return err
+		}
+	}
+	if len(selected) > 1 {
+		return fmt.Errorf("the shell can only be started in one way, use only one of %s", strings.Join(selected, ", "))
+	}
+	if len(options.SSHDestination) > 0 && len(options.FixturesDir) > 0 {
+		return fmt.Errorf("fixtures cannot be used on a remote machine")
+	}
+	return nil
+}
+
+// backend returns the backend that starts the shell, nil to start it on the host, and records it in the properties
+// of the test suite
+func (context *Context) backend(suite *junitxml.JUnitTestSuite) (shell.Backend, error) {
+	switch {
+	case len(context.options.DockerImage) > 0:
+		return shell.Docker{Image: context.options.DockerImage}, nil
+	case len(context.options.SSHDestination) > 0:
+		return shell.SSH{Destination: context.options.SSHDestination}, nil
+	case context.options.Tmux:
+		width, height, err := parseTerminalSize(context.options.TerminalSize)
+		if err != nil {
+			return nil, err
+		}
+		suite.AddProperty("terminal", fmt.Sprintf("tmux %dx%d", width, height))
+		return shell.Tmux{Width: width, Height: height}, nil
+	case len(context.options.EnterCommand) > 0:
+		wrapper, err := shell.NewWrapper(context.options.EnterCommand)
+		if err != nil {
+			return nil, err
+		}
+		suite.AddProperty("enter", context.options.EnterCommand)
+		return wrapper, nil
+	default:
+		return nil, nil
+	}
+}
+
+// parseTerminalSize reads a terminal size like 80x24, an empty size selects the default size
+func parseTerminalSize(size string) (width, height int, err error) {
+	if len(size) == 0 {
+		return shell.DefaultTerminalWidth, shell.DefaultTerminalHeight, nil
+	}
+	parts := strings.Split(strings.ToLower(size), "x")
+	if len(parts) == 2 {
+		width, err = strconv.Atoi(parts[0])
+		if err == nil {
+			height, err = strconv.Atoi(parts[1])
+		}
+		if err == nil && width > 0 && height > 0 {
+			return width, height, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid terminal size \"%s\", expected COLUMNSxLINES like 80x24", size)
+}
@@ -0,0 +1,107 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// backgroundLogLines is the number of lines of the output of a background process that are shown if it stopped
+const backgroundLogLines = 10
+
+// backgroundProcess is a process started by a code block with the background option
+type backgroundProcess struct {
+	pid     int
+	logfile string
+}
+
+// backgroundProcesses tracks the processes started in the background while a file is executed, by name
+type backgroundProcesses struct {
+	processes map[string][]backgroundProcess
+}
+
+func newBackgroundProcesses() *backgroundProcesses {
+	return &backgroundProcesses{processes: make(map[string][]backgroundProcess)}
+}
+
+// start starts the command of the interaction in the background, with its output written to a temporary log file
+func (b *backgroundProcesses) start(sh *shell.Shell, interaction *tokenizer.Interaction) error {
+	name := strings.TrimSpace(interaction.Attributes[tokenizer.BackgroundOption])
+	if len(name) == 0 {
+		return fmt.Errorf("%s needs a name", tokenizer.BackgroundOption)
+	}
+	file, err := ioutil.TempFile("", "shelldoc-background-")
+	if err != nil {
+		return fmt.Errorf("unable to create log file for background process %s: %v", name, err)
+	}
+	file.Close()
+	pid, err := interaction.ExecuteInBackground(sh, file.Name())
+	if err != nil {
+		os.Remove(file.Name())
+		return err
+	}
+	b.processes[name] = append(b.processes[name], backgroundProcess{pid: pid, logfile: file.Name()})
+	return nil
+}
+
+// check verifies that the background processes the code block of the interaction needs are running
+func (b *backgroundProcesses) check(sh *shell.Shell, interaction *tokenizer.Interaction) error {
+	for _, name := range strings.Split(interaction.Attributes[tokenizer.RunningOption], ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		processes, ok := b.processes[name]
+		if !ok {
+			return fmt.Errorf("no background process named %s has been started", name)
+		}
+		for _, process := range processes {
+			if !sh.Running(process.pid) {
+				return fmt.Errorf("the background process %s (PID %d) is not running, its last output was:\n%s", name,
+					process.pid, process.tail())
+			}
+		}
+	}
+	return nil
+}
+
+// tail returns the last lines of the output of the process
+func (process backgroundProcess) tail() string {
+	data, err := ioutil.ReadFile(process.logfile)
+	if err != nil {
+		return err.Error()
+	}
+	lines := strings.Split(strings.TrimRight(string(data), "\n"), "\n")
+	if len(lines) > backgroundLogLines {
+		lines = lines[len(lines)-backgroundLogLines:]
+	}
+	return strings.Join(lines, "\n")
+}
+
+// terminateBackground stops all background processes and removes their log files
+func (context *Context) terminateBackground(sh *shell.Shell, b *backgroundProcesses) {
+	var names []string
+	for name := range b.processes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, process := range b.processes[name] {
+			if context.options.Verbose {
+				fmt.Printf(" --  terminating background process %s (PID %d), its last output was:\n%s\n", name,
+					process.pid, process.tail())
+			}
+			sh.Terminate(process.pid)
+			os.Remove(process.logfile)
+		}
+	}
+}
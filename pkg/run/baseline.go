@@ -0,0 +1,110 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// baselineEntry identifies a failing interaction by the file and its ID, which does not change when lines are added or
+// removed elsewhere in the file (see tokenizer.AssignIDs). The command makes the baseline file readable.
+type baselineEntry struct {
+	File    string `json:"file"`
+	ID      string `json:"id"`
+	Command string `json:"command"`
+}
+
+// key returns the part of the entry that identifies the interaction
+func (entry baselineEntry) key() baselineEntry {
+	return baselineEntry{File: filepath.Clean(entry.File), ID: entry.ID}
+}
+
+// baseline contains the accepted failures of a previous run, and collects the failures of the current run
+type baseline struct {
+	Failures []baselineEntry `json:"failures"`
+	known    map[baselineEntry]bool
+	current  []baselineEntry
+	files    map[string]bool
+	// capture accepts all failures of the current run, to write a new baseline
+	capture bool
+}
+
+// readBaseline reads the baseline file at path. If update is true, all failures of the current run are accepted and
+// later written as the new baseline, and the file does not need to exist yet.
+func readBaseline(path string, update bool) (*baseline, error) {
+	result := &baseline{capture: update, known: make(map[baselineEntry]bool), files: make(map[string]bool)}
+	data, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) && update {
+		return result, nil
+	} else if os.IsNotExist(err) {
+		return nil, fmt.Errorf("baseline file %s does not exist, create it using --update-baseline", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("unable to read baseline file: %v", err)
+	}
+	if err := json.Unmarshal(data, result); err != nil {
+		return nil, fmt.Errorf("unable to parse baseline file %s: %v", path, err)
+	}
+	for _, entry := range result.Failures {
+		result.known[entry.key()] = true
+	}
+	return result, nil
+}
+
+// registerFile records that the specified file has been tested in the current run
+func (b *baseline) registerFile(file string) {
+	if b != nil {
+		b.files[filepath.Clean(file)] = true
+	}
+}
+
+// registerFailure records a failure of the current run and returns true if it is a known failure
+func (b *baseline) registerFailure(file string, id string, command string) bool {
+	if b == nil {
+		return false
+	}
+	entry := baselineEntry{filepath.Clean(file), id, command}
+	b.current = append(b.current, entry)
+	return b.capture || b.known[entry.key()]
+}
+
+// detached returns a baseline that accepts the same failures, but does not record the failures of the run
+func (b *baseline) detached() *baseline {
+	if b == nil {
+		return nil
+	}
+	return &baseline{Failures: b.Failures, known: b.known, files: make(map[string]bool), capture: b.capture}
+}
+
+// fixed returns the known failures in the tested files that did not fail in the current run
+func (b *baseline) fixed() []baselineEntry {
+	failing := make(map[baselineEntry]bool)
+	for _, entry := range b.current {
+		failing[entry.key()] = true
+	}
+	var result []baselineEntry
+	for _, entry := range b.Failures {
+		entry.File = filepath.Clean(entry.File)
+		if b.files[entry.File] && !failing[entry.key()] {
+			result = append(result, entry)
+		}
+	}
+	return result
+}
+
+// write stores the failures of the current run as the new baseline
+func (b *baseline) write(path string) error {
+	data, err := json.MarshalIndent(baseline{Failures: b.current}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("unable to encode baseline: %v", err)
+	}
+	if err := ioutil.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("unable to write baseline file: %v", err)
+	}
+	return nil
+}
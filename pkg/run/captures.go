@@ -0,0 +1,32 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// exportCaptures sets the values of the named capture groups of the expected response of the interaction as
+// variables in the shell, so that the following commands can use values from the output, like generated IDs
+func (context *Context) exportCaptures(sh *shell.Shell, interaction *tokenizer.Interaction) error {
+	if len(interaction.Captures) == 0 {
+		return nil
+	}
+	if context.options.Verbose {
+		var names []string
+		for name := range interaction.Captures {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf(" --  captured %s=%s\n", name, interaction.Captures[name])
+		}
+	}
+	return sh.Export(interaction.Captures)
+}
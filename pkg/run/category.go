@@ -0,0 +1,53 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// parseCategories reads the names of the failure categories that do not affect the return code
+func parseCategories(names []string) (map[tokenizer.Category]bool, error) {
+	categories := make(map[tokenizer.Category]bool)
+	for _, name := range names {
+		category, err := tokenizer.ParseCategory(name)
+		if err != nil {
+			return nil, err
+		}
+		categories[category] = true
+	}
+	return categories, nil
+}
+
+// tolerates returns true if failures of the category do not affect the return code, see --tolerate
+func (context *Context) tolerates(category tokenizer.Category) bool {
+	return category != tokenizer.CategoryNone && context.tolerated[category]
+}
+
+// problemType returns the type of the failure or error of a test case, TOLERATED if the category is tolerated
+func (context *Context) problemType(failuretype string, category tokenizer.Category) string {
+	if context.tolerates(category) {
+		return "TOLERATED"
+	}
+	return failuretype
+}
+
+// registerProblem registers the return code for a command that was not executed because of a problem of the
+// category. It returns a marker for the result if the category is tolerated.
+func (context *Context) registerProblem(code int, category tokenizer.Category) string {
+	if context.tolerates(category) {
+		return " [tolerated]"
+	}
+	context.registerFailure(code)
+	return ""
+}
+
+// addCategory records the failure category of the interaction in the properties of the test case
+func addCategory(testcase *junitxml.JUnitTestCase, category tokenizer.Category) {
+	if category != tokenizer.CategoryNone {
+		testcase.AddProperty("category", category.String())
+	}
+}
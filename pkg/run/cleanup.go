@@ -0,0 +1,73 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// cleanupTimeout is the time a cleanup command may take (scaled by the timeout multiplier)
+const cleanupTimeout = time.Minute
+
+// cleanup is the cleanup command of a code block whose commands have been executed
+type cleanup struct {
+	block   int
+	command string
+}
+
+// pendingCleanup returns the cleanup command of the code block of the interaction, or nil if it has none
+func pendingCleanup(interaction *tokenizer.Interaction) *cleanup {
+	command := strings.TrimSpace(interaction.Attributes[tokenizer.CleanupOption])
+	if len(command) == 0 {
+		return nil
+	}
+	return &cleanup{block: interaction.Block, command: command}
+}
+
+// runCleanup executes the cleanup command of a code block after its commands, no matter whether they succeeded. If
+// the shell has been stopped, for example because the time budget was exhausted, the command is executed in a new
+// shell. A failing cleanup command is reported, but does not affect the results. A cleanup command that is denied by
+// the command policy is not executed, and recorded as a policy violation in the properties of the test suite.
+func (context *Context) runCleanup(suite *junitxml.JUnitTestSuite, sh *shell.Shell, shellpath string, options shell.Options,
+	pending *cleanup) {
+	if pending == nil {
+		return
+	}
+	if reason := context.policy.check(pending.command); len(reason) > 0 {
+		suite.AddProperty(fmt.Sprintf("policy-violation.cleanup.%d", pending.block),
+			fmt.Sprintf("%s: %s", pending.command, reason))
+		problem := context.registerProblem(returnFailure, tokenizer.CategoryPolicyViolation)
+		fmt.Printf(" --  ERROR: cleanup of code block %d not executed, command %s%s\n", pending.block, reason, problem)
+		return
+	}
+	if context.options.Verbose {
+		fmt.Printf(" --  cleanup of code block %d: %s\n", pending.block, pending.command)
+	}
+	deadline := sh.Deadline()
+	sh.SetDeadline(time.Now().Add(context.scaleTimeout(cleanupTimeout)))
+	output, rc, err := sh.ExecuteCommand(pending.command)
+	sh.SetDeadline(deadline)
+	if err != nil {
+		// the shell is gone, start a new one for the cleanup
+		var fresh shell.Shell
+		if fresh, err = shell.StartShellWithOptions(shellpath, options); err == nil {
+			fresh.SetDeadline(time.Now().Add(context.scaleTimeout(cleanupTimeout)))
+			output, rc, err = fresh.ExecuteCommand(pending.command)
+			fresh.Exit()
+		}
+	}
+	if err != nil {
+		fmt.Printf(" --  WARNING: cleanup of code block %d failed: %v\n", pending.block, err)
+	} else if rc != 0 {
+		fmt.Printf(" --  WARNING: cleanup of code block %d exited with exit code %d: %s\n", pending.block, rc,
+			strings.Join(output, " "))
+	}
+}
@@ -0,0 +1,135 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"time"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// Config contains the settings read from a shelldoc configuration file in JSON format.
+type Config struct {
+	// Files contains settings for individual input files, keyed by file name
+	Files map[string]FileConfig `json:"files"`
+	// Redactions replace volatile text in the output of all commands with placeholders, in order
+	Redactions []RedactionRule `json:"redactions"`
+	// Responders answer prompts of all commands automatically, after the rules of their code block
+	Responders []ResponderRule `json:"responders"`
+	// Profiles contains named sets of settings, selected using --profile
+	Profiles map[string]Profile `json:"profiles"`
+	// Warnings configures the patterns of warnings that are counted in the output of all commands, and their budget
+	Warnings WarningConfig `json:"warnings"`
+	// redactions contains the compiled redaction rules
+	redactions []tokenizer.Redaction
+	// responders contains the compiled responder rules
+	responders []shell.Responder
+}
+
+// RedactionRule is a regular expression and the placeholder that replaces its matches, for example
+// {"pattern": "[0-9a-f]{8}(-[0-9a-f]{4}){3}-[0-9a-f]{12}", "replacement": "<UUID>"}
+type RedactionRule struct {
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// ResponderRule is a regular expression that matches a prompt and the reply that is sent to the command, for example
+// {"pattern": "\\[y/N\\]", "reply": "y"}
+type ResponderRule struct {
+	Pattern string `json:"pattern"`
+	Reply   string `json:"reply"`
+}
+
+// FileConfig contains the settings for an individual input file.
+type FileConfig struct {
+	// Budget is the time available for testing the file, after which the remaining interactions are skipped
+	Budget Duration `json:"budget"`
+}
+
+// Duration is a time.Duration that is represented as a string like "1m30s" in the configuration file.
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalJSON parses a duration string.
+func (duration *Duration) UnmarshalJSON(data []byte) error {
+	var value string
+	if err := json.Unmarshal(data, &value); err != nil {
+		return fmt.Errorf("durations need to be specified as strings like \"30s\": %v", err)
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return err
+	}
+	duration.Duration = parsed
+	return nil
+}
+
+// MarshalJSON writes the duration as a string.
+func (duration Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(duration.String())
+}
+
+// ReadConfig reads the configuration file at path.
+func ReadConfig(path string) (*Config, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read configuration file: %v", err)
+	}
+	config := &Config{}
+	if err := json.Unmarshal(data, config); err != nil {
+		return nil, fmt.Errorf("unable to parse configuration file %s: %v", path, err)
+	}
+	for _, rule := range config.Redactions {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redaction pattern in configuration file %s: %v", path, err)
+		}
+		config.redactions = append(config.redactions, tokenizer.Redaction{Pattern: pattern, Replacement: rule.Replacement})
+	}
+	for _, rule := range config.Responders {
+		pattern, err := regexp.Compile(rule.Pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid responder pattern in configuration file %s: %v", path, err)
+		}
+		config.responders = append(config.responders, shell.Responder{Pattern: pattern, Reply: rule.Reply})
+	}
+	return config, nil
+}
+
+// fileConfig returns the settings for the specified input file
+func (config *Config) fileConfig(file string) FileConfig {
+	if config == nil {
+		return FileConfig{}
+	}
+	for name, settings := range config.Files {
+		if filepath.Clean(name) == filepath.Clean(file) {
+			return settings
+		}
+	}
+	return FileConfig{}
+}
+
+// redactionRules returns the compiled redaction rules
+func (config *Config) redactionRules() []tokenizer.Redaction {
+	if config == nil {
+		return nil
+	}
+	return config.redactions
+}
+
+// responderRules returns the compiled responder rules
+func (config *Config) responderRules() []shell.Responder {
+	if config == nil {
+		return nil
+	}
+	return config.responders
+}
@@ -1,65 +1,395 @@
 package run
 
 import (
+	"bytes"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
 
+	"github.com/mirkoboehm/shelldoc/pkg/cast"
+	"github.com/mirkoboehm/shelldoc/pkg/history"
 	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/patch"
+	"github.com/mirkoboehm/shelldoc/pkg/report"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
 )
 
-// Context contains the context of an execution of the run subcommand.
+// Context contains the context of an execution of the run subcommand: the options, which are resolved when the run
+// starts and do not change while it executes, and the results. Contexts are created using NewContext, and execute
+// only one run. The results may be registered concurrently.
 type Context struct {
-	// input (configuration) variables
-	ShellName     string
-	Verbose       bool
-	FailureStops  bool
-	XMLOutputFile string
-	ReplaceDots   bool
-	Files         []string
+	options Options
 	// output variables
-	Suites     junitxml.JUnitTestSuites
-	returnCode int
+	Suites       junitxml.JUnitTestSuites
+	mutex        sync.Mutex
+	returnCode   int
+	failureCount int
+	staleCount   int
+	quarantine   *quarantine
+	policy       *policy
+	fixes        *fixes
+	baseline     *baseline
+	transcript   *shell.Transcript
+	stdin        []byte
+	stdinName    string
+	recorder     *cast.Recorder
+	environment  []string
+	interrupts   *interrupts
+	tolerated    map[tokenizer.Category]bool
+	warnings     *warningBudget
+	locale       string
+	metadata     []junitxml.JUnitProperty
+	seed         int64
+	// prepared is set when the options have been resolved, a context executes only one run
+	prepared bool
 }
 
 // RegisterReturnCode registers a potential error. The return code can never decrease.
 func (context *Context) RegisterReturnCode(returnCode int) int {
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
 	context.returnCode = max(context.returnCode, returnCode)
 	return context.returnCode
 }
 
 // ReturnCode returns the overall result of the operation.
 func (context *Context) ReturnCode() int {
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
 	return context.returnCode
 }
 
-// WriteXML writes the test results to the specified XML output file
-func (context *Context) WriteXML() error {
-	if len(context.XMLOutputFile) > 0 {
-		file, err := os.OpenFile(context.XMLOutputFile, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0777)
+// registerFailure counts a failed test and registers the return code
+func (context *Context) registerFailure(returnCode int) {
+	context.RegisterReturnCode(returnCode)
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
+	context.failureCount++
+}
+
+// failures returns the number of failed tests so far
+func (context *Context) failures() int {
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
+	return context.failureCount
+}
+
+// registerSuite adds the test suite of an input file to the results
+func (context *Context) registerSuite(suite *junitxml.JUnitTestSuite) {
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
+	context.Suites.Suites = append(context.Suites.Suites, *suite)
+}
+
+// registerStale counts a test that did not behave as documented in advisory mode
+func (context *Context) registerStale() {
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
+	context.staleCount++
+}
+
+// stale returns the number of tests that did not behave as documented in advisory mode
+func (context *Context) stale() int {
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
+	return context.staleCount
+}
+
+// outputs returns the report files requested in the context. If XMLAppend is set, the test suites are appended to
+// the ones in an existing XML output file.
+func (context *Context) outputs() ([]report.Output, error) {
+	var outputs []report.Output
+	if len(context.options.XMLOutputFile) > 0 {
+		var existing junitxml.JUnitTestSuites
+		if context.options.XMLAppend {
+			var err error
+			if existing, err = readXML(context.options.XMLOutputFile); err != nil {
+				return nil, err
+			}
+		}
+		outputs = append(outputs, report.Output{Format: "XML", Path: context.options.XMLOutputFile,
+			Write: func(writer io.Writer, suites junitxml.JUnitTestSuites) error {
+				suites.Suites = append(append([]junitxml.JUnitTestSuite(nil), existing.Suites...), suites.Suites...)
+				return suites.Write(writer)
+			}})
+	}
+	if len(context.options.XMLOutputDir) > 0 {
+		for _, file := range context.options.Files {
+			name := file
+			outputs = append(outputs, report.Output{Format: "XML", Path: xmlReportPath(context.options.XMLOutputDir, file),
+				Write: func(writer io.Writer, suites junitxml.JUnitTestSuites) error {
+					return suitesOf(suites, name).Write(writer)
+				}})
+		}
+	}
+	if len(context.options.HTMLOutputFile) > 0 {
+		outputs = append(outputs, report.Output{Format: "HTML", Path: context.options.HTMLOutputFile,
+			Write: func(writer io.Writer, suites junitxml.JUnitTestSuites) error {
+				return report.WriteHTML(writer, suites, context.options.SourceURL)
+			}})
+	}
+	if len(context.options.JSONOutputFile) > 0 {
+		outputs = append(outputs, report.Output{Format: "JSON", Path: context.options.JSONOutputFile, Write: report.WriteJSON})
+	}
+	if len(context.options.SummaryFile) > 0 {
+		outputs = append(outputs, report.Output{Format: "Markdown summary", Path: context.options.SummaryFile,
+			Write: report.WriteMarkdownSummary})
+	}
+	return outputs, nil
+}
+
+// prepareReports creates the requested report files before the tests are executed, so that a report that cannot be
+// written is detected before the run, not after it
+func (context *Context) prepareReports() error {
+	outputs, err := context.outputs()
+	if err != nil {
+		return err
+	}
+	return report.PrepareAll(outputs)
+}
+
+// xmlReportNameRx matches the characters that are replaced in the names of per-file XML reports
+var xmlReportNameRx = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// xmlReportPath returns the path of the XML report of an input file in the directory, see Options.XMLOutputDir
+func xmlReportPath(directory, file string) string {
+	return filepath.Join(directory, "TEST-"+xmlReportNameRx.ReplaceAllString(filepath.ToSlash(file), "_")+".xml")
+}
+
+// suitesOf returns the test suites of an input file
+func suitesOf(suites junitxml.JUnitTestSuites, file string) junitxml.JUnitTestSuites {
+	var selected junitxml.JUnitTestSuites
+	for _, suite := range suites.Suites {
+		if suite.Name == file {
+			selected.Suites = append(selected.Suites, suite)
+		}
+	}
+	return selected
+}
+
+// WriteReports writes the test results to all requested report files
+func (context *Context) WriteReports() error {
+	outputs, err := context.outputs()
+	if err != nil {
+		return err
+	}
+	return report.WriteAll(outputs, context.Suites)
+}
+
+// WriteHistory stores the results of the run in the history directory, if one is specified.
+func (context *Context) WriteHistory() error {
+	if len(context.options.HistoryDir) == 0 {
+		return nil
+	}
+	return history.Write(context.options.HistoryDir, history.NewRun(time.Now(), context.Suites))
+}
+
+// readXML reads the test suites from an existing XML output file. A missing or empty file contains no test suites.
+func readXML(path string) (junitxml.JUnitTestSuites, error) {
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return junitxml.JUnitTestSuites{}, nil
+	} else if err != nil {
+		return junitxml.JUnitTestSuites{}, fmt.Errorf("unable to open XML output file for appending: %v", err)
+	}
+	defer file.Close()
+	if info, err := file.Stat(); err == nil && info.Size() == 0 {
+		return junitxml.JUnitTestSuites{}, nil
+	}
+	suites, err := junitxml.Read(file)
+	if err != nil {
+		return junitxml.JUnitTestSuites{}, fmt.Errorf("unable to append to XML output file %s: %v", path, err)
+	}
+	return suites, nil
+}
+
+// prepare resolves the options, reading the input from stdin if it is requested, validates them, and reads the
+// files that are used while the input files are executed, like the command policy and the baseline
+func (context *Context) prepare(stdin io.Reader) error {
+	context.mutex.Lock()
+	prepared := context.prepared
+	context.prepared = true
+	context.mutex.Unlock()
+	if prepared {
+		return fmt.Errorf("the context has already executed a run, every run needs its own context")
+	}
+	context.RegisterReturnCode(returnSuccess)
+	options, err := context.resolveOptions(stdin)
+	if err != nil {
+		return err
+	}
+	context.options = options
+	if err := context.applyEnvironment(); err != nil {
+		return err
+	}
+	if err := tokenizer.ValidateDefaultExitCode(context.options.DefaultExitCode); err != nil {
+		return err
+	}
+	if err := validateDirectoryMode(context.options.DirectoryMode); err != nil {
+		return err
+	}
+	if err := validateIsolation(context.options.Isolation); err != nil {
+		return err
+	}
+	if err := validateBackend(context.options); err != nil {
+		return err
+	}
+	if err := validateWorkdir(context.options); err != nil {
+		return err
+	}
+	tolerated, err := parseCategories(context.options.Tolerate)
+	if err != nil {
+		return err
+	}
+	context.tolerated = tolerated
+	if context.locale, err = tokenizer.ParseLocale(context.options.Locale); err != nil {
+		return err
+	}
+	if context.metadata, err = parseMetadata(context.options.Properties); err != nil {
+		return err
+	}
+	if context.warnings, err = newWarningBudget(context.options); err != nil {
+		return err
+	}
+	if len(context.options.QuarantineFile) > 0 {
+		quarantine, err := readQuarantine(context.options.QuarantineFile)
+		if err != nil {
+			return err
+		}
+		context.quarantine = quarantine
+	}
+	if len(context.options.PolicyFile) > 0 {
+		policy, err := readPolicy(context.options.PolicyFile)
 		if err != nil {
-			return fmt.Errorf("unable to open XML output file for writing: %v", err)
+			return err
 		}
-		if err := context.Suites.Write(file); err != nil {
-			return fmt.Errorf("error writing XML output file: %v", err)
+		context.policy = policy
+	}
+	if len(context.options.BaselineFile) > 0 {
+		baseline, err := readBaseline(context.options.BaselineFile, context.options.UpdateBaseline)
+		if err != nil {
+			return err
 		}
+		context.baseline = baseline
 	}
 	return nil
 }
 
-// ExecuteFiles runs each file through performInteractions and aggregates the results
-func (context *Context) ExecuteFiles() int {
-	context.RegisterReturnCode(returnSuccess)
-	for _, file := range context.Files {
-		suite, err := context.performInteractions(file)
+// ExecuteFiles runs each file through performInteractions and aggregates the results. It returns the exit code for
+// the results of the tests, and an error if the files could not be executed or the reports could not be written.
+func (context *Context) ExecuteFiles() (int, error) {
+	if err := context.prepare(os.Stdin); err != nil {
+		return returnError, err
+	}
+	if err := context.prepareReports(); err != nil {
+		return returnError, err
+	}
+	if len(context.options.PatchFile) > 0 || context.options.Update {
+		context.fixes = &fixes{sources: make(map[string][]string), changes: make(map[string][]patch.Change)}
+	}
+	if len(context.options.TranscriptFile) > 0 {
+		file, err := os.Create(context.options.TranscriptFile)
+		if err != nil {
+			return returnError, fmt.Errorf("unable to open transcript file for writing: %v", err)
+		}
+		defer file.Close()
+		context.transcript = shell.NewTranscript(file)
+	}
+	if len(context.options.CastFile) > 0 {
+		file, err := os.Create(context.options.CastFile)
+		if err != nil {
+			return returnError, fmt.Errorf("unable to open recording file for writing: %v", err)
+		}
+		defer file.Close()
+		if context.recorder, err = cast.NewRecorder(file, strings.Join(context.options.Files, ", ")); err != nil {
+			return returnError, err
+		}
+	}
+	if context.options.HandleInterrupts {
+		defer context.handleInterrupts()()
+	}
+	perform := context.performInteractions
+	if context.options.DryRun || context.options.CheckSyntax {
+		perform = context.dryRunInteractions
+	}
+	for _, file := range context.options.Files {
+		suite, err := perform(file)
+		if err != nil {
+			return returnError, err
+		}
+		context.addMetadata(suite)
+		context.registerSuite(suite)
+	}
+	if context.fixes != nil && len(context.options.PatchFile) > 0 {
+		count, err := context.fixes.write(context.options.PatchFile)
+		if err != nil {
+			return returnError, err
+		}
+		fmt.Printf("SHELLDOC: wrote %d suggested fixes to \"%s\", apply them using git apply\n", count, context.options.PatchFile)
+	}
+	if context.fixes != nil && context.options.Update {
+		count, err := context.fixes.apply()
 		if err != nil {
-			fmt.Println(err) // log may be disabled (see "verbose")
-			os.Exit(returnError)
+			return returnError, err
 		}
-		context.Suites.Suites = append(context.Suites.Suites, *suite)
+		fmt.Printf("SHELLDOC: updated %d expected responses to the actual output\n", count)
+	}
+	context.checkWarningBudget()
+	if err := context.finishBaseline(); err != nil {
+		return returnError, err
 	}
-	if err := context.WriteXML(); err != nil {
-		fmt.Printf("%v\n", err)
-		os.Exit(returnError)
+	if err := context.WriteReports(); err != nil {
+		return returnError, err
 	}
-	return context.ReturnCode()
+	if err := context.WriteHistory(); err != nil {
+		return returnError, err
+	}
+	if len(context.Suites.Suites) > 1 {
+		fmt.Println("SHELLDOC: summary:")
+		writeSummary(os.Stdout, context.Suites)
+	}
+	fmt.Printf("SHELLDOC: random seed %d, replay it with --seed %d\n", context.seed, context.seed)
+	if context.options.Advisory {
+		if stale := context.stale(); stale > 0 {
+			fmt.Printf("SHELLDOC: WARNING: %d commands did not behave as documented (stale documentation)\n", stale)
+		}
+		return returnSuccess, nil
+	}
+	return context.ReturnCode(), nil
+}
+
+// ExecuteDocument executes the text of a single document, like one that is open in an editor, with the options of the
+// context and returns its test suite. The text is read like input from stdin, name is the input file in the results.
+// No reports are written. Like for ExecuteFiles, every document needs its own context.
+func (context *Context) ExecuteDocument(name string, text []byte) (*junitxml.JUnitTestSuite, error) {
+	context.options.Files = []string{StdinArgument}
+	context.options.StdinName = name
+	if err := context.prepare(bytes.NewReader(text)); err != nil {
+		return nil, err
+	}
+	return context.performInteractions(name)
+}
+
+// finishBaseline writes a new baseline file, or reports the known failures that have been fixed
+func (context *Context) finishBaseline() error {
+	if context.baseline == nil {
+		return nil
+	}
+	if context.baseline.capture {
+		fmt.Printf("SHELLDOC: recording %d failures in baseline file \"%s\"\n", len(context.baseline.current), context.options.BaselineFile)
+		return context.baseline.write(context.options.BaselineFile)
+	}
+	if fixed := context.baseline.fixed(); len(fixed) > 0 {
+		fmt.Printf("SHELLDOC: %d known failures have been fixed, consider updating the baseline (--update-baseline):\n", len(fixed))
+		for _, entry := range fixed {
+			fmt.Printf(" FIXED: %s: %s\n", entry.File, entry.Command)
+		}
+	}
+	return nil
 }
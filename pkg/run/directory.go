@@ -0,0 +1,142 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+const (
+	// DirectoryKeep does not check the working directory between code blocks (the default)
+	DirectoryKeep = "keep"
+	// DirectoryWarn warns when a code block changes the working directory without the shelldocdir attribute
+	DirectoryWarn = "warn"
+	// DirectoryReset changes back to the working directory of the previous code blocks after every code block
+	DirectoryReset = "reset"
+	// WorkdirTemporary selects a temporary working directory for every file, which is removed afterwards
+	WorkdirTemporary = "tmp"
+)
+
+// validateDirectoryMode checks that mode is a supported working directory mode
+func validateDirectoryMode(mode string) error {
+	switch mode {
+	case "", DirectoryKeep, DirectoryWarn, DirectoryReset:
+		return nil
+	default:
+		return fmt.Errorf("unsupported working directory mode \"%s\", expected %s, %s or %s", mode, DirectoryKeep,
+			DirectoryWarn, DirectoryReset)
+	}
+}
+
+// leaveBlock is called after the last command of a code block. It checks whether the code block changed the working
+// directory of the shell, and warns about it or changes back, depending on the working directory mode. Code blocks
+// with the shelldocdir attribute change the working directory for the following code blocks on purpose. The
+// returned directory is the expected working directory for the next code block.
+func (context *Context) leaveBlock(sh *shell.Shell, last *tokenizer.Interaction, directory string) (string, error) {
+	if context.options.DirectoryMode != DirectoryWarn && context.options.DirectoryMode != DirectoryReset {
+		return directory, nil
+	}
+	current, err := sh.WorkingDirectory()
+	if err != nil {
+		return directory, err
+	}
+	if current == directory {
+		return directory, nil
+	}
+	if _, ok := last.Attributes[tokenizer.DirectoryOption]; ok {
+		return current, nil
+	}
+	if context.options.DirectoryMode == DirectoryReset {
+		if context.options.Verbose {
+			fmt.Printf(" --  changing back to %s\n", directory)
+		}
+		return directory, sh.ChangeDirectory(directory)
+	}
+	fmt.Printf(" --  WARNING: the code block ending with \"%s\" changed the working directory to %s (use %s if this is intended)\n",
+		last.Cmd, current, tokenizer.DirectoryOption)
+	return current, nil
+}
+
+// validateWorkdir checks that the working directory can be used with the other options
+func validateWorkdir(options Options) error {
+	if len(options.Workdir) == 0 {
+		return nil
+	}
+	if len(options.FixturesDir) > 0 {
+		return fmt.Errorf("the working directory cannot be set together with fixtures, use only one of --workdir, --fixtures")
+	}
+	if len(options.SSHDestination) > 0 {
+		return fmt.Errorf("the working directory cannot be set on a remote machine")
+	}
+	return nil
+}
+
+// prepareWorkdir returns the working directory the shell of a file is started in, and a function that removes it
+// afterwards if it is temporary. An empty directory means the working directory of shelldoc.
+func (context *Context) prepareWorkdir() (string, func(), error) {
+	workdir := context.options.Workdir
+	switch workdir {
+	case "":
+		return context.prepareFixtures()
+	case WorkdirTemporary:
+		directory, err := ioutil.TempDir("", "shelldoc-workdir-")
+		if err != nil {
+			return "", nil, fmt.Errorf("unable to create temporary working directory: %v", err)
+		}
+		return directory, func() { os.RemoveAll(directory) }, nil
+	}
+	directory, err := filepath.Abs(workdir)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to use working directory %s: %v", workdir, err)
+	}
+	info, err := os.Stat(directory)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to use working directory: %v", err)
+	}
+	if !info.IsDir() {
+		return "", nil, fmt.Errorf("unable to use working directory: %s is not a directory", workdir)
+	}
+	return directory, func() {}, nil
+}
+
+// blockDirectory returns the directory the commands of the code block of the interaction are executed in
+// (shelldocdir=path), or an empty string if the code block does not specify one
+func blockDirectory(interaction *tokenizer.Interaction) string {
+	return strings.TrimSpace(interaction.Attributes[tokenizer.DirectoryOption])
+}
+
+// baseDirectory returns the directory relative paths in shelldocdir attributes refer to, which is the initial working
+// directory of the shell. It is only determined if a code block of the file specifies a directory.
+func baseDirectory(sh *shell.Shell, interactions []*tokenizer.Interaction) (string, error) {
+	for _, interaction := range interactions {
+		if len(blockDirectory(interaction)) > 0 {
+			return sh.WorkingDirectory()
+		}
+	}
+	return "", nil
+}
+
+// enterDirectory changes to the directory specified by the code block of the interaction. Relative paths are resolved
+// against base.
+func (context *Context) enterDirectory(sh *shell.Shell, interaction *tokenizer.Interaction, base string) error {
+	directory := blockDirectory(interaction)
+	if len(directory) == 0 {
+		return nil
+	}
+	if !filepath.IsAbs(directory) && !strings.HasPrefix(directory, "/") {
+		directory = filepath.Join(base, directory)
+	}
+	if context.options.Verbose {
+		fmt.Printf(" --  changing to %s\n", directory)
+	}
+	return sh.ChangeDirectory(directory)
+}
@@ -0,0 +1,62 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/version"
+)
+
+// dryRunInteractions lists the interactions of the input file without executing them. If CheckSyntax is set, every
+// command is parsed by the shell in no-exec mode, and syntax errors are reported as failures.
+func (context *Context) dryRunInteractions(inputfile string) (*junitxml.JUnitTestSuite, error) {
+	start := time.Now()
+	suite := &junitxml.JUnitTestSuite{Name: inputfile}
+	suite.AddProperty("shelldoc-version", version.Version())
+	defer junitxml.RegisterElapsedTime(start, &suite.Time)
+	shellpath := ""
+	if context.options.CheckSyntax {
+		var err error
+		if shellpath, err = context.detectShell(suite); err != nil {
+			return nil, err
+		}
+	}
+	visitor, err := context.readInteractions(inputfile)
+	if err != nil {
+		return nil, err
+	}
+	fmt.Printf("SHELLDOC: dry run of \"%s\" ...\n", inputfile)
+	context.reportSkippedBlocks(suite, visitor.Skipped)
+	width := context.descriptionWidth()
+	for index, interaction := range visitor.Interactions {
+		fmt.Printf(" CMD (%d): %s  : ", index+1, interaction.DescribeWidth(width))
+		if reason, skip := interaction.SkipReason(); skip {
+			context.skipInteraction(suite, inputfile, interaction, reason)
+			fmt.Println(context.describeResult(interaction))
+			continue
+		}
+		testcase := context.newTestCase(inputfile, interaction)
+		if context.options.CheckSyntax {
+			if err := shell.CheckSyntax(shellpath, interaction.Cmd); err != nil {
+				fmt.Printf("FAIL (%v)\n", err)
+				context.RegisterReturnCode(returnFailure)
+				testcase.RegisterFailure("SYNTAX", "FAIL (syntax error)", err.Error())
+				suite.RegisterTestCase(*testcase)
+				continue
+			}
+		}
+		interaction.Skip("dry run")
+		testcase.RegisterSkipped(interaction.Result())
+		suite.RegisterTestCase(*testcase)
+		fmt.Println(context.describeResult(interaction))
+	}
+	fmt.Printf("%s: %d tests - %d failures, %d skipped\n", result(context.ReturnCode()), suite.TestCount(),
+		suite.FailureCount(), suite.SkippedCount())
+	return suite, nil
+}
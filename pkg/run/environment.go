@@ -0,0 +1,96 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// variableNameEx matches the names of environment variables that can be set in a shell
+const variableNameEx = `^[A-Za-z_][A-Za-z0-9_]*$`
+
+var variableNameRx = regexp.MustCompile(variableNameEx)
+
+// parseVariable reads a variable in the form NAME=VALUE. A NAME without a value takes the value from the environment
+// of shelldoc, lookup is used to read it.
+func parseVariable(variable string, lookup func(string) (string, bool)) (string, error) {
+	name, value := variable, ""
+	index := strings.Index(variable, "=")
+	if index >= 0 {
+		name, value = variable[:index], variable[index+1:]
+	}
+	if !variableNameRx.MatchString(name) {
+		return "", fmt.Errorf("invalid environment variable name \"%s\"", name)
+	}
+	if index < 0 {
+		inherited, ok := lookup(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		value = inherited
+	}
+	return name + "=" + value, nil
+}
+
+// readEnvFile reads the variables from a file in the format of .env files: one NAME=VALUE assignment per line,
+// optionally prefixed with export. Values may be enclosed in single or double quotes. Empty lines and lines starting
+// with # are ignored.
+func readEnvFile(path string) ([]string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read environment file: %v", err)
+	}
+	defer file.Close()
+	var variables []string
+	scanner := bufio.NewScanner(file)
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		line = strings.TrimSpace(strings.TrimPrefix(line, "export "))
+		index := strings.Index(line, "=")
+		if index < 0 {
+			return nil, fmt.Errorf("%s:%d: expected NAME=VALUE", path, number)
+		}
+		name, value := strings.TrimSpace(line[:index]), strings.TrimSpace(line[index+1:])
+		if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+			value = value[1 : len(value)-1]
+		}
+		variable, err := parseVariable(name+"="+value, nil)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, number, err)
+		}
+		variables = append(variables, variable)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read environment file %s: %v", path, err)
+	}
+	return variables, nil
+}
+
+// applyEnvironment adds the variables from the environment files and the command line to the environment of the
+// shell. They are applied after the ones of the profile, so the command line takes precedence.
+func (context *Context) applyEnvironment() error {
+	for _, path := range context.options.EnvFiles {
+		variables, err := readEnvFile(path)
+		if err != nil {
+			return err
+		}
+		context.environment = append(context.environment, variables...)
+	}
+	for _, entry := range context.options.Env {
+		variable, err := parseVariable(entry, os.LookupEnv)
+		if err != nil {
+			return err
+		}
+		context.environment = append(context.environment, variable)
+	}
+	return nil
+}
@@ -0,0 +1,147 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/capture"
+	"github.com/mirkoboehm/shelldoc/pkg/patch"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// fixes collects suggested changes to the Markdown source files that update expected responses to the actual output
+type fixes struct {
+	files   []string
+	sources map[string][]string
+	changes map[string][]patch.Change
+}
+
+// responseLocation finds the source lines of the expected response of the command on line (starting at 1). It
+// returns the range of the response lines as slice indexes and the indentation of the command. The response starts
+// after the continuation lines of commands that end with a backslash.
+func responseLocation(lines []string, line int) (int, int, string) {
+	cmdRx := regexp.MustCompile(`^(\s*)[\$>]\s+`)
+	match := cmdRx.FindStringSubmatch(lines[line-1])
+	indentation := match[1]
+	for line < len(lines) && tokenizer.Continued(strings.TrimSpace(lines[line-1])) &&
+		tokenizer.ContinuationLine(lines[line], indentation) {
+		line++
+	}
+	end := line
+	for ; end < len(lines); end++ {
+		trimmed := strings.TrimSpace(lines[end])
+		if len(trimmed) == 0 || cmdRx.MatchString(lines[end]) || strings.HasPrefix(trimmed, "```") ||
+			strings.HasPrefix(trimmed, "~~~") || !strings.HasPrefix(lines[end], indentation) {
+			break
+		}
+	}
+	return line, end, indentation
+}
+
+// suggest records a change that replaces the expected response of the interaction with its actual output. Nothing
+// is recorded if the expected response cannot be located in the source file reliably, for example because it was
+// included from another file or contains block variables, or if the source file is remote.
+func (f *fixes) suggest(inputfile string, interaction *tokenizer.Interaction) error {
+	if _, transaction := interaction.Attributes[tokenizer.TransactionOption]; transaction {
+		return nil // the expected responses are spread over the code block
+	}
+	if _, verification := interaction.VerifiedFile(); verification {
+		return nil // the expected file contents are not a response
+	}
+	if _, regex := interaction.Attributes[tokenizer.RegexOption]; regex {
+		return nil // the expected response contains patterns that the output would replace
+	}
+	if _, anchors := interaction.Attributes[tokenizer.AnchorsOption]; anchors {
+		return nil // the expected response contains partial lines that the output would replace
+	}
+	if len(interaction.Snippet) > 0 || len(interaction.Attributes[tokenizer.VarsOption]) > 0 || interaction.Line < 1 {
+		return nil
+	}
+	if _, remote := remoteURL(inputfile); remote {
+		return nil
+	}
+	lines, ok := f.sources[inputfile]
+	if !ok {
+		data, err := ioutil.ReadFile(inputfile)
+		if err != nil {
+			return fmt.Errorf("unable to read %s to suggest fixes: %v", inputfile, err)
+		}
+		lines = strings.Split(strings.TrimSuffix(string(data), "\n"), "\n")
+		f.sources[inputfile] = lines
+		f.files = append(f.files, inputfile)
+	}
+	if interaction.Line > len(lines) {
+		return nil
+	}
+	start, end, indentation := responseLocation(lines, interaction.Line)
+	var current []string
+	for _, line := range lines[start:end] {
+		current = append(current, strings.TrimSpace(line))
+	}
+	if strings.Join(current, "\n") != strings.Join(interaction.Response, "\n") {
+		return nil // the response in the source is not the one that was tested
+	}
+	output := interaction.Output
+	if _, ok := interaction.Attributes[tokenizer.SquashOption]; ok {
+		output = tokenizer.SquashRepeats(output)
+	}
+	var replacement []string
+	for _, line := range capture.Response(output) {
+		replacement = append(replacement, indentation+line)
+	}
+	f.changes[inputfile] = append(f.changes[inputfile], patch.Change{Line: start + 1, Old: lines[start:end], New: replacement})
+	return nil
+}
+
+// write writes the suggested changes as a unified diff to path and returns the number of changes
+func (f *fixes) write(path string) (int, error) {
+	var diff strings.Builder
+	count := 0
+	for _, file := range f.files {
+		diff.WriteString(patch.Unified(file, f.sources[file], f.changes[file]))
+		count += len(f.changes[file])
+	}
+	if err := ioutil.WriteFile(path, []byte(diff.String()), 0644); err != nil {
+		return 0, fmt.Errorf("unable to write patch file: %v", err)
+	}
+	return count, nil
+}
+
+// apply writes the suggested changes to the source files in place and returns the number of changes. Files with
+// Windows line endings keep them.
+func (f *fixes) apply() (int, error) {
+	count := 0
+	for _, file := range f.files {
+		changes := f.changes[file]
+		if len(changes) == 0 {
+			continue
+		}
+		lines := f.sources[file]
+		if len(lines) > 0 && strings.HasSuffix(lines[0], "\r") {
+			for index, change := range changes {
+				var replacement []string
+				for _, line := range change.New {
+					replacement = append(replacement, line+"\r")
+				}
+				changes[index].New = replacement
+			}
+		}
+		info, err := os.Stat(file)
+		if err != nil {
+			return count, fmt.Errorf("unable to update %s: %v", file, err)
+		}
+		updated := strings.Join(patch.Apply(lines, changes), "\n") + "\n"
+		if err := ioutil.WriteFile(file, []byte(updated), info.Mode()); err != nil {
+			return count, fmt.Errorf("unable to update %s: %v", file, err)
+		}
+		count += len(changes)
+	}
+	return count, nil
+}
@@ -0,0 +1,88 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// prepareFixtures creates a temporary working directory for a file that contains a copy of the fixtures directory.
+// The fixtures are copied for every file, so that changes made by the commands of one file do not affect the others.
+// It returns the directory and a function that removes it. Without a fixtures directory, the commands are executed
+// in the current directory, and the returned directory is empty.
+func (context *Context) prepareFixtures() (string, func(), error) {
+	if len(context.options.FixturesDir) == 0 {
+		return "", func() {}, nil
+	}
+	info, err := os.Stat(context.options.FixturesDir)
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to use fixtures: %v", err)
+	}
+	if !info.IsDir() {
+		return "", nil, fmt.Errorf("unable to use fixtures: %s is not a directory", context.options.FixturesDir)
+	}
+	sandbox, err := ioutil.TempDir("", "shelldoc-fixtures-")
+	if err != nil {
+		return "", nil, fmt.Errorf("unable to create working directory for the fixtures: %v", err)
+	}
+	remove := func() { os.RemoveAll(sandbox) }
+	if err := copyTree(context.options.FixturesDir, sandbox); err != nil {
+		remove()
+		return "", nil, fmt.Errorf("unable to copy fixtures: %v", err)
+	}
+	return sandbox, remove, nil
+}
+
+// copyTree copies the contents of the source directory into the existing target directory, preserving the file
+// modes and symbolic links
+func copyTree(source, target string) error {
+	return filepath.Walk(source, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relative, err := filepath.Rel(source, path)
+		if err != nil {
+			return err
+		}
+		destination := filepath.Join(target, relative)
+		switch {
+		case info.IsDir():
+			if relative == "." {
+				return nil
+			}
+			return os.Mkdir(destination, info.Mode().Perm()|0700)
+		case info.Mode()&os.ModeSymlink != 0:
+			link, err := os.Readlink(path)
+			if err != nil {
+				return err
+			}
+			return os.Symlink(link, destination)
+		default:
+			return copyFile(path, destination, info.Mode().Perm())
+		}
+	})
+}
+
+// copyFile copies a regular file
+func copyFile(source, destination string, mode os.FileMode) error {
+	input, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer input.Close()
+	output, err := os.OpenFile(destination, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(output, input); err != nil {
+		output.Close()
+		return err
+	}
+	return output.Close()
+}
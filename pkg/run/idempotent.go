@@ -0,0 +1,83 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// outcome returns the result of a test case as it is reported
+func outcome(testcase junitxml.JUnitTestCase) string {
+	switch {
+	case testcase.Error != nil:
+		return "ERROR"
+	case testcase.Failure != nil:
+		return "FAILURE"
+	case testcase.SkipMessage != nil:
+		return "SKIPPED"
+	default:
+		return "SUCCESS"
+	}
+}
+
+// rerun executes the input file a second time in the sandbox directory of the first run, with the same environment,
+// command policy and other settings, without registering any results
+func (context *Context) rerun(inputfile string, sandbox string) (*junitxml.JUnitTestSuite, error) {
+	second := NewContext(WithOptions(context.options))
+	second.prepared = true
+	second.environment = context.environment
+	second.stdin = context.stdin
+	second.stdinName = context.stdinName
+	second.interrupts = context.interrupts
+	second.quarantine = context.quarantine
+	second.policy = context.policy
+	second.baseline = context.baseline.detached()
+	second.transcript = context.transcript
+	second.tolerated = context.tolerated
+	second.locale = context.locale
+	second.metadata = context.metadata
+	second.seed = context.seed
+	return second.performInteractionsIn(inputfile, sandbox)
+}
+
+// verifyIdempotent executes the input file again and warns about the commands that behave differently the second
+// time, for example because they create files that already exist now. The differences are recorded in the properties
+// of the test suite of the first run.
+func (context *Context) verifyIdempotent(inputfile string, sandbox string, suite *junitxml.JUnitTestSuite) error {
+	fmt.Printf("SHELLDOC: executing \"%s\" again to verify that it is idempotent ...\n", inputfile)
+	again, err := context.rerun(inputfile, sandbox)
+	if err != nil {
+		return err
+	}
+	differences := compareRuns(suite, again)
+	for _, difference := range differences {
+		suite.AddProperty("not-idempotent", difference)
+		fmt.Printf("SHELLDOC: WARNING: not idempotent: %s\n", difference)
+	}
+	if len(differences) == 0 {
+		fmt.Printf("SHELLDOC: \"%s\" is idempotent\n", inputfile)
+	}
+	return nil
+}
+
+// compareRuns describes the test cases that have a different result in the second run
+func compareRuns(first, second *junitxml.JUnitTestSuite) []string {
+	results := make(map[string]string)
+	for _, testcase := range second.TestCases {
+		results[testcase.ID] = outcome(testcase)
+	}
+	var differences []string
+	for _, testcase := range first.TestCases {
+		result, ok := results[testcase.ID]
+		if !ok || result == outcome(testcase) {
+			continue
+		}
+		differences = append(differences, fmt.Sprintf("%s:%d: %s (first run: %s, second run: %s)",
+			testcase.File, testcase.Line, testcase.Name, outcome(testcase), result))
+	}
+	return differences
+}
@@ -5,20 +5,28 @@ package run
 // SPDX-License-Identifier: LGPL-3.0
 
 import (
+	"bytes"
+	"encoding/binary"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"unicode/utf16"
+	"unicode/utf8"
 )
 
-// ReadInput reads either the files specified on the command line or stdin and returns the bytes.
+// ReadInput reads either the files specified on the command line or stdin and returns the bytes. Files can be
+// remote, see remoteURL.
 // Markdown.Parse expects bytes, not a stream.
 func ReadInput(args []string) ([]byte, error) {
 	if len(args) > 0 {
 		var result []byte
 		for _, filename := range args {
-			content, err := ioutil.ReadFile(filename)
+			content, err := readFile(filename)
 			if err != nil {
-				return nil, fmt.Errorf("unable to read file %s", filename)
+				return nil, err
+			}
+			if content, err = decodeInput(content); err != nil {
+				return nil, fmt.Errorf("unable to decode file %s: %v", filename, err)
 			}
 			result = append(result, content[:]...)
 		}
@@ -30,5 +38,42 @@ func ReadInput(args []string) ([]byte, error) {
 			return nil, fmt.Errorf("unable to read from stdin: %v", err)
 		}
 	}
+	if result, err = decodeInput(result); err != nil {
+		return nil, fmt.Errorf("unable to decode stdin: %v", err)
+	}
+	return result, nil
+}
+
+// decodeInput removes a byte-order mark and converts UTF-16 encoded input, as saved by some Windows editors, to
+// UTF-8. UTF-16 input without a byte-order mark is recognized by the zero byte of the first ASCII character.
+func decodeInput(content []byte) ([]byte, error) {
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(content, []byte{0xEF, 0xBB, 0xBF}):
+		return content[3:], nil
+	case bytes.HasPrefix(content, []byte{0xFF, 0xFE}):
+		order, content = binary.LittleEndian, content[2:]
+	case bytes.HasPrefix(content, []byte{0xFE, 0xFF}):
+		order, content = binary.BigEndian, content[2:]
+	case len(content) >= 2 && content[0] != 0 && content[1] == 0:
+		order = binary.LittleEndian
+	case len(content) >= 2 && content[0] == 0 && content[1] != 0:
+		order = binary.BigEndian
+	default:
+		return content, nil
+	}
+	if len(content)%2 != 0 {
+		return nil, fmt.Errorf("the input looks like UTF-16, but has an odd number of bytes")
+	}
+	units := make([]uint16, len(content)/2)
+	for index := range units {
+		units[index] = order.Uint16(content[2*index:])
+	}
+	var result []byte
+	buffer := make([]byte, utf8.UTFMax)
+	for _, r := range utf16.Decode(units) {
+		length := utf8.EncodeRune(buffer, r)
+		result = append(result, buffer[:length]...)
+	}
 	return result, nil
 }
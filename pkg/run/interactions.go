@@ -4,10 +4,16 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/mirkoboehm/shelldoc/pkg/exitcode"
+	"github.com/mirkoboehm/shelldoc/pkg/include"
 	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/screen"
 	"github.com/mirkoboehm/shelldoc/pkg/shell"
 	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
 	"github.com/mirkoboehm/shelldoc/pkg/version"
@@ -21,9 +27,9 @@ func max(a, b int) int { // really, golang?
 }
 
 const (
-	returnSuccess = iota // the test succeeded
-	returnFailure        // the test failed (a problemn with the test)
-	returnError          // there was an error executing the test (a problem with shelldoc)
+	returnSuccess = exitcode.Success // the test succeeded
+	returnFailure = exitcode.Failure // the test failed (a problemn with the test)
+	returnError   = exitcode.Error   // there was an error executing the test (a problem with shelldoc)
 )
 
 func result(code int) string {
@@ -37,81 +43,549 @@ func result(code int) string {
 	}
 }
 
+// performInteractions executes the input file in its own working directory, see prepareWorkdir. To verify that the
+// file is idempotent, it is executed a second time in the same directory.
 func (context *Context) performInteractions(inputfile string) (*junitxml.JUnitTestSuite, error) {
+	// with fixtures, every file is executed in its own copy of the fixtures directory, with a temporary working
+	// directory in its own empty directory
+	sandbox, removeSandbox, err := context.prepareWorkdir()
+	if err != nil {
+		return nil, err
+	}
+	defer removeSandbox()
+	suite, err := context.performInteractionsIn(inputfile, sandbox)
+	if err != nil || !context.options.Idempotent {
+		return suite, err
+	}
+	return suite, context.verifyIdempotent(inputfile, sandbox, suite)
+}
+
+// performInteractionsIn executes the input file with the shell started in the sandbox directory, or in the working
+// directory of shelldoc if it is empty
+func (context *Context) performInteractionsIn(inputfile string, sandbox string) (*junitxml.JUnitTestSuite, error) {
 	// the test suite object for this file
+	start := time.Now()
 	suite := &junitxml.JUnitTestSuite{Name: inputfile}
 	suite.AddProperty("shelldoc-version", version.Version())
-	defer junitxml.RegisterElapsedTime(time.Now(), &suite.Time)
+	defer junitxml.RegisterElapsedTime(start, &suite.Time)
+	context.baseline.registerFile(inputfile)
 	// detect shell
-	shellpath, err := shell.DetectShell(context.ShellName)
+	shellpath, err := context.detectShell(suite)
 	if err != nil {
 		return nil, err
 	}
 	// start a background shell, it will run until the function ends
-	shell, err := shell.StartShell(shellpath)
+	context.transcript.Record(shell.TranscriptNote, fmt.Sprintf("testing %s", inputfile))
+	// services started by the commands can listen on a port that is free, even if files are tested in parallel
+	port, err := freePort()
+	if err != nil {
+		return nil, err
+	}
+	suite.AddProperty("free-port", strconv.Itoa(port))
+	if name := currentUser(); len(name) > 0 {
+		suite.AddProperty("user", name)
+	}
+	seed := strconv.FormatInt(context.seed, 10)
+	suite.AddProperty("seed", seed)
+	environment := []string{"SHELLDOC=1", "SHELLDOC_FILE=" + inputfile, "SHELLDOC_FREE_PORT=" + strconv.Itoa(port),
+		seedVariable + "=" + seed}
+	options := shell.Options{
+		Transcript:     context.transcript,
+		Environment:    append(environment, context.environment...),
+		ProbeResources: context.options.ResourceUsage,
+		CleanStartup:   context.options.CleanStartup,
+		Directory:      sandbox,
+	}
+	if options.Backend, err = context.backend(suite); err != nil {
+		return nil, err
+	}
+	shell, err := shell.StartShellWithOptions(shellpath, options)
 	if err != nil {
 		return nil, fmt.Errorf("unable to start shell: %v", err)
 	}
+	context.interrupts.register(&shell)
+	defer context.interrupts.unregister(&shell)
 	defer shell.Exit()
-	// read input data
-	data, err := ReadInput([]string{inputfile})
+	// the shell that executes the current command, it differs from the shell of the file for code blocks that are
+	// executed as another user
+	active, activeOptions := &shell, options
+	var switched *userShell
+	budget := context.scaleTimeout(context.options.Config.fileConfig(inputfile).Budget.Duration)
+	var running *tokenizer.Interaction
+	limit := func() {
+		if budget > 0 {
+			active.SetDeadline(start.Add(budget))
+		}
+		context.warnBeforeBudget(active, inputfile, start, budget, func() *tokenizer.Interaction { return running })
+	}
+	limit()
+	visitor, err := context.readInteractions(inputfile)
 	if err != nil {
-		return nil, fmt.Errorf("unable to read input data: %v", err)
+		return nil, err
+	}
+	base, err := baseDirectory(&shell, visitor.Interactions)
+	if err != nil {
+		return nil, err
 	}
-	// run the input through the tokenizer
-	visitor := tokenizer.NewInteractionVisitor()
-	tokenizer.Tokenize(data, visitor)
 	// execute the interactions and verify the results:
 	fmt.Printf("SHELLDOC: doc-testing \"%s\" ...\n", inputfile)
+	context.reportSkippedBlocks(suite, visitor.Skipped)
 	// construct the opener and closer format strings, since they depend on verbose mode
 	magnitude := int(math.Log10(float64(len(visitor.Interactions)))) + 1
 	openerLineEnding := "  : "
 	resultString := " "
-	if context.Verbose {
+	if context.options.Verbose {
 		openerLineEnding = "\n"
 		resultString = " <-- "
 	}
 	counterFormat := fmt.Sprintf("%%%ds", magnitude+2)
 	opener := fmt.Sprintf(" CMD %s: %%s%s", counterFormat, openerLineEnding)
+	width := context.descriptionWidth()
 	closer := fmt.Sprintf("%s%%s\n", resultString)
 
+	directory := ""
+	if context.options.DirectoryMode == DirectoryWarn || context.options.DirectoryMode == DirectoryReset {
+		if directory, err = shell.WorkingDirectory(); err != nil {
+			return nil, err
+		}
+	}
+	// background processes are terminated at the end of the file, after the cleanup commands
+	processes := newBackgroundProcesses()
+	defer context.terminateBackground(&shell, processes)
+	// the cleanup command of the code block that is executed, it runs when the code block is left
+	var pending *cleanup
+	// executed is set when the first command has been executed
+	executed := false
+	// the code block whose readiness command has been executed, and its result
+	waited := 0
+	var notReady error
+	// with isolation, the code block or interaction the shell has been started for
+	isolated := 0
+	// the code block whose directory has been entered (shelldocdir=path), and the error changing to it
+	entered := 0
+	var directoryErr error
+	// the number of warnings in the output of the commands of the file
+	warnings := 0
+	defer func() {
+		context.runCleanup(suite, active, shellpath, activeOptions, pending)
+		if switched != nil {
+			context.leaveUser(switched)
+		}
+	}()
 	for index, interaction := range visitor.Interactions {
-		fmt.Printf(opener, fmt.Sprintf("(%d)", index+1), interaction.Describe())
-		if context.Verbose {
+		if context.interrupts.interrupted() {
+			fmt.Printf(opener, fmt.Sprintf("(%d)", index+1), interaction.DescribeWidth(width))
+			context.skipInteraction(suite, inputfile, interaction, "interrupted")
+			fmt.Printf(closer, context.describeResult(interaction))
+			continue
+		}
+		if index > 0 && interaction.Block != visitor.Interactions[index-1].Block {
+			context.runCleanup(suite, active, shellpath, activeOptions, pending)
+			pending = nil
+			if directory, err = context.leaveBlock(active, visitor.Interactions[index-1], directory); err != nil {
+				return nil, err
+			}
+			if switched != nil {
+				context.leaveUser(switched)
+				switched = nil
+				active, activeOptions = &shell, options
+				limit()
+			}
+		}
+		interaction.DefaultExitCode = context.options.DefaultExitCode
+		interaction.NormalizePaths = context.options.NormalizePaths
+		interaction.Redactions = context.options.Config.redactionRules()
+		interaction.Responders = context.options.Config.responderRules()
+		interaction.Vars = map[string]string{freePortVariable: strconv.Itoa(port)}
+		interaction.Strict = context.options.ShellStrict
+		fmt.Printf(opener, fmt.Sprintf("(%d)", index+1), interaction.DescribeWidth(width))
+		if reason, skip := interaction.SkipReason(); skip {
+			context.skipInteraction(suite, inputfile, interaction, reason)
+			fmt.Printf(closer, context.describeResult(interaction))
+			continue
+		}
+		if failures := context.failures(); context.options.MaxFailures > 0 && failures >= context.options.MaxFailures {
+			context.skipInteraction(suite, inputfile, interaction, fmt.Sprintf("stopped after %d failures", failures))
+			fmt.Printf(closer, context.describeResult(interaction))
+			continue
+		}
+		if budget > 0 && time.Since(start) >= budget {
+			context.skipInteraction(suite, inputfile, interaction, fmt.Sprintf("time budget of %v exhausted", budget))
+			fmt.Printf(closer, context.describeResult(interaction))
+			continue
+		}
+		if unit := context.isolationUnit(index, interaction); unit != isolated {
+			if isolated > 0 {
+				if err := context.restartShell(active, shellpath, activeOptions, processes); err != nil {
+					return nil, err
+				}
+				limit()
+				// the fresh shell starts in the initial working directory
+				entered = 0
+			}
+			isolated = unit
+		}
+		if interaction.Block != entered {
+			entered = interaction.Block
+			directoryErr = context.enterDirectory(active, interaction, base)
+		}
+		if directoryErr != nil {
+			interaction.Skip("unable to change the working directory")
+			interaction.Category = tokenizer.CategoryShellError
+			testcase := context.newTestCase(inputfile, interaction)
+			testcase.RegisterError(context.problemType("DIRECTORY", interaction.Category), "ERROR (directory)",
+				directoryErr.Error())
+			suite.RegisterTestCase(*testcase)
+			fmt.Printf(closer, "ERROR (directory)"+context.registerProblem(returnError, interaction.Category))
+			if context.options.Verbose {
+				fmt.Printf(" --  %v\n", directoryErr)
+			}
+			continue
+		}
+		if name := runAs(interaction); len(name) > 0 && (switched == nil || switched.block != interaction.Block) {
+			if switched = context.switchUser(&shell, shellpath, options, interaction); switched.started() {
+				active, activeOptions = &switched.shell, switched.options
+				limit()
+			}
+		}
+		if switched != nil && len(switched.skipped) > 0 {
+			context.skipInteraction(suite, inputfile, interaction, switched.skipped)
+			fmt.Printf(closer, context.describeResult(interaction))
+			continue
+		}
+		if switched != nil && switched.err != nil {
+			interaction.Skip("unable to switch user")
+			interaction.Category = tokenizer.CategoryShellError
+			testcase := context.newTestCase(inputfile, interaction)
+			testcase.RegisterError(context.problemType("USER", interaction.Category), "ERROR (user)", switched.err.Error())
+			suite.RegisterTestCase(*testcase)
+			fmt.Printf(closer, "ERROR (user)"+context.registerProblem(returnError, interaction.Category))
+			if context.options.Verbose {
+				fmt.Printf(" --  %v\n", switched.err)
+			}
+			continue
+		}
+		if interaction.Block != waited {
+			waited = interaction.Block
+			if notReady = processes.check(active, interaction); notReady == nil {
+				notReady = context.waitUntilReady(active, interaction)
+			}
+		}
+		// a readiness command that is denied by the policy denies all commands of the code block
+		subject, reason := "command", ""
+		if violation, denied := notReady.(policyViolation); denied {
+			subject, reason = "readiness command", string(violation)
+		} else if notReady != nil {
+			interaction.Skip("code block not ready")
+			interaction.Category = tokenizer.CategoryAssertion
+			testcase := context.newTestCase(inputfile, interaction)
+			testcase.RegisterFailure(context.problemType("NOT READY", interaction.Category), "FAIL (not ready)", notReady.Error())
+			suite.RegisterTestCase(*testcase)
+			fmt.Printf(closer, "FAIL (not ready)"+context.registerProblem(returnFailure, interaction.Category))
+			if context.options.Verbose {
+				fmt.Printf(" --  %v\n", notReady)
+			}
+			if context.options.FailureStops && !context.tolerates(interaction.Category) {
+				log.Printf("Stop requested after first failed test.")
+				break
+			}
+			continue
+		}
+		if len(reason) == 0 {
+			reason = context.policy.check(interaction.Cmd)
+		}
+		if len(reason) > 0 {
+			interaction.Deny(reason)
+			testcase := context.newTestCase(inputfile, interaction)
+			testcase.RegisterFailure(context.problemType("POLICY", interaction.Category), interaction.Result(),
+				fmt.Sprintf("%s %s", subject, reason))
+			suite.RegisterTestCase(*testcase)
+			fmt.Printf(closer, context.describeResult(interaction)+context.registerProblem(returnFailure, interaction.Category))
+			if context.options.FailureStops && !context.tolerates(interaction.Category) {
+				log.Printf("Stop requested after first failed test.")
+				break
+			}
+			continue
+		}
+		if context.options.Verbose {
 			fmt.Printf(" --> %s\n", interaction.Cmd)
+			fmt.Printf(" --  ID: %s\n", interaction.ID)
 		}
-		testcase, err := context.performTestCase(interaction, shell)
-		testcase.Classname = inputfile // testcase is always returned, even if err is not nil
-		if context.ReplaceDots {
-			testcase.Classname = strings.ReplaceAll(inputfile, ".", "●")
-		} else {
-			testcase.Classname = inputfile // testcase is always returned, even if err is not nil
+		// pause between commands, for example for rate-limited services, invalid delays are reported by Execute
+		if delay, err := interaction.Delay(context.options.Delay); err == nil && delay > 0 && executed {
+			time.Sleep(delay)
+		}
+		executed = true
+		running = interaction
+		if cleanup := pendingCleanup(interaction); cleanup != nil {
+			pending = cleanup
+		}
+		// tell the command which file, code block and interaction it is executed for:
+		variables := map[string]string{
+			"SHELLDOC_BLOCK": strconv.Itoa(interaction.Block),
+			"SHELLDOC_INDEX": strconv.Itoa(index + 1),
+		}
+		// the variables set by env directives are exported again, in case the shell has been restarted
+		for name, value := range interaction.Env {
+			variables[name] = value
+		}
+		if err := active.Export(variables); err != nil {
+			return nil, err
+		}
+		started := time.Now()
+		deadline, limited := context.limitCommand(active, interaction)
+		testcase, err := context.performTestCase(inputfile, interaction, active, processes)
+		if limited {
+			active.SetDeadline(deadline)
+			if interaction.Category == tokenizer.CategoryTimeout {
+				// the shell has been stopped, the following commands are executed in a fresh one
+				if err := context.restartShell(active, shellpath, activeOptions, processes); err != nil {
+					return nil, err
+				}
+				limit()
+				entered = 0
+			}
+		}
+		if active.Tripped() {
+			// the command failed in strict mode and the shell exited, the following commands are executed in a fresh one
+			if err := context.restartShell(active, shellpath, activeOptions, processes); err != nil {
+				return nil, err
+			}
+			limit()
+			entered = 0
+		}
+		if switched != nil {
+			testcase.AddProperty("user", switched.name)
+		}
+		if context.fixes != nil && interaction.ResultCode == tokenizer.ResultMismatch {
+			if err := context.fixes.suggest(inputfile, interaction); err != nil {
+				return nil, err
+			}
+		}
+		if err := context.recorder.Record(interaction.Cmd, interaction.Output, time.Since(started)); err != nil {
+			return nil, err
+		}
+		if err := context.exportCaptures(active, interaction); err != nil {
+			return nil, err
+		}
+		if context.options.ResourceUsage {
+			suite.AddProperty("resource-usage."+interaction.ID, interaction.Usage.String())
+			if context.options.Verbose {
+				fmt.Printf(" --  usage: %v\n", interaction.Usage)
+			}
+		}
+		warnings += context.countWarnings(testcase, interaction)
+		failed := err != nil || interaction.HasFailure()
+		if context.quarantine.contains(inputfile, interaction.ID) {
+			context.registerTolerated(testcase, interaction, err, "QUARANTINED")
+			fmt.Printf(closer, context.describeResult(interaction)+" [quarantined]")
+			suite.RegisterTestCase(*testcase)
+			continue
+		}
+		if failed && context.baseline.registerFailure(inputfile, interaction.ID, interaction.Cmd) {
+			context.registerTolerated(testcase, interaction, err, "KNOWN FAILURE")
+			fmt.Printf(closer, context.describeResult(interaction)+" [known failure]")
+			suite.RegisterTestCase(*testcase)
+			continue
+		}
+		if failed && context.tolerates(interaction.Category) {
+			context.registerTolerated(testcase, interaction, err, "TOLERATED")
+			fmt.Printf(closer, context.describeResult(interaction)+" [tolerated]")
+			suite.RegisterTestCase(*testcase)
+			continue
+		}
+		if failed && context.options.Advisory {
+			context.registerTolerated(testcase, interaction, err, "STALE DOCUMENTATION")
+			fmt.Printf(closer, context.describeResult(interaction)+" [stale documentation]")
+			suite.RegisterTestCase(*testcase)
+			context.registerStale()
+			continue
 		}
 		if err != nil {
 			fmt.Printf(" --  ERROR: %v", err)
-			context.RegisterReturnCode(returnError)
+			context.registerFailure(returnError)
 			testcase.RegisterError(result(returnError), interaction.Result(), err.Error())
 		}
-		fmt.Printf(closer, interaction.Result())
+		fmt.Printf(closer, context.describeResult(interaction))
 		if interaction.HasFailure() {
-			context.RegisterReturnCode(returnFailure)
+			context.registerFailure(returnFailure)
 			testcase.RegisterFailure(result(returnFailure), interaction.Result(), interaction.DescribeFull())
 		}
 		suite.RegisterTestCase(*testcase)
-		if interaction.HasFailure() && context.FailureStops {
+		if interaction.HasFailure() && context.options.FailureStops {
 			log.Printf("Stop requested after first failed test.")
 			break
 		}
 	}
-	fmt.Printf("%s: %d tests - %d successful, %d failures, %d errors\n", result(context.ReturnCode()), suite.TestCount(),
-		suite.SuccessCount(), suite.FailureCount(), suite.ErrorCount())
+	if context.warnings != nil {
+		suite.AddProperty("warnings", strconv.Itoa(warnings))
+	}
+	skipped := ""
+	if suite.SkippedCount() > 0 {
+		skipped = fmt.Sprintf(", %d skipped", suite.SkippedCount())
+	}
+	fmt.Printf("%s: %d tests - %d successful, %d failures, %d errors%s\n", result(context.ReturnCode()), suite.TestCount(),
+		suite.SuccessCount(), suite.FailureCount(), suite.ErrorCount(), skipped)
 	return suite, nil
 }
 
-func (context *Context) performTestCase(interaction *tokenizer.Interaction, shell shell.Shell) (*junitxml.JUnitTestCase, error) {
+// defaultRemoteShell is the shell that executes the commands in a container or on a remote machine if no shell is
+// selected, it exists on most systems
+const defaultRemoteShell = "/bin/sh"
+
+// detectShell returns the shell that executes the commands and records it in the properties of the test suite
+func (context *Context) detectShell(suite *junitxml.JUnitTestSuite) (string, error) {
+	if len(context.options.DockerImage) > 0 || len(context.options.SSHDestination) > 0 {
+		// the shell runs in the container or on the remote machine, it cannot be detected on the host
+		shellpath := context.options.ShellName
+		if len(shellpath) == 0 {
+			shellpath = defaultRemoteShell
+		}
+		suite.AddProperty("shell", shellpath)
+		if len(context.options.DockerImage) > 0 {
+			suite.AddProperty("docker-image", context.options.DockerImage)
+		} else {
+			suite.AddProperty("ssh", context.options.SSHDestination)
+		}
+		return shellpath, nil
+	}
+	candidates := context.options.ShellCandidates
+	if candidates == nil {
+		candidates = shell.DefaultCandidates
+	}
+	shellpath, err := shell.DetectShellWithCandidates(context.options.ShellName, candidates)
+	if err != nil {
+		return "", err
+	}
+	suite.AddProperty("shell", shellpath)
+	return shellpath, nil
+}
+
+// readInteractions reads the input file and returns the tokenizer results for it
+func (context *Context) readInteractions(inputfile string) (*tokenizer.Visitor, error) {
+	var err error
+	data := context.stdin
+	if data == nil || inputfile != context.stdinName {
+		if data, err = ReadInput([]string{inputfile}); err != nil {
+			return nil, fmt.Errorf("unable to read input data: %v", err)
+		}
+	}
+	if _, remote := remoteURL(inputfile); remote && context.options.ResolveIncludes {
+		return nil, fmt.Errorf("includes cannot be resolved for the remote input %s", inputfile)
+	}
+	if context.options.ResolveIncludes {
+		if data, err = include.Resolve(data, filepath.Dir(inputfile)); err != nil {
+			return nil, fmt.Errorf("unable to resolve includes: %v", err)
+		}
+	}
+	// run the input through the tokenizer
+	visitor := tokenizer.NewInteractionVisitor()
+	if err := tokenizer.ParserFor(inputfile)(data, visitor); err != nil {
+		return nil, fmt.Errorf("unable to parse %s: %v", inputfile, err)
+	}
+	tokenizer.AssignIDs(inputfile, visitor.Interactions)
+	if visitor.Interactions, err = tokenizer.OrderBlocks(visitor.Interactions); err != nil {
+		return nil, fmt.Errorf("unable to order the code blocks in %s: %v", inputfile, err)
+	}
+	if len(context.options.Section) > 0 {
+		var selected []*tokenizer.Interaction
+		for _, interaction := range visitor.Interactions {
+			if interaction.InSection(context.options.Section) {
+				selected = append(selected, interaction)
+			}
+		}
+		visitor.Interactions = selected
+	}
+	return visitor, nil
+}
+
+// registerTolerated reports the result of a quarantined interaction, known failure or stale documentation without affecting the return code
+func (context *Context) registerTolerated(testcase *junitxml.JUnitTestCase, interaction *tokenizer.Interaction, err error, failuretype string) {
+	if err != nil {
+		fmt.Printf(" --  ERROR: %v", err)
+		testcase.RegisterError(failuretype, interaction.Result(), err.Error())
+	} else if interaction.HasFailure() {
+		testcase.RegisterFailure(failuretype, interaction.Result(), interaction.DescribeFull())
+	}
+}
+
+// skipInteraction marks the interaction as skipped and registers it with the test suite
+func (context *Context) skipInteraction(suite *junitxml.JUnitTestSuite, inputfile string, interaction *tokenizer.Interaction, reason string) {
+	interaction.Skip(reason)
+	testcase := context.newTestCase(inputfile, interaction)
+	testcase.RegisterSkipped(interaction.Result())
+	suite.RegisterTestCase(*testcase)
+}
+
+// newTestCase creates the test case for an interaction from the specified input file
+func (context *Context) newTestCase(inputfile string, interaction *tokenizer.Interaction) *junitxml.JUnitTestCase {
 	testcase := &junitxml.JUnitTestCase{
-		Name: interaction.Cmd,
+		Name:      interaction.Cmd,
+		Classname: inputfile,
+		ID:        interaction.ID,
+		File:      inputfile,
+		Line:      interaction.Line,
+	}
+	if context.options.ReplaceDots {
+		testcase.Classname = strings.ReplaceAll(inputfile, ".", "●")
 	}
+	// the raw command, expected response and attributes allow automated triage of the results
+	testcase.AddProperty("command", interaction.Cmd)
+	testcase.AddProperty("expected", strings.Join(interaction.Response, "\n"))
+	if len(interaction.ErrorResponse) > 0 {
+		testcase.AddProperty("expected-errors", strings.Join(interaction.ErrorResponse, "\n"))
+	}
+	var attributes []string
+	for name, value := range interaction.Attributes {
+		attributes = append(attributes, fmt.Sprintf("%s=%s", name, value))
+	}
+	if len(attributes) > 0 {
+		sort.Strings(attributes)
+		testcase.AddProperty("attributes", strings.Join(attributes, " "))
+	}
+	addCategory(testcase, interaction.Category)
+	// custom properties, for example to route failures to the owners of the documentation
+	properties := interaction.Properties()
+	var names []string
+	for name := range properties {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		testcase.AddProperty(name, properties[name])
+	}
+	return testcase
+}
+
+func (context *Context) performTestCase(inputfile string, interaction *tokenizer.Interaction, shell *shell.Shell,
+	processes *backgroundProcesses) (*junitxml.JUnitTestCase, error) {
+	testcase := context.newTestCase(inputfile, interaction)
 	defer junitxml.RegisterElapsedTime(time.Now(), &testcase.Time)
-	return testcase, interaction.Execute(&shell)
+	var err error
+	if _, ok := interaction.Attributes[tokenizer.BackgroundOption]; ok {
+		err = processes.start(shell, interaction)
+	} else {
+		err = interaction.Execute(shell)
+	}
+	testcase.AddProperty("exit-code", strconv.Itoa(interaction.ExitCode))
+	addCategory(testcase, interaction.Category)
+	testcase.SystemOut = strings.Join(interaction.Output, "\n")
+	testcase.SystemErr = strings.Join(interaction.ErrorOutput, "\n")
+	// full-screen tools and progress bars redraw the terminal, which garbles the captured lines
+	if interaction.HasFailure() {
+		lines, captured, err := shell.Screen()
+		if !captured && screen.HasControls(interaction.Output) {
+			lines, captured = screen.Render(interaction.Output), true
+		}
+		if err != nil {
+			log.Printf("unable to capture the screen: %v", err)
+		} else if captured {
+			rendered := strings.Join(lines, "\n")
+			testcase.AddProperty("screen", rendered)
+			if context.options.Verbose {
+				fmt.Printf(" --  final screen:\n%s\n", rendered)
+			}
+		}
+	}
+	return testcase, err
 }
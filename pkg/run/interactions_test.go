@@ -5,9 +5,23 @@ package run
 // SPDX-License-Identifier: Apache-2.0
 
 import (
+	"bytes"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
 	"github.com/stretchr/testify/require"
 )
 
@@ -23,26 +37,1124 @@ func TestMain(m *testing.M) {
 	os.Exit(m.Run())
 }
 func TestHelloWorld(t *testing.T) {
-	context := Context{
-		Verbose: true,
-	}
+	context := NewContext(WithVerbose(true))
 	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/helloworld.md")
 	require.NoError(t, err, "The HelloWorld example should execute without errors.")
 	require.Equal(t, returnSuccess, context.ReturnCode(), "The expected return code is returnSuccess.")
 	require.Equal(t, 4, testsuite.SuccessCount(), "There are three successful tests in the sample.")
+	require.Contains(t, testsuite.Properties, junitxml.JUnitProperty{Name: "shell", Value: os.Getenv("SHELL")}, "The shell is recorded.")
+}
+
+func TestConcurrentContexts(t *testing.T) {
+	contexts := []*Context{NewContext(), NewContext(WithDefaultExitCode("nonzero"))}
+	var wait sync.WaitGroup
+	for _, context := range contexts {
+		wait.Add(1)
+		go func(context *Context) {
+			defer wait.Done()
+			_, err := context.performInteractions("../../pkg/tokenizer/samples/nonzero.md")
+			require.NoError(t, err, "The nonzero example should execute without errors.")
+		}(context)
+	}
+	wait.Wait()
+	require.Equal(t, returnFailure, contexts[0].ReturnCode(), "By default, failing commands are test failures.")
+	require.Equal(t, returnSuccess, contexts[1].ReturnCode(), "The results of the runs are independent.")
+	require.Equal(t, "nonzero", contexts[1].Options().DefaultExitCode)
 }
 
 func TestHFailNoMatch(t *testing.T) {
-	context := Context{}
+	context := NewContext()
 	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/failnomatch.md")
 	require.NoError(t, err, "The failnomatch example should fail with a mismatch.")
 	require.Equal(t, returnFailure, context.ReturnCode(), "The expected return code is returnFailure.")
 	require.Equal(t, 1, testsuite.FailureCount(), "There is one failing test in the sample.")
+	testcase := testsuite.TestCases[0]
+	require.Equal(t, "No", testcase.SystemOut, "The test case contains the actual output.")
+	expected, _ := testcase.Property("expected")
+	require.Equal(t, "Yes", expected, "The test case contains the expected response.")
+	exitCode, _ := testcase.Property("exit-code")
+	require.Equal(t, "0", exitCode, "The test case contains the exit code.")
+	require.Equal(t, `got: "No", want: "Yes"`, testcase.Failure.Contents, "The failure describes the output and the expected response.")
 }
 
 func TestExitCodesOptions(t *testing.T) {
-	context := Context{}
+	context := NewContext()
 	_, err := context.performInteractions("../../pkg/tokenizer/samples/options.md")
 	require.NoError(t, err, "The HelloWorld example should execute without errors.")
 	require.Equal(t, returnSuccess, context.ReturnCode(), "The expected return code is returnSuccess.")
 }
+
+func TestDefaultExitCodePolicy(t *testing.T) {
+	context := NewContext(WithDefaultExitCode("nonzero"))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/nonzero.md")
+	require.NoError(t, err, "The nonzero example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "All commands fail as expected, or specify their exit code.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "There are three successful tests in the sample.")
+	context = NewContext()
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/nonzero.md")
+	require.NoError(t, err, "The nonzero example should execute without errors.")
+	require.Equal(t, returnFailure, context.ReturnCode(), "By default, failing commands are test failures.")
+	require.Equal(t, 2, testsuite.FailureCount(), "Two commands fail in the sample.")
+}
+
+func TestMaxFailures(t *testing.T) {
+	context := NewContext(WithMaxFailures(1))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/nonzero.md")
+	require.NoError(t, err, "The nonzero example should execute without errors.")
+	require.Equal(t, returnFailure, context.ReturnCode(), "The first command fails.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The run stops after the first failure.")
+	require.Equal(t, 2, testsuite.SkippedCount(), "The remaining tests are skipped.")
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/helloworld.md")
+	require.NoError(t, err, "The HelloWorld example should execute without errors.")
+	require.Equal(t, 4, testsuite.SkippedCount(), "The tests in the following files are skipped as well.")
+	require.Equal(t, 0, testsuite.SuccessCount(), "Skipped tests are not successful.")
+}
+
+func TestQuarantine(t *testing.T) {
+	quarantine, err := readQuarantine("../../pkg/tokenizer/samples/quarantine.txt")
+	require.NoError(t, err, "The quarantine file should be readable.")
+	context := NewContext()
+	context.quarantine = quarantine
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/failnomatch.md")
+	require.NoError(t, err, "The failnomatch example should fail with a mismatch.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "Quarantined failures do not affect the return code.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The quarantined failure is still reported.")
+	require.True(t, quarantine.contains("../../pkg/tokenizer/samples/failnomatch.md", testsuite.TestCases[0].ID),
+		"Commands are quarantined by their ID, which does not change when lines are added.")
+	require.False(t, quarantine.contains("../../pkg/tokenizer/samples/helloworld.md", "44cd5d7f549b"))
+}
+
+func TestBaseline(t *testing.T) {
+	const failnomatch = "../../pkg/tokenizer/samples/failnomatch.md"
+	directory, err := ioutil.TempDir("", "shelldoc-baseline-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "baseline.json")
+	_, err = readBaseline(path, false)
+	require.Error(t, err, "A baseline file that does not exist is an error, unless it is created.")
+	// the first run records the baseline
+	baseline, err := readBaseline(path, true)
+	require.NoError(t, err, "A baseline file that does not exist yet is created.")
+	context := NewContext(WithOptions(Options{BaselineFile: path}))
+	context.baseline = baseline
+	_, err = context.performInteractions(failnomatch)
+	require.NoError(t, err, "The failnomatch example should fail with a mismatch.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "All failures are accepted when recording the baseline.")
+	require.NoError(t, context.finishBaseline(), "Writing the baseline should work.")
+	// the following runs only fail on new failures
+	baseline, err = readBaseline(path, false)
+	require.NoError(t, err, "The baseline file should be readable.")
+	context = NewContext(WithOptions(Options{BaselineFile: path}))
+	context.baseline = baseline
+	testsuite, err := context.performInteractions(failnomatch)
+	require.NoError(t, err, "The failnomatch example should fail with a mismatch.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The known failure does not affect the return code.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The known failure is still reported.")
+	_, err = context.performInteractions("../../pkg/tokenizer/samples/nonzero.md")
+	require.NoError(t, err, "The nonzero example should execute without errors.")
+	require.Equal(t, returnFailure, context.ReturnCode(), "New failures affect the return code.")
+	require.Empty(t, baseline.fixed(), "The known failure has not been fixed.")
+}
+
+func TestBaselineFixed(t *testing.T) {
+	const helloworld = "../../pkg/tokenizer/samples/helloworld.md"
+	baseline, err := readBaseline("does-not-exist.json", true)
+	require.NoError(t, err, "A baseline file that does not exist yet is created.")
+	context := NewContext()
+	visitor, err := context.readInteractions(helloworld)
+	require.NoError(t, err)
+	world := visitor.Interactions[2]
+	baseline.capture = false
+	baseline.Failures = []baselineEntry{{helloworld, world.ID, world.Cmd}, {"other.md", "0123456789ab", "false"}}
+	baseline.known[baseline.Failures[0].key()] = true
+	context.baseline = baseline
+	_, err = context.performInteractions(helloworld)
+	require.NoError(t, err, "The HelloWorld example should execute without errors.")
+	fixed := baseline.fixed()
+	require.Len(t, fixed, 1, "Only known failures in tested files are reported as fixed.")
+	require.Equal(t, "echo World", fixed[0].Command)
+}
+
+func TestFileBudget(t *testing.T) {
+	config, err := ReadConfig("../../pkg/tokenizer/samples/config.json")
+	require.NoError(t, err, "The configuration file should be readable.")
+	context := NewContext(WithConfig(config))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/slow.md")
+	require.NoError(t, err, "The slow example should execute without errors.")
+	require.Equal(t, returnError, context.ReturnCode(), "The command that exceeds the budget is aborted with an error.")
+	require.Equal(t, 1, testsuite.SuccessCount(), "The first command finishes within the budget.")
+	require.Equal(t, 1, testsuite.ErrorCount(), "The second command is aborted.")
+	require.Equal(t, 1, testsuite.SkippedCount(), "The remaining command is skipped.")
+}
+
+func TestRedactions(t *testing.T) {
+	config, err := ReadConfig("../../pkg/tokenizer/samples/config.json")
+	require.NoError(t, err, "The configuration file should be readable.")
+	context := NewContext(WithConfig(config))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/redact.md")
+	require.NoError(t, err, "The redaction example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "The redacted output matches the placeholders.")
+	require.Equal(t, "created job <UUID>", testsuite.TestCases[0].SystemOut, "The results contain the redacted output.")
+	context = NewContext()
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/redact.md")
+	require.NoError(t, err, "The redaction example should execute without errors.")
+	require.Equal(t, 1, testsuite.SuccessCount(), "Without redactions, the volatile output does not match.")
+}
+
+func TestResponders(t *testing.T) {
+	config, err := ReadConfig("../../pkg/tokenizer/samples/config.json")
+	require.NoError(t, err, "The configuration file should be readable.")
+	context := NewContext(WithConfig(config))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/respond.md")
+	require.NoError(t, err, "The responder example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The prompts are answered automatically.")
+}
+
+func TestTransaction(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/transaction.md")
+	require.NoError(t, err, "The transaction example should execute without errors.")
+	require.Equal(t, 2, testsuite.TestCount(), "The transaction is one test case.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The combined output matches the combined responses.")
+}
+
+func TestCleanup(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/cleanup.md")
+	require.NoError(t, err, "The cleanup example should execute without errors.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The failing command is reported.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The cleanup command ran after the failure.")
+	// cleanup commands run in a new shell if the shell has been stopped
+	directory, err := ioutil.TempDir("", "shelldoc-cleanup-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	shellpath, err := shell.DetectShell("")
+	require.NoError(t, err, "A shell is needed for this test")
+	sh, err := shell.StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	sh.Kill()
+	sh.Exit()
+	marker := filepath.Join(directory, "cleaned")
+	context.runCleanup(testsuite, &sh, shellpath, shell.Options{}, &cleanup{block: 1, command: "touch " + shell.Quote(marker)})
+	_, err = os.Stat(marker)
+	require.NoError(t, err, "The cleanup command was executed in a new shell.")
+	// cleanup commands that are denied by the policy are not executed
+	defer os.Remove("shelldoc-cleanup.txt")
+	policyfile := filepath.Join(directory, "policy.txt")
+	require.NoError(t, ioutil.WriteFile(policyfile, []byte("deny ^rm\\b\n"), 0644))
+	context = NewContext()
+	context.policy, err = readPolicy(policyfile)
+	require.NoError(t, err)
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/cleanup.md")
+	require.NoError(t, err, "Denied cleanup commands are not execution errors.")
+	require.Equal(t, returnFailure, context.ReturnCode(), "Denied cleanup commands fail the test run.")
+	require.Equal(t, 2, testsuite.FailureCount(), "The file is not removed by the denied cleanup command.")
+	violation := ""
+	for _, property := range testsuite.Properties {
+		if property.Name == "policy-violation.cleanup.1" {
+			violation = property.Value
+		}
+	}
+	require.Equal(t, "rm -f shelldoc-cleanup.txt: denied by policy rule in line 1", violation,
+		"The policy violation is recorded in the test suite")
+}
+
+func TestVerifyIdempotent(t *testing.T) {
+	os.RemoveAll("shelldoc-idempotent")
+	defer os.RemoveAll("shelldoc-idempotent")
+	differences := func(testsuite *junitxml.JUnitTestSuite) []string {
+		var result []string
+		for _, property := range testsuite.Properties {
+			if property.Name == "not-idempotent" {
+				result = append(result, property.Value)
+			}
+		}
+		return result
+	}
+	context := NewContext(WithOptions(Options{Idempotent: true}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/idempotent.md")
+	require.NoError(t, err, "The idempotent example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The first run succeeds.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The second run does not affect the return code.")
+	require.Equal(t, []string{"../../pkg/tokenizer/samples/idempotent.md:7: mkdir shelldoc-idempotent (first run: SUCCESS, second run: FAILURE)"},
+		differences(testsuite), "The command that fails the second time is reported.")
+	// both runs are executed in the same temporary working directory
+	context = NewContext(WithOptions(Options{Idempotent: true, Workdir: WorkdirTemporary}))
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/idempotent.md")
+	require.NoError(t, err)
+	require.Len(t, differences(testsuite), 1, "The directory created in the first run exists in the second run.")
+	// the second run uses the command policy of the first one
+	context = NewContext(WithOptions(Options{Idempotent: true, Workdir: WorkdirTemporary}))
+	context.policy = &policy{rules: []policyRule{{policyDeny, regexp.MustCompile(`^mkdir`), 1}}}
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/idempotent.md")
+	require.NoError(t, err)
+	require.Equal(t, 1, testsuite.FailureCount(), "The denied command is not executed.")
+	require.Empty(t, differences(testsuite), "The denied command is not executed in the second run either.")
+}
+
+func TestSection(t *testing.T) {
+	context := NewContext(WithOptions(Options{Section: "Getting Started"}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/sections.md")
+	require.NoError(t, err, "The sections example should execute without errors.")
+	require.Equal(t, 2, testsuite.TestCount(), "Only the commands in the section and its subsections are executed.")
+	require.Equal(t, "echo start", testsuite.TestCases[0].Name)
+}
+
+func TestScreen(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/screen.md")
+	require.NoError(t, err, "The screen example should execute without errors.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The progress bar does not match.")
+	rendered, ok := testsuite.TestCases[0].Property("screen")
+	require.True(t, ok, "The final screen is attached to the failed test")
+	require.Equal(t, "100%", rendered)
+}
+
+func TestDelay(t *testing.T) {
+	context := NewContext()
+	start := time.Now()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/delay.md")
+	require.NoError(t, err, "The delay example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount())
+	require.Equal(t, 1, testsuite.ErrorCount(), "Invalid delays are reported as errors.")
+	require.True(t, time.Since(start) >= 200*time.Millisecond, "The commands are executed with a pause in between.")
+}
+
+func TestWaitUntilReady(t *testing.T) {
+	interval := waitInterval
+	waitInterval = 100 * time.Millisecond
+	defer func() { waitInterval = interval }()
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/wait.md")
+	require.NoError(t, err, "The wait example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The command waits until the service is ready.")
+	require.Equal(t, 2, testsuite.FailureCount(), "All commands of a code block that is not ready fail.")
+	require.Equal(t, "NOT READY", testsuite.TestCases[2].Failure.Type)
+	// readiness commands that are denied by the policy are not executed
+	context = NewContext()
+	context.policy = &policy{rules: []policyRule{{policyDeny, regexp.MustCompile(`shelldoc-never`), 1}}}
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/wait.md")
+	require.NoError(t, err, "Denied readiness commands are not execution errors.")
+	require.Equal(t, returnFailure, context.ReturnCode(), "Denied readiness commands fail the test run.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "Code blocks with allowed readiness commands are executed.")
+	require.Equal(t, 2, testsuite.FailureCount(), "The commands of the code block with the denied readiness command are denied.")
+	require.Equal(t, "POLICY", testsuite.TestCases[2].Failure.Type)
+	require.Equal(t, "readiness command denied by policy rule in line 1", testsuite.TestCases[2].Failure.Contents)
+	// the policy is checked after the placeholders have been replaced
+	context.policy = &policy{rules: []policyRule{{policyDeny, regexp.MustCompile(`:8080$`), 1}}}
+	interaction := &tokenizer.Interaction{Attributes: map[string]string{tokenizer.WaitForOption: "curl localhost:{{freeport}}"},
+		Vars: map[string]string{freePortVariable: "8080"}}
+	require.Equal(t, policyViolation("denied by policy rule in line 1"), context.waitUntilReady(nil, interaction))
+}
+
+func TestBackground(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/background.md")
+	require.NoError(t, err, "The background example should execute without errors.")
+	require.Equal(t, 4, testsuite.SuccessCount(), "Background processes are started, and the running one is found.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The crashed background process is reported.")
+	failure := testsuite.TestCases[4].Failure
+	require.Contains(t, failure.Contents, "the background process crash")
+	require.Contains(t, failure.Contents, "crashing", "The output of the crashed process is shown")
+}
+
+func TestFreePort(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/freeport.md")
+	require.NoError(t, err, "The free port example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The placeholder is replaced in commands and expected responses.")
+	require.Equal(t, "echo \"listening on port {{freeport}}\"", testsuite.TestCases[0].Name, "Test names do not change")
+	port, err := freePort()
+	require.NoError(t, err)
+	require.True(t, port > 0 && port < 65536, "Free ports are valid port numbers")
+}
+
+func TestErrorOutput(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/stderr.md")
+	require.NoError(t, err, "The error output example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The expected error output matches.")
+	require.Equal(t, 1, testsuite.FailureCount(), "Unexpected error output is reported.")
+	require.Equal(t, "no such file", testsuite.TestCases[0].SystemErr, "The error output is part of the results")
+}
+
+func TestFixtures(t *testing.T) {
+	context := NewContext(WithOptions(Options{FixturesDir: "../../pkg/tokenizer/samples/fixtures"}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/fixtures.md")
+	require.NoError(t, err, "The fixtures example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "The fixtures are copied into the working directory.")
+	data, err := ioutil.ReadFile("../../pkg/tokenizer/samples/fixtures/config.yaml")
+	require.NoError(t, err)
+	require.Equal(t, "port: 8080\n", string(data), "The fixtures directory is not modified")
+	_, err = os.Stat("../../pkg/tokenizer/samples/fixtures/output.txt")
+	require.True(t, os.IsNotExist(err), "Files are created in the copy")
+	context = NewContext(WithOptions(Options{FixturesDir: "../../pkg/tokenizer/samples/missing"}))
+	_, err = context.performInteractions("../../pkg/tokenizer/samples/fixtures.md")
+	require.Error(t, err, "A missing fixtures directory is an error")
+}
+
+func TestWorkdir(t *testing.T) {
+	context := NewContext(WithOptions(Options{Workdir: WorkdirTemporary}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/workdir.md")
+	require.NoError(t, err, "The workdir example should execute without errors.")
+	require.Equal(t, 4, testsuite.SuccessCount(), "The commands are executed in the temporary directory")
+	require.Equal(t, 1, testsuite.ErrorCount(), "Missing directories are reported")
+	require.Equal(t, "ERROR (directory)", testsuite.TestCases[4].Error.Message)
+	directory, err := ioutil.TempDir("", "shelldoc-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+	context = NewContext(WithOptions(Options{Workdir: directory}))
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/workdir.md")
+	require.NoError(t, err)
+	require.Equal(t, 4, testsuite.SuccessCount(), "The commands are executed in the specified directory")
+	_, err = os.Stat(filepath.Join(directory, "project", "docs", "README.md"))
+	require.NoError(t, err, "Specified working directories are not removed")
+	require.Error(t, validateWorkdir(Options{Workdir: WorkdirTemporary, FixturesDir: "fixtures"}),
+		"Fixtures cannot be combined with a working directory")
+}
+
+func TestVerifyFile(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/verifyfile.md")
+	require.NoError(t, err, "The file verification example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "The file contents match.")
+	require.Equal(t, 2, testsuite.FailureCount(), "Different contents and missing files fail.")
+	require.Equal(t, "FAIL (mismatch)", testsuite.TestCases[2].Failure.Message, "The indentation is verified.")
+	require.Equal(t, "FAIL (execution failed)", testsuite.TestCases[3].Failure.Message, "The missing file is reported.")
+}
+
+func TestCategories(t *testing.T) {
+	category := func(testcase junitxml.JUnitTestCase) string {
+		for _, property := range testcase.Properties.Properties {
+			if property.Name == "category" {
+				return property.Value
+			}
+		}
+		return ""
+	}
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/categories.md")
+	require.NoError(t, err, "The categories example should execute without errors.")
+	require.Equal(t, returnFailure, context.ReturnCode())
+	require.Equal(t, "output-mismatch", category(testsuite.TestCases[0]))
+	require.Equal(t, "wrong-exit-code", category(testsuite.TestCases[1]))
+	tolerated, err := parseCategories([]string{"output-mismatch", "wrong-exit-code"})
+	require.NoError(t, err)
+	context = NewContext()
+	context.tolerated = tolerated
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/categories.md")
+	require.NoError(t, err)
+	require.Equal(t, returnSuccess, context.ReturnCode(), "Failures of tolerated categories do not fail the run")
+	require.Equal(t, "TOLERATED", testsuite.TestCases[0].Failure.Type, "Tolerated failures are reported")
+	_, err = parseCategories([]string{"flaky"})
+	require.Error(t, err, "Unknown categories are rejected")
+}
+
+func TestCaptures(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/captures.md")
+	require.NoError(t, err, "The captures example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "The captured value is exported to the following commands.")
+	require.Equal(t, 1, testsuite.FailureCount(), "Patterns match complete lines.")
+}
+
+func TestRegexLines(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/regexlines.md")
+	require.NoError(t, err, "The regex lines example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "Lines with the re: prefix are regular expressions")
+	require.Equal(t, 1, testsuite.FailureCount(), "The other lines match literally")
+}
+
+func TestGlob(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/glob.md")
+	require.NoError(t, err, "The glob example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "Wildcards match volatile values")
+	require.Equal(t, 1, testsuite.FailureCount(), "Escaped wildcards match literally")
+}
+
+func TestAnchors(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/anchors.md")
+	require.NoError(t, err, "The anchors example should execute without errors.")
+	require.Equal(t, 4, testsuite.SuccessCount(), "Anchored and unanchored lines match parts of the output")
+	require.Equal(t, 1, testsuite.FailureCount(), "Anchored lines have to match at the start of the line")
+}
+
+func TestExitCodes(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/exitcodes.md")
+	require.NoError(t, err, "The exit codes example should execute without errors.")
+	require.Equal(t, 5, testsuite.SuccessCount(), "Ranges, lists, nonzero and any are supported")
+	require.Equal(t, 1, testsuite.FailureCount(), "Exit codes outside of the range fail")
+}
+
+func TestSorted(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/sorted.md")
+	require.NoError(t, err, "The sorted example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The order of the lines does not matter")
+	require.Equal(t, 1, testsuite.FailureCount(), "Missing lines are detected")
+	require.Equal(t, "got: \"cherry\napple\", want: \"apple\nbanana\"", testsuite.TestCases[2].Failure.Contents,
+		"The failure shows the original order")
+}
+
+func TestSkip(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/skip.md")
+	require.NoError(t, err, "The skip example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "Skipped commands do not fail the test")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The commands without shelldocskip are executed")
+	require.Equal(t, 3, testsuite.SkippedCount(), "The skipped commands are counted")
+	require.Equal(t, "SKIPPED (needs access to the release server)", testsuite.TestCases[1].SkipMessage.Message,
+		"The reason is reported")
+	require.Equal(t, "SKIPPED (marked with shelldocskip)", testsuite.TestCases[3].SkipMessage.Message,
+		"Without a reason, the attribute is named")
+	var output bytes.Buffer
+	require.NoError(t, junitxml.JUnitTestSuites{Suites: []junitxml.JUnitTestSuite{*testsuite}}.Write(&output))
+	require.Contains(t, output.String(), "<skipped>SKIPPED (needs access to the release server)</skipped>",
+		"Skipped commands are reported in the JUnit XML")
+}
+
+func TestTerminalInput(t *testing.T) {
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid is not available")
+	}
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/tty.md")
+	require.NoError(t, err, "The terminal example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "Prompts on the terminal are answered")
+	require.Equal(t, 4, testsuite.SuccessCount(), "All commands succeed")
+}
+
+func TestSeed(t *testing.T) {
+	context := NewContext(WithOptions(Options{Seed: 42}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/seed.md")
+	require.NoError(t, err, "The seed example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The seed is exported as SHELLDOC_SEED")
+	require.Contains(t, testsuite.Properties, junitxml.JUnitProperty{Name: "seed", Value: "42"},
+		"The seed is recorded in the properties of the test suite")
+	seed := NewContext().seed
+	require.True(t, seed > 0 && seed <= math.MaxInt32, "Random seeds are positive and fit into 31 bits")
+}
+
+func TestDirectives(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/directives.md")
+	require.NoError(t, err, "The directives example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "Variables set with env directives are exported")
+	require.Equal(t, 1, testsuite.SkippedCount(), "skip-next skips the next code block")
+	require.Equal(t, "SKIPPED (needs access to the release server)", testsuite.TestCases[1].SkipMessage.Message)
+	require.Equal(t, 1, testsuite.ErrorCount(), "The command that exceeds its timeout is stopped")
+	category, _ := testsuite.TestCases[2].Property("category")
+	require.Equal(t, "timeout", category)
+	require.Contains(t, testsuite.Properties, junitxml.JUnitProperty{Name: "skipped-block.1",
+		Value: "line=30 block=0 language= reason=invalid-directive"}, "Invalid directives are reported")
+}
+
+func TestDefaults(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/defaults.md")
+	require.NoError(t, err, "The defaults example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "The code blocks inherit the default attributes")
+}
+
+func TestIsolation(t *testing.T) {
+	for mode, successes := range map[string]int{IsolateFile: 2, IsolateBlock: 3, IsolateInteraction: 2} {
+		context := NewContext(WithOptions(Options{Isolation: mode}))
+		testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/isolation.md")
+		require.NoError(t, err, "The isolation example should execute without errors.")
+		require.Equal(t, successes, testsuite.SuccessCount(), "Isolation mode %s", mode)
+	}
+	require.Error(t, validateIsolation("none"), "Unknown isolation modes are rejected")
+}
+
+func TestUser(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/user.md")
+	require.NoError(t, err, "The user example should execute without errors.")
+	if os.Geteuid() != 0 {
+		require.Equal(t, 1, testsuite.SuccessCount())
+		require.Equal(t, 2, testsuite.SkippedCount(), "Without root privileges, the commands of other users are skipped")
+		return
+	}
+	require.Equal(t, 3, testsuite.SuccessCount(), "The commands are executed as the user")
+	property := func(testcase junitxml.JUnitTestCase) string {
+		for _, property := range testcase.Properties.Properties {
+			if property.Name == "user" {
+				return property.Value
+			}
+		}
+		return ""
+	}
+	require.Equal(t, "", property(testsuite.TestCases[0]))
+	require.Equal(t, "nobody", property(testsuite.TestCases[1]), "The user is recorded in the results")
+}
+
+func TestRegisterFormat(t *testing.T) {
+	// a format with one command per line, the expected responses follow after " => "
+	tokenizer.RegisterFormat(func(data []byte, visitor *tokenizer.Visitor) error {
+		for index, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+			parts := strings.SplitN(line, " => ", 2)
+			interaction := &tokenizer.Interaction{Cmd: parts[0], Block: index + 1, Line: index + 1}
+			if len(parts) > 1 {
+				interaction.Response = []string{parts[1]}
+			}
+			visitor.Interactions = append(visitor.Interactions, interaction)
+		}
+		return nil
+	}, ".cmds")
+	directory, err := ioutil.TempDir("", "shelldoc-format-")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+	input := filepath.Join(directory, "example.cmds")
+	require.NoError(t, ioutil.WriteFile(input, []byte("echo one => one\necho two => three\n"), 0644))
+	context := NewContext()
+	testsuite, err := context.performInteractions(input)
+	require.NoError(t, err, "Input files in registered formats should execute without errors.")
+	require.Equal(t, 1, testsuite.SuccessCount(), "The interactions are found by the registered parser.")
+	require.Equal(t, 1, testsuite.FailureCount())
+}
+
+func TestTmux(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	context := NewContext(WithOptions(Options{Tmux: true, TerminalSize: "80x24"}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/terminal.md")
+	require.NoError(t, err, "The terminal example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The commands are executed in a terminal")
+	width, height, err := parseTerminalSize("120X40")
+	require.NoError(t, err)
+	require.Equal(t, []int{120, 40}, []int{width, height})
+	_, _, err = parseTerminalSize("wide")
+	require.Error(t, err, "Invalid terminal sizes are rejected")
+}
+
+func TestValidateBackend(t *testing.T) {
+	require.NoError(t, validateBackend(Options{SSHDestination: "admin@server"}))
+	require.Error(t, validateBackend(Options{DockerImage: "debian:12", EnterCommand: "nix develop --command"}),
+		"Only one backend can be selected")
+	require.Error(t, validateBackend(Options{Tmux: true, TerminalSize: "80"}), "The terminal size needs columns and lines")
+	require.Error(t, validateBackend(Options{SSHDestination: "admin@server", FixturesDir: "fixtures"}),
+		"Fixtures are not available on remote machines")
+}
+
+func TestSquash(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/squash.md")
+	require.NoError(t, err, "The squash example should execute without errors.")
+	require.Equal(t, 1, testsuite.SuccessCount(), "Squashed lines match the repeated output.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The number of repeated lines is verified.")
+}
+
+func TestNormalizers(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/normalize.md")
+	require.NoError(t, err, "The normalization example should execute without errors.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The normalized values compare as equal.")
+	require.Equal(t, 1, testsuite.FailureCount(), "Values are only normalized in the selected code blocks.")
+}
+
+func TestTimeoutMultiplier(t *testing.T) {
+	environment := map[string]string{}
+	getenv := func(name string) string { return environment[name] }
+	multiplier, err := resolveTimeoutMultiplier(0, getenv)
+	require.NoError(t, err)
+	require.Equal(t, 1.0, multiplier, "Timeouts are not scaled by default.")
+	environment[TimeoutMultiplierVariable] = "2.5"
+	multiplier, err = resolveTimeoutMultiplier(0, getenv)
+	require.NoError(t, err)
+	require.Equal(t, 2.5, multiplier, "The multiplier is read from the environment.")
+	multiplier, err = resolveTimeoutMultiplier(3, getenv)
+	require.NoError(t, err)
+	require.Equal(t, 3.0, multiplier, "The command line takes precedence over the environment.")
+	_, err = resolveTimeoutMultiplier(-1, getenv)
+	require.Error(t, err, "The multiplier needs to be positive.")
+	environment[TimeoutMultiplierVariable] = "slow"
+	_, err = resolveTimeoutMultiplier(0, getenv)
+	require.Error(t, err, "The multiplier needs to be a number.")
+	context := NewContext(WithOptions(Options{TimeoutFactor: 4}))
+	require.Equal(t, 2*time.Second, context.scaleTimeout(500*time.Millisecond), "Timeouts are scaled by the multiplier.")
+}
+
+func TestXMLAppend(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-xml-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	path := filepath.Join(directory, "results.xml")
+	for _, sample := range []string{"helloworld.md", "failnomatch.md"} {
+		context := NewContext(WithOptions(Options{XMLOutputFile: path, XMLAppend: true}))
+		testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/" + sample)
+		require.NoError(t, err, "The samples should execute without errors.")
+		context.Suites.Suites = append(context.Suites.Suites, *testsuite)
+		require.NoError(t, context.WriteReports(), "Writing the XML output file should work.")
+	}
+	suites, err := readXML(path)
+	require.NoError(t, err, "Reading the XML output file should work.")
+	require.Len(t, suites.Suites, 2, "The second run appended its test suite.")
+	require.Equal(t, 5, suites.Tests, "The totals are recomputed.")
+	require.Equal(t, 1, suites.Failures, "The totals are recomputed.")
+}
+
+func TestWriteReports(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-reports-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	context := NewContext(WithOptions(Options{
+		XMLOutputFile:  filepath.Join(directory, "results.xml"),
+		HTMLOutputFile: filepath.Join(directory, "results.html"),
+		JSONOutputFile: filepath.Join(directory, "results.json"),
+		SummaryFile:    filepath.Join(directory, "summary.md"),
+	}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/helloworld.md")
+	require.NoError(t, err, "The HelloWorld example should execute without errors.")
+	context.Suites.Suites = append(context.Suites.Suites, *testsuite)
+	require.NoError(t, context.WriteReports(), "All report files should be written in one run.")
+	for _, name := range []string{"results.xml", "results.html", "results.json", "summary.md"} {
+		data, err := ioutil.ReadFile(filepath.Join(directory, name))
+		require.NoError(t, err, "The report file exists.")
+		require.Contains(t, string(data), "helloworld.md", "The report contains the test suite.")
+	}
+}
+
+func TestDescriptionWidth(t *testing.T) {
+	os.Setenv("COLUMNS", "120")
+	defer os.Unsetenv("COLUMNS")
+	require.Equal(t, 120-resultColumns, NewContext().descriptionWidth(), "The description fits the terminal")
+	require.Equal(t, 50, NewContext(WithOptions(Options{ElideWidth: 50})).descriptionWidth())
+	require.Equal(t, 0, NewContext(WithOptions(Options{ElideWidth: -1})).descriptionWidth(), "-1 disables eliding")
+	require.Equal(t, 0, NewContext(WithVerbose(true)).descriptionWidth(), "Verbose output is never elided")
+	os.Setenv("COLUMNS", "40")
+	require.Equal(t, minimumDescriptionWidth, NewContext().descriptionWidth())
+}
+
+func TestMetadata(t *testing.T) {
+	metadata, err := parseMetadata([]string{"build=1234", "image=alpine@sha256:abc=="})
+	require.NoError(t, err)
+	require.Equal(t, []junitxml.JUnitProperty{{Name: "build", Value: "1234"}, {Name: "image", Value: "alpine@sha256:abc=="}},
+		metadata, "The value may contain equal signs")
+	context := NewContext()
+	context.metadata = metadata
+	suite := junitxml.JUnitTestSuite{Name: "README.md"}
+	context.addMetadata(&suite)
+	require.Equal(t, metadata, suite.Properties, "The properties are attached to the test suite")
+	for _, invalid := range []string{"build", "=1234", " =1234"} {
+		_, err := parseMetadata([]string{invalid})
+		require.Error(t, err, "%s is rejected", invalid)
+	}
+}
+
+func TestXMLDir(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-xml-")
+	require.NoError(t, err, "Unable to create temporary directory")
+	defer os.RemoveAll(directory)
+	samples := []string{"../../pkg/tokenizer/samples/helloworld.md", "../../pkg/tokenizer/samples/failnomatch.md"}
+	context := NewContext(WithOptions(Options{XMLOutputDir: filepath.Join(directory, "reports", "xml")}),
+		WithFiles(samples...))
+	require.NoError(t, context.prepareReports(), "The report directory is created before the run")
+	for _, sample := range samples {
+		testsuite, err := context.performInteractions(sample)
+		require.NoError(t, err, "The samples should execute without errors.")
+		context.Suites.Suites = append(context.Suites.Suites, *testsuite)
+	}
+	require.NoError(t, context.WriteReports(), "Writing the XML output files should work.")
+	suites, err := readXML(filepath.Join(directory, "reports", "xml", "TEST-.._.._pkg_tokenizer_samples_failnomatch.md.xml"))
+	require.NoError(t, err, "Every input file has its own report")
+	require.Len(t, suites.Suites, 1, "The report contains the test suite of its input file")
+	require.Equal(t, samples[1], suites.Suites[0].Name)
+	context = NewContext(WithOptions(Options{XMLOutputFile: filepath.Join(directory, "reports", "xml",
+		"TEST-.._.._pkg_tokenizer_samples_helloworld.md.xml", "results.xml")}))
+	require.Error(t, context.prepareReports(), "Report files that cannot be written are detected before the run")
+}
+
+func TestResourceUsage(t *testing.T) {
+	context := NewContext(WithOptions(Options{ResourceUsage: true, Verbose: true}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/helloworld.md")
+	require.NoError(t, err, "The HelloWorld example should execute without errors.")
+	require.Equal(t, 4, testsuite.SuccessCount(), "Measuring resources does not affect the results.")
+	count := 0
+	for _, property := range testsuite.Properties {
+		if strings.HasPrefix(property.Name, "resource-usage.") {
+			require.Contains(t, property.Value, "wall ", "The property contains the wall time.")
+			count++
+		}
+	}
+	require.Equal(t, 4, count, "The resource usage of every command is reported.")
+}
+
+func TestShellStrict(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/strict.md")
+	require.NoError(t, err, "The strict mode example should execute without errors.")
+	require.Equal(t, 5, testsuite.SuccessCount(), "Without strict mode, all commands succeed")
+	context = NewContext(WithOptions(Options{ShellStrict: true}))
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/strict.md")
+	require.NoError(t, err, "The strict mode example should execute without errors.")
+	require.Equal(t, returnError, context.ReturnCode())
+	require.Equal(t, 3, testsuite.SuccessCount(), "The opted out block, the expected failure and the last command succeed")
+	require.Equal(t, 2, testsuite.ErrorCount(), "The failing command and the unset variable trip strict mode")
+	for _, index := range []int{0, 1} {
+		require.NotNil(t, testsuite.TestCases[index].Error)
+		require.Contains(t, testsuite.TestCases[index].Error.Contents, "strict mode", "The tripped interaction is reported")
+	}
+	require.Nil(t, testsuite.TestCases[2].Error, "The code block opted out of strict mode")
+}
+
+func TestEnvironment(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/environment.md")
+	require.NoError(t, err, "The environment example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The SHELLDOC variables describe the file, block and interaction.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "There are three successful tests in the sample.")
+}
+
+func TestProfile(t *testing.T) {
+	config, err := ReadConfig("../../pkg/tokenizer/samples/config.json")
+	require.NoError(t, err, "The configuration file should be readable.")
+	context := NewContext(WithConfig(config), WithProfile("ci"), WithShell("bash"))
+	options, err := context.resolveOptions(strings.NewReader(""))
+	require.NoError(t, err, "The ci profile is defined in the configuration file.")
+	require.Equal(t, "bash", options.ShellName, "The command line takes precedence over the profile.")
+	require.True(t, options.CleanStartup, "The profile enables a clean startup.")
+	require.Equal(t, 2.0, options.TimeoutFactor, "The profile sets the timeout multiplier.")
+	require.False(t, context.options.CleanStartup, "The options of the context are not changed.")
+	context.options = options
+	require.Equal(t, []string{"CI=true", "GREETING=Hello"}, context.environment, "The variables are sorted by name.")
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/profile.md")
+	require.NoError(t, err, "The profile example should execute without errors.")
+	require.Equal(t, 1, testsuite.SuccessCount(), "The commands see the environment of the profile.")
+	context = NewContext(WithConfig(config), WithProfile("docker"))
+	_, err = context.resolveOptions(strings.NewReader(""))
+	require.Error(t, err, "Undefined profiles are rejected.")
+}
+
+func TestEnvFile(t *testing.T) {
+	os.Setenv("SHELLDOC_TEST_TARGET", "world")
+	defer os.Unsetenv("SHELLDOC_TEST_TARGET")
+	context := NewContext(WithOptions(Options{EnvFiles: []string{"../../pkg/tokenizer/samples/envfile.env"},
+		Env: []string{"TARGET=mars", "SHELLDOC_TEST_TARGET"}}))
+	require.NoError(t, context.applyEnvironment())
+	require.Equal(t, []string{"GREETING=Hello", "TARGET=moon", "TARGET=mars", "SHELLDOC_TEST_TARGET=world"},
+		context.environment, "The command line is applied after the environment files")
+	context = NewContext(WithOptions(Options{EnvFiles: []string{"../../pkg/tokenizer/samples/envfile.env"},
+		Env: []string{"TARGET=world"}}))
+	require.NoError(t, context.applyEnvironment())
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/envfile.md")
+	require.NoError(t, err, "The environment file example should execute without errors.")
+	require.Equal(t, 1, testsuite.SuccessCount(), "Variables on the command line take precedence")
+	for _, invalid := range []string{"1ST=value", "SHELLDOC_TEST_UNSET"} {
+		context = NewContext(WithOptions(Options{Env: []string{invalid}}))
+		require.Error(t, context.applyEnvironment(), "%s is rejected", invalid)
+	}
+	context = NewContext(WithOptions(Options{EnvFiles: []string{"../../pkg/tokenizer/samples/envfile.md"}}))
+	require.Error(t, context.applyEnvironment(), "Environment files need NAME=VALUE lines")
+}
+
+func TestWarningBudget(t *testing.T) {
+	budget := 1
+	context := NewContext(WithOptions(Options{WarningPatterns: []string{"(?i)deprecated"}, WarningBudget: -1}),
+		WithConfig(&Config{Warnings: WarningConfig{Budget: &budget}}))
+	warnings, err := newWarningBudget(context.options)
+	require.NoError(t, err)
+	context.warnings = warnings
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/warnings.md")
+	require.NoError(t, err, "The warnings example should execute without errors.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "Warnings do not fail the commands")
+	require.Equal(t, 2, context.warnings.count, "Warnings are counted in the output and error output")
+	context.checkWarningBudget()
+	require.Equal(t, returnFailure, context.ReturnCode(), "The run fails if the warnings exceed the budget")
+	context = NewContext(WithOptions(Options{WarningPatterns: []string{"(?i)deprecated"}, WarningBudget: 2}),
+		WithConfig(&Config{Warnings: WarningConfig{Budget: &budget}}))
+	context.warnings, err = newWarningBudget(context.options)
+	require.NoError(t, err)
+	_, err = context.performInteractions("../../pkg/tokenizer/samples/warnings.md")
+	require.NoError(t, err)
+	context.checkWarningBudget()
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The budget of the options takes precedence")
+	_, err = newWarningBudget(Options{WarningPatterns: []string{"("}})
+	require.Error(t, err, "Invalid patterns are rejected")
+}
+
+func TestInterrupts(t *testing.T) {
+	exitCode := -1
+	context := NewContext()
+	context.interrupts = &interrupts{shells: make(map[*shell.Shell]bool), exit: func(code int) { exitCode = code }}
+	context.interrupts.interrupt()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/helloworld.md")
+	require.NoError(t, err, "The HelloWorld example should execute without errors.")
+	require.Equal(t, testsuite.TestCount(), testsuite.SkippedCount(), "After an interrupt, the remaining commands are skipped.")
+	require.Equal(t, -1, exitCode, "The first interrupt does not exit.")
+	context.interrupts.interrupt()
+	require.Equal(t, returnError, exitCode, "The second interrupt exits immediately.")
+}
+
+func TestPolicy(t *testing.T) {
+	policy, err := readPolicy("../../pkg/tokenizer/samples/policy.txt")
+	require.NoError(t, err, "The policy sample should be readable.")
+	context := NewContext()
+	context.policy = policy
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/policy.md")
+	require.NoError(t, err, "Denied commands are not execution errors.")
+	require.Equal(t, returnFailure, context.ReturnCode(), "Denied commands fail the test run.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "The harmless commands are executed.")
+	require.Equal(t, 2, testsuite.FailureCount(), "The denied commands are reported as policy failures.")
+	require.Zero(t, testsuite.ErrorCount(), "Denied commands are not execution errors.")
+	require.Equal(t, "POLICY", testsuite.TestCases[2].Failure.Type, "The explicitly denied command is a policy failure.")
+	require.Contains(t, testsuite.TestCases[3].Failure.Contents, "not allowed", "Commands that match no allow rule are denied.")
+}
+
+func TestPolicyErrors(t *testing.T) {
+	file, err := ioutil.TempFile("", "shelldoc-policy-")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("permit ^echo\n")
+	require.NoError(t, err)
+	file.Close()
+	_, err = readPolicy(file.Name())
+	require.Error(t, err, "Unknown actions are rejected.")
+	require.NoError(t, ioutil.WriteFile(file.Name(), []byte("deny ([a-z]\n"), 0644))
+	_, err = readPolicy(file.Name())
+	require.Error(t, err, "Invalid regular expressions are rejected.")
+}
+
+func TestDryRun(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.dryRunInteractions("../../pkg/tokenizer/samples/syntax.md")
+	require.NoError(t, err, "A dry run does not execute the commands.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "Without syntax check, the dry run succeeds.")
+	require.Equal(t, 2, testsuite.SkippedCount(), "All commands are skipped.")
+	context = NewContext(WithOptions(Options{CheckSyntax: true}))
+	testsuite, err = context.dryRunInteractions("../../pkg/tokenizer/samples/syntax.md")
+	require.NoError(t, err, "A dry run does not execute the commands.")
+	require.Equal(t, returnFailure, context.ReturnCode(), "The syntax error is a failure.")
+	require.Equal(t, 1, testsuite.SkippedCount(), "The valid command is skipped.")
+	require.Equal(t, 1, testsuite.FailureCount(), "The command with the missing quote fails the syntax check.")
+}
+
+func TestDirectoryReset(t *testing.T) {
+	context := NewContext(WithOptions(Options{DirectoryMode: DirectoryReset}))
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/directory.md")
+	require.NoError(t, err, "The directory example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The working directory is reset unless shelldocdir is specified.")
+	require.Equal(t, 4, testsuite.SuccessCount(), "There are four successful tests in the sample.")
+	context = NewContext(WithOptions(Options{DirectoryMode: DirectoryWarn}))
+	testsuite, err = context.performInteractions("../../pkg/tokenizer/samples/directory.md")
+	require.NoError(t, err, "The directory example should execute without errors.")
+	require.Equal(t, 1, testsuite.FailureCount(), "Without reset, the second code block runs in the root directory.")
+}
+
+func TestVars(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/vars.md")
+	require.NoError(t, err, "The vars example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The variables are substituted consistently in commands and responses.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "There are three successful tests in the sample.")
+}
+
+func TestSnippets(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/snippets.md")
+	require.NoError(t, err, "The snippets example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The replayed setup steps are executed before the following commands.")
+	require.Equal(t, 6, testsuite.SuccessCount(), "There are six successful tests in the sample.")
+}
+
+func TestNeeds(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/needs.md")
+	require.NoError(t, err, "The needs example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The needed code block is executed first.")
+	require.Equal(t, 2, testsuite.SuccessCount(), "There are two successful tests in the sample.")
+}
+
+func TestDetailsOutput(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/details.md")
+	require.NoError(t, err, "The details example should execute without errors.")
+	require.Equal(t, returnSuccess, context.ReturnCode(), "The collapsed output is the expected response.")
+	require.Equal(t, 3, testsuite.SuccessCount(), "There are three successful tests in the sample.")
+}
+
+func TestAdvisory(t *testing.T) {
+	context := NewContext(WithOptions(Options{Advisory: true, Files: []string{"../../pkg/tokenizer/samples/failnomatch.md"}}))
+	code, err := context.ExecuteFiles()
+	require.NoError(t, err)
+	require.Equal(t, returnSuccess, code, "In advisory mode, failures do not affect the exit code.")
+	require.Equal(t, 1, context.stale(), "The failure is reported as stale documentation.")
+	require.Equal(t, 1, context.Suites.Suites[0].FailureCount(), "The failure is still recorded.")
+	require.Equal(t, "STALE DOCUMENTATION", context.Suites.Suites[0].TestCases[0].Failure.Type, "The failure is marked as stale documentation.")
+}
+
+func TestExecuteFilesErrors(t *testing.T) {
+	context := NewContext(WithOptions(Options{Isolation: "none", Files: []string{"../../pkg/tokenizer/samples/helloworld.md"}}))
+	code, err := context.ExecuteFiles()
+	require.Error(t, err, "Invalid options are returned instead of exiting")
+	require.Equal(t, returnError, code)
+	context = NewContext(WithFiles("../../pkg/tokenizer/samples/helloworld.md"))
+	code, err = context.ExecuteFiles()
+	require.NoError(t, err)
+	require.Equal(t, returnSuccess, code)
+	require.Nil(t, context.interrupts, "Ctrl+C is only handled if requested")
+	code, err = context.ExecuteFiles()
+	require.Error(t, err, "Every run needs its own context")
+	require.Equal(t, returnError, code)
+	require.Len(t, context.Suites.Suites, 1, "The results of the first run are kept")
+}
+
+func TestWritePatch(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-patch-")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+	patchfile := filepath.Join(directory, "fixes.patch")
+	context := NewContext(WithOptions(Options{PatchFile: patchfile, Files: []string{"../../pkg/tokenizer/samples/stale.md"}}))
+	code, err := context.ExecuteFiles()
+	require.NoError(t, err)
+	require.Equal(t, returnFailure, code, "The stale documentation fails.")
+	data, err := ioutil.ReadFile(patchfile)
+	require.NoError(t, err, "The patch file was written.")
+	expected := "--- a/../../pkg/tokenizer/samples/stale.md\n+++ b/../../pkg/tokenizer/samples/stale.md\n" +
+		"@@ -3,12 +3,14 @@\n This output changed:\n \n     $ echo \"Hello World\"\n-    Hello\n+    Hello World\n \n" +
+		" This output was never documented:\n \n ```shell\n $ echo one; echo two\n+one\n+two\n ```\n \n This one is correct:\n"
+	require.Equal(t, expected, string(data), "The patch updates the expected responses to the actual output.")
+}
+
+func TestUpdate(t *testing.T) {
+	directory, err := ioutil.TempDir("", "shelldoc-update-")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+	data, err := ioutil.ReadFile("../../pkg/tokenizer/samples/stale.md")
+	require.NoError(t, err)
+	sample := filepath.Join(directory, "stale.md")
+	require.NoError(t, ioutil.WriteFile(sample, data, 0644))
+	context := NewContext(WithOptions(Options{Update: true, Files: []string{sample}}))
+	code, err := context.ExecuteFiles()
+	require.NoError(t, err)
+	require.Equal(t, returnFailure, code, "The mismatches are still reported.")
+	updated, err := ioutil.ReadFile(sample)
+	require.NoError(t, err)
+	require.Contains(t, string(updated), "    $ echo \"Hello World\"\n    Hello World\n", "The expected response is rewritten.")
+	require.Contains(t, string(updated), "$ echo one; echo two\none\ntwo\n```", "The missing response is added.")
+	context = NewContext(WithOptions(Options{Files: []string{sample}}))
+	code, err = context.ExecuteFiles()
+	require.NoError(t, err)
+	require.Equal(t, returnSuccess, code, "The updated documentation passes.")
+}
+
+func TestContinuation(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/continuation.md")
+	require.NoError(t, err, "The continuation example should execute without errors.")
+	require.Equal(t, 4, testsuite.SuccessCount(), "Commands with continuation lines are executed as written")
+	lines := []string{"$ echo one \\", "  two", "one two", "", "$ true"}
+	start, end, _ := responseLocation(lines, 1)
+	require.Equal(t, []string{"one two"}, lines[start:end], "The response follows the continuation lines")
+	lines = []string{"$ echo C:\\", "C:\\", "$ true"}
+	start, end, _ = responseLocation(lines, 1)
+	require.Equal(t, []string{"C:\\"}, lines[start:end], "Responses are no continuation lines")
+}
+
+func TestEncodings(t *testing.T) {
+	for _, sample := range []string{"utf16.md", "bom.md"} {
+		context := NewContext()
+		testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/" + sample)
+		require.NoError(t, err, "The sample should execute without errors.")
+		require.Equal(t, returnSuccess, context.ReturnCode(), "The byte-order mark does not end up in the commands.")
+		require.Equal(t, 1, testsuite.SuccessCount(), "The sample contains one successful command.")
+	}
+	decoded, err := decodeInput([]byte{0, '$', 0, ' ', 0, 'l', 0, 's'})
+	require.NoError(t, err)
+	require.Equal(t, "$ ls", string(decoded), "UTF-16 without a byte-order mark is detected.")
+	_, err = decodeInput([]byte{0xFF, 0xFE, '$', 0, ' '})
+	require.Error(t, err, "Truncated UTF-16 input is an error.")
+}
+
+func TestRemoteInput(t *testing.T) {
+	server := httptest.NewServer(http.FileServer(http.Dir("../../pkg/tokenizer/samples")))
+	defer server.Close()
+	context := NewContext()
+	testsuite, err := context.performInteractions(server.URL + "/helloworld.md")
+	require.NoError(t, err, "Remote input files should be fetched.")
+	require.Equal(t, returnSuccess, context.ReturnCode())
+	require.Equal(t, 4, testsuite.SuccessCount(), "The commands in the remote file are executed.")
+	_, err = context.performInteractions(server.URL + "/does-not-exist.md")
+	require.Error(t, err, "Missing remote files are an error.")
+	url, remote := remoteURL("mirkoboehm/shelldoc#README.md@v1.0")
+	require.True(t, remote, "The GitHub shorthand refers to a remote file.")
+	require.Equal(t, "https://raw.githubusercontent.com/mirkoboehm/shelldoc/v1.0/README.md", url)
+	url, _ = remoteURL("mirkoboehm/shelldoc#docs/tutorial.md")
+	require.Equal(t, "https://raw.githubusercontent.com/mirkoboehm/shelldoc/HEAD/docs/tutorial.md", url,
+		"The default branch is used if no ref is specified.")
+	_, remote = remoteURL("../../pkg/tokenizer/samples/helloworld.md")
+	require.False(t, remote, "Local files are not remote.")
+}
+
+func TestStdinName(t *testing.T) {
+	data, err := ioutil.ReadFile("../../pkg/tokenizer/samples/helloworld.md")
+	require.NoError(t, err)
+	context := NewContext(WithOptions(Options{StdinName: "docs/hello.md", ReplaceDots: true}))
+	options, err := context.resolveOptions(strings.NewReader(string(data)))
+	require.NoError(t, err, "Reading stdin should work.")
+	require.Equal(t, []string{"docs/hello.md"}, options.Files, "Without input files, stdin is read.")
+	testsuite, err := context.performInteractions(options.Files[0])
+	require.NoError(t, err, "The input from stdin should execute without errors.")
+	require.Equal(t, "docs/hello.md", testsuite.Name, "The suite is named after the stdin name.")
+	require.Equal(t, "docs/hello●md", testsuite.TestCases[0].Classname, "Dots are replaced in the stdin name.")
+	files := []string{"README.md", "-", "-"}
+	context = NewContext(WithOptions(Options{Files: files}))
+	_, err = context.resolveOptions(strings.NewReader(""))
+	require.Error(t, err, "Stdin can only be read once.")
+	files = []string{"-"}
+	context = NewContext(WithOptions(Options{Files: files}))
+	_, err = context.resolveOptions(strings.NewReader(""))
+	require.NoError(t, err)
+	require.Equal(t, []string{"-"}, files, "The input files of the caller are not changed.")
+}
+
+func TestSummary(t *testing.T) {
+	context := NewContext()
+	for _, sample := range []string{"helloworld.md", "failnomatch.md"} {
+		testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/" + sample)
+		require.NoError(t, err, "The samples should execute without errors.")
+		context.Suites.Suites = append(context.Suites.Suites, *testsuite)
+	}
+	var summary strings.Builder
+	require.NoError(t, writeSummary(&summary, context.Suites))
+	lines := strings.Split(strings.TrimSpace(summary.String()), "\n")
+	require.Len(t, lines, 4, "The summary contains a header, a line per file and the totals.")
+	require.Regexp(t, `^FILE\s+TESTS\s+PASS\s+FAIL\s+ERROR\s+SKIPPED\s+TIME$`, lines[0])
+	require.Regexp(t, `^\.\./\.\./pkg/tokenizer/samples/failnomatch\.md\s+1\s+0\s+1\s+0\s+0\s+\d+\.\d{3}s$`, lines[2])
+	require.Regexp(t, `^TOTAL\s+5\s+4\s+1\s+0\s+0\s+\d+\.\d{3}s$`, lines[3])
+	require.Equal(t, strings.Index(lines[0], "TESTS"), strings.Index(lines[3], "5"), "The columns are aligned.")
+}
+
+func TestCustomProperties(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/properties.md")
+	require.NoError(t, err, "The sample should execute without errors.")
+	testcase := testsuite.TestCases[0]
+	owner, ok := testcase.Property("owner")
+	require.True(t, ok, "Namespaced attributes are passed through as properties.")
+	require.Equal(t, "docs-team", owner)
+	component, _ := testcase.Property("component")
+	require.Equal(t, "build system", component, "Quoted values are unquoted.")
+}
+
+func TestSkippedBlocks(t *testing.T) {
+	context := NewContext()
+	testsuite, err := context.performInteractions("../../pkg/tokenizer/samples/skipped.md")
+	require.NoError(t, err, "The sample should execute without errors.")
+	require.Equal(t, 1, testsuite.SuccessCount(), "The block with a trigger character is executed.")
+	var skipped []string
+	for _, property := range testsuite.Properties {
+		if strings.HasPrefix(property.Name, "skipped-block.") {
+			skipped = append(skipped, property.Value)
+		}
+	}
+	require.Equal(t, []string{
+		"line=6 block=1 language=json reason=no-commands",
+		"line=11 block=2 language= reason=no-commands",
+		"line=13 block=0 language= reason=undefined-snippet",
+	}, skipped, "The skipped blocks are listed in the suite properties.")
+}
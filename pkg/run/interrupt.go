@@ -0,0 +1,99 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sync"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+)
+
+// interrupts handles Ctrl+C in two stages. The first interrupt is forwarded to the running commands, the remaining
+// commands are skipped and the reports are written. The second one kills the shells with all their children and
+// exits immediately.
+type interrupts struct {
+	mutex  sync.Mutex
+	count  int
+	shells map[*shell.Shell]bool
+	exit   func(int)
+}
+
+// handleInterrupts installs the handler for Ctrl+C. The returned function uninstalls it again.
+func (context *Context) handleInterrupts() func() {
+	context.interrupts = &interrupts{shells: make(map[*shell.Shell]bool), exit: os.Exit}
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case <-signals:
+				context.interrupts.interrupt()
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(signals)
+		close(done)
+	}
+}
+
+// interrupt processes one Ctrl+C
+func (interrupts *interrupts) interrupt() {
+	interrupts.mutex.Lock()
+	defer interrupts.mutex.Unlock()
+	interrupts.count++
+	if interrupts.count == 1 {
+		fmt.Println("\nSHELLDOC: interrupted, skipping the remaining commands and writing the reports (press Ctrl+C again to stop immediately)")
+		for running := range interrupts.shells {
+			running.Interrupt()
+		}
+		return
+	}
+	fmt.Println("\nSHELLDOC: interrupted again, stopping immediately")
+	for running := range interrupts.shells {
+		running.Kill()
+	}
+	interrupts.exit(returnError)
+}
+
+// interrupted returns true once Ctrl+C has been pressed
+func (interrupts *interrupts) interrupted() bool {
+	if interrupts == nil {
+		return false
+	}
+	interrupts.mutex.Lock()
+	defer interrupts.mutex.Unlock()
+	return interrupts.count > 0
+}
+
+// register adds a running shell that receives the interrupts. A shell that is started after the first interrupt is
+// interrupted right away.
+func (interrupts *interrupts) register(running *shell.Shell) {
+	if interrupts == nil {
+		return
+	}
+	interrupts.mutex.Lock()
+	defer interrupts.mutex.Unlock()
+	interrupts.shells[running] = true
+	if interrupts.count > 0 {
+		running.Interrupt()
+	}
+}
+
+// unregister removes a shell that has exited
+func (interrupts *interrupts) unregister(running *shell.Shell) {
+	if interrupts == nil {
+		return
+	}
+	interrupts.mutex.Lock()
+	defer interrupts.mutex.Unlock()
+	delete(interrupts.shells, running)
+}
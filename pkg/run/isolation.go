@@ -0,0 +1,64 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+const (
+	// IsolateFile executes all commands of a file in one shell, so that state like variables and the working
+	// directory carries over between code blocks (the default)
+	IsolateFile = "file"
+	// IsolateBlock starts a fresh shell for every code block
+	IsolateBlock = "block"
+	// IsolateInteraction starts a fresh shell for every command
+	IsolateInteraction = "interaction"
+)
+
+// validateIsolation checks that mode is a supported isolation mode
+func validateIsolation(mode string) error {
+	switch mode {
+	case "", IsolateFile, IsolateBlock, IsolateInteraction:
+		return nil
+	default:
+		return fmt.Errorf("unsupported isolation mode \"%s\", expected %s, %s or %s", mode, IsolateFile, IsolateBlock,
+			IsolateInteraction)
+	}
+}
+
+// isolationUnit returns the part of the file the interaction (at index) belongs to that is executed in its own
+// shell: the code block, the interaction (counting from 1), or 0 if the whole file shares one shell
+func (context *Context) isolationUnit(index int, interaction *tokenizer.Interaction) int {
+	switch context.options.Isolation {
+	case IsolateBlock:
+		return interaction.Block
+	case IsolateInteraction:
+		return index + 1
+	default:
+		return 0
+	}
+}
+
+// restartShell replaces the shell with a freshly started one. The background processes started in the old shell are
+// terminated.
+func (context *Context) restartShell(sh *shell.Shell, shellpath string, options shell.Options,
+	processes *backgroundProcesses) error {
+	context.terminateBackground(sh, processes)
+	processes.processes = make(map[string][]backgroundProcess)
+	if context.options.Verbose {
+		fmt.Printf(" --  starting a fresh shell\n")
+	}
+	sh.Exit()
+	fresh, err := shell.StartShellWithOptions(shellpath, options)
+	if err != nil {
+		return fmt.Errorf("unable to start shell: %v", err)
+	}
+	*sh = fresh
+	return nil
+}
@@ -0,0 +1,21 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// describeResult returns the result of the interaction for the console output in the selected locale. In other
+// locales than the default, the stable result ID is appended, so that scripts can still parse the output. The
+// reports always contain the results in the default locale.
+func (context *Context) describeResult(interaction *tokenizer.Interaction) string {
+	if len(context.locale) == 0 || context.locale == tokenizer.DefaultLocale {
+		return interaction.Result()
+	}
+	return fmt.Sprintf("%s [%s]", interaction.LocalizedResult(context.locale), interaction.ResultID())
+}
@@ -0,0 +1,34 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// parseMetadata reads the properties in the form key=value that are attached to every test suite of the run, like
+// build numbers or image digests
+func parseMetadata(properties []string) ([]junitxml.JUnitProperty, error) {
+	var metadata []junitxml.JUnitProperty
+	for _, property := range properties {
+		index := strings.Index(property, "=")
+		if index < 0 || len(strings.TrimSpace(property[:index])) == 0 {
+			return nil, fmt.Errorf("invalid property \"%s\", expected key=value", property)
+		}
+		metadata = append(metadata, junitxml.JUnitProperty{Name: strings.TrimSpace(property[:index]),
+			Value: property[index+1:]})
+	}
+	return metadata, nil
+}
+
+// addMetadata attaches the properties of the run to the test suite
+func (context *Context) addMetadata(suite *junitxml.JUnitTestSuite) {
+	for _, property := range context.metadata {
+		suite.AddProperty(property.Name, property.Value)
+	}
+}
@@ -0,0 +1,194 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"io"
+	"os"
+	"time"
+)
+
+// Options contains the settings of an execution of the run subcommand. The options are resolved in a copy when the run
+// starts (for example, a selected profile is applied), which is used from then on and does not change while it
+// executes, see resolveOptions.
+type Options struct {
+	ShellName       string
+	ShellCandidates []string
+	CleanStartup    bool
+	ShellStrict     bool
+	ConfigFile      string
+	Config          *Config
+	Profile         string
+	Verbose         bool
+	Locale          string
+	ElideWidth      int
+	DryRun          bool
+	CheckSyntax     bool
+	FailureStops    bool
+	Advisory        bool
+	XMLOutputFile   string
+	XMLAppend       bool
+	XMLOutputDir    string
+	PatchFile       string
+	Update          bool
+	HTMLOutputFile  string
+	JSONOutputFile  string
+	SummaryFile     string
+	SourceURL       string
+	Properties      []string
+	HistoryDir      string
+	TranscriptFile  string
+	CastFile        string
+	ResourceUsage   bool
+	ReplaceDots     bool
+	DefaultExitCode string
+	DirectoryMode   string
+	Isolation       string
+	NormalizePaths  bool
+	MaxFailures     int
+	TimeoutFactor   float64
+	BudgetWarning   float64
+	QuarantineFile  string
+	PolicyFile      string
+	BaselineFile    string
+	UpdateBaseline  bool
+	ResolveIncludes bool
+	Idempotent      bool
+	Section         string
+	Delay           time.Duration
+	Seed            int64
+	FixturesDir     string
+	Workdir         string
+	DockerImage     string
+	EnterCommand    string
+	SSHDestination  string
+	Tmux            bool
+	TerminalSize    string
+	Env             []string
+	EnvFiles        []string
+	Tolerate        []string
+	WarningPatterns []string
+	WarningBudget   int
+	StdinName       string
+	Files           []string
+	// HandleInterrupts installs a handler for Ctrl+C while the files are executed, see WithInterrupts. It changes the
+	// signal handling of the whole process, and is meant for command line programs.
+	HandleInterrupts bool
+}
+
+// Option changes a setting when a Context is constructed, see NewContext.
+type Option func(*Options)
+
+// WithOptions replaces all settings, for example with the ones bound to command line flags.
+func WithOptions(options Options) Option {
+	return func(target *Options) {
+		*target = options
+	}
+}
+
+// WithFiles sets the input files.
+func WithFiles(files ...string) Option {
+	return func(options *Options) {
+		options.Files = files
+	}
+}
+
+// WithShell selects the shell that executes the commands.
+func WithShell(name string) Option {
+	return func(options *Options) {
+		options.ShellName = name
+	}
+}
+
+// WithConfig sets the configuration, instead of reading it from a configuration file.
+func WithConfig(config *Config) Option {
+	return func(options *Options) {
+		options.Config = config
+	}
+}
+
+// WithProfile selects a profile from the configuration.
+func WithProfile(name string) Option {
+	return func(options *Options) {
+		options.Profile = name
+	}
+}
+
+// WithVerbose enables verbose output.
+func WithVerbose(verbose bool) Option {
+	return func(options *Options) {
+		options.Verbose = verbose
+	}
+}
+
+// WithDefaultExitCode sets the expected exit code of commands without exit code attributes (any, 0 or nonzero).
+func WithDefaultExitCode(policy string) Option {
+	return func(options *Options) {
+		options.DefaultExitCode = policy
+	}
+}
+
+// WithMaxFailures skips the remaining tests after the specified number of failures.
+func WithMaxFailures(count int) Option {
+	return func(options *Options) {
+		options.MaxFailures = count
+	}
+}
+
+// WithXMLOutput writes the results to the specified file in JUnitXML format.
+func WithXMLOutput(path string) Option {
+	return func(options *Options) {
+		options.XMLOutputFile = path
+	}
+}
+
+// WithInterrupts handles Ctrl+C while the files are executed: the first interrupt skips the remaining commands and
+// writes the reports, the second one stops the process immediately.
+func WithInterrupts() Option {
+	return func(options *Options) {
+		options.HandleInterrupts = true
+	}
+}
+
+// NewContext creates the context for one run with the specified options. Every run needs its own context.
+func NewContext(options ...Option) *Context {
+	context := &Context{}
+	for _, option := range options {
+		option(&context.options)
+	}
+	context.seed = newSeed(context.options.Seed)
+	return context
+}
+
+// Options returns the settings of the run.
+func (context *Context) Options() Options {
+	return context.options
+}
+
+// resolveOptions returns a copy of the options of the context with the settings resolved: the configuration file is
+// read, the selected profile is applied, the input from stdin is read, and the timeout multiplier is taken from the
+// environment if it is not specified.
+func (context *Context) resolveOptions(stdin io.Reader) (Options, error) {
+	options := context.options
+	if len(options.ConfigFile) > 0 {
+		config, err := ReadConfig(options.ConfigFile)
+		if err != nil {
+			return Options{}, err
+		}
+		options.Config = config
+	}
+	if err := context.applyProfile(&options); err != nil {
+		return Options{}, err
+	}
+	if err := context.readStdin(&options, stdin); err != nil {
+		return Options{}, err
+	}
+	multiplier, err := resolveTimeoutMultiplier(options.TimeoutFactor, os.Getenv)
+	if err != nil {
+		return Options{}, err
+	}
+	options.TimeoutFactor = multiplier
+	return options, nil
+}
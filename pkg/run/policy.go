@@ -0,0 +1,87 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+const (
+	policyAllow = "allow"
+	policyDeny  = "deny"
+)
+
+// policyRule allows or denies the commands matching the regular expression
+type policyRule struct {
+	action     string
+	expression *regexp.Regexp
+	line       int
+}
+
+// policy is an ordered list of rules that decide which commands may be executed
+type policy struct {
+	rules      []policyRule
+	allowRules bool
+}
+
+// readPolicy reads a command policy. Every line contains the action allow or deny, followed by a regular expression
+// that is matched against the command. Empty lines and lines starting with # are ignored.
+func readPolicy(path string) (*policy, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open policy file: %v", err)
+	}
+	defer file.Close()
+	result := &policy{}
+	scanner := bufio.NewScanner(file)
+	for lineNumber := 1; scanner.Scan(); lineNumber++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		fields := strings.SplitN(line, " ", 2)
+		if len(fields) < 2 || len(strings.TrimSpace(fields[1])) == 0 {
+			return nil, fmt.Errorf("%s:%d: expected \"allow REGEX\" or \"deny REGEX\"", path, lineNumber)
+		}
+		action := fields[0]
+		if action != policyAllow && action != policyDeny {
+			return nil, fmt.Errorf("%s:%d: unknown policy action \"%s\", expected allow or deny", path, lineNumber, action)
+		}
+		expression, err := regexp.Compile(strings.TrimSpace(fields[1]))
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid regular expression: %v", path, lineNumber, err)
+		}
+		result.rules = append(result.rules, policyRule{action, expression, lineNumber})
+		result.allowRules = result.allowRules || action == policyAllow
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read policy file: %v", err)
+	}
+	return result, nil
+}
+
+// check returns an empty string if the command may be executed, or the reason why it is denied. The first matching
+// rule decides. Commands that match no rule are allowed, unless the policy contains allow rules.
+func (p *policy) check(command string) string {
+	if p == nil {
+		return ""
+	}
+	for _, rule := range p.rules {
+		if rule.expression.MatchString(command) {
+			if rule.action == policyDeny {
+				return fmt.Sprintf("denied by policy rule in line %d", rule.line)
+			}
+			return ""
+		}
+	}
+	if p.allowRules {
+		return "not allowed by policy"
+	}
+	return ""
+}
@@ -0,0 +1,25 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"net"
+)
+
+// freePortVariable is the name of the placeholder ({{freeport}}) that is replaced with a free TCP port in the commands
+// and expected responses. The port is also available in the SHELLDOC_FREE_PORT environment variable.
+const freePortVariable = "freeport"
+
+// freePort returns a TCP port that is currently not in use on the local host. Every file gets its own port, so that
+// files that start services can be tested in parallel.
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("unable to find a free TCP port: %v", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
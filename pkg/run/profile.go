@@ -0,0 +1,71 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Profile bundles the settings for running the documents in a specific context, like a developer machine or a CI
+// system. Profiles are defined in the configuration file and selected using --profile.
+type Profile struct {
+	// Shell is the shell to invoke, like --shell
+	Shell string `json:"shell"`
+	// CleanStartup starts the shell without the profile and rc files of the user, like --clean-startup
+	CleanStartup bool `json:"cleanStartup"`
+	// Env contains additional environment variables for the shell
+	Env map[string]string `json:"env"`
+	// TimeoutMultiplier scales all timeouts and time budgets, like --timeout-multiplier
+	TimeoutMultiplier float64 `json:"timeoutMultiplier"`
+	// DefaultExitCode is the expected exit code of commands without exit code attributes, like --default-exit-code
+	DefaultExitCode string `json:"defaultExitCode"`
+	// NormalizePaths compares output with normalized paths, like --normalize-paths
+	NormalizePaths bool `json:"normalizePaths"`
+}
+
+// profile returns the profile with the specified name
+func (config *Config) profile(name string) (Profile, error) {
+	if config == nil {
+		return Profile{}, fmt.Errorf("profile %s selected, but no configuration file specified (--config)", name)
+	}
+	profile, ok := config.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("profile %s is not defined in the configuration file", name)
+	}
+	return profile, nil
+}
+
+// applyProfile applies the profile selected in the options to them. Settings specified on the command line take
+// precedence over the ones in the profile.
+func (context *Context) applyProfile(options *Options) error {
+	if len(options.Profile) == 0 {
+		return nil
+	}
+	profile, err := options.Config.profile(options.Profile)
+	if err != nil {
+		return err
+	}
+	if len(options.ShellName) == 0 {
+		options.ShellName = profile.Shell
+	}
+	if len(options.DefaultExitCode) == 0 {
+		options.DefaultExitCode = profile.DefaultExitCode
+	}
+	if options.TimeoutFactor == 0 {
+		options.TimeoutFactor = profile.TimeoutMultiplier
+	}
+	options.CleanStartup = options.CleanStartup || profile.CleanStartup
+	options.NormalizePaths = options.NormalizePaths || profile.NormalizePaths
+	names := make([]string, 0, len(profile.Env))
+	for name := range profile.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		context.environment = append(context.environment, name+"="+profile.Env[name])
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// idRx matches the IDs of interactions, see tokenizer.AssignIDs
+var idRx = regexp.MustCompile(`^[0-9a-f]{12}$`)
+
+// quarantine is a list of files and commands whose failures do not affect the return code
+type quarantine struct {
+	files    map[string]bool
+	commands map[string]map[string]bool
+}
+
+// readQuarantine reads a quarantine list. Every line contains a file name, optionally followed by a colon and the
+// ID of a command in that file, as shown in the verbose output and the reports. IDs do not change when lines are
+// added or removed elsewhere in the file. Empty lines and lines starting with # are ignored.
+func readQuarantine(path string) (*quarantine, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("unable to open quarantine file: %v", err)
+	}
+	defer file.Close()
+	result := &quarantine{make(map[string]bool), make(map[string]map[string]bool)}
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if separator := strings.LastIndex(line, ":"); separator > 0 && idRx.MatchString(line[separator+1:]) {
+			name := filepath.Clean(line[:separator])
+			if result.commands[name] == nil {
+				result.commands[name] = make(map[string]bool)
+			}
+			result.commands[name][line[separator+1:]] = true
+			continue
+		}
+		result.files[filepath.Clean(line)] = true
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("unable to read quarantine file: %v", err)
+	}
+	return result, nil
+}
+
+// contains returns true if the command with the specified ID in the file is quarantined
+func (q *quarantine) contains(file string, id string) bool {
+	if q == nil {
+		return false
+	}
+	file = filepath.Clean(file)
+	return q.files[file] || q.commands[file][id]
+}
@@ -0,0 +1,75 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// gitHubShorthandEx matches input arguments like mirkoboehm/shelldoc#README.md@master
+const gitHubShorthandEx = `^([\w.-]+)/([\w.-]+)#([^@]+)(?:@(.+))?$`
+
+var gitHubShorthandRx = regexp.MustCompile(gitHubShorthandEx)
+
+// remoteTimeout limits the time for fetching a remote input file
+const remoteTimeout = 30 * time.Second
+
+// remoteURL returns the URL to fetch for an input argument, if it refers to a remote file. Input arguments can be
+// http(s) URLs, or use the GitHub shorthand owner/repo#path@ref (ref defaults to the default branch). Existing local
+// files take precedence over the shorthand.
+func remoteURL(name string) (string, bool) {
+	if strings.HasPrefix(name, "http://") || strings.HasPrefix(name, "https://") {
+		return name, true
+	}
+	match := gitHubShorthandRx.FindStringSubmatch(name)
+	if match == nil {
+		return "", false
+	}
+	if _, err := os.Stat(name); err == nil {
+		return "", false
+	}
+	ref := match[4]
+	if len(ref) == 0 {
+		ref = "HEAD"
+	}
+	return fmt.Sprintf("https://raw.githubusercontent.com/%s/%s/%s/%s", match[1], match[2], ref,
+		strings.TrimPrefix(match[3], "/")), true
+}
+
+// fetchRemote downloads a remote input file
+func fetchRemote(url string) ([]byte, error) {
+	client := http.Client{Timeout: remoteTimeout}
+	response, err := client.Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %v", url, err)
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unable to fetch %s: %s", url, response.Status)
+	}
+	content, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to fetch %s: %v", url, err)
+	}
+	return content, nil
+}
+
+// readFile reads a local or remote input file
+func readFile(filename string) ([]byte, error) {
+	if url, ok := remoteURL(filename); ok {
+		return fetchRemote(url)
+	}
+	content, err := ioutil.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read file %s", filename)
+	}
+	return content, nil
+}
@@ -0,0 +1,24 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"math"
+	"math/rand"
+	"time"
+)
+
+// seedVariable contains the seed of the run in the environment of the shell, so that commands that involve
+// randomness can produce the same output when the run is replayed with the seed
+const seedVariable = "SHELLDOC_SEED"
+
+// newSeed returns the seed of the run: the specified one to replay a previous run, or a random one if it is 0.
+// Random seeds are positive and fit into 31 bits, so that they are accepted by most tools, like $RANDOM in bash.
+func newSeed(seed int64) int64 {
+	if seed != 0 {
+		return seed
+	}
+	return rand.New(rand.NewSource(time.Now().UnixNano())).Int63n(math.MaxInt32) + 1
+}
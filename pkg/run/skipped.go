@@ -0,0 +1,24 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// reportSkippedBlocks registers the code blocks that were not executed as properties of the test suite, and lists
+// them in verbose mode, so that authors know which blocks shelldoc ignored
+func (context *Context) reportSkippedBlocks(suite *junitxml.JUnitTestSuite, skipped []tokenizer.SkippedBlock) {
+	for index, block := range skipped {
+		description := fmt.Sprintf("line=%d block=%d language=%s reason=%s", block.Line, block.Block, block.Language, block.Reason)
+		suite.AddProperty(fmt.Sprintf("skipped-block.%d", index+1), description)
+		if context.options.Verbose {
+			fmt.Printf(" --  skipped code block in line %d: %s\n", block.Line, block.Reason)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+)
+
+// StdinArgument is the input file argument that refers to stdin
+const StdinArgument = "-"
+
+// DefaultStdinName is the name of the input read from stdin in the results if no name is specified
+const DefaultStdinName = "stdin"
+
+// readStdin reads stdin if no input files are specified in the options or one of them is "-". The input file is
+// renamed to StdinName, so that the results, environment variables and report options refer to a meaningful name.
+func (context *Context) readStdin(options *Options, stdin io.Reader) error {
+	// the list of files is copied, since it may be shared with the caller
+	files := append([]string(nil), options.Files...)
+	if len(files) == 0 {
+		files = []string{StdinArgument}
+	}
+	name := options.StdinName
+	if len(name) == 0 {
+		name = DefaultStdinName
+	}
+	for index, file := range files {
+		if file != StdinArgument {
+			continue
+		}
+		if context.stdin != nil {
+			return fmt.Errorf("stdin can only be read once")
+		}
+		data, err := readStdinData(stdin)
+		if err != nil {
+			return err
+		}
+		context.stdin = data
+		context.stdinName = name
+		files[index] = name
+	}
+	options.Files = files
+	return nil
+}
+
+// readStdinData reads and decodes all input from stdin
+func readStdinData(stdin io.Reader) ([]byte, error) {
+	data, err := ioutil.ReadAll(stdin)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read from stdin: %v", err)
+	}
+	if data == nil {
+		data = []byte{}
+	}
+	return decodeInput(data)
+}
@@ -0,0 +1,35 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+)
+
+// writeSummary writes an aligned table with the results of every file and the totals
+func writeSummary(writer io.Writer, suites junitxml.JUnitTestSuites) error {
+	table := tabwriter.NewWriter(writer, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(table, "FILE\tTESTS\tPASS\tFAIL\tERROR\tSKIPPED\tTIME")
+	var tests, successes, failures, errors, skipped int
+	var elapsed float64
+	for _, suite := range suites.Suites {
+		seconds, _ := strconv.ParseFloat(suite.Time, 64)
+		fmt.Fprintf(table, "%s\t%d\t%d\t%d\t%d\t%d\t%.3fs\n", suite.Name, suite.TestCount(), suite.SuccessCount(),
+			suite.FailureCount(), suite.ErrorCount(), suite.SkippedCount(), seconds)
+		tests += suite.TestCount()
+		successes += suite.SuccessCount()
+		failures += suite.FailureCount()
+		errors += suite.ErrorCount()
+		skipped += suite.SkippedCount()
+		elapsed += seconds
+	}
+	fmt.Fprintf(table, "TOTAL\t%d\t%d\t%d\t%d\t%d\t%.3fs\n", tests, successes, failures, errors, skipped, elapsed)
+	return table.Flush()
+}
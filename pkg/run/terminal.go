@@ -0,0 +1,69 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// resultColumns is the space taken by the counter and the result of a command in a line of console output, in
+// addition to its description
+const resultColumns = 45
+
+// minimumDescriptionWidth is the narrowest description of a command, even in very narrow terminals
+const minimumDescriptionWidth = 30
+
+// terminalWidth returns the number of columns of the terminal the output is written to, or 0 if the output does not
+// go to a terminal or its size is unknown. $COLUMNS takes precedence over the size reported by the terminal.
+func terminalWidth() int {
+	if columns, err := strconv.Atoi(os.Getenv("COLUMNS")); err == nil && columns > 0 {
+		return columns
+	}
+	if info, err := os.Stdout.Stat(); err != nil || info.Mode()&os.ModeCharDevice == 0 {
+		return 0
+	}
+	tty, err := os.Open("/dev/tty")
+	if err != nil {
+		return 0
+	}
+	defer tty.Close()
+	cmd := exec.Command("stty", "size")
+	cmd.Stdin = tty
+	output, err := cmd.Output()
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(output))
+	if len(fields) != 2 {
+		return 0
+	}
+	columns, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return 0
+	}
+	return columns
+}
+
+// descriptionWidth returns the width the commands and expected responses are elided to in the console output, or 0
+// if they are not elided. In verbose mode, they are never elided, since truncated commands make failures hard to
+// identify.
+func (context *Context) descriptionWidth() int {
+	switch {
+	case context.options.Verbose || context.options.ElideWidth < 0:
+		return 0
+	case context.options.ElideWidth > 0:
+		return context.options.ElideWidth
+	}
+	columns := terminalWidth()
+	if columns == 0 {
+		return tokenizer.DefaultDescriptionWidth
+	}
+	return max(columns-resultColumns, minimumDescriptionWidth)
+}
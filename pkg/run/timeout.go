@@ -0,0 +1,79 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// TimeoutMultiplierVariable names the environment variable that sets the timeout multiplier if it is not specified
+// on the command line
+const TimeoutMultiplierVariable = "SHELLDOC_TIMEOUT_MULTIPLIER"
+
+// resolveTimeoutMultiplier determines the factor all timeouts are scaled with. The command line takes precedence over
+// the environment, the default is 1.
+func resolveTimeoutMultiplier(multiplier float64, getenv func(string) string) (float64, error) {
+	if multiplier == 0 {
+		value := getenv(TimeoutMultiplierVariable)
+		if len(value) == 0 {
+			return 1, nil
+		}
+		parsed, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%s needs to be a number, got \"%s\"", TimeoutMultiplierVariable, value)
+		}
+		multiplier = parsed
+	}
+	if multiplier <= 0 {
+		return 0, fmt.Errorf("the timeout multiplier needs to be greater than zero, got %v", multiplier)
+	}
+	return multiplier, nil
+}
+
+// scaleTimeout applies the timeout multiplier to a timeout
+func (context *Context) scaleTimeout(timeout time.Duration) time.Duration {
+	if context.options.TimeoutFactor == 0 {
+		return timeout
+	}
+	return time.Duration(float64(timeout) * context.options.TimeoutFactor)
+}
+
+// warnBeforeBudget prints a warning naming the running command when the fraction BudgetWarning of the time budget of
+// the file is used, so that slow commands can be identified before they are stopped
+func (context *Context) warnBeforeBudget(sh *shell.Shell, inputfile string, start time.Time, budget time.Duration,
+	running func() *tokenizer.Interaction) {
+	if budget <= 0 || context.options.BudgetWarning <= 0 {
+		return
+	}
+	warnAt := time.Duration(float64(budget) * context.options.BudgetWarning)
+	sh.SetWarning(start.Add(warnAt), func() {
+		if interaction := running(); interaction != nil {
+			fmt.Printf("\nSHELLDOC: WARNING: %s:%d: \"%s\" is still running, %v of the time budget of %v used\n",
+				inputfile, interaction.Line, interaction.Cmd, time.Since(start).Round(time.Millisecond), budget)
+		}
+	})
+}
+
+// limitCommand sets the deadline of the shell for the command of the interaction, if it has a timeout (see
+// tokenizer.TimeoutOption) that ends before the current deadline. It returns the deadline to restore after the
+// command, and false if the deadline was not changed.
+func (context *Context) limitCommand(sh *shell.Shell, interaction *tokenizer.Interaction) (time.Time, bool) {
+	previous := sh.Deadline()
+	timeout, err := interaction.Timeout()
+	if err != nil || timeout <= 0 {
+		return previous, false // invalid timeouts are reported by Execute
+	}
+	deadline := time.Now().Add(context.scaleTimeout(timeout))
+	if !previous.IsZero() && previous.Before(deadline) {
+		return previous, false
+	}
+	sh.SetDeadline(deadline)
+	return previous, true
+}
@@ -0,0 +1,95 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// userShell is the shell that executes the commands of a code block as another user, see tokenizer.UserOption. It
+// is started in the working directory of the shell of the file, and exits when the code block is left, so variables
+// set in the code block do not carry over to the following code blocks.
+type userShell struct {
+	block   int
+	name    string
+	shell   shell.Shell
+	options shell.Options
+	// skipped contains the reason why the commands of the code block are not executed, err the error that occurred
+	// starting the shell
+	skipped string
+	err     error
+}
+
+// started returns true if the shell of the user is running
+func (switched *userShell) started() bool {
+	return len(switched.skipped) == 0 && switched.err == nil
+}
+
+// currentUser returns the name of the user running shelldoc, or an empty string if it cannot be determined
+func currentUser() string {
+	if account, err := user.Current(); err == nil {
+		return account.Username
+	}
+	return ""
+}
+
+// runAs returns the user the commands of the interaction are executed as, or an empty string if they are executed
+// by the user running shelldoc
+func runAs(interaction *tokenizer.Interaction) string {
+	name := strings.TrimSpace(interaction.Attributes[tokenizer.UserOption])
+	if name == currentUser() {
+		return ""
+	}
+	return name
+}
+
+// switchUser starts the shell that executes the commands of the code block of the interaction as another user, in
+// the current working directory of sh. Switching users requires root privileges, without them the commands are
+// skipped, so that blocks that need a specific user still pass for developers running shelldoc locally.
+func (context *Context) switchUser(sh *shell.Shell, shellpath string, options shell.Options,
+	interaction *tokenizer.Interaction) *userShell {
+	switched := &userShell{block: interaction.Block, name: runAs(interaction)}
+	if len(context.options.DockerImage) > 0 || len(context.options.SSHDestination) > 0 || context.options.Tmux {
+		switched.err = fmt.Errorf("the commands can only be executed as user %s in a shell on the local host, "+
+			"without --tmux", switched.name)
+		return switched
+	}
+	if os.Geteuid() != 0 {
+		switched.skipped = fmt.Sprintf("requires user %s", switched.name)
+		return switched
+	}
+	directory, err := sh.WorkingDirectory()
+	if err != nil {
+		switched.err = err
+		return switched
+	}
+	if context.options.Verbose {
+		fmt.Printf(" --  starting a shell as user %s\n", switched.name)
+	}
+	options.Directory = directory
+	options.User = switched.name
+	switched.options = options
+	if switched.shell, err = shell.StartShellWithOptions(shellpath, options); err != nil {
+		switched.err = fmt.Errorf("unable to start shell as user %s: %v", switched.name, err)
+		return switched
+	}
+	context.interrupts.register(&switched.shell)
+	return switched
+}
+
+// leaveUser stops the shell of the user when its code block is left
+func (context *Context) leaveUser(switched *userShell) {
+	if !switched.started() {
+		return
+	}
+	context.interrupts.unregister(&switched.shell)
+	switched.shell.Exit()
+}
@@ -0,0 +1,69 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// waitInterval is the pause between two executions of a readiness command
+var waitInterval = time.Second
+
+// policyViolation is returned by waitUntilReady if the readiness command is denied by the command policy, it
+// contains the reason
+type policyViolation string
+
+func (violation policyViolation) Error() string {
+	return "readiness command " + string(violation)
+}
+
+// waitUntilReady executes the readiness command of the code block of the interaction repeatedly, until it succeeds
+// or the wait timeout (scaled by the timeout multiplier) expires. The command is executed in the shell that executes
+// the code block, so it sees the same environment and working directory. A readiness command that is denied by the
+// command policy is not executed.
+func (context *Context) waitUntilReady(sh *shell.Shell, interaction *tokenizer.Interaction) error {
+	command := interaction.WaitFor()
+	if len(command) == 0 {
+		return nil
+	}
+	if reason := context.policy.check(command); len(reason) > 0 {
+		return policyViolation(reason)
+	}
+	timeout, err := interaction.WaitTimeout()
+	if err != nil {
+		return err
+	}
+	timeout = context.scaleTimeout(timeout)
+	if context.options.Verbose {
+		fmt.Printf(" --  waiting up to %v for code block %d to be ready: %s\n", timeout, interaction.Block, command)
+	}
+	end := time.Now().Add(timeout)
+	// a readiness command that hangs must not block the rest of the file
+	deadline := sh.Deadline()
+	sh.SetDeadline(end)
+	if !deadline.IsZero() && deadline.Before(end) {
+		sh.SetDeadline(deadline)
+	}
+	defer sh.SetDeadline(deadline)
+	for {
+		output, rc, err := sh.ExecuteCommand(command)
+		if err != nil {
+			return fmt.Errorf("unable to execute the readiness command: %v", err)
+		}
+		if rc == 0 {
+			return nil
+		}
+		if time.Now().Add(waitInterval).After(end) {
+			return fmt.Errorf("not ready after %v, the readiness command %s exited with exit code %d: %s", timeout,
+				command, rc, strings.Join(output, " "))
+		}
+		time.Sleep(waitInterval)
+	}
+}
@@ -0,0 +1,111 @@
+package run
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+
+	"github.com/mirkoboehm/shelldoc/pkg/junitxml"
+	"github.com/mirkoboehm/shelldoc/pkg/tokenizer"
+)
+
+// WarningConfig contains the patterns of warnings, like deprecation warnings, that are counted in the output of all
+// commands, and the number of warnings that is tolerated, for example
+// {"patterns": ["(?i)deprecated"], "budget": 12}
+type WarningConfig struct {
+	Patterns []string `json:"patterns"`
+	// Budget is the maximum number of warnings, no limit if not set
+	Budget *int `json:"budget"`
+}
+
+// warningBudget counts the lines of the output and error output of all commands that match a warning pattern. The
+// run fails if the total exceeds the budget, which allows to ratchet down the number of warnings over time.
+type warningBudget struct {
+	patterns []*regexp.Regexp
+	// budget is the maximum number of warnings, negative for no limit
+	budget int
+	count  int
+}
+
+// newWarningBudget compiles the warning patterns of the options and the configuration. The budget of the options
+// takes precedence if it is not negative. It returns nil if no patterns are specified.
+func newWarningBudget(options Options) (*warningBudget, error) {
+	warnings := &warningBudget{budget: options.WarningBudget}
+	patterns := options.WarningPatterns
+	if options.Config != nil {
+		patterns = append(append([]string(nil), patterns...), options.Config.Warnings.Patterns...)
+		if warnings.budget < 0 && options.Config.Warnings.Budget != nil {
+			warnings.budget = *options.Config.Warnings.Budget
+		}
+	}
+	if len(patterns) == 0 {
+		return nil, nil
+	}
+	for _, pattern := range patterns {
+		rx, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid warning pattern \"%s\": %v", pattern, err)
+		}
+		warnings.patterns = append(warnings.patterns, rx)
+	}
+	return warnings, nil
+}
+
+// match returns the number of lines that match a warning pattern
+func (warnings *warningBudget) match(lines []string) int {
+	count := 0
+	for _, line := range lines {
+		for _, pattern := range warnings.patterns {
+			if pattern.MatchString(line) {
+				count++
+				break
+			}
+		}
+	}
+	return count
+}
+
+// countWarnings counts the warnings in the output of the interaction and records them in the properties of the test
+// case. It returns the number of warnings.
+func (context *Context) countWarnings(testcase *junitxml.JUnitTestCase, interaction *tokenizer.Interaction) int {
+	if context.warnings == nil {
+		return 0
+	}
+	count := context.warnings.match(interaction.Output) + context.warnings.match(interaction.ErrorOutput)
+	if count == 0 {
+		return 0
+	}
+	testcase.AddProperty("warnings", strconv.Itoa(count))
+	if context.options.Verbose {
+		fmt.Printf(" --  %d warnings\n", count)
+	}
+	context.mutex.Lock()
+	defer context.mutex.Unlock()
+	context.warnings.count += count
+	return count
+}
+
+// checkWarningBudget reports the number of warnings of the run, and registers a failure if it exceeds the budget
+func (context *Context) checkWarningBudget() {
+	if context.warnings == nil {
+		return
+	}
+	count, budget := context.warnings.count, context.warnings.budget
+	if budget < 0 {
+		fmt.Printf("SHELLDOC: %d warnings\n", count)
+		return
+	}
+	if count > budget {
+		fmt.Printf("SHELLDOC: FAILURE: %d warnings exceed the warning budget of %d\n", count, budget)
+		context.RegisterReturnCode(returnFailure)
+		return
+	}
+	fmt.Printf("SHELLDOC: %d warnings, within the warning budget of %d\n", count, budget)
+	if count < budget {
+		fmt.Printf("SHELLDOC: the warning budget can be lowered to %d\n", count)
+	}
+}
@@ -0,0 +1,211 @@
+package screen
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	escape    = '\x1b'
+	tabStop   = 8
+	maxCursor = 1000 // limits the size of the screen if commands move the cursor far away
+)
+
+// screen is a simple model of a terminal screen that is large enough for all output
+type screen struct {
+	rows   [][]rune
+	row    int
+	column int
+}
+
+// HasControls returns true if the output contains carriage returns, backspaces or escape sequences, which means that
+// the output as seen in a terminal differs from the captured lines
+func HasControls(lines []string) bool {
+	for _, line := range lines {
+		if strings.ContainsAny(line, "\r\b\x1b") {
+			return true
+		}
+	}
+	return false
+}
+
+// Render returns the text that a terminal displays after printing the output lines, for example only the final
+// state of a progress bar that is redrawn using carriage returns. Cursor movement and erase sequences are applied,
+// colors and other escape sequences are removed.
+func Render(lines []string) []string {
+	s := &screen{}
+	for index, line := range lines {
+		if index > 0 {
+			s.move(s.row+1, 0)
+		}
+		s.print(line)
+	}
+	var result []string
+	for _, row := range s.rows {
+		result = append(result, strings.TrimRight(string(row), " "))
+	}
+	for len(result) > 0 && len(result[len(result)-1]) == 0 {
+		result = result[:len(result)-1]
+	}
+	return result
+}
+
+// print interprets the characters of one line of output
+func (s *screen) print(line string) {
+	text := []rune(line)
+	for index := 0; index < len(text); index++ {
+		switch text[index] {
+		case '\r':
+			s.column = 0
+		case '\b':
+			s.move(s.row, s.column-1)
+		case '\t':
+			s.move(s.row, (s.column/tabStop+1)*tabStop)
+		case escape:
+			index = s.escape(text, index+1)
+		default:
+			s.put(text[index])
+		}
+	}
+}
+
+// escape interprets the escape sequence that starts at text[start], after the escape character, and returns the
+// index of its last character
+func (s *screen) escape(text []rune, start int) int {
+	if start >= len(text) {
+		return start
+	}
+	switch text[start] {
+	case '[': // control sequence, ESC [ parameters final
+		end := start + 1
+		for end < len(text) && (text[end] < 0x40 || text[end] > 0x7e) {
+			end++
+		}
+		if end < len(text) {
+			s.control(text[end], string(text[start+1:end]))
+		}
+		return end
+	case ']': // operating system command like a window title, terminated by BEL or ESC \
+		for end := start + 1; end < len(text); end++ {
+			if text[end] == '\a' {
+				return end
+			}
+			if text[end] == escape && end+1 < len(text) && text[end+1] == '\\' {
+				return end + 1
+			}
+		}
+		return len(text)
+	case '(', ')': // character set selection
+		return start + 1
+	default:
+		return start
+	}
+}
+
+// control applies a control sequence with the specified final character and parameters
+func (s *screen) control(final rune, parameters string) {
+	var values []int
+	for _, parameter := range strings.Split(strings.TrimLeft(parameters, "?"), ";") {
+		value, _ := strconv.Atoi(parameter)
+		values = append(values, value)
+	}
+	// parameter returns the value at index, or fallback if it is not specified
+	parameter := func(index, fallback int) int {
+		if index < len(values) && values[index] > 0 {
+			return values[index]
+		}
+		return fallback
+	}
+	switch final {
+	case 'A':
+		s.move(s.row-parameter(0, 1), s.column)
+	case 'B':
+		s.move(s.row+parameter(0, 1), s.column)
+	case 'C':
+		s.move(s.row, s.column+parameter(0, 1))
+	case 'D':
+		s.move(s.row, s.column-parameter(0, 1))
+	case 'G':
+		s.move(s.row, parameter(0, 1)-1)
+	case 'H', 'f':
+		s.move(parameter(0, 1)-1, parameter(1, 1)-1)
+	case 'K':
+		s.eraseLine(values[0])
+	case 'J':
+		s.eraseScreen(values[0])
+	}
+}
+
+// move places the cursor at the specified position, within the limits of the screen
+func (s *screen) move(row, column int) {
+	clamp := func(value int) int {
+		if value < 0 {
+			return 0
+		}
+		if value > maxCursor {
+			return maxCursor
+		}
+		return value
+	}
+	s.row = clamp(row)
+	s.column = clamp(column)
+}
+
+// line returns the row the cursor is in, and makes sure it exists
+func (s *screen) line() []rune {
+	for len(s.rows) <= s.row {
+		s.rows = append(s.rows, nil)
+	}
+	return s.rows[s.row]
+}
+
+// put writes a character at the cursor position and advances the cursor
+func (s *screen) put(character rune) {
+	row := s.line()
+	for len(row) <= s.column {
+		row = append(row, ' ')
+	}
+	row[s.column] = character
+	s.rows[s.row] = row
+	s.move(s.row, s.column+1)
+}
+
+// eraseLine clears the current line from the cursor to the end (mode 0), from the start to the cursor (mode 1),
+// or completely (mode 2)
+func (s *screen) eraseLine(mode int) {
+	row := s.line()
+	switch mode {
+	case 0:
+		if s.column < len(row) {
+			s.rows[s.row] = row[:s.column]
+		}
+	case 1:
+		for index := 0; index <= s.column && index < len(row); index++ {
+			row[index] = ' '
+		}
+	default:
+		s.rows[s.row] = nil
+	}
+}
+
+// eraseScreen clears the screen from the cursor to the end (mode 0), from the start to the cursor (mode 1), or
+// completely (mode 2 and 3)
+func (s *screen) eraseScreen(mode int) {
+	s.line()
+	switch mode {
+	case 0:
+		s.eraseLine(0)
+		s.rows = s.rows[:s.row+1]
+	case 1:
+		for index := 0; index < s.row; index++ {
+			s.rows[index] = nil
+		}
+		s.eraseLine(1)
+	default:
+		s.rows = nil
+	}
+}
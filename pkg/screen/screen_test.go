@@ -0,0 +1,30 @@
+package screen
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHasControls(t *testing.T) {
+	require.False(t, HasControls([]string{"Hello", "World"}))
+	require.True(t, HasControls([]string{"10%\r20%"}))
+	require.True(t, HasControls([]string{"\x1b[32mOK\x1b[0m"}))
+}
+
+func TestRender(t *testing.T) {
+	require.Equal(t, []string{"Hello", "World"}, Render([]string{"Hello", "World"}), "Plain output is not changed")
+	require.Equal(t, []string{"100% done"}, Render([]string{"10%\r50%\r100% done"}), "Carriage returns redraw the line")
+	require.Equal(t, []string{"OK"}, Render([]string{"\x1b[32mOK\x1b[0m"}), "Colors are removed")
+	require.Equal(t, []string{"ab"}, Render([]string{"abc\bd\b\x1b[K"}), "Backspaces and erasing work")
+	require.Equal(t, []string{"step 2", "done"}, Render([]string{"step 1", "\x1b[1A\rstep 2", "done"}),
+		"The cursor can move up")
+	require.Equal(t, []string{"final"}, Render([]string{"frame 1", "frame 2", "\x1b[2J\x1b[Hfinal"}),
+		"Clearing the screen removes the previous output")
+	require.Equal(t, []string{"title"}, Render([]string{"\x1b]0;window\atitle"}), "Window titles are ignored")
+	require.Empty(t, Render([]string{"\x1b[1000;1000H"}), "The screen contains no empty lines")
+}
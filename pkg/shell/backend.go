@@ -0,0 +1,237 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"sync/atomic"
+	"unicode"
+)
+
+// Backend creates the process that runs the shell, for example on the host or in a container. The commands are sent
+// to the standard input of the process, and its output is read from its standard output and error output.
+type Backend interface {
+	// Command returns the command that runs the shell with the arguments, using the environment and working
+	// directory of the options
+	Command(shell string, arguments []string, options Options) (*exec.Cmd, error)
+	// Kill immediately stops the process started with cmd and all processes started by it
+	Kill(cmd *exec.Cmd)
+}
+
+// Capturer is implemented by backends that run the shell in a terminal whose screen can be captured
+type Capturer interface {
+	// Capture returns the visible lines of the terminal of the shell started with cmd
+	Capture(cmd *exec.Cmd) ([]string, error)
+}
+
+// Host runs the shell as a process on the local host. It is used if Options.Backend is not set.
+type Host struct{}
+
+// Command implements Backend
+func (Host) Command(shell string, arguments []string, options Options) (*exec.Cmd, error) {
+	cmd := exec.Command(shell, arguments...)
+	cmd.Dir = options.Directory
+	setProcessGroup(cmd)
+	if options.CleanStartup {
+		cmd.Env = append(cleanEnvironment(os.Environ()), options.Environment...)
+	} else if len(options.Environment) > 0 {
+		cmd.Env = append(os.Environ(), options.Environment...)
+	}
+	if len(options.User) > 0 {
+		if err := setUser(cmd, options.User); err != nil {
+			return nil, fmt.Errorf("unable to run the shell as user %s: %v", options.User, err)
+		}
+	}
+	return cmd, nil
+}
+
+// Kill implements Backend
+func (Host) Kill(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+}
+
+// Wrapper runs the shell on the host through a command that enters a development environment, like
+// "nix develop --command", so that the commands are executed with the pinned toolchain of a project. The shell and
+// its arguments are appended to the wrapper command.
+type Wrapper struct {
+	// Prefix contains the wrapper command and its arguments
+	Prefix []string
+}
+
+// NewWrapper creates a Wrapper from a command line. Arguments are separated by white space, and may be quoted with
+// single or double quotes.
+func NewWrapper(command string) (Wrapper, error) {
+	prefix, err := splitWords(command)
+	if err != nil {
+		return Wrapper{}, fmt.Errorf("invalid wrapper command %s: %v", command, err)
+	}
+	if len(prefix) == 0 {
+		return Wrapper{}, fmt.Errorf("the wrapper command is empty")
+	}
+	return Wrapper{Prefix: prefix}, nil
+}
+
+// Command implements Backend
+func (wrapper Wrapper) Command(shell string, arguments []string, options Options) (*exec.Cmd, error) {
+	if len(wrapper.Prefix) == 0 {
+		return nil, fmt.Errorf("the wrapper command is empty")
+	}
+	wrapped := append(append(append([]string(nil), wrapper.Prefix[1:]...), shell), arguments...)
+	return Host{}.Command(wrapper.Prefix[0], wrapped, options)
+}
+
+// Kill implements Backend
+func (Wrapper) Kill(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+}
+
+// splitWords splits a command line into words at white space, quotes group words
+func splitWords(command string) ([]string, error) {
+	var words []string
+	var current strings.Builder
+	word := false
+	var quote rune
+	for _, char := range command {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(char)
+		case char == '"' || char == '\'':
+			quote = char
+			word = true
+		case unicode.IsSpace(char):
+			if word {
+				words = append(words, current.String())
+				current.Reset()
+				word = false
+			}
+		default:
+			current.WriteRune(char)
+			word = true
+		}
+	}
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated quote")
+	}
+	if word {
+		words = append(words, current.String())
+	}
+	return words, nil
+}
+
+// DockerWorkingDirectory is the directory in the container that Options.Directory is mounted at
+const DockerWorkingDirectory = "/shelldoc"
+
+// containers counts the containers started by this process, to give them unique names
+var containers int32
+
+// Docker runs the shell in a new container of an image, which is removed when the shell exits. The shell is
+// specified as a path or name inside the container. The environment of the host is not passed to the container,
+// only the additional variables of the options. If the options specify a working directory, it is mounted at
+// DockerWorkingDirectory.
+type Docker struct {
+	// Image is the name of the image the container is created from, like debian:12
+	Image string
+	// Executable is the docker command, "docker" if empty (compatible tools like podman work as well)
+	Executable string
+}
+
+// Command implements Backend
+func (docker Docker) Command(shell string, arguments []string, options Options) (*exec.Cmd, error) {
+	if len(docker.Image) == 0 {
+		return nil, fmt.Errorf("no image specified to run the shell in")
+	}
+	if len(options.User) > 0 {
+		return nil, fmt.Errorf("the shell cannot be run as user %s in a container", options.User)
+	}
+	name := fmt.Sprintf("shelldoc-%d-%d", os.Getpid(), atomic.AddInt32(&containers, 1))
+	args := []string{"run", "--rm", "--interactive", "--init", "--name", name}
+	for _, variable := range options.Environment {
+		args = append(args, "--env", variable)
+	}
+	if len(options.Directory) > 0 {
+		args = append(args, "--volume", options.Directory+":"+DockerWorkingDirectory, "--workdir", DockerWorkingDirectory)
+	}
+	args = append(args, docker.Image, shell)
+	cmd := exec.Command(docker.executable(), append(args, arguments...)...)
+	setProcessGroup(cmd)
+	return cmd, nil
+}
+
+// Kill implements Backend. Killing the docker client does not stop the container, so it is removed as well.
+func (docker Docker) Kill(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+	if name := containerName(cmd); len(name) > 0 {
+		exec.Command(docker.executable(), "rm", "--force", name).Run()
+	}
+}
+
+func (docker Docker) executable() string {
+	if len(docker.Executable) == 0 {
+		return "docker"
+	}
+	return docker.Executable
+}
+
+// containerName returns the name of the container started by cmd
+func containerName(cmd *exec.Cmd) string {
+	for index, arg := range cmd.Args {
+		if arg == "--name" && index+1 < len(cmd.Args) {
+			return cmd.Args[index+1]
+		}
+	}
+	return ""
+}
+
+// SSH runs the shell on a remote machine over SSH. The shell is specified as a path or name on the remote machine.
+// The environment of the host is not passed to the remote machine, only the additional variables of the options.
+// Authentication must not require a password, since the input of ssh is used for the commands.
+type SSH struct {
+	// Destination is the remote machine, like user@host
+	Destination string
+	// Executable is the ssh command, "ssh" if empty
+	Executable string
+}
+
+// Command implements Backend
+func (ssh SSH) Command(shell string, arguments []string, options Options) (*exec.Cmd, error) {
+	if len(ssh.Destination) == 0 {
+		return nil, fmt.Errorf("no remote machine specified to run the shell on")
+	}
+	if len(options.Directory) > 0 {
+		return nil, fmt.Errorf("the local working directory %s cannot be used on the remote machine", options.Directory)
+	}
+	if len(options.User) > 0 {
+		return nil, fmt.Errorf("the shell cannot be run as user %s on the remote machine", options.User)
+	}
+	// ssh passes the remote command to the login shell of the user, so every word is quoted
+	remote := []string{"exec"}
+	if len(options.Environment) > 0 {
+		remote = append(remote, "env")
+		for _, variable := range options.Environment {
+			remote = append(remote, Quote(variable))
+		}
+	}
+	remote = append(remote, Quote(shell))
+	for _, argument := range arguments {
+		remote = append(remote, Quote(argument))
+	}
+	executable := ssh.Executable
+	if len(executable) == 0 {
+		executable = "ssh"
+	}
+	cmd := exec.Command(executable, "-T", "-o", "BatchMode=yes", ssh.Destination, "--", strings.Join(remote, " "))
+	setProcessGroup(cmd)
+	return cmd, nil
+}
+
+// Kill implements Backend. The remote shell is stopped when the connection is closed.
+func (SSH) Kill(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+}
@@ -0,0 +1,50 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// StartBackground starts the command in the background and returns its process ID. The output of the command is
+// written to logfile, its input is empty. Background processes are killed when the shell exits. They are only
+// supported in POSIX shells.
+func (shell *Shell) StartBackground(command, logfile string) (int, error) {
+	if !shell.dialect.posix() {
+		return 0, fmt.Errorf("background processes are only supported in POSIX shells")
+	}
+	// a trailing & is redundant, and would be a syntax error in the script
+	command = strings.TrimSpace(command)
+	if !strings.HasSuffix(command, "&&") {
+		command = strings.TrimSpace(strings.TrimSuffix(command, "&"))
+	}
+	output, rc, err := shell.ExecuteCommand(fmt.Sprintf("{ %s\n} >%s 2>&1 </dev/null & echo $!", command, Quote(logfile)))
+	if err != nil {
+		return 0, fmt.Errorf("unable to start background process: %v", err)
+	}
+	shell.background = true
+	if rc != 0 || len(output) != 1 {
+		return 0, fmt.Errorf("unable to start background process: %s", strings.Join(output, " "))
+	}
+	pid, err := strconv.Atoi(strings.TrimSpace(output[0]))
+	if err != nil {
+		return 0, fmt.Errorf("unable to determine the process ID of the background process: %v", err)
+	}
+	return pid, nil
+}
+
+// Running returns true if the background process with the specified process ID is still running
+func (shell *Shell) Running(pid int) bool {
+	_, rc, err := shell.ExecuteCommand(fmt.Sprintf("kill -0 %d 2>/dev/null", pid))
+	return err == nil && rc == 0
+}
+
+// Terminate asks the background process with the specified process ID to terminate. Processes that ignore the
+// request are killed when the shell exits, see Exit.
+func (shell *Shell) Terminate(pid int) {
+	shell.ExecuteCommand(fmt.Sprintf("kill %d 2>/dev/null", pid))
+}
@@ -0,0 +1,45 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// startupVariables name the files that non-interactive shells source on startup
+var startupVariables = []string{"BASH_ENV", "ENV"}
+
+// cleanArguments returns the command line arguments that prevent the shell from reading the user's startup files.
+// cmd.exe and PowerShell are always started without AutoRun commands and profiles, see their dialects.
+func cleanArguments(shell string) []string {
+	name := strings.ToLower(filepath.Base(strings.Replace(shell, "\\", "/", -1)))
+	switch strings.TrimSuffix(name, ".exe") {
+	case "bash":
+		return []string{"--noprofile", "--norc"}
+	case "zsh":
+		return []string{"-f"}
+	case "fish":
+		return []string{"--no-config"}
+	default:
+		return nil
+	}
+}
+
+// cleanEnvironment removes the variables from the environment that make shells source startup files
+func cleanEnvironment(environment []string) []string {
+	var result []string
+	for _, variable := range environment {
+		name := strings.SplitN(variable, "=", 2)[0]
+		startup := false
+		for _, startupVariable := range startupVariables {
+			startup = startup || name == startupVariable
+		}
+		if !startup {
+			result = append(result, variable)
+		}
+	}
+	return result
+}
@@ -0,0 +1,221 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// dialect describes how commands are sent to a specific kind of shell, and how their exit code is reported
+type dialect interface {
+	// arguments returns the command line arguments used to start the shell reading commands from stdin
+	arguments() []string
+	// script returns the input that runs the command between the begin and end markers. The end marker is followed
+	// by a space and the exit code of the command.
+	script(command, beginMarker, endMarker string) string
+	// export returns the command that sets an environment variable
+	export(name, value string) string
+	// separator is used to run several commands in one line
+	separator() string
+	// pwd returns the command that prints the working directory
+	pwd() string
+	// cd returns the command that changes the working directory
+	cd(directory string) string
+	// posix is true for shells that implement the POSIX shell command language
+	posix() bool
+	// errorEcho returns the input that prints the marker to the error output of the shell
+	errorEcho(marker string) string
+	// echo returns the input that prints the marker to the output of the shell
+	echo(marker string) string
+	// strict returns the input that enables strict mode before a command and the one that disables it afterwards.
+	// In strict mode, a failing command makes the shell print the marker followed by a space and the exit code, and
+	// exit. Both are empty if the shell has no strict mode.
+	strict(marker string) (enable, disable string)
+}
+
+// dialectFor selects the dialect based on the name of the shell executable
+func dialectFor(shell string) dialect {
+	name := strings.ToLower(filepath.Base(strings.Replace(shell, "\\", "/", -1)))
+	name = strings.TrimSuffix(name, ".exe")
+	switch name {
+	case "cmd":
+		return cmdDialect{}
+	case "powershell", "pwsh":
+		return powershellDialect{}
+	case "fish":
+		return fishDialect{}
+	case "csh", "tcsh":
+		return cshDialect{}
+	default:
+		return posixDialect{}
+	}
+}
+
+// posixDialect is used for sh, bash, zsh and other POSIX shells
+type posixDialect struct{}
+
+func (posixDialect) arguments() []string { return nil }
+
+func (posixDialect) script(command, beginMarker, endMarker string) string {
+	return fmt.Sprintf("echo \"%s\"\n%s; echo \"%s $?\"\n", beginMarker, command, endMarker)
+}
+
+func (posixDialect) export(name, value string) string {
+	return fmt.Sprintf("export %s=%s", name, Quote(value))
+}
+
+func (posixDialect) separator() string { return "; " }
+
+func (posixDialect) pwd() string { return "pwd" }
+
+func (posixDialect) cd(directory string) string { return "cd " + Quote(directory) }
+
+func (posixDialect) posix() bool { return true }
+
+func (posixDialect) errorEcho(marker string) string { return fmt.Sprintf("echo \"%s\" >&2\n", marker) }
+
+func (posixDialect) echo(marker string) string { return fmt.Sprintf("echo \"%s\"\n", marker) }
+
+// pipefail is not supported by all POSIX shells, setting it in a subshell first avoids that the shell exits
+func (posixDialect) strict(marker string) (string, string) {
+	return fmt.Sprintf("(set -o pipefail) 2>/dev/null && set -o pipefail; trap 'echo \"%s $?\"' EXIT; set -eu\n", marker),
+		"set +eu; trap - EXIT; (set +o pipefail) 2>/dev/null && set +o pipefail\n"
+}
+
+// fishDialect is used for the fish shell, which reports the exit code in $status
+type fishDialect struct{}
+
+func (fishDialect) arguments() []string { return nil }
+
+func (fishDialect) script(command, beginMarker, endMarker string) string {
+	return fmt.Sprintf("echo \"%s\"\n%s; echo \"%s $status\"\n", beginMarker, command, endMarker)
+}
+
+func (fishDialect) export(name, value string) string {
+	return fmt.Sprintf("set -gx %s %s", name, fishQuote(value))
+}
+
+func (fishDialect) separator() string { return "; " }
+
+func (fishDialect) pwd() string { return "pwd" }
+
+func (fishDialect) cd(directory string) string { return "cd " + fishQuote(directory) }
+
+func (fishDialect) posix() bool { return false }
+
+func (fishDialect) errorEcho(marker string) string { return fmt.Sprintf("echo \"%s\" >&2\n", marker) }
+
+func (fishDialect) echo(marker string) string { return fmt.Sprintf("echo \"%s\"\n", marker) }
+
+func (fishDialect) strict(string) (string, string) { return "", "" }
+
+// fishQuote returns value in single quotes, so that fish does not interpret it
+func fishQuote(value string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, "'", `\'`).Replace(value) + "'"
+}
+
+// cshDialect is used for csh and tcsh, which report the exit code in $status
+type cshDialect struct{}
+
+func (cshDialect) arguments() []string { return nil }
+
+func (cshDialect) script(command, beginMarker, endMarker string) string {
+	return fmt.Sprintf("echo \"%s\"\n%s; echo \"%s $status\"\n", beginMarker, command, endMarker)
+}
+
+func (cshDialect) export(name, value string) string {
+	return fmt.Sprintf("setenv %s %s", name, Quote(value))
+}
+
+func (cshDialect) separator() string { return "; " }
+
+func (cshDialect) pwd() string { return "pwd" }
+
+func (cshDialect) cd(directory string) string { return "cd " + Quote(directory) }
+
+func (cshDialect) posix() bool { return false }
+
+// csh cannot redirect only the output of a command to stderr
+func (cshDialect) errorEcho(marker string) string {
+	return fmt.Sprintf("echo \"%s\" > /dev/stderr\n", marker)
+}
+
+func (cshDialect) echo(marker string) string { return fmt.Sprintf("echo \"%s\"\n", marker) }
+
+func (cshDialect) strict(string) (string, string) { return "", "" }
+
+// cmdDialect is used for the Windows command interpreter cmd.exe
+type cmdDialect struct{}
+
+func (cmdDialect) arguments() []string {
+	// /D: do not run AutoRun commands, /Q: do not echo the commands
+	return []string{"/D", "/Q"}
+}
+
+func (cmdDialect) script(command, beginMarker, endMarker string) string {
+	// cmd.exe expands %ERRORLEVEL% when a line is read, so the exit code is reported on a separate line
+	return fmt.Sprintf("echo %s\r\n%s\r\necho %s %%ERRORLEVEL%%\r\n", beginMarker, command, endMarker)
+}
+
+func (cmdDialect) export(name, value string) string { return fmt.Sprintf("set \"%s=%s\"", name, value) }
+
+func (cmdDialect) separator() string { return " & " }
+
+func (cmdDialect) pwd() string { return "cd" }
+
+func (cmdDialect) cd(directory string) string { return fmt.Sprintf("cd /d \"%s\"", directory) }
+
+func (cmdDialect) posix() bool { return false }
+
+func (cmdDialect) errorEcho(marker string) string { return fmt.Sprintf("echo %s 1>&2\r\n", marker) }
+
+func (cmdDialect) echo(marker string) string { return fmt.Sprintf("echo %s\r\n", marker) }
+
+func (cmdDialect) strict(string) (string, string) { return "", "" }
+
+// powershellDialect is used for Windows PowerShell and PowerShell Core
+type powershellDialect struct{}
+
+func (powershellDialect) arguments() []string {
+	return []string{"-NoLogo", "-NoProfile", "-NonInteractive", "-Command", "-"}
+}
+
+func (powershellDialect) script(command, beginMarker, endMarker string) string {
+	// $? reports whether the command succeeded, $LASTEXITCODE contains the exit code of native programs
+	return fmt.Sprintf("Write-Output '%s'\n$global:LASTEXITCODE = 0; %s\n"+
+		"$shelldocrc = if ($?) { 0 } elseif ($LASTEXITCODE) { $LASTEXITCODE } else { 1 }; Write-Output \"%s $shelldocrc\"\n",
+		beginMarker, command, endMarker)
+}
+
+func (powershellDialect) export(name, value string) string {
+	return fmt.Sprintf("$env:%s = %s", name, powershellQuote(value))
+}
+
+func (powershellDialect) separator() string { return "; " }
+
+func (powershellDialect) pwd() string { return "(Get-Location).Path" }
+
+func (powershellDialect) cd(directory string) string {
+	return "Set-Location -LiteralPath " + powershellQuote(directory)
+}
+
+func (powershellDialect) posix() bool { return false }
+
+func (powershellDialect) errorEcho(marker string) string {
+	return fmt.Sprintf("[Console]::Error.WriteLine('%s')\n", marker)
+}
+
+func (powershellDialect) echo(marker string) string {
+	return fmt.Sprintf("Write-Output '%s'\n", marker)
+}
+
+func (powershellDialect) strict(string) (string, string) { return "", "" }
+
+// powershellQuote returns value in single quotes, so that PowerShell does not interpret it
+func powershellQuote(value string) string {
+	return "'" + strings.Replace(value, "'", "''", -1) + "'"
+}
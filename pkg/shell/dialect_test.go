@@ -0,0 +1,94 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: Apache-2.0
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDialectFor(t *testing.T) {
+	require.IsType(t, posixDialect{}, dialectFor("/bin/bash"))
+	require.IsType(t, posixDialect{}, dialectFor("/usr/bin/zsh"))
+	require.IsType(t, cmdDialect{}, dialectFor(`C:\Windows\System32\cmd.exe`))
+	require.IsType(t, cmdDialect{}, dialectFor(`C:\WINDOWS\system32\CMD.EXE`))
+	require.IsType(t, powershellDialect{}, dialectFor(`C:\Windows\System32\WindowsPowerShell\v1.0\powershell.exe`))
+	require.IsType(t, powershellDialect{}, dialectFor("/usr/local/bin/pwsh"))
+}
+
+func TestDialectScripts(t *testing.T) {
+	require.Equal(t, "echo BEGIN\r\ndir\r\necho END %ERRORLEVEL%\r\n", cmdDialect{}.script("dir", "BEGIN", "END"),
+		"cmd.exe reports the exit code on a separate line")
+	require.Equal(t, `set "NAME=a b"`, cmdDialect{}.export("NAME", "a b"))
+	require.Equal(t, `$env:NAME = 'it''s'`, powershellDialect{}.export("NAME", "it's"))
+	require.Equal(t, "Set-Location -LiteralPath 'C:\\Program Files'", powershellDialect{}.cd(`C:\Program Files`))
+	require.Equal(t, "export NAME='it'\\''s'", posixDialect{}.export("NAME", "it's"))
+}
+
+func TestPowerShell(t *testing.T) {
+	// PowerShell is only available on some systems, see https://github.com/PowerShell/PowerShell
+	path, err := DetectShell("pwsh")
+	if err != nil {
+		t.Skip("PowerShell is not installed")
+	}
+	shell, err := StartShell(path)
+	require.NoError(t, err, "Starting PowerShell should work")
+	defer shell.Exit()
+	output, rc, err := shell.ExecuteCommand("Write-Output Hello")
+	require.NoError(t, err)
+	require.Equal(t, 0, rc, "Successful cmdlets report exit code 0")
+	require.Equal(t, []string{"Hello"}, output)
+	_, rc, err = shell.ExecuteCommand("Get-Item /does/not/exist")
+	require.NoError(t, err)
+	require.Equal(t, 1, rc, "Failing cmdlets report exit code 1")
+}
+
+func TestWindowsShellPath(t *testing.T) {
+	environment := map[string]string{"ProgramFiles": `C:\Program Files`}
+	getenv := func(name string) string { return environment[name] }
+	installed := map[string]bool{`C:\Program Files\Git\bin\bash.exe`: true, `C:\tools\msys64\usr\bin\zsh.exe`: true}
+	exists := func(path string) bool { return installed[path] }
+	require.Equal(t, `C:\Program Files\Git\bin\bash.exe`, windowsShellPath("/usr/bin/bash", getenv, exists),
+		"MSYS paths in $SHELL are translated to Git Bash")
+	require.Equal(t, `C:\Program Files\Git\bin\bash.exe`, windowsShellPath("bash", getenv, exists),
+		"Git Bash is preferred over the WSL launcher")
+	require.Equal(t, `C:\tools\msys64\usr\bin\zsh.exe`, windowsShellPath("/c/tools/msys64/usr/bin/zsh.exe", getenv, exists),
+		"Drive paths are translated")
+	require.Equal(t, `C:\Windows\System32\cmd.exe`, windowsShellPath(`C:\Windows\System32\cmd.exe`, getenv, exists),
+		"Windows paths are not modified")
+	require.Equal(t, "pwsh", windowsShellPath("pwsh", getenv, exists), "Other shells are not modified")
+}
+
+func TestDefaultCandidates(t *testing.T) {
+	require.Equal(t, []string{"/bin/bash", "/bin/sh", "bash"}, defaultCandidates("linux"))
+	require.Equal(t, []string{"/bin/bash", "/bin/sh", "bash", "pwsh", "powershell", "cmd"}, defaultCandidates("windows"),
+		"PowerShell and cmd.exe are used on Windows if no POSIX shell is found")
+}
+
+func TestFishAndCsh(t *testing.T) {
+	require.IsType(t, fishDialect{}, dialectFor("/usr/bin/fish"))
+	require.IsType(t, cshDialect{}, dialectFor("/bin/tcsh"))
+	require.Equal(t, "echo \"BEGIN\"\nfalse; echo \"END $status\"\n", fishDialect{}.script("false", "BEGIN", "END"),
+		"fish reports the exit code in $status")
+	require.Equal(t, `set -gx NAME 'it\'s'`, fishDialect{}.export("NAME", "it's"))
+	require.Equal(t, "setenv NAME 'a b'", cshDialect{}.export("NAME", "a b"))
+}
+
+func TestProbeUnsupportedShell(t *testing.T) {
+	// cat never answers the probe command, which should fail instead of hanging
+	path, err := DetectShell("cat")
+	require.NoError(t, err, "cat should be installed")
+	defer func(timeout time.Duration) { probeTimeout = timeout }(probeTimeout)
+	probeTimeout = 100 * time.Millisecond
+	_, err = StartShell(path)
+	require.Error(t, err, "Shells that do not answer the probe are not supported")
+	require.Contains(t, err.Error(), "is not supported")
+	require.Contains(t, err.Error(), "fish:", "All dialects are tried")
+	require.Equal(t, posixDialect{}, candidateDialects("/bin/sh")[0], "The dialect selected by name is tried first")
+	require.Equal(t, cmdDialect{}, candidateDialects("cmd.exe")[0])
+	require.Len(t, candidateDialects("cmd.exe"), len(dialectNames))
+}
@@ -0,0 +1,48 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// syncMarker is printed by the shell when it has finished starting up
+const syncMarker = "##########SHELLDOC_MARKER"
+
+// handshake synchronizes with a freshly started shell. Everything the shell prints before the sync marker, like
+// login banners, the message of the day or the output of startup files, is discarded, so that it does not end up in
+// the output of the first command. The shell is stopped if it does not print the marker.
+func (shell *Shell) handshake() error {
+	shell.write(shell.dialect.echo(syncMarker))
+	timeout := time.NewTimer(probeTimeout)
+	defer timeout.Stop()
+	discarded := 0
+	for {
+		select {
+		case line, ok := <-shell.lines:
+			if !ok {
+				return fmt.Errorf("the shell exited during startup")
+			}
+			// the startup output may not end with a newline, the marker is then printed on the same line
+			if !strings.HasSuffix(line, syncMarker) {
+				discarded++
+				continue
+			}
+			if prefix := strings.TrimSuffix(line, syncMarker); len(prefix) > 0 {
+				discarded++
+			}
+			discarded += len(shell.collectErrors())
+			if discarded > 0 {
+				shell.options.Transcript.Record(TranscriptNote, fmt.Sprintf("discarded %d lines of startup output", discarded))
+			}
+			return nil
+		case <-timeout.C:
+			killProcessGroup(shell.cmd)
+			return fmt.Errorf("the shell did not finish starting up within %v", probeTimeout)
+		}
+	}
+}
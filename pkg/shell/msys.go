@@ -0,0 +1,53 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"path"
+	"regexp"
+	"strings"
+)
+
+// msysDriveEx matches the drive letter in paths used by MSYS shells like Git Bash, for example /c/Users
+const msysDriveEx = `^/([A-Za-z])(/.*)?$`
+
+var msysDriveRx = regexp.MustCompile(msysDriveEx)
+
+// gitBashLocations returns the usual installation paths of Git Bash, based on the environment
+func gitBashLocations(getenv func(string) string) []string {
+	var locations []string
+	for _, variable := range []string{"ProgramW6432", "ProgramFiles", "ProgramFiles(x86)"} {
+		if directory := getenv(variable); len(directory) > 0 {
+			locations = append(locations, directory+`\Git\bin\bash.exe`)
+		}
+	}
+	if directory := getenv("LOCALAPPDATA"); len(directory) > 0 {
+		locations = append(locations, directory+`\Programs\Git\bin\bash.exe`)
+	}
+	return locations
+}
+
+// windowsShellPath translates the shell selected on Windows to a path that can be executed. MSYS paths like
+// /usr/bin/bash, which Git Bash puts into $SHELL, are translated to the Git installation, and drive paths like
+// /c/Program Files to C:\Program Files. For bash and sh, Git Bash is preferred over the WSL launcher in
+// C:\Windows\System32, which runs the commands in a Linux environment instead. The selection is returned unchanged
+// if no translation applies.
+func windowsShellPath(selected string, getenv func(string) string, exists func(string) bool) string {
+	name := strings.TrimSuffix(strings.ToLower(path.Base(strings.Replace(selected, `\`, "/", -1))), ".exe")
+	if match := msysDriveRx.FindStringSubmatch(selected); match != nil {
+		translated := strings.ToUpper(match[1]) + `:\` + strings.Replace(strings.TrimPrefix(match[2], "/"), "/", `\`, -1)
+		if exists(translated) {
+			return translated
+		}
+	}
+	if strings.HasPrefix(selected, "/") || ((name == "bash" || name == "sh") && !strings.ContainsAny(selected, `/\`)) {
+		for _, location := range gitBashLocations(getenv) {
+			if exists(location) {
+				return location
+			}
+		}
+	}
+	return selected
+}
@@ -0,0 +1,72 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// probeTimeout is the time a shell has to answer the probe command after it has been started
+var probeTimeout = 5 * time.Second
+
+// probeText is printed by the probe command
+const probeText = "SHELLDOC_PROBE"
+
+// dialectNames names the dialects in the order in which they are tried if the shell does not answer the probe
+var dialectNames = []struct {
+	name    string
+	dialect dialect
+}{
+	{"POSIX", posixDialect{}},
+	{"fish", fishDialect{}},
+	{"csh", cshDialect{}},
+	{"PowerShell", powershellDialect{}},
+	{"cmd.exe", cmdDialect{}},
+}
+
+// candidateDialects returns the dialect selected by the name of the shell, followed by the others
+func candidateDialects(shell string) []dialect {
+	selected := dialectFor(shell)
+	candidates := []dialect{selected}
+	for _, candidate := range dialectNames {
+		if candidate.dialect != selected {
+			candidates = append(candidates, candidate.dialect)
+		}
+	}
+	return candidates
+}
+
+// dialectName returns a human readable name for the dialect
+func dialectName(dialect dialect) string {
+	for _, candidate := range dialectNames {
+		if candidate.dialect == dialect {
+			return candidate.name
+		}
+	}
+	return "unknown"
+}
+
+// probe verifies that the shell echoes the probe text without echoing the commands, and reports exit code 0 for it.
+// The shell is stopped if it does not.
+func (shell *Shell) probe() error {
+	shell.SetDeadline(time.Now().Add(probeTimeout))
+	defer shell.SetDeadline(time.Time{})
+	output, rc, err := shell.ExecuteCommand("echo " + probeText)
+	if err != nil {
+		return err
+	}
+	if rc == 0 && reflect.DeepEqual(output, []string{probeText}) {
+		return nil
+	}
+	killProcessGroup(shell.cmd)
+	if rc != 0 {
+		return fmt.Errorf("the probe command reported exit code %d", rc)
+	}
+	return fmt.Errorf("unexpected output from the probe command (line editing or command echo enabled?): %s",
+		strings.Join(output, " | "))
+}
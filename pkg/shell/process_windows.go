@@ -0,0 +1,34 @@
+//go:build windows
+// +build windows
+
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os/exec"
+)
+
+// setProcessGroup does nothing on Windows
+func setProcessGroup(cmd *exec.Cmd) {
+}
+
+// killProcessGroup kills the shell process
+func killProcessGroup(cmd *exec.Cmd) {
+	if cmd.Process != nil {
+		cmd.Process.Kill()
+	}
+}
+
+// interruptProcessGroup kills the shell process, since Windows cannot send SIGINT to other processes
+func interruptProcessGroup(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+}
+
+// setUser is not supported on Windows
+func setUser(cmd *exec.Cmd, name string) error {
+	return fmt.Errorf("running the shell as another user is not supported on Windows")
+}
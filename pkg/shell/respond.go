@@ -0,0 +1,80 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+)
+
+// Responder answers a prompt of a command automatically, like expect. If the output of a running command matches
+// Pattern, Reply is sent to it, followed by a newline.
+type Responder struct {
+	// Pattern matches the prompt, like `\[y/N\]`
+	Pattern *regexp.Regexp
+	// Reply is the answer to the prompt
+	Reply string
+}
+
+// partialLine contains the output of the shell after the last newline. Prompts usually do not end with a newline,
+// so they can only be detected here.
+type partialLine struct {
+	mutex    sync.Mutex
+	text     string
+	answered bool
+}
+
+// set stores the output after the last newline
+func (partial *partialLine) set(text string) {
+	partial.mutex.Lock()
+	defer partial.mutex.Unlock()
+	if text != partial.text {
+		partial.text, partial.answered = text, false
+	}
+}
+
+// unanswered returns the output after the last newline, unless it has been answered already
+func (partial *partialLine) unanswered() string {
+	partial.mutex.Lock()
+	defer partial.mutex.Unlock()
+	if partial.answered {
+		return ""
+	}
+	return partial.text
+}
+
+// answer marks the output after the last newline as answered
+func (partial *partialLine) answer() {
+	partial.mutex.Lock()
+	defer partial.mutex.Unlock()
+	partial.answered = true
+}
+
+// SetResponders sets the rules that answer prompts of the following commands. nil disables them.
+func (shell *Shell) SetResponders(responders []Responder) {
+	shell.responders = responders
+}
+
+// respond sends the reply of the first responder that matches text to the running command. It returns true if a
+// reply was sent.
+func (shell *Shell) respond(text string) bool {
+	reply, ok := shell.reply(text)
+	if ok {
+		shell.options.Transcript.Record(TranscriptNote, fmt.Sprintf("auto-responding to prompt %q with %q", text, reply))
+		shell.write(reply + "\n")
+	}
+	return ok
+}
+
+// reply returns the reply of the first responder that matches text, and false if none matches
+func (shell *Shell) reply(text string) (string, bool) {
+	for _, responder := range shell.responders {
+		if responder.Pattern.MatchString(text) {
+			return responder.Reply, true
+		}
+	}
+	return "", false
+}
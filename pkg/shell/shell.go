@@ -5,43 +5,194 @@ package shell
 // SPDX-License-Identifier: LGPL-3.0
 
 import (
-	"bufio"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
 	"os/exec"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
+	"sync/atomic"
+	"time"
 )
 
 // Shell represents the shell process that runs in the background and executes the commands.
 type Shell struct {
-	cmd    *exec.Cmd
-	stdin  io.WriteCloser
-	stdout io.ReadCloser
+	cmd      *exec.Cmd
+	stdin    io.WriteCloser
+	stdout   io.ReadCloser
+	lines    chan string
+	deadline time.Time
+	warnAt   time.Time
+	warn     func()
+	// responders answer prompts of the running command, partial contains its output after the last newline
+	responders []Responder
+	partial    *partialLine
+	options    Options
+	usage      Usage
+	dialect    dialect
+	// interrupted is set to 1 once the shell has been interrupted (accessed atomically)
+	interrupted int32
+	// background is set once a background process has been started, see StartBackground
+	background bool
+	// errors collects the error output of the shell, errorOutput contains the one of the last command
+	errors      *errorLines
+	errorOutput []string
+	// strict is set while the commands are executed in strict mode, tripped once a command failed in it, see SetStrict
+	strict  bool
+	tripped bool
 }
 
-// DetectShell returns the path to the selected shell or the content of $SHELL
+// ErrDeadline is returned by ExecuteCommand if the command did not finish before the deadline, see SetDeadline
+var ErrDeadline = errors.New("the command did not finish before the deadline, the shell was stopped")
+
+// Options contains optional settings for starting a shell.
+type Options struct {
+	// Transcript records the raw session with the shell, if set
+	Transcript *Transcript
+	// Environment contains additional environment variables for the shell in the form KEY=VALUE
+	Environment []string
+	// ProbeResources enables measuring the CPU time and memory used by every command, see Usage
+	ProbeResources bool
+	// CleanStartup starts the shell without reading the user's profile and rc files
+	CleanStartup bool
+	// Directory is the working directory the shell is started in, the current directory if empty
+	Directory string
+	// Backend starts the shell process, on the local host if nil
+	Backend Backend
+	// User is the name of the user the shell is executed as, which requires root privileges. The shell runs as the
+	// current user if empty.
+	User string
+}
+
+// backend returns the backend that starts the shell process
+func (options Options) backend() Backend {
+	if options.Backend == nil {
+		return Host{}
+	}
+	return options.Backend
+}
+
+// DefaultCandidates are the shells that are tried in order if no shell is selected and $SHELL is not usable, for
+// example in minimal containers
+var DefaultCandidates = defaultCandidates(runtime.GOOS)
+
+// defaultCandidates returns the shells that are tried on the operating system. On Windows, PowerShell and cmd.exe are
+// tried after Git Bash, so that Windows-oriented documentation can be tested without installing a POSIX shell.
+func defaultCandidates(goos string) []string {
+	candidates := []string{"/bin/bash", "/bin/sh", "bash"}
+	if goos == "windows" {
+		candidates = append(candidates, "pwsh", "powershell", "cmd")
+	}
+	return candidates
+}
+
+// DetectShell returns the path to the selected shell or the content of $SHELL, or the first usable one of the
+// DefaultCandidates
 func DetectShell(selected string) (string, error) {
+	return DetectShellWithCandidates(selected, DefaultCandidates)
+}
+
+// DetectShellWithCandidates returns the path to the selected shell or the content of $SHELL. If no shell is selected
+// and $SHELL is not set or not usable, the first usable one of the candidates is returned. A selected shell that is
+// not usable is an error.
+func DetectShellWithCandidates(selected string, candidates []string) (string, error) {
 	if len(selected) > 0 {
 		// accept what the user said
 		log.Printf("Using user-specified shell %s.", selected)
-	} else if selected = os.Getenv("SHELL"); len(selected) > 0 {
-		log.Printf("Using shell %s (according to $SHELL).", selected)
-	} else {
-		return "", fmt.Errorf("no shell specified and no $SHELL variable set")
+		return resolveShell(selected)
+	}
+	if selected = os.Getenv("SHELL"); len(selected) > 0 {
+		path, err := resolveShell(selected)
+		if err == nil {
+			log.Printf("Using shell %s (according to $SHELL).", path)
+			return path, nil
+		}
+		log.Printf("The shell %s in $SHELL is not usable (%v).", selected, err)
+	} else if selected = os.Getenv("COMSPEC"); runtime.GOOS == "windows" && len(selected) > 0 {
+		log.Printf("Using shell %s (according to %%COMSPEC%%).", selected)
+		return resolveShell(selected)
+	}
+	for _, candidate := range candidates {
+		if path, err := resolveShell(candidate); err == nil {
+			log.Printf("Using shell %s (fallback).", path)
+			return path, nil
+		}
+	}
+	if len(candidates) == 0 {
+		return "", fmt.Errorf("no shell specified and no usable $SHELL variable set")
+	}
+	return "", fmt.Errorf("no shell specified, no usable $SHELL variable set, and none of %s found", strings.Join(candidates, ", "))
+}
+
+// resolveShell returns the path to a shell, which may be specified by name if it is found in $PATH
+func resolveShell(selected string) (string, error) {
+	if runtime.GOOS == "windows" {
+		selected = windowsShellPath(selected, os.Getenv, func(path string) bool {
+			_, err := os.Stat(path)
+			return err == nil
+		})
 	}
 	if _, err := os.Stat(selected); os.IsNotExist(err) {
-		return "", fmt.Errorf("the selected shell does not exist: %v", err)
+		// accept shell names like bash or powershell that are found in $PATH
+		path, lookupErr := exec.LookPath(selected)
+		if lookupErr != nil {
+			return "", fmt.Errorf("the selected shell does not exist: %v", err)
+		}
+		selected = path
 	}
 	return selected, nil
 }
 
 // StartShell starts a shell as a background process
 func StartShell(shell string) (Shell, error) {
-	cmd := exec.Command(shell)
+	return StartShellWithOptions(shell, Options{})
+}
+
+// StartShellWithOptions starts a shell as a background process with the specified options. The output the shell
+// prints while starting up is discarded, see handshake. The shell is then probed with a simple command to verify that
+// it understands the command protocol of the dialect selected based on its name. If it does not, the other dialects
+// are tried, and an error is returned if the shell is not supported.
+func StartShellWithOptions(shell string, options Options) (Shell, error) {
+	var problems []string
+	for _, dialect := range candidateDialects(shell) {
+		started, err := startShell(shell, dialect, options)
+		if err != nil {
+			return Shell{}, err
+		}
+		err = started.handshake()
+		if err == nil {
+			err = started.probe()
+		}
+		if err == nil {
+			return started, nil
+		}
+		go func() {
+			for range started.lines {
+				// discard remaining output, so that the reader can finish
+			}
+		}()
+		started.cmd.Wait()
+		options.Transcript.Record(TranscriptNote, fmt.Sprintf("the %s dialect does not work: %v", dialectName(dialect), err))
+		problems = append(problems, fmt.Sprintf("%s: %v", dialectName(dialect), err))
+	}
+	return Shell{}, fmt.Errorf("the shell %s is not supported (%s)", shell, strings.Join(problems, "; "))
+}
+
+// startShell starts a shell as a background process that is driven using the specified dialect
+func startShell(shell string, dialect dialect, options Options) (Shell, error) {
+	arguments := dialect.arguments()
+	if options.CleanStartup {
+		arguments = append(cleanArguments(shell), arguments...)
+	}
+	cmd, err := options.backend().Command(shell, arguments, options)
+	if err != nil {
+		return Shell{}, fmt.Errorf("Unable to start shell %s: %v", shell, err)
+	}
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
 		return Shell{}, fmt.Errorf("Unable to set up input stream for shell %s: %v", shell, err)
@@ -50,58 +201,359 @@ func StartShell(shell string) (Shell, error) {
 	if err != nil {
 		return Shell{}, fmt.Errorf("Unable to set up output stream for shell %s: %v", shell, err)
 	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return Shell{}, fmt.Errorf("Unable to set up error stream for shell %s: %v", shell, err)
+	}
 	err = cmd.Start()
 	if err != nil {
 		return Shell{}, fmt.Errorf("Unable to start shell %s: %v", shell, err)
 	}
-	return Shell{cmd, stdin, stdout}, nil
+	options.Transcript.Record(TranscriptNote, fmt.Sprintf("started shell %s (pid %d)", shell, cmd.Process.Pid))
+	lines := make(chan string)
+	partial := &partialLine{}
+	go readLines(stdout, lines, partial, options.Transcript)
+	errors := newErrorLines()
+	go readErrors(stderr, errors, options.Transcript)
+	return Shell{cmd: cmd, stdin: stdin, stdout: stdout, lines: lines, partial: partial, errors: errors, options: options,
+		dialect: dialect}, nil
+}
+
+// readLines reads the output of the shell line by line until the output stream is closed. The output after the last
+// newline is stored in partial.
+func readLines(reader io.Reader, lines chan<- string, partial *partialLine, transcript *Transcript) {
+	buffer := make([]byte, 4096)
+	pending := ""
+	for {
+		count, err := reader.Read(buffer)
+		pending += string(buffer[:count])
+		for {
+			newline := strings.IndexByte(pending, '\n')
+			if newline < 0 {
+				break
+			}
+			// shells on Windows terminate lines with CRLF
+			line := strings.TrimSuffix(pending[:newline], "\r")
+			pending = pending[newline+1:]
+			transcript.Record(TranscriptOutput, line)
+			lines <- line
+		}
+		partial.set(pending)
+		if err != nil {
+			break
+		}
+	}
+	if len(pending) > 0 {
+		transcript.Record(TranscriptOutput, pending)
+		lines <- pending
+	}
+	close(lines)
+}
+
+// write sends text to the shell and records it in the transcript
+func (shell *Shell) write(text string) {
+	for _, line := range strings.Split(strings.TrimRight(text, "\r\n"), "\n") {
+		shell.options.Transcript.Record(TranscriptInput, strings.TrimSuffix(line, "\r"))
+	}
+	io.WriteString(shell.stdin, text)
+}
+
+// SetDeadline sets the time after which running commands are aborted by killing the shell.
+// A zero value means commands never time out.
+func (shell *Shell) SetDeadline(deadline time.Time) {
+	shell.deadline = deadline
+}
+
+// Deadline returns the time after which running commands are aborted, see SetDeadline.
+func (shell *Shell) Deadline() time.Time {
+	return shell.deadline
+}
+
+// SetWarning sets the time at which warn is called if a command is still running, for example to report slow
+// commands before the deadline is reached. warn is called at most once, from ExecuteCommand. A zero value disables
+// the warning.
+func (shell *Shell) SetWarning(at time.Time, warn func()) {
+	shell.warnAt, shell.warn = at, warn
+}
+
+// SetStrict enables or disables strict mode for the following commands. In strict mode, the shell exits as soon as a
+// command fails, a pipeline fails or an unset variable is used (set -euo pipefail), and ExecuteCommand returns an
+// error, see Tripped. Shells that have no strict mode return an error when it is enabled.
+func (shell *Shell) SetStrict(strict bool) error {
+	if enable, _ := shell.dialect.strict(""); strict && len(enable) == 0 {
+		return fmt.Errorf("the %s dialect does not support strict mode", dialectName(shell.dialect))
+	}
+	shell.strict = strict
+	return nil
+}
+
+// Tripped returns true if a command failed in strict mode, which made the shell exit
+func (shell *Shell) Tripped() bool {
+	return shell.tripped
 }
 
 // ExecuteCommand runs a command in the shell and returns its output and exit code
 func (shell *Shell) ExecuteCommand(command string) ([]string, int, error) {
 	const (
-		beginMarker = ">>>>>>>>>>SHELLDOC_MARKER>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>"
-		endMarker   = "<<<<<<<<<<SHELLDOC_MARKER"
+		beginMarker  = ">>>>>>>>>>SHELLDOC_MARKER>>>>>>>>>>>>>>>>>>>>>>>>>>>>>>"
+		endMarker    = "<<<<<<<<<<SHELLDOC_MARKER"
+		probeMarker  = "==========SHELLDOC_MARKER"
+		strictMarker = "!!!!!!!!!!SHELLDOC_MARKER"
 	)
 	instruction := fmt.Sprintf("%s", strings.TrimSpace(command))
-	io.WriteString(shell.stdin, fmt.Sprintf("echo \"%s\"\n", beginMarker))
-	io.WriteString(shell.stdin, fmt.Sprintf("%s; echo \"%s $?\"\n", instruction, endMarker))
+	enableStrict, disableStrict := shell.dialect.strict(strictMarker)
+	if shell.strict {
+		shell.write(enableStrict)
+	}
+	// resources are measured using the times builtin of POSIX shells
+	probe := shell.options.ProbeResources && shell.dialect.posix()
+	if probe {
+		// the times builtin reports the CPU time used by the children of the shell before and after the command
+		shell.write(fmt.Sprintf("times; echo \"%s\"\n", beginMarker))
+		shell.write(fmt.Sprintf("%s; echo \"%s $?\"; times; echo \"%s\"\n", instruction, endMarker, probeMarker))
+	} else {
+		shell.write(shell.dialect.script(instruction, beginMarker, endMarker))
+	}
+	if shell.strict {
+		shell.write(disableStrict)
+	}
 
-	// read output (TODO: with timeout), watch for markers:
+	// read output until the deadline, watch for markers:
 	beginEx := fmt.Sprintf("^%s$", beginMarker)
 	beginRx := regexp.MustCompile(beginEx)
 	endEx := fmt.Sprintf("^%s (.+)$", endMarker)
 	endRx := regexp.MustCompile(endEx)
+	strictRx := regexp.MustCompile(fmt.Sprintf("^%s (.+)$", strictMarker))
 
+	var timeout <-chan time.Time
+	if !shell.deadline.IsZero() {
+		timer := time.NewTimer(time.Until(shell.deadline))
+		defer timer.Stop()
+		timeout = timer.C
+	}
+	var warning <-chan time.Time
+	if !shell.warnAt.IsZero() && shell.warn != nil {
+		timer := time.NewTimer(time.Until(shell.warnAt))
+		defer timer.Stop()
+		warning = timer.C
+	}
+	var prompts <-chan time.Time
+	if len(shell.responders) > 0 {
+		ticker := time.NewTicker(50 * time.Millisecond)
+		defer ticker.Stop()
+		prompts = ticker.C
+	}
+	var sample <-chan time.Time
+	if probe {
+		ticker := time.NewTicker(10 * time.Millisecond)
+		defer ticker.Stop()
+		sample = ticker.C
+	}
+	shell.usage = Usage{}
+	shell.errorOutput = nil
+	start := time.Now()
 	var output []string
-	var rc int
+	var userBefore, systemBefore time.Duration
 	beginFound := false
-	scanner := bufio.NewScanner(shell.stdout)
-	for scanner.Scan() {
-		line := scanner.Text()
+	// answered is true if the prompt in the current line has been answered before the line was complete
+	answered := false
+	rc := -1
+	for {
+		var line string
+		select {
+		case received, ok := <-shell.lines:
+			if !ok {
+				if shell.Interrupted() {
+					return output, -1, fmt.Errorf("the command was interrupted")
+				}
+				return output, -1, fmt.Errorf("the shell exited unexpectedly")
+			}
+			line = received
+		case <-timeout:
+			shell.options.Transcript.Record(TranscriptNote, "deadline exceeded, stopping the shell")
+			shell.options.backend().Kill(shell.cmd)
+			return output, -1, ErrDeadline
+		case <-warning:
+			shell.options.Transcript.Record(TranscriptNote, "the command is still running")
+			warn := shell.warn
+			shell.SetWarning(time.Time{}, nil)
+			warn()
+			continue
+		case <-prompts:
+			if text := shell.partial.unanswered(); beginFound && rc < 0 && len(text) > 0 && shell.respond(text) {
+				shell.partial.answer()
+				answered = true
+			}
+			continue
+		case <-sample:
+			if rss := maxRSS(shell.cmd.Process.Pid); rss > shell.usage.MaxRSS {
+				shell.usage.MaxRSS = rss
+			}
+			continue
+		}
+		if rc >= 0 {
+			// the command has finished, read the output of the times builtin until the probe marker
+			if line == probeMarker {
+				shell.errorOutput = shell.collectErrors()
+				return output, rc, nil
+			}
+			if user, system, ok := parseTimes(line); ok {
+				shell.usage.User, shell.usage.System = user-userBefore, system-systemBefore
+			}
+			continue
+		}
 		if beginRx.MatchString(line) {
 			beginFound = true
 			continue
 		}
 		if beginFound == false {
+			if user, system, ok := parseTimes(line); ok {
+				// the second line of the output of times contains the times of the children
+				userBefore, systemBefore = user, system
+			}
 			continue
 		}
+		if match := strictRx.FindStringSubmatch(line); shell.strict && len(match) > 1 {
+			// the shell exits after the marker, the error output is complete when its error stream is closed
+			shell.tripped = true
+			shell.usage.Wall = time.Since(start)
+			shell.errorOutput = shell.collectErrors()
+			value, err := strconv.Atoi(match[1])
+			if err != nil {
+				return output, -1, fmt.Errorf("the command failed in strict mode (set -euo pipefail), the shell exited")
+			}
+			return output, value, fmt.Errorf("the command failed in strict mode (set -euo pipefail) with exit code %d, "+
+				"the shell exited", value)
+		}
 		match := endRx.FindStringSubmatch(line)
 		if len(match) > 1 {
 			value, err := strconv.Atoi(match[1])
 			if err != nil {
 				return nil, -1, fmt.Errorf("unable to read exit code for shell command: %v", err)
 			}
+			shell.usage.Wall = time.Since(start)
+			if !probe {
+				shell.errorOutput = shell.collectErrors()
+				return output, value, nil
+			}
 			rc = value
-			break
+			continue
+		}
+		if !answered {
+			shell.respond(line)
 		}
+		answered = false
 		output = append(output, line)
 	}
-	return output, rc, nil
+}
+
+// Export sets environment variables in the running shell
+func (shell *Shell) Export(variables map[string]string) error {
+	var names []string
+	for name := range variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var statements []string
+	for _, name := range names {
+		statements = append(statements, shell.dialect.export(name, variables[name]))
+	}
+	output, rc, err := shell.ExecuteCommand(strings.Join(statements, shell.dialect.separator()))
+	if err != nil {
+		return fmt.Errorf("unable to export environment variables: %v", err)
+	}
+	if rc != 0 {
+		return fmt.Errorf("unable to export environment variables: %s", strings.Join(output, " "))
+	}
+	return nil
+}
+
+// WorkingDirectory returns the current working directory of the shell
+func (shell *Shell) WorkingDirectory() (string, error) {
+	output, rc, err := shell.ExecuteCommand(shell.dialect.pwd())
+	if err != nil || rc != 0 || len(output) != 1 {
+		return "", fmt.Errorf("unable to determine the working directory of the shell (%v)", err)
+	}
+	return output[0], nil
+}
+
+// ChangeDirectory changes the working directory of the shell
+func (shell *Shell) ChangeDirectory(directory string) error {
+	output, rc, err := shell.ExecuteCommand(shell.dialect.cd(directory))
+	if err != nil {
+		return fmt.Errorf("unable to change the working directory to %s: %v", directory, err)
+	}
+	if rc != 0 {
+		return fmt.Errorf("unable to change the working directory to %s: %s", directory, strings.Join(output, " "))
+	}
+	return nil
+}
+
+// Quote returns value in single quotes, so that a POSIX shell does not interpret it
+func Quote(value string) string {
+	return "'" + strings.Replace(value, "'", `'\''`, -1) + "'"
+}
+
+// Usage returns the resources used by the last command. CPU time and memory are only measured if
+// Options.ProbeResources is set.
+func (shell *Shell) Usage() Usage {
+	return shell.usage
 }
 
 // Exit tells a running shell to exit and waits for it
 func (shell *Shell) Exit() error {
-	io.WriteString(shell.stdin, "exit\n")
-	return shell.cmd.Wait()
+	shell.write("exit\n")
+	// the shell may be replaced by a fresh one after it exited, see Tripped
+	lines := shell.lines
+	go func() {
+		for range lines {
+			// discard remaining output, so that the reader can finish
+		}
+	}()
+	err := shell.cmd.Wait()
+	shell.options.Transcript.Record(TranscriptNote, fmt.Sprintf("shell exited (%v)", shell.cmd.ProcessState))
+	if shell.Interrupted() || shell.background {
+		// commands that ignored the interrupt, and background processes, must not outlive shelldoc
+		shell.options.backend().Kill(shell.cmd)
+	}
+	return err
+}
+
+// Interrupt sends an interrupt to the shell and the processes started by it, like pressing Ctrl+C in a terminal. It
+// can be called while a command is executed. When the shell exits, processes that are still running are killed.
+func (shell *Shell) Interrupt() {
+	atomic.StoreInt32(&shell.interrupted, 1)
+	shell.options.Transcript.Record(TranscriptNote, "interrupted")
+	interruptProcessGroup(shell.cmd)
+}
+
+// Interrupted returns true if the shell has been interrupted
+func (shell *Shell) Interrupted() bool {
+	return atomic.LoadInt32(&shell.interrupted) != 0
+}
+
+// markerTag is contained in all markers of the command protocol
+const markerTag = "SHELLDOC_MARKER"
+
+// Screen returns the visible lines of the terminal the shell runs in, for example after a full-screen tool exited.
+// ok is false if the backend does not provide a terminal, see Capturer.
+func (shell *Shell) Screen() (lines []string, ok bool, err error) {
+	capturer, ok := shell.options.backend().(Capturer)
+	if !ok {
+		return nil, false, nil
+	}
+	captured, err := capturer.Capture(shell.cmd)
+	for _, line := range captured {
+		// the markers that delimit the output of the commands are printed to the terminal as well
+		if !strings.Contains(line, markerTag) {
+			lines = append(lines, line)
+		}
+	}
+	return lines, true, err
+}
+
+// Kill immediately kills the shell and all processes started by it. It can be called while a command is executed.
+func (shell *Shell) Kill() {
+	atomic.StoreInt32(&shell.interrupted, 1)
+	shell.options.backend().Kill(shell.cmd)
 }
@@ -5,9 +5,16 @@ package shell
 // SPDX-License-Identifier: Apache-2.0
 
 import (
+	"bytes"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -18,6 +25,21 @@ func TestMain(m *testing.M) {
 	shellpath, _ = DetectShell("")
 	os.Exit(m.Run())
 }
+func TestDetectShellCandidates(t *testing.T) {
+	shellvar := os.Getenv("SHELL")
+	defer os.Setenv("SHELL", shellvar)
+	os.Setenv("SHELL", "/nonexistent/bin/bash")
+	sh, err := exec.LookPath("sh")
+	require.NoError(t, err, "sh should be installed")
+	path, err := DetectShellWithCandidates("", []string{"/nonexistent/bin/zsh", "sh"})
+	require.NoError(t, err, "The first usable candidate is selected")
+	require.Equal(t, sh, path, "Candidates are looked up in $PATH")
+	_, err = DetectShellWithCandidates("", []string{"/nonexistent/bin/zsh"})
+	require.Error(t, err, "Without usable candidates, no shell is found")
+	_, err = DetectShellWithCandidates("/nonexistent/bin/zsh", []string{"sh"})
+	require.Error(t, err, "A selected shell that is not usable is an error")
+}
+
 func TestShellLifeCycle(t *testing.T) {
 	// The most basic test, start a shell and exit it again
 	shell, err := StartShell(shellpath)
@@ -71,3 +93,343 @@ func TestCaptureOutput(t *testing.T) {
 		require.Equal(t, output[1], world, "actually, two")
 	}
 }
+
+func TestCaptureErrorOutput(t *testing.T) {
+	shell, err := StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	output, rc, err := shell.ExecuteCommand("echo out; echo err1 >&2; echo err2 >&2")
+	require.NoError(t, err)
+	require.Equal(t, 0, rc)
+	require.Equal(t, []string{"out"}, output, "The error output is not mixed into the output")
+	require.Equal(t, []string{"err1", "err2"}, shell.ErrorOutput(), "The error output is captured separately")
+	_, _, err = shell.ExecuteCommand("echo quiet")
+	require.NoError(t, err)
+	require.Empty(t, shell.ErrorOutput(), "The error output belongs to one command")
+}
+
+func TestStrict(t *testing.T) {
+	// Does strict mode stop the shell at the first failing command, and only when it is enabled?
+	shell, err := StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	require.NoError(t, shell.SetStrict(true), "POSIX shells support strict mode")
+	output, rc, err := shell.ExecuteCommand("echo one | cat; export STRICT=yes")
+	require.NoError(t, err, "Commands that succeed do not trip strict mode")
+	require.Equal(t, 0, rc)
+	require.Equal(t, []string{"one"}, output)
+	require.NoError(t, shell.SetStrict(false))
+	_, rc, err = shell.ExecuteCommand("false | cat; echo $UNDEFINED_IN_STRICT_MODE")
+	require.NoError(t, err, "Without strict mode, failing pipelines and unset variables are ignored")
+	require.Equal(t, 0, rc)
+	output, _, err = shell.ExecuteCommand("echo $STRICT")
+	require.NoError(t, err)
+	require.Equal(t, []string{"yes"}, output, "Strict mode does not affect the state of the shell")
+	require.NoError(t, shell.SetStrict(true))
+	output, rc, err = shell.ExecuteCommand("false; echo not reached")
+	require.Error(t, err, "A failing command trips strict mode")
+	require.Empty(t, output, "The shell exits at the failing command")
+	require.Equal(t, 1, rc, "The exit code of the failing command is reported")
+	require.True(t, shell.Tripped())
+}
+
+func TestDeadline(t *testing.T) {
+	// Are commands that do not finish before the deadline aborted?
+	shell, err := StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	shell.SetDeadline(time.Now().Add(200 * time.Millisecond))
+	start := time.Now()
+	_, _, err = shell.ExecuteCommand("sleep 10")
+	require.Error(t, err, "The command should not finish before the deadline")
+	require.True(t, time.Since(start) < 5*time.Second, "The command should be aborted at the deadline")
+}
+
+func TestWarning(t *testing.T) {
+	// Is the warning issued once while a slow command is still running?
+	shell, err := StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	warnings := 0
+	shell.SetWarning(time.Now().Add(100*time.Millisecond), func() { warnings++ })
+	_, rc, err := shell.ExecuteCommand("sleep 0.3")
+	require.NoError(t, err, "The warning does not abort the command")
+	require.Equal(t, 0, rc, "The command finishes successfully")
+	_, _, err = shell.ExecuteCommand("sleep 0.2")
+	require.NoError(t, err, "The second command should work")
+	require.Equal(t, 1, warnings, "The warning is issued only once")
+}
+
+func TestInterrupt(t *testing.T) {
+	// Does an interrupt stop the running command, and are the remaining children killed when the shell exits?
+	shell, err := StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	output, _, err := shell.ExecuteCommand("sleep 30 >/dev/null 2>&1 & echo $!")
+	require.NoError(t, err, "Starting a background process should work")
+	require.Len(t, output, 1, "The background process reports its PID")
+	timer := time.AfterFunc(200*time.Millisecond, shell.Interrupt)
+	defer timer.Stop()
+	start := time.Now()
+	shell.ExecuteCommand("sleep 10")
+	require.True(t, time.Since(start) < 5*time.Second, "The command should be interrupted")
+	require.True(t, shell.Interrupted(), "The shell knows that it has been interrupted")
+	shell.Exit()
+	for attempt := 0; attempt < 50 && running(output[0]); attempt++ {
+		time.Sleep(20 * time.Millisecond)
+	}
+	require.False(t, running(output[0]), "The background process should have been killed")
+}
+
+// running returns true if the process with the specified PID is running (zombies are not)
+func running(pid string) bool {
+	state, err := exec.Command("ps", "-o", "stat=", "-p", pid).Output()
+	return err == nil && len(state) > 0 && state[0] != 'Z'
+}
+
+func TestTranscript(t *testing.T) {
+	// Does the transcript record the commands and the raw output?
+	var buffer bytes.Buffer
+	shell, err := StartShellWithOptions(shellpath, Options{Transcript: NewTranscript(&buffer)})
+	require.NoError(t, err, "Starting a shell should work")
+	_, _, err = shell.ExecuteCommand("echo Hello")
+	require.NoError(t, err, "The echo command is a builtin and should always work")
+	require.NoError(t, shell.Exit(), "Exiting a running shell should work")
+	transcript := buffer.String()
+	require.Contains(t, transcript, " >> echo Hello; echo ", "The command is recorded")
+	require.Contains(t, transcript, " << Hello\n", "The output is recorded")
+	require.Contains(t, transcript, " << <<<<<<<<<<SHELLDOC_MARKER 0\n", "The protocol markers are recorded")
+}
+
+func TestProbeResources(t *testing.T) {
+	// Are the CPU time and memory used by a command measured?
+	shell, err := StartShellWithOptions(shellpath, Options{ProbeResources: true})
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	output, rc, err := shell.ExecuteCommand("echo Hello && sh -c 'i=0; while [ $i -lt 30000 ]; do i=$((i+1)); done'")
+	require.NoError(t, err, "The command should work")
+	require.Equal(t, 0, rc, "The exit code of the command should be zero")
+	require.Equal(t, []string{"Hello"}, output, "The output of times is not part of the output")
+	usage := shell.Usage()
+	require.True(t, usage.Wall > 0, "The wall time is measured")
+	require.True(t, usage.User+usage.System > 0, "The CPU time of the child process is measured")
+	output, _, err = shell.ExecuteCommand("echo World")
+	require.NoError(t, err, "The following command should work")
+	require.Equal(t, []string{"World"}, output, "The protocol is in sync")
+}
+
+func TestEnvironment(t *testing.T) {
+	// Are environment variables passed at startup and exported later available to commands?
+	shell, err := StartShellWithOptions(shellpath, Options{Environment: []string{"SHELLDOC_TEST=started"}})
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	require.NoError(t, shell.Export(map[string]string{"SHELLDOC_QUOTED": "it's \"quoted\" $HOME"}), "Exporting should work")
+	output, _, err := shell.ExecuteCommand("echo \"$SHELLDOC_TEST\" && echo \"$SHELLDOC_QUOTED\"")
+	require.NoError(t, err, "The echo command is a builtin and should always work")
+	require.Equal(t, []string{"started", "it's \"quoted\" $HOME"}, output, "The variables are available to commands")
+}
+
+func TestCheckSyntax(t *testing.T) {
+	// Does the syntax check find errors without executing the command?
+	marker := fmt.Sprintf("%s/shelldoc-syntax-%d", os.TempDir(), os.Getpid())
+	require.NoError(t, CheckSyntax(shellpath, "touch "+marker), "A valid command passes the syntax check")
+	_, err := os.Stat(marker)
+	require.True(t, os.IsNotExist(err), "The command is not executed")
+	require.Error(t, CheckSyntax(shellpath, "echo \"unterminated"), "An unterminated quote is a syntax error")
+	require.Error(t, CheckSyntax(shellpath, "if true; then echo"), "An incomplete if statement is a syntax error")
+}
+
+func TestWorkingDirectory(t *testing.T) {
+	// Can the working directory of the shell be queried and changed?
+	shell, err := StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	directory, err := shell.WorkingDirectory()
+	require.NoError(t, err, "The working directory can always be determined")
+	require.NoError(t, shell.ChangeDirectory("/"), "Changing to the root directory should work")
+	changed, err := shell.WorkingDirectory()
+	require.NoError(t, err, "The working directory can always be determined")
+	require.Equal(t, "/", changed, "The working directory was changed")
+	require.NoError(t, shell.ChangeDirectory(directory), "Changing back should work")
+	require.Error(t, shell.ChangeDirectory("/does/not/exist"), "Changing to a missing directory fails")
+}
+
+func TestCleanStartup(t *testing.T) {
+	// Does a clean startup ignore the startup file named in $BASH_ENV?
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	file, err := ioutil.TempFile("", "shelldoc-rc-")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("SHELLDOC_POLLUTED=yes\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	os.Setenv("BASH_ENV", file.Name())
+	defer os.Unsetenv("BASH_ENV")
+	for _, clean := range []bool{false, true} {
+		shell, err := StartShellWithOptions(bash, Options{CleanStartup: clean})
+		require.NoError(t, err, "Starting a shell should work")
+		output, _, err := shell.ExecuteCommand("echo \"${SHELLDOC_POLLUTED:-clean}\"")
+		shell.Exit()
+		require.NoError(t, err, "The echo command is a builtin and should always work")
+		expected := "yes"
+		if clean {
+			expected = "clean"
+		}
+		require.Equal(t, []string{expected}, output, "The startup file is only read without a clean startup")
+	}
+	require.Equal(t, []string{"--noprofile", "--norc"}, cleanArguments(`C:\Program Files\Git\bin\bash.exe`))
+	require.Equal(t, []string{"-f"}, cleanArguments("/usr/bin/zsh"))
+	require.Empty(t, cleanArguments("/bin/sh"))
+}
+
+func TestStartupBanner(t *testing.T) {
+	// Is the output of startup files discarded, even if it does not end with a newline?
+	bash, err := exec.LookPath("bash")
+	if err != nil {
+		t.Skip("bash is not available")
+	}
+	file, err := ioutil.TempFile("", "shelldoc-rc-")
+	require.NoError(t, err)
+	defer os.Remove(file.Name())
+	_, err = file.WriteString("echo 'Welcome!'\necho 'Banner' >&2\nprintf 'Last login: today'\n")
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+	shell, err := StartShellWithOptions(bash, Options{Environment: []string{"BASH_ENV=" + file.Name()}})
+	require.NoError(t, err, "Starting a shell that prints a banner should work")
+	defer shell.Exit()
+	output, rc, err := shell.ExecuteCommand("echo Hello")
+	require.NoError(t, err)
+	require.Equal(t, 0, rc)
+	require.Equal(t, []string{"Hello"}, output, "The banner is not part of the output of the first command")
+	require.Empty(t, shell.ErrorOutput(), "The banner is not part of the error output of the first command")
+}
+
+func TestExecuteInTerminal(t *testing.T) {
+	// Can commands that read from /dev/tty be executed non-interactively?
+	if _, err := exec.LookPath("setsid"); err != nil {
+		t.Skip("setsid is not available")
+	}
+	shell, err := StartShell(shellpath)
+	require.NoError(t, err, "Starting a shell should work")
+	defer shell.Exit()
+	command := "printf 'Password: ' > /dev/tty; read -r secret < /dev/tty; printf 'Again: ' > /dev/tty; " +
+		"read -r again < /dev/tty; echo \"$secret $again\""
+	output, rc, err := shell.ExecuteInTerminal(command, []string{"first", "second"})
+	if err != nil && strings.Contains(err.Error(), "unable to open a terminal") {
+		t.Skipf("pseudo terminals are not available: %v", err)
+	}
+	require.NoError(t, err)
+	require.Equal(t, 0, rc)
+	require.Equal(t, []string{"first second"}, output, "The prompts are answered with the input lines in order")
+	shell.SetResponders([]Responder{{Pattern: regexp.MustCompile("Again"), Reply: "second"}})
+	output, rc, err = shell.ExecuteInTerminal(command, []string{"first"})
+	require.NoError(t, err)
+	require.Equal(t, 0, rc)
+	require.Equal(t, []string{"first second"}, output, "Prompts are answered by the input, then by the responders")
+	_, rc, err = shell.ExecuteInTerminal("exit 3", nil)
+	require.NoError(t, err)
+	require.Equal(t, 3, rc, "The exit code of the command is reported")
+}
+
+func TestDockerBackend(t *testing.T) {
+	docker := Docker{Image: "debian:12"}
+	cmd, err := docker.Command("/bin/sh", []string{"-l"}, Options{Environment: []string{"SHELLDOC=1"}, Directory: "/tmp/fixtures"})
+	require.NoError(t, err)
+	name := containerName(cmd)
+	require.NotEmpty(t, name, "Containers are named, so that they can be removed")
+	require.Equal(t, []string{"docker", "run", "--rm", "--interactive", "--init", "--name", name, "--env", "SHELLDOC=1",
+		"--volume", "/tmp/fixtures:/shelldoc", "--workdir", "/shelldoc", "debian:12", "/bin/sh", "-l"}, cmd.Args)
+	_, err = Docker{}.Command("/bin/sh", nil, Options{})
+	require.Error(t, err, "An image is required")
+
+	// a fake docker command that runs the shell on the host
+	directory, err := ioutil.TempDir("", "shelldoc-docker-")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+	fake := directory + "/docker"
+	script := "#!/bin/sh\nwhile [ \"$1\" != test-image ]; do shift; done\nshift\nexec \"$@\"\n"
+	require.NoError(t, ioutil.WriteFile(fake, []byte(script), 0755))
+	sh, err := StartShellWithOptions("/bin/sh", Options{Backend: Docker{Image: "test-image", Executable: fake}})
+	require.NoError(t, err, "The shell is started by the backend")
+	defer sh.Exit()
+	output, rc, err := sh.ExecuteCommand("echo hello")
+	require.NoError(t, err)
+	require.Equal(t, 0, rc)
+	require.Equal(t, []string{"hello"}, output, "Commands are executed in the shell started by the backend")
+}
+
+func TestWrapperBackend(t *testing.T) {
+	wrapper, err := NewWrapper(`env "GREETING=hello world" ''`)
+	require.NoError(t, err)
+	require.Equal(t, []string{"env", "GREETING=hello world", ""}, wrapper.Prefix, "Quotes group words")
+	_, err = NewWrapper(`nix develop "--command`)
+	require.Error(t, err, "Unterminated quotes are an error")
+	_, err = NewWrapper("  ")
+	require.Error(t, err, "The wrapper command must not be empty")
+	wrapper, err = NewWrapper(`env "GREETING=hello world"`)
+	require.NoError(t, err)
+	sh, err := StartShellWithOptions("/bin/sh", Options{Backend: wrapper})
+	require.NoError(t, err, "The shell is started through the wrapper command")
+	defer sh.Exit()
+	output, _, err := sh.ExecuteCommand("echo $GREETING")
+	require.NoError(t, err)
+	require.Equal(t, []string{"hello world"}, output, "The shell runs in the environment of the wrapper command")
+}
+
+func TestSSHBackend(t *testing.T) {
+	cmd, err := SSH{Destination: "admin@server"}.Command("/bin/sh", nil, Options{Environment: []string{"GREETING=it's me"}})
+	require.NoError(t, err)
+	require.Equal(t, []string{"ssh", "-T", "-o", "BatchMode=yes", "admin@server", "--", `exec env 'GREETING=it'\''s me' '/bin/sh'`},
+		cmd.Args, "The remote command is quoted for the login shell")
+	_, err = SSH{Destination: "admin@server"}.Command("/bin/sh", nil, Options{Directory: "/tmp/fixtures"})
+	require.Error(t, err, "Local working directories cannot be used")
+
+	// a fake ssh command that runs the remote command on the host, like the login shell would
+	directory, err := ioutil.TempDir("", "shelldoc-ssh-")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+	fake := directory + "/ssh"
+	script := "#!/bin/sh\nwhile [ \"$1\" != -- ]; do shift; done\nshift\nexec /bin/sh -c \"$*\"\n"
+	require.NoError(t, ioutil.WriteFile(fake, []byte(script), 0755))
+	backend := SSH{Destination: "admin@server", Executable: fake}
+	sh, err := StartShellWithOptions("/bin/sh", Options{Backend: backend, Environment: []string{"GREETING=it's me"}})
+	require.NoError(t, err, "The shell is started over SSH")
+	defer sh.Exit()
+	output, rc, err := sh.ExecuteCommand("echo \"$GREETING\"; echo oops >&2; false")
+	require.NoError(t, err)
+	require.Equal(t, 1, rc, "Exit codes are reported")
+	require.Equal(t, []string{"it's me"}, output, "The environment is passed to the remote shell")
+	require.Equal(t, []string{"oops"}, sh.ErrorOutput(), "The error output is captured")
+}
+
+func TestTmuxBackend(t *testing.T) {
+	if _, err := exec.LookPath("tmux"); err != nil {
+		t.Skip("tmux is not installed")
+	}
+	directory, err := ioutil.TempDir("", "shelldoc-tmux-test-")
+	require.NoError(t, err)
+	defer os.RemoveAll(directory)
+	sh, err := StartShellWithOptions("/bin/sh", Options{Backend: Tmux{Width: 100, Height: 30}, Directory: directory,
+		Environment: []string{"GREETING=hello"}})
+	require.NoError(t, err, "The shell is started in a tmux session")
+	defer sh.Exit()
+	output, rc, err := sh.ExecuteCommand("test -t 1 && echo terminal; stty size </dev/tty; pwd; echo $GREETING")
+	require.NoError(t, err)
+	require.Equal(t, 0, rc)
+	resolved, err := filepath.EvalSymlinks(directory)
+	require.NoError(t, err)
+	require.Equal(t, []string{"terminal", "30 100", resolved, "hello"}, output,
+		"The output goes to a terminal of the specified size")
+	_, rc, err = sh.ExecuteCommand("echo oops >&2; false")
+	require.NoError(t, err)
+	require.Equal(t, 1, rc)
+	require.Equal(t, []string{"oops"}, sh.ErrorOutput(), "The error output is forwarded separately")
+	_, _, err = sh.ExecuteCommand("clear; printf 'top line\\n'")
+	require.NoError(t, err)
+	screen, ok, err := sh.Screen()
+	require.NoError(t, err)
+	require.True(t, ok, "The screen of the terminal can be captured")
+	require.Equal(t, "top line", screen[0])
+}
@@ -0,0 +1,112 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bufio"
+	"io"
+	"io/ioutil"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// TranscriptError marks text received from the shell on its error output
+	TranscriptError = "<!"
+	// errorMarker ends the error output of a command
+	errorMarker = "!!!!!!!!!!SHELLDOC_MARKER"
+	// errorTimeout limits the time to wait for the error marker, in case the shell does not print it
+	errorTimeout = 5 * time.Second
+)
+
+// errorLines collects the error output of the shell. The stdout and stderr streams are independent, so the error
+// output of a command is complete when the error marker that is printed after it has been received.
+type errorLines struct {
+	mutex  sync.Mutex
+	lines  []string
+	closed bool
+	// changed receives a value when lines are added or the stream is closed
+	changed chan struct{}
+}
+
+func newErrorLines() *errorLines {
+	return &errorLines{changed: make(chan struct{}, 1)}
+}
+
+// readErrors reads the error output of the shell line by line until the stream is closed
+func readErrors(reader io.Reader, errors *errorLines, transcript *Transcript) {
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		transcript.Record(TranscriptError, line)
+		errors.mutex.Lock()
+		errors.lines = append(errors.lines, line)
+		errors.mutex.Unlock()
+		errors.notify()
+	}
+	// keep reading if a line was too long, so that the shell does not block
+	io.Copy(ioutil.Discard, reader)
+	errors.mutex.Lock()
+	errors.closed = true
+	errors.mutex.Unlock()
+	errors.notify()
+}
+
+// notify signals a change without blocking
+func (errors *errorLines) notify() {
+	select {
+	case errors.changed <- struct{}{}:
+	default:
+	}
+}
+
+// take returns the lines before the error marker and removes them, including the marker. ok is false if the marker
+// has not been received yet.
+func (errors *errorLines) take() (lines []string, ok bool, closed bool) {
+	errors.mutex.Lock()
+	defer errors.mutex.Unlock()
+	for index, line := range errors.lines {
+		if line == errorMarker {
+			lines = append([]string(nil), errors.lines[:index]...)
+			errors.lines = errors.lines[index+1:]
+			return lines, true, errors.closed
+		}
+	}
+	if errors.closed {
+		// the marker will not arrive anymore
+		lines, errors.lines = errors.lines, nil
+	}
+	return lines, false, errors.closed
+}
+
+// collectErrors prints the error marker to the error output of the shell and returns the error output received
+// before it
+func (shell *Shell) collectErrors() []string {
+	if shell.errors == nil {
+		return nil
+	}
+	shell.write(shell.dialect.errorEcho(errorMarker))
+	timeout := time.NewTimer(errorTimeout)
+	defer timeout.Stop()
+	for {
+		lines, ok, closed := shell.errors.take()
+		if ok || closed {
+			return lines
+		}
+		select {
+		case <-shell.errors.changed:
+		case <-timeout.C:
+			shell.options.Transcript.Record(TranscriptNote, "the error output marker was not received")
+			return nil
+		}
+	}
+}
+
+// ErrorOutput returns the lines the last command wrote to its error output (stderr).
+func (shell *Shell) ErrorOutput() []string {
+	return shell.errorOutput
+}
@@ -0,0 +1,32 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// CheckSyntax parses the command with the no-exec mode (-n) of a POSIX shell, without executing it. It returns an
+// error describing the problem if the command is not valid.
+func CheckSyntax(shell string, command string) error {
+	if !dialectFor(shell).posix() {
+		return fmt.Errorf("syntax checks are only supported for POSIX shells, not %s", shell)
+	}
+	cmd := exec.Command(shell, "-n")
+	cmd.Stdin = strings.NewReader(command + "\n")
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	err := cmd.Run()
+	if _, ok := err.(*exec.ExitError); ok {
+		return fmt.Errorf("syntax error: %s", strings.TrimSpace(output.String()))
+	} else if err != nil {
+		return fmt.Errorf("unable to check syntax using %s: %v", shell, err)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// TranscriptTerminal marks text received from the controlling terminal of a command, see ExecuteInTerminal
+const TranscriptTerminal = "<#"
+
+// terminalQuiet is the time the command has to stop writing to the terminal before its output is considered a prompt
+const terminalQuiet = 50 * time.Millisecond
+
+// terminal is a pseudo terminal that becomes the controlling terminal of a command
+type terminal struct {
+	master *os.File
+	slave  *os.File
+	// path is the name of the slave device, like /dev/pts/3
+	path string
+}
+
+// close closes both sides of the terminal
+func (tty *terminal) close() {
+	tty.slave.Close()
+	tty.master.Close()
+}
+
+// ExecuteInTerminal runs a command like ExecuteCommand, with a pseudo terminal as its controlling terminal, for
+// commands that read from /dev/tty instead of their standard input, like password prompts. Prompts the command writes
+// to the terminal are answered with the input lines in order, and with the responders after that, see
+// SetResponders. The command is executed in its own session, so it cannot change the working directory or the
+// variables of the shell. This requires a POSIX shell on the local host and the setsid command.
+func (shell *Shell) ExecuteInTerminal(command string, input []string) ([]string, int, error) {
+	if !shell.dialect.posix() {
+		return nil, -1, fmt.Errorf("commands can only read from the terminal in POSIX shells")
+	}
+	if _, local := shell.options.backend().(Host); !local {
+		return nil, -1, fmt.Errorf("commands can only read from the terminal if the shell runs on the local host")
+	}
+	setsid, err := exec.LookPath("setsid")
+	if err != nil {
+		return nil, -1, fmt.Errorf("the setsid command is needed to provide a terminal: %v", err)
+	}
+	tty, err := openTerminal()
+	if err != nil {
+		return nil, -1, fmt.Errorf("unable to open a terminal: %v", err)
+	}
+	finished := make(chan struct{})
+	go func() {
+		shell.answerTerminal(tty, input)
+		close(finished)
+	}()
+	// the session leader acquires the terminal as its controlling terminal when it opens it
+	wrapped := fmt.Sprintf("%s -w /bin/sh -c 'exec 3<>\"$1\" && eval \"$2\"' shelldoc %s %s", Quote(setsid),
+		Quote(tty.path), Quote(strings.TrimSpace(command)))
+	output, rc, err := shell.ExecuteCommand(wrapped)
+	tty.close()
+	<-finished
+	return output, rc, err
+}
+
+// answerTerminal records the output of the command on the terminal, and answers its prompts until the terminal is
+// closed
+func (shell *Shell) answerTerminal(tty *terminal, input []string) {
+	chunks := make(chan string)
+	go func() {
+		buffer := make([]byte, 4096)
+		for {
+			count, err := tty.master.Read(buffer)
+			if count > 0 {
+				chunks <- string(buffer[:count])
+			}
+			if err != nil {
+				close(chunks)
+				return
+			}
+		}
+	}()
+	ticker := time.NewTicker(terminalQuiet)
+	defer ticker.Stop()
+	pending := ""
+	received := time.Now()
+	answered := false
+	for {
+		select {
+		case chunk, ok := <-chunks:
+			if !ok {
+				if len(pending) > 0 {
+					shell.options.Transcript.Record(TranscriptTerminal, pending)
+				}
+				return
+			}
+			pending += chunk
+			if newline := strings.LastIndexByte(pending, '\n'); newline >= 0 {
+				for _, line := range strings.Split(pending[:newline], "\n") {
+					shell.options.Transcript.Record(TranscriptTerminal, strings.TrimSuffix(line, "\r"))
+				}
+				pending = pending[newline+1:]
+			}
+			received, answered = time.Now(), false
+		case <-ticker.C:
+			if answered || len(strings.TrimSpace(pending)) == 0 || time.Since(received) < terminalQuiet {
+				continue
+			}
+			answered = true
+			var reply string
+			if len(input) > 0 {
+				reply, input = input[0], input[1:]
+			} else if reply, answered = shell.reply(pending); !answered {
+				continue
+			}
+			shell.options.Transcript.Record(TranscriptNote, fmt.Sprintf("answering terminal prompt %q", pending))
+			tty.master.WriteString(reply + "\n")
+		}
+	}
+}
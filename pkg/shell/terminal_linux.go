@@ -0,0 +1,47 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// openTerminal opens a new pseudo terminal. The master is non-blocking, so that closing it stops a pending read.
+func openTerminal() (*terminal, error) {
+	fd, err := syscall.Open("/dev/ptmx", syscall.O_RDWR|syscall.O_NOCTTY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+	if err != nil {
+		return nil, err
+	}
+	var unlock int32
+	if err := ioctl(fd, syscall.TIOCSPTLCK, uintptr(unsafe.Pointer(&unlock))); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("unable to unlock the pseudo terminal: %v", err)
+	}
+	var number uint32
+	if err := ioctl(fd, syscall.TIOCGPTN, uintptr(unsafe.Pointer(&number))); err != nil {
+		syscall.Close(fd)
+		return nil, fmt.Errorf("unable to determine the pseudo terminal: %v", err)
+	}
+	master := os.NewFile(uintptr(fd), "/dev/ptmx")
+	path := fmt.Sprintf("/dev/pts/%d", number)
+	// the slave is kept open until the command has finished, so that reading the master does not fail before the
+	// command opens the terminal
+	slave, err := os.OpenFile(path, os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		master.Close()
+		return nil, err
+	}
+	return &terminal{master: master, slave: slave, path: path}, nil
+}
+
+func ioctl(fd int, request, argument uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), request, argument); errno != 0 {
+		return errno
+	}
+	return nil
+}
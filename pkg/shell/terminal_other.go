@@ -0,0 +1,15 @@
+//go:build !linux
+// +build !linux
+
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import "fmt"
+
+// openTerminal is not available on this platform
+func openTerminal() (*terminal, error) {
+	return nil, fmt.Errorf("pseudo terminals are not supported on this platform")
+}
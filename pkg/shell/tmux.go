@@ -0,0 +1,128 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	// DefaultTerminalWidth is the number of columns of the terminal if Tmux.Width is not set
+	DefaultTerminalWidth = 80
+	// DefaultTerminalHeight is the number of lines of the terminal if Tmux.Height is not set
+	DefaultTerminalHeight = 24
+	// tmuxSession is the name of the session in the private tmux server of a shell
+	tmuxSession = "shelldoc"
+)
+
+// tmuxScript starts the shell in a session of a private tmux server. The output of the shell goes to the terminal of
+// the session and is forwarded using pipe-pane, so that commands see a real terminal of a known size. The commands
+// are read from a named pipe instead of being typed into the terminal, so they are not echoed, and the error output
+// is forwarded separately. The arguments are the tmux executable, the directory for the named pipes (which is also
+// the name of the server socket), the size of the terminal, the working directory, the shell and its arguments. The
+// script ends when the terminal is closed after the shell exited.
+const tmuxScript = `tmux=$1 fifos=$2 width=$3 height=$4 directory=$5
+shift 5
+socket=$(basename "$fifos")
+trap '"$tmux" -L "$socket" kill-server 2>/dev/null; rm -rf "$fifos"' EXIT
+mkfifo "$fifos/in" "$fifos/out" "$fifos/err" || exit 1
+[ -n "$directory" ] || directory=$PWD
+"$tmux" -L "$socket" -f /dev/null new-session -d -s ` + tmuxSession + ` -x "$width" -y "$height" -c "$directory" \
+	/bin/sh -c 'stty -echo; exec "$@" <"$0/in" 2>"$0/err"' "$fifos" "$@" || exit 1
+"$tmux" -L "$socket" pipe-pane -t ` + tmuxSession + ` "cat > '$fifos/out'" || exit 1
+cat "$fifos/err" >&2 &
+# background commands read /dev/null instead of the standard input
+exec 3<&0
+cat <&3 > "$fifos/in" &
+cat "$fifos/out"
+`
+
+// Tmux runs the shell on the local host in a tmux session, which gives the commands a real terminal of a fixed size,
+// for example to test full-screen tools and editors. The final screen can be captured, see Shell.Screen. The standard
+// input and error output of the commands are not connected to the terminal. Every shell uses its own tmux server,
+// which is stopped when the shell exits.
+type Tmux struct {
+	// Width and Height are the size of the terminal, DefaultTerminalWidth and DefaultTerminalHeight if zero
+	Width  int
+	Height int
+	// Executable is the tmux command, "tmux" if empty
+	Executable string
+}
+
+// Command implements Backend
+func (tmux Tmux) Command(shell string, arguments []string, options Options) (*exec.Cmd, error) {
+	if len(options.User) > 0 {
+		return nil, fmt.Errorf("the shell cannot be run as user %s in tmux", options.User)
+	}
+	width, height := tmux.Width, tmux.Height
+	if width <= 0 {
+		width = DefaultTerminalWidth
+	}
+	if height <= 0 {
+		height = DefaultTerminalHeight
+	}
+	// the socket name is derived from the directory, keep it short since the length of socket paths is limited
+	fifos, err := ioutil.TempDir("", "shelldoc-tmux-")
+	if err != nil {
+		return nil, fmt.Errorf("unable to create the directory for the terminal: %v", err)
+	}
+	script := append([]string{"-c", tmuxScript, "shelldoc-tmux", tmux.executable(), fifos, strconv.Itoa(width),
+		strconv.Itoa(height), options.Directory, shell}, arguments...)
+	// the working directory is passed to the session, the script itself may run anywhere
+	options.Directory = ""
+	cmd, err := Host{}.Command("/bin/sh", script, options)
+	if err != nil {
+		os.RemoveAll(fifos)
+		return nil, err
+	}
+	return cmd, nil
+}
+
+// Kill implements Backend. The tmux server runs independently of the process that started it, so it is stopped
+// as well.
+func (tmux Tmux) Kill(cmd *exec.Cmd) {
+	killProcessGroup(cmd)
+	if fifos := tmuxDirectory(cmd); len(fifos) > 0 {
+		exec.Command(tmux.executable(), "-L", filepath.Base(fifos), "kill-server").Run()
+		os.RemoveAll(fifos)
+	}
+}
+
+// Capture implements Capturer, it returns the visible lines of the terminal without trailing empty lines
+func (tmux Tmux) Capture(cmd *exec.Cmd) ([]string, error) {
+	fifos := tmuxDirectory(cmd)
+	if len(fifos) == 0 {
+		return nil, fmt.Errorf("the shell does not run in tmux")
+	}
+	output, err := exec.Command(tmux.executable(), "-L", filepath.Base(fifos), "capture-pane", "-p", "-t",
+		tmuxSession).Output()
+	if err != nil {
+		return nil, fmt.Errorf("unable to capture the terminal: %v", err)
+	}
+	return strings.Split(strings.TrimRight(string(output), "\n"), "\n"), nil
+}
+
+func (tmux Tmux) executable() string {
+	if len(tmux.Executable) == 0 {
+		return "tmux"
+	}
+	return tmux.Executable
+}
+
+// tmuxDirectory returns the directory of the named pipes of the shell started with cmd
+func tmuxDirectory(cmd *exec.Cmd) string {
+	for index, arg := range cmd.Args {
+		if arg == "shelldoc-tmux" && index+2 < len(cmd.Args) {
+			return cmd.Args[index+2]
+		}
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"time"
+)
+
+const (
+	// TranscriptInput marks text sent to the shell
+	TranscriptInput = ">>"
+	// TranscriptOutput marks text received from the shell
+	TranscriptOutput = "<<"
+	// TranscriptNote marks comments about the session
+	TranscriptNote = "--"
+)
+
+// Transcript records the raw session with the shell, including the protocol markers, with timestamps.
+// It may be shared by multiple shells.
+type Transcript struct {
+	mutex  sync.Mutex
+	writer io.Writer
+}
+
+// NewTranscript creates a transcript that writes to writer.
+func NewTranscript(writer io.Writer) *Transcript {
+	return &Transcript{writer: writer}
+}
+
+// Record writes a line of the session to the transcript. Nothing happens if the transcript is nil.
+func (transcript *Transcript) Record(direction string, text string) {
+	if transcript == nil {
+		return
+	}
+	transcript.mutex.Lock()
+	defer transcript.mutex.Unlock()
+	fmt.Fprintf(transcript.writer, "%s %s %s\n", time.Now().Format("2006-01-02T15:04:05.000Z07:00"), direction, text)
+}
@@ -0,0 +1,49 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+// Usage describes the resources used by a command.
+type Usage struct {
+	// Wall is the elapsed real time
+	Wall time.Duration
+	// User and System are the CPU times used by the processes started by the command
+	User   time.Duration
+	System time.Duration
+	// MaxRSS is the largest resident set size of a process started by the command in kilobytes, sampled
+	// while the command runs (0 if unknown)
+	MaxRSS int64
+}
+
+func (usage Usage) String() string {
+	result := fmt.Sprintf("wall %v, user %v, system %v", usage.Wall, usage.User, usage.System)
+	if usage.MaxRSS > 0 {
+		result += fmt.Sprintf(", max RSS %d kB", usage.MaxRSS)
+	}
+	return result
+}
+
+// the output of the times builtin: user and system time, for example "0m0.004s 0m0.010s"
+var timesRx = regexp.MustCompile(`^(\d+)m([\d.]+)s\s+(\d+)m([\d.]+)s$`)
+
+// parseTimes parses a line of output of the times builtin
+func parseTimes(line string) (time.Duration, time.Duration, bool) {
+	match := timesRx.FindStringSubmatch(line)
+	if match == nil {
+		return 0, 0, false
+	}
+	duration := func(minutes, seconds string) time.Duration {
+		m, _ := strconv.Atoi(minutes)
+		s, _ := strconv.ParseFloat(seconds, 64)
+		return time.Duration(m)*time.Minute + time.Duration(s*float64(time.Second))
+	}
+	return duration(match[1], match[2]), duration(match[3], match[4]), true
+}
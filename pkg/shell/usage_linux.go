@@ -0,0 +1,46 @@
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// maxRSS returns the largest resident set size in kilobytes of the processes in the process group of the shell,
+// excluding the shell itself
+func maxRSS(shellpid int) int64 {
+	statFiles, err := filepath.Glob("/proc/[0-9]*/stat")
+	if err != nil {
+		return 0
+	}
+	pageSize := int64(os.Getpagesize())
+	var result int64
+	for _, statFile := range statFiles {
+		data, err := ioutil.ReadFile(statFile)
+		if err != nil {
+			continue // the process has exited in the meantime
+		}
+		// the command name in parentheses may contain spaces, the other fields follow after it
+		text := string(data)
+		fields := strings.Fields(text[strings.LastIndex(text, ")")+1:])
+		if len(fields) < 22 {
+			continue
+		}
+		pid, _ := strconv.Atoi(filepath.Base(filepath.Dir(statFile)))
+		pgid, _ := strconv.Atoi(fields[2])
+		if pgid != shellpid || pid == shellpid {
+			continue
+		}
+		pages, _ := strconv.ParseInt(fields[21], 10, 64)
+		if rss := pages * pageSize / 1024; rss > result {
+			result = rss
+		}
+	}
+	return result
+}
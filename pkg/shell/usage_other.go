@@ -0,0 +1,13 @@
+//go:build !linux
+// +build !linux
+
+package shell
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+// maxRSS is not available on this platform
+func maxRSS(shellpid int) int64 {
+	return 0
+}
@@ -0,0 +1,51 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import "strings"
+
+// anchorMatch compares an output line to an expected line with anchors, see AnchorsOption. A leading ^ anchors the
+// expected text at the start of the output line, a trailing $ at its end. Without anchors, the expected text may
+// occur anywhere in the output line. A backslash makes a leading ^ or trailing $ literal, like \^ or \$.
+func anchorMatch(expected string, output string) bool {
+	start, end := false, false
+	switch {
+	case strings.HasPrefix(expected, `\^`):
+		expected = expected[1:]
+	case strings.HasPrefix(expected, "^"):
+		start = true
+		expected = expected[1:]
+	}
+	switch {
+	case strings.HasSuffix(expected, `\$`):
+		expected = expected[:len(expected)-2] + "$"
+	case strings.HasSuffix(expected, "$"):
+		end = true
+		expected = expected[:len(expected)-1]
+	}
+	switch {
+	case start && end:
+		return output == expected
+	case start:
+		return strings.HasPrefix(output, expected)
+	case end:
+		return strings.HasSuffix(output, expected)
+	default:
+		return strings.Contains(output, expected)
+	}
+}
+
+// anchorMatches compares the output lines to the expected lines with anchors, see AnchorsOption
+func anchorMatches(expected []string, output []string) bool {
+	if len(expected) != len(output) {
+		return false
+	}
+	for index, line := range expected {
+		if !anchorMatch(line, output[index]) {
+			return false
+		}
+	}
+	return true
+}
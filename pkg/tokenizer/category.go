@@ -0,0 +1,65 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+)
+
+// Category classifies why an interaction failed or could not be executed, so that reports and gating logic can
+// treat different kinds of problems differently
+type Category int
+
+const (
+	// CategoryNone indicates that the interaction did not fail
+	CategoryNone Category = iota
+	// CategoryOutputMismatch indicates that the output of the command did not match the expected response
+	CategoryOutputMismatch
+	// CategoryWrongExitCode indicates that the command exited with an unexpected exit code
+	CategoryWrongExitCode
+	// CategoryTimeout indicates that the command did not finish before the deadline
+	CategoryTimeout
+	// CategoryShellError indicates that the command could not be executed, for example because the shell exited
+	CategoryShellError
+	// CategoryPolicyViolation indicates that the command was not executed because it violates the command policy
+	CategoryPolicyViolation
+	// CategoryAssertion indicates that a check other than the output of the command failed, like the contents of a
+	// verified file or the readiness of a code block
+	CategoryAssertion
+)
+
+// errDeadline is returned by the shell for commands that time out, the shell parameter of Execute hides the package
+var errDeadline = shell.ErrDeadline
+
+// categoryNames are the names of the categories used in reports and on the command line
+var categoryNames = []string{"", "output-mismatch", "wrong-exit-code", "timeout", "shell-error", "policy-violation",
+	"assertion"}
+
+// String returns the name of the category, or an empty string for CategoryNone
+func (category Category) String() string {
+	if category < 0 || int(category) >= len(categoryNames) {
+		return fmt.Sprintf("category-%d", int(category))
+	}
+	return categoryNames[category]
+}
+
+// CategoryNames returns the names of all categories except CategoryNone
+func CategoryNames() []string {
+	return append([]string(nil), categoryNames[1:]...)
+}
+
+// ParseCategory returns the category with the specified name
+func ParseCategory(name string) (Category, error) {
+	for index, categoryName := range categoryNames {
+		if index > 0 && categoryName == strings.TrimSpace(name) {
+			return Category(index), nil
+		}
+	}
+	return CategoryNone, fmt.Errorf("unknown failure category \"%s\", expected one of %s", name,
+		strings.Join(CategoryNames(), ", "))
+}
@@ -0,0 +1,57 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"regexp"
+	"strings"
+)
+
+// commandRx matches the lines that start a new command
+var commandRx = regexp.MustCompile(cmdEx)
+
+// Continued returns true if the line ends with a backslash that continues the command on the next line. An escaped
+// backslash (\\) does not.
+func Continued(line string) bool {
+	count := len(line) - len(strings.TrimRight(line, `\`))
+	return count%2 == 1
+}
+
+// ContinuationLine returns true if the line continues a command that ends with a backslash. Continuation lines are
+// indented further than the command, whose indentation is specified. Lines that start with a trigger character are
+// always new commands, and other lines are the expected response of the command, so that documents where a command
+// ends with a backslash keep their meaning.
+func ContinuationLine(line, indentation string) bool {
+	return len(leadingSpace(line)) > len(indentation) && !commandRx.MatchString(strings.TrimSpace(line))
+}
+
+// leadingSpace returns the white space at the beginning of the line
+func leadingSpace(line string) string {
+	return line[:len(line)-len(strings.TrimLeft(line, " \t"))]
+}
+
+// continues returns true if the command of the interaction is continued on the next line of the code block
+func (interaction *Interaction) continues() bool {
+	return len(interaction.Response) == 0 && len(interaction.ErrorResponse) == 0 && Continued(interaction.Cmd)
+}
+
+// continueCommand adds a continuation line to the command of the interaction. The command keeps the backslash and the
+// line break, so that it is executed as written.
+func (interaction *Interaction) continueCommand(line string) {
+	interaction.Cmd += "\n" + line
+}
+
+// commandLines returns the first source lines of the commands in cmd, without their continuation lines
+func commandLines(cmd string) []string {
+	var commands []string
+	continuation := false
+	for _, line := range strings.Split(cmd, "\n") {
+		if !continuation {
+			commands = append(commands, line)
+		}
+		continuation = Continued(line)
+	}
+	return commands
+}
@@ -0,0 +1,96 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"bytes"
+	"strings"
+)
+
+// Default attributes are inherited by all code blocks of a file, unless a code block specifies the attribute itself.
+// They are specified in the front matter of the file (shelldoc: {shelldoctimeout=30s shelldocglob}), or with a
+// defaults directive (<!-- shelldoc: defaults shelldoctimeout=30s -->) that applies to the following code blocks.
+const (
+	// DirectiveDefaults sets default attributes for the following code blocks
+	DirectiveDefaults = "defaults"
+	// FrontMatterKey is the key in the front matter of a file that contains the default attributes
+	FrontMatterKey = "shelldoc"
+	// AliasPrefix marks default attributes that rename the language of code blocks, for example
+	// shelldocalias-console=shell treats console code blocks as shell code blocks
+	AliasPrefix = "shelldocalias-"
+)
+
+// setDefaults adds the attributes in spec, written like the attributes in an info string, to the default attributes
+// of the following code blocks
+func (visitor *Visitor) setDefaults(spec string) {
+	spec = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(unquote(strings.TrimSpace(spec))), "{"), "}")
+	_, attributes, _ := parseCodeBlockInfoString("{" + spec + "}")
+	// new maps, since the defaults are shared with the code blocks found so far
+	defaults := make(map[string]string)
+	for key, value := range visitor.defaults {
+		defaults[key] = value
+	}
+	aliases := make(map[string]string)
+	for key, value := range visitor.aliases {
+		aliases[key] = value
+	}
+	for key, value := range attributes {
+		if language := strings.TrimPrefix(key, AliasPrefix); len(language) > 0 && language != key {
+			aliases[language] = value
+		} else {
+			defaults[key] = value
+		}
+	}
+	visitor.defaults, visitor.aliases = defaults, aliases
+}
+
+// inherit returns the attributes of a code block, with the default attributes added. The attributes of the code
+// block take precedence.
+func (visitor *Visitor) inherit(attributes map[string]string) map[string]string {
+	if len(visitor.defaults) == 0 {
+		return attributes
+	}
+	merged := make(map[string]string)
+	for key, value := range visitor.defaults {
+		merged[key] = value
+	}
+	for key, value := range attributes {
+		merged[key] = value
+	}
+	return merged
+}
+
+// alias returns the language code blocks of the specified language are treated as, see AliasPrefix
+func (visitor *Visitor) alias(language string) string {
+	if alias, ok := visitor.aliases[language]; ok {
+		return alias
+	}
+	return language
+}
+
+// splitFrontMatter returns data with the YAML front matter at its beginning replaced by empty lines, so that the
+// line numbers do not change, and the value of the FrontMatterKey in it. Front matter starts with a line "---" and
+// ends with a line "---" or "...". Only single-line values are supported.
+func splitFrontMatter(data []byte) ([]byte, string) {
+	lines := bytes.Split(data, []byte("\n"))
+	if len(lines) == 0 || string(bytes.TrimRight(lines[0], " \t")) != "---" {
+		return data, ""
+	}
+	for end := 1; end < len(lines); end++ {
+		closer := string(bytes.TrimRight(lines[end], " \t"))
+		if closer != "---" && closer != "..." {
+			continue
+		}
+		spec := ""
+		for _, line := range lines[1:end] {
+			if text := string(line); strings.HasPrefix(text, FrontMatterKey+":") {
+				spec = strings.TrimSpace(strings.TrimPrefix(text, FrontMatterKey+":"))
+			}
+		}
+		stripped := append(bytes.Repeat([]byte("\n"), end+1), bytes.Join(lines[end+1:], []byte("\n"))...)
+		return stripped, spec
+	}
+	return data, ""
+}
@@ -0,0 +1,124 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"log"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Directives are embedded in HTML comments like <!-- shelldoc: skip-next -->, which Markdown renderers do not
+// display, unlike the attributes in the info strings of fenced code blocks on some sites.
+const (
+	// DirectiveUse replays a named code block, like use NAME, see DefineOption
+	DirectiveUse = "use"
+	// DirectiveSkipNext skips the commands of the next code block, with an optional reason, see SkipOption
+	DirectiveSkipNext = "skip-next"
+	// DirectiveTimeout limits the time each command of the next code block may take, like timeout=30s, see
+	// TimeoutOption
+	DirectiveTimeout = "timeout"
+	// DirectiveEnv sets environment variables for all following commands in the file, like env FOO=bar
+	DirectiveEnv = "env"
+)
+
+// SkipInvalidDirective means that an HTML comment contains a shelldoc directive that is unknown or malformed
+const SkipInvalidDirective = "invalid-directive"
+
+// directiveEx matches a shelldoc directive in an HTML comment
+const directiveEx = `<!--\s*shelldoc:\s*(.*?)\s*-->`
+
+var directiveRx = regexp.MustCompile(directiveEx)
+
+// directiveNameEx splits a directive into its name and its argument, which is separated by white space or =
+const directiveNameEx = `^([a-z-]+)(?:\s*=\s*|\s+|$)(.*)$`
+
+var directiveNameRx = regexp.MustCompile(directiveNameEx)
+
+// handleDirectives processes the shelldoc directives in the HTML comments of an HTML node in order
+func (visitor *Visitor) handleDirectives(html string) {
+	for _, match := range directiveRx.FindAllStringSubmatch(html, -1) {
+		if err := visitor.handleDirective(match[1]); err != nil {
+			log.Printf("%v, ignored\n", err)
+			visitor.Skipped = append(visitor.Skipped, SkippedBlock{Reason: SkipInvalidDirective, Content: []string{match[1]},
+				text: match[1]})
+		}
+	}
+}
+
+// handleDirective processes one directive, the text of the HTML comment after "shelldoc:"
+func (visitor *Visitor) handleDirective(directive string) error {
+	match := directiveNameRx.FindStringSubmatch(directive)
+	if match == nil {
+		return fmt.Errorf("invalid shelldoc directive \"%s\"", directive)
+	}
+	name, argument := match[1], strings.TrimSpace(match[2])
+	switch name {
+	case DirectiveUse:
+		if len(argument) == 0 {
+			return fmt.Errorf("the %s directive needs the name of a code block", DirectiveUse)
+		}
+		handleUseDirective(visitor, argument)
+	case DirectiveSkipNext:
+		visitor.setNext(SkipOption, unquote(argument))
+	case DirectiveTimeout:
+		if timeout, err := time.ParseDuration(argument); err != nil || timeout <= 0 {
+			return fmt.Errorf("the %s directive needs a duration like 30s, got \"%s\"", DirectiveTimeout, argument)
+		}
+		visitor.setNext(TimeoutOption, argument)
+	case DirectiveEnv:
+		variables, err := parseAssignments(argument, "the "+DirectiveEnv+" directive")
+		if err != nil {
+			return err
+		}
+		if len(variables) == 0 {
+			return fmt.Errorf("the %s directive needs NAME=VALUE assignments", DirectiveEnv)
+		}
+		// a new map, since the variables are shared with the interactions found so far
+		env := make(map[string]string)
+		for key, value := range visitor.env {
+			env[key] = value
+		}
+		for key, value := range variables {
+			env[key] = value
+		}
+		visitor.env = env
+	case DirectiveDefaults:
+		if len(argument) == 0 {
+			return fmt.Errorf("the %s directive needs attributes", DirectiveDefaults)
+		}
+		visitor.setDefaults(argument)
+	default:
+		return fmt.Errorf("unknown shelldoc directive \"%s\"", directive)
+	}
+	return nil
+}
+
+// setNext sets an attribute for the next code block
+func (visitor *Visitor) setNext(key, value string) {
+	if visitor.next == nil {
+		visitor.next = make(map[string]string)
+	}
+	visitor.next[key] = value
+}
+
+// takeNext returns the attributes of a code block, with the ones set by directives before it added. Attributes
+// specified in the code block take precedence.
+func (visitor *Visitor) takeNext(attributes map[string]string) map[string]string {
+	if len(visitor.next) == 0 {
+		return attributes
+	}
+	merged := make(map[string]string)
+	for key, value := range visitor.next {
+		merged[key] = value
+	}
+	for key, value := range attributes {
+		merged[key] = value
+	}
+	visitor.next = nil
+	return merged
+}
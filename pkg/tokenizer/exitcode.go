@@ -0,0 +1,81 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	// ExitCodeAny accepts any exit code
+	ExitCodeAny = "any"
+	// ExitCodeNonZero accepts any exit code except zero
+	ExitCodeNonZero = "nonzero"
+)
+
+// ValidateDefaultExitCode checks that policy is a valid default exit code policy (any, 0 or nonzero)
+func ValidateDefaultExitCode(policy string) error {
+	switch policy {
+	case "", "0", ExitCodeAny, ExitCodeNonZero:
+		return nil
+	default:
+		return fmt.Errorf("the default exit code needs to be one of any, 0 or nonzero, got \"%s\"", policy)
+	}
+}
+
+// ValidateExitCodes checks that spec is a valid expected exit code, see matchExitCode
+func ValidateExitCodes(spec string) error {
+	for _, item := range strings.Split(spec, ",") {
+		if _, _, err := exitCodeRange(strings.TrimSpace(item)); err != nil {
+			return fmt.Errorf("argument to %s needs to be an exit code, a range like 1-125, %s or %s, separated by "+
+				"commas, got \"%s\"", ExitCodeOption, ExitCodeAny, ExitCodeNonZero, spec)
+		}
+	}
+	return nil
+}
+
+// exitCodeRange returns the lowest and highest exit code accepted by an item of an expected exit code. ExitCodeNonZero
+// is not a range, it is handled by matchExitCode.
+func exitCodeRange(item string) (low, high int, err error) {
+	switch item {
+	case ExitCodeAny, ExitCodeNonZero:
+		return math.MinInt32, math.MaxInt32, nil
+	}
+	if value, err := strconv.Atoi(item); err == nil {
+		return value, value, nil
+	}
+	bounds := strings.SplitN(item, "-", 2)
+	if len(bounds) == 2 {
+		low, err = strconv.Atoi(strings.TrimSpace(bounds[0]))
+		if err == nil {
+			high, err = strconv.Atoi(strings.TrimSpace(bounds[1]))
+		}
+		if err == nil && low <= high {
+			return low, high, nil
+		}
+	}
+	return 0, 0, fmt.Errorf("invalid exit code \"%s\"", item)
+}
+
+// matchExitCode returns true if the exit code rc satisfies the expectation spec. spec is a comma separated list of
+// integers, ranges like 1-125, ExitCodeAny and ExitCodeNonZero.
+func matchExitCode(spec string, rc int) bool {
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == ExitCodeNonZero {
+			if rc != 0 {
+				return true
+			}
+			continue
+		}
+		if low, high, err := exitCodeRange(item); err == nil && low <= rc && rc <= high {
+			return true
+		}
+	}
+	return false
+}
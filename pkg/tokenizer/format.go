@@ -0,0 +1,59 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"mime"
+	"path"
+	"strings"
+	"sync"
+)
+
+// Parser finds the interactions in the content of an input file and adds them to the visitor, like Tokenize does
+// for Markdown. Parsers for other formats can convert the content to Markdown and call Tokenize, or append
+// interactions with the Line of their commands to visitor.Interactions.
+type Parser func(data []byte, visitor *Visitor) error
+
+// formats contains the registered parsers by lower case extension and MIME type
+var formats = struct {
+	sync.Mutex
+	parsers map[string]Parser
+}{parsers: make(map[string]Parser)}
+
+func init() {
+	RegisterFormat(Tokenize, ".md", ".markdown", ".mdown", ".mkd", "text/markdown", "text/x-markdown")
+}
+
+// RegisterFormat registers the parser for input files with the specified extensions (like ".adoc") or MIME types
+// (like "text/asciidoc"). Keys are not case sensitive, registering a key again replaces its parser. Applications
+// that embed shelldoc use it to support additional documentation formats.
+func RegisterFormat(parser Parser, keys ...string) {
+	formats.Lock()
+	defer formats.Unlock()
+	for _, key := range keys {
+		formats.parsers[strings.ToLower(key)] = parser
+	}
+}
+
+// ParserFor returns the parser for the input file with the specified name or URL. It is selected by the extension
+// of the name, or by the MIME type registered for the extension in the system. Markdown is the default.
+func ParserFor(name string) Parser {
+	if index := strings.IndexAny(name, "?#"); index >= 0 && strings.Contains(name, "://") {
+		name = name[:index] // the query of a URL is not part of the extension
+	}
+	extension := strings.ToLower(path.Ext(strings.Replace(name, "\\", "/", -1)))
+	formats.Lock()
+	defer formats.Unlock()
+	if parser, ok := formats.parsers[extension]; ok && len(extension) > 0 {
+		return parser
+	}
+	if mimeType := mime.TypeByExtension(extension); len(mimeType) > 0 {
+		mimeType = strings.ToLower(strings.TrimSpace(strings.SplitN(mimeType, ";", 2)[0]))
+		if parser, ok := formats.parsers[mimeType]; ok {
+			return parser
+		}
+	}
+	return Tokenize
+}
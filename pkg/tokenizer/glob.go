@@ -0,0 +1,52 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"regexp"
+	"strings"
+)
+
+// globPattern converts a line of the expected response with wildcards into a regular expression that matches the
+// complete line: * matches any text, ? matches a single character, and a backslash makes the following character
+// literal, like \*
+func globPattern(line string) *regexp.Regexp {
+	var pattern strings.Builder
+	pattern.WriteString("^")
+	escaped := false
+	for _, char := range line {
+		switch {
+		case escaped:
+			pattern.WriteString(regexp.QuoteMeta(string(char)))
+			escaped = false
+		case char == '\\':
+			escaped = true
+		case char == '*':
+			pattern.WriteString(".*")
+		case char == '?':
+			pattern.WriteString(".")
+		default:
+			pattern.WriteString(regexp.QuoteMeta(string(char)))
+		}
+	}
+	if escaped {
+		pattern.WriteString(regexp.QuoteMeta(`\`))
+	}
+	pattern.WriteString("$")
+	return regexp.MustCompile(pattern.String())
+}
+
+// globMatches compares the output lines to the expected lines with wildcards, see GlobOption
+func globMatches(expected []string, output []string) bool {
+	if len(expected) != len(output) {
+		return false
+	}
+	for index, line := range expected {
+		if !globPattern(line).MatchString(output[index]) {
+			return false
+		}
+	}
+	return true
+}
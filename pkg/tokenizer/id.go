@@ -0,0 +1,25 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"path/filepath"
+)
+
+// AssignIDs assigns stable identifiers to the interactions found in the file at path.
+// The identifier is a hash of the path, the section heading, the command and the number of
+// identical commands before it in the same section. It does not change when line numbers shift.
+func AssignIDs(path string, interactions []*Interaction) {
+	path = filepath.ToSlash(filepath.Clean(path))
+	occurrences := make(map[string]int)
+	for _, interaction := range interactions {
+		key := fmt.Sprintf("%s\x00%s\x00%s", path, interaction.Heading, interaction.Cmd)
+		hash := sha256.Sum256([]byte(fmt.Sprintf("%s\x00%d", key, occurrences[key])))
+		occurrences[key]++
+		interaction.ID = fmt.Sprintf("%x", hash[:6])
+	}
+}
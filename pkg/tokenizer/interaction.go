@@ -7,8 +7,8 @@ package tokenizer
 import (
 	"fmt"
 	"reflect"
-	"strconv"
 	"strings"
+	"time"
 
 	"github.com/mirkoboehm/shelldoc/pkg/shell"
 )
@@ -26,34 +26,245 @@ const (
 	ResultRegexMatch
 	// ResultMismatch indicates that the output from the command did not match expectations in any way
 	ResultMismatch
+	// ResultSkipped indicates that the interaction was not executed on purpose, the Comment contains the reason
+	ResultSkipped
+	// ResultDenied indicates that the command was not executed because it violates the command policy
+	ResultDenied
+	// ResultStarted indicates that the command was started in the background, see BackgroundOption
+	ResultStarted
 )
 
+const (
+	// ExitCodeOption specifies the expected exit code of the commands in a code block, like 2, a range like 1-125,
+	// any or nonzero, or a comma separated list of them
+	ExitCodeOption = "shelldocexitcode"
+	// ExitCodeWhatever specifies that the exit code of the commands in a code block does not matter
+	ExitCodeWhatever = "shelldocwhatever"
+	// DirectoryOption specifies that the commands in a code block intentionally change the working directory. With a
+	// path (shelldocdir=path), the commands are executed in that directory.
+	DirectoryOption = "shelldocdir"
+	// VarsOption declares variables that are substituted for {{NAME}} placeholders in the commands and responses
+	VarsOption = "shelldocvars"
+	// DefineOption names a code block, so that it can be replayed later with a <!-- shelldoc: use NAME --> directive
+	DefineOption = "shelldocdefine"
+	// NeedsOption lists the named code blocks that have to be executed before a code block (comma separated)
+	NeedsOption = "shelldocneeds"
+	// NormalizeOption selects built-in normalizers for volatile values like timestamps in the output of the commands
+	// in a code block (comma separated), see Normalizers
+	NormalizeOption = "shelldocnormalize"
+	// RespondOption specifies rules that answer prompts of the commands in a code block automatically, see
+	// ParseResponders
+	RespondOption = "shelldocrespond"
+	// TransactionOption turns the commands in a code block into a single test case. All commands are executed, and
+	// their combined output is compared to the combined expected responses.
+	TransactionOption = "shelldoctransaction"
+	// CleanupOption specifies a command that is executed after the commands of a code block, even if they fail or
+	// time out, for example to remove containers or files the code block created
+	CleanupOption = "shelldoccleanup"
+	// SquashOption enables the notation "LINE (xN)" in the expected responses of a code block, which stands for N
+	// identical lines in the output
+	SquashOption = "shelldocsquash"
+	// DelayOption specifies a pause before each command of a code block, like 500ms, for example for rate-limited
+	// services
+	DelayOption = "shelldocdelay"
+	// WaitForOption specifies a readiness command that is executed repeatedly before the commands of a code block,
+	// until it succeeds, for example to wait for a service to start
+	WaitForOption = "shelldocwaitfor"
+	// WaitTimeoutOption specifies how long to wait for the readiness command to succeed, like 30s (default
+	// DefaultWaitTimeout)
+	WaitTimeoutOption = "shelldocwaittimeout"
+	// BackgroundOption starts the commands of a code block in the background under the specified name, for example
+	// a server. Background processes are terminated at the end of the file.
+	BackgroundOption = "shelldocbackground"
+	// RunningOption lists the names of background processes that have to be running before the commands of a code
+	// block are executed (comma separated)
+	RunningOption = "shelldocrunning"
+	// VerifyFileOption turns a code block into the expected contents of the specified file, which is compared to the
+	// actual file after the previous commands, for example "your config should now look like this"
+	VerifyFileOption = "shelldocverifyfile"
+	// RegexOption specifies that the expected responses of the commands in a code block are regular expressions that
+	// match complete output lines. The values of named capture groups like (?P<ID>\w+) are exported as shell
+	// variables for the following commands.
+	RegexOption = "shelldocregex"
+	// UserOption executes the commands of a code block as the specified user, for example nobody to document
+	// unprivileged behavior, or root to mark commands that need privileges. This requires shelldoc to run as root,
+	// otherwise the commands are skipped unless the user is the one running shelldoc.
+	UserOption = "shelldocuser"
+	// GlobOption enables wildcards in the expected responses of the commands in a code block: * matches any text and
+	// ? a single character, for output that contains timestamps, process IDs or hashes
+	GlobOption = "shelldocglob"
+	// SortedOption compares the output of the commands in a code block to the expected response independent of the
+	// order of the lines, for commands like ls whose order is not stable
+	SortedOption = "shelldocsorted"
+	// SkipOption marks the commands of a code block as not to be executed. They are reported as skipped tests, with
+	// the value of the attribute as the reason if it is specified (shelldocskip="reason").
+	SkipOption = "shelldocskip"
+	// TimeoutOption limits the time each command of a code block may take, like 30s. A command that takes longer is
+	// stopped and fails, and the following commands are executed in a fresh shell.
+	TimeoutOption = "shelldoctimeout"
+	// TerminalOption provides a terminal to the commands of a code block, for commands that read from /dev/tty
+	// instead of their standard input, like password prompts. The prompts are answered with the lines of the file
+	// named in the value (shelldoctty=answers.txt), and by the responders after that, see RespondOption.
+	TerminalOption = "shelldoctty"
+	// AnchorsOption compares each line of the expected responses of the commands in a code block to a part of the
+	// output line: a line starting with ^ has to match from the start of the output line, one ending with $ up to its
+	// end, and other lines may occur anywhere in the output line
+	AnchorsOption = "shelldocanchors"
+	// NoStrictOption executes the commands of a code block without strict mode, even if it is enabled for the run,
+	// for example for commands that are expected to leave a variable unset, see Interaction.Strict
+	NoStrictOption = "shelldocnostrict"
+)
+
+// DefaultWaitTimeout is the time to wait for the readiness command of a code block if WaitTimeoutOption is not set
+const DefaultWaitTimeout = 30 * time.Second
+
+// ErrorPrefix marks the lines of the expected response that are written to the error output (stderr), like
+// "! No such file or directory"
+const ErrorPrefix = "!"
+
+// PropertyPrefix marks attributes that are passed through as properties of the test cases in the results, for
+// example shelldocprop-owner=docs-team
+const PropertyPrefix = "shelldocprop-"
+
+// KnownAttributes lists the shelldoc attributes that may be specified in a fenced code block
+var KnownAttributes = []string{
+	ExitCodeOption,
+	ExitCodeWhatever,
+	DirectoryOption,
+	VarsOption,
+	DefineOption,
+	NeedsOption,
+	NormalizeOption,
+	RespondOption,
+	TransactionOption,
+	CleanupOption,
+	SquashOption,
+	DelayOption,
+	WaitForOption,
+	WaitTimeoutOption,
+	BackgroundOption,
+	RunningOption,
+	VerifyFileOption,
+	RegexOption,
+	UserOption,
+	GlobOption,
+	SortedOption,
+	SkipOption,
+	TimeoutOption,
+	TerminalOption,
+	AnchorsOption,
+	NoStrictOption,
+}
+
 // Interaction represents one interaction with the shell
 type Interaction struct {
 	// Cmd contains exactly the command the shell is supposed to execute
 	Cmd string
 	// Response contains the expected response from the shell, in plain text
 	Response []string
+	// ErrorResponse contains the expected error output (stderr) of the command, from the response lines with the
+	// ErrorPrefix. The error output is only verified if it is not empty.
+	ErrorResponse []string
 	//AlternativeRegEx string
 	// Language contains the language specified if the interaction was extracted from a fenced code block
 	Language string
 	// Attributes contains the shelldoc attributes specified in a fenced code block
 	Attributes map[string]string
+	// Meta contains other information from the info string of a fenced code block, like title or highlighted lines
+	Meta map[string]string
+	// Heading contains the text of the heading of the section the interaction is in
+	Heading string
+	// Sections contains the headings of all sections the interaction is in, outermost first
+	Sections []string
+	// Block is the number of the code block the interaction was found in, starting at 1
+	Block int
+	// Snippet is the name of the snippet if the interaction is replayed by a use directive, empty otherwise
+	Snippet string
+	// Env contains the environment variables set with env directives before the interaction, see DirectiveEnv
+	Env map[string]string
+	// ID is a stable identifier of the interaction, see AssignIDs
+	ID string
+	// Line contains the line number of the command in the input, starting at 1 (0 if unknown)
+	Line int
+	// DefaultExitCode is the expected exit code if none is specified in the attributes (an integer, any or nonzero, default 0)
+	DefaultExitCode string
+	// NormalizePaths enables comparing the output with normalized path separators and temporary directories
+	NormalizePaths bool
+	// Strict executes the command in strict mode (set -euo pipefail), unless the code block opts out with
+	// NoStrictOption or the command is expected to fail. A command that fails in strict mode makes the shell exit.
+	Strict bool
+	// Redactions are applied to the output before it is compared and stored, and to the expected response
+	Redactions []Redaction
+	// Responders answer prompts of the command automatically, after the ones specified with RespondOption
+	Responders []shell.Responder
+	// Vars are substituted for {{NAME}} placeholders in the command and the expected response when the interaction is
+	// executed, for values that are only known at run time like a free port
+	Vars map[string]string
 	// Caption contains a descriptive name for the interaction
 	Caption string
 	// Result contains a human readable description of the result after the interaction has been executed
 	ResultCode int
 	// Comment contains an explanation of the ResultCode after execution
 	Comment string
+	// Category classifies the problem if the interaction failed or could not be executed, CategoryNone otherwise
+	Category Category
+	// Captures contains the values of the named capture groups in the expected response after a regex match, see
+	// RegexOption
+	Captures map[string]string
 	// Output contains the output of the interaction after it has been executed as individual lines
 	Output []string
+	// ErrorOutput contains the error output (stderr) of the interaction after it has been executed
+	ErrorOutput []string
+	// ExitCode contains the exit code of the command after it has been executed (-1 if execution failed)
+	ExitCode int
+	// Usage contains the resources used by the command after it has been executed
+	Usage shell.Usage
+}
+
+// InSection returns true if the interaction is in the section with the specified heading, or in one of its
+// subsections
+func (interaction *Interaction) InSection(heading string) bool {
+	for _, section := range interaction.Sections {
+		if section == strings.TrimSpace(heading) {
+			return true
+		}
+	}
+	return false
 }
 
+// Properties returns the attributes with the PropertyPrefix, with the prefix removed from their names
+func (interaction *Interaction) Properties() map[string]string {
+	properties := make(map[string]string)
+	for key, value := range interaction.Attributes {
+		if name := strings.TrimPrefix(key, PropertyPrefix); len(name) > 0 && name != key {
+			properties[name] = value
+		}
+	}
+	return properties
+}
+
+// DefaultDescriptionWidth is the width of the command and the expected response in the description returned by
+// Describe, without the separator between them
+const DefaultDescriptionWidth = 65
+
 // Describe returns a human-readable description of the interaction
 func (interaction *Interaction) Describe() string {
-	const elideCmdAt = 40
-	const elideResponseAt = 25
+	return interaction.DescribeWidth(DefaultDescriptionWidth)
+}
+
+// DescribeWidth returns a human-readable description of the interaction, with the command and the expected response
+// elided to fit into width columns. A width of zero or less does not elide them.
+func (interaction *Interaction) DescribeWidth(width int) string {
+	elideCmdAt, elideResponseAt := 40, 25
+	if width > 0 {
+		elideResponseAt = width * elideResponseAt / DefaultDescriptionWidth
+		elideCmdAt = width - elideResponseAt
+	}
 	format := fmt.Sprintf("%%-%ds  ?  %%-%ds", elideCmdAt, elideResponseAt)
+	if width <= 0 {
+		// the columns are padded to the default width, but not elided
+		elideCmdAt, elideResponseAt = 0, 0
+	}
 	name := interaction.Cmd
 	if len(interaction.Caption) != 0 {
 		name = interaction.Caption
@@ -70,31 +281,17 @@ func (interaction *Interaction) Describe() string {
 func (interaction *Interaction) DescribeFull() string {
 	response := strings.Join(interaction.Response, "\n")
 	output := strings.Join(interaction.Output, "\n")
-	description := fmt.Sprintf("got: \"%s\", want: \"%s\"", response, output)
+	description := fmt.Sprintf("got: \"%s\", want: \"%s\"", output, response)
+	if len(interaction.ErrorResponse) > 0 {
+		description += fmt.Sprintf(", error output got: \"%s\", want: \"%s\"", strings.Join(interaction.ErrorOutput, "\n"),
+			strings.Join(interaction.ErrorResponse, "\n"))
+	}
 	return description
 }
 
 // Result returns a human readable description of the result of the interaction
 func (interaction *Interaction) Result() string {
-	switch interaction.ResultCode {
-	case NewInteraction:
-		return "not executed"
-	case ResultExecutionError:
-		return "ERROR (result not evaluated)"
-	case ResultMatch:
-		if len(interaction.Response) == 0 {
-			return "PASS (execution successful)"
-		}
-		return "PASS (match)"
-	case ResultRegexMatch:
-		return "PASS (regex match)"
-	case ResultMismatch:
-		return "FAIL (mismatch)"
-	case ResultError:
-		return "FAIL (execution failed)"
-	default:
-		return "YOU FOUND A BUG!!11!1!"
-	}
+	return interaction.LocalizedResult(DefaultLocale)
 }
 
 // HasFailure returns true if the interaction failed (not on execution errors)
@@ -102,6 +299,44 @@ func (interaction *Interaction) HasFailure() bool {
 	return interaction.ResultCode == ResultError || interaction.ResultCode == ResultMismatch
 }
 
+// Skip marks the interaction as skipped for the specified reason
+func (interaction *Interaction) Skip(reason string) {
+	interaction.ResultCode = ResultSkipped
+	interaction.Comment = reason
+}
+
+// DefaultSkipReason is reported for interactions that are skipped using SkipOption without a reason
+const DefaultSkipReason = "marked with " + SkipOption
+
+// SkipReason returns the reason why the interaction should not be executed, and true if it is marked with SkipOption
+func (interaction *Interaction) SkipReason() (string, bool) {
+	reason, ok := interaction.Attributes[SkipOption]
+	if !ok {
+		return "", false
+	}
+	if reason = strings.TrimSpace(reason); len(reason) == 0 {
+		reason = DefaultSkipReason
+	}
+	return reason, true
+}
+
+// IsSkipped returns true if the interaction was skipped
+func (interaction *Interaction) IsSkipped() bool {
+	return interaction.ResultCode == ResultSkipped
+}
+
+// Deny marks the interaction as not executed because it violates the command policy
+func (interaction *Interaction) Deny(reason string) {
+	interaction.ResultCode = ResultDenied
+	interaction.Category = CategoryPolicyViolation
+	interaction.Comment = reason
+}
+
+// IsDenied returns true if the interaction was denied by the command policy
+func (interaction *Interaction) IsDenied() bool {
+	return interaction.ResultCode == ResultDenied
+}
+
 // New creates an empty interaction with a Caption
 func New(caption string) *Interaction {
 	interaction := new(Interaction)
@@ -109,67 +344,239 @@ func New(caption string) *Interaction {
 	return interaction
 }
 
+// addResponse adds a line of the expected response, or of the expected error output if it has the ErrorPrefix
+func (interaction *Interaction) addResponse(line string) {
+	if line == ErrorPrefix || strings.HasPrefix(line, ErrorPrefix+" ") {
+		interaction.ErrorResponse = append(interaction.ErrorResponse, strings.TrimPrefix(line[len(ErrorPrefix):], " "))
+		return
+	}
+	interaction.Response = append(interaction.Response, line)
+}
+
 // evaluateResponse compares the output to the expected response, and respects "ellipsis" (don't care from here on forward)
 func (interaction *Interaction) evaluateResponse(response []string) bool {
-	output := response
-	expected := interaction.Response
-	for index, line := range interaction.Response {
+	return interaction.matches(interaction.Response, response)
+}
+
+// evaluateErrors compares the error output to the expected error output, if any is specified
+func (interaction *Interaction) evaluateErrors(errors []string) bool {
+	if len(interaction.ErrorResponse) == 0 {
+		return true
+	}
+	return interaction.matches(interaction.ErrorResponse, errors)
+}
+
+// matches compares output lines to the expected lines, after substituting variables and applying the normalizers
+func (interaction *Interaction) matches(lines []string, response []string) bool {
+	output := interaction.normalizeOutput(response)
+	expected := interaction.normalizeExpected(interaction.expectedLines(lines))
+	if _, ok := interaction.Attributes[SortedOption]; ok {
+		return sortedMatches(expected, output)
+	}
+	for index, line := range expected {
 		if strings.TrimSpace(line) == "..." {
-			output = response[:index]
-			expected = interaction.Response[:index]
+			if index > len(output) {
+				return false // the output is shorter than the expected response before the ellipsis
+			}
+			output = output[:index]
+			expected = expected[:index]
 			break
 		}
 	}
 	if len(output) == 0 && len(expected) == 0 {
 		return true
 	}
+	if _, ok := interaction.Attributes[GlobOption]; ok {
+		return globMatches(expected, output)
+	}
+	if _, ok := interaction.Attributes[AnchorsOption]; ok {
+		return anchorMatches(expected, output)
+	}
 	return reflect.DeepEqual(output, expected)
 }
 
+// expectedLines returns the lines of an expected response with the variables substituted, and the repeated lines
+// expanded if the SquashOption is set
+func (interaction *Interaction) expectedLines(lines []string) []string {
+	var expected []string
+	for _, line := range lines {
+		expected = append(expected, substituteVars(line, interaction.Vars))
+	}
+	if _, ok := interaction.Attributes[SquashOption]; ok {
+		expected = expandRepeats(expected)
+	}
+	return expected
+}
+
+// normalizeExpected returns the lines of an expected response with the redactions and normalizers applied, so that
+// they can be compared to the normalized output
+func (interaction *Interaction) normalizeExpected(lines []string) []string {
+	return interaction.normalizeOutput(redact(lines, interaction.Redactions))
+}
+
+// normalizeOutput returns the output lines with normalized paths if NormalizePaths is set, and the volatile values
+// selected with the NormalizeOption replaced
+func (interaction *Interaction) normalizeOutput(lines []string) []string {
+	if interaction.NormalizePaths {
+		lines = normalizePaths(lines)
+	}
+	if names, err := ParseNormalizers(interaction.Attributes[NormalizeOption]); err == nil {
+		lines = normalizeValues(lines, names)
+	}
+	return lines
+}
+
+// ExecuteInBackground starts the command of the interaction in the background, with its output written to logfile,
+// and returns its process ID. The expected response is not verified.
+func (interaction *Interaction) ExecuteInBackground(shell *shell.Shell, logfile string) (int, error) {
+	pid, err := shell.StartBackground(substituteVars(interaction.Cmd, interaction.Vars), logfile)
+	if err != nil {
+		interaction.ResultCode = ResultExecutionError
+		interaction.Category = CategoryShellError
+		interaction.ExitCode = -1
+		interaction.Comment = err.Error()
+		return 0, err
+	}
+	interaction.ResultCode = ResultStarted
+	interaction.Category = CategoryNone
+	interaction.ExitCode = 0
+	interaction.Comment = fmt.Sprintf("PID %d", pid)
+	return pid, nil
+}
+
+// Delay returns the pause before the command specified with DelayOption, or fallback if the attribute is not set
+func (interaction *Interaction) Delay(fallback time.Duration) (time.Duration, error) {
+	value, ok := interaction.Attributes[DelayOption]
+	if !ok {
+		return fallback, nil
+	}
+	delay, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || delay < 0 {
+		return 0, fmt.Errorf("argument to %s needs to be a duration like 500ms, got \"%s\"", DelayOption, value)
+	}
+	return delay, nil
+}
+
+// WaitFor returns the readiness command specified with WaitForOption with the placeholders replaced by the values of
+// Vars, or an empty string if none is specified
+func (interaction *Interaction) WaitFor() string {
+	return strings.TrimSpace(substituteVars(interaction.Attributes[WaitForOption], interaction.Vars))
+}
+
+// WaitTimeout returns how long to wait for the readiness command specified with WaitForOption
+func (interaction *Interaction) WaitTimeout() (time.Duration, error) {
+	value, ok := interaction.Attributes[WaitTimeoutOption]
+	if !ok {
+		return DefaultWaitTimeout, nil
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("argument to %s needs to be a duration like 30s, got \"%s\"", WaitTimeoutOption, value)
+	}
+	return timeout, nil
+}
+
+// Timeout returns the time the command may take, see TimeoutOption, and 0 if it is not limited
+func (interaction *Interaction) Timeout() (time.Duration, error) {
+	value, ok := interaction.Attributes[TimeoutOption]
+	if !ok {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil || timeout <= 0 {
+		return 0, fmt.Errorf("argument to %s needs to be a duration like 30s, got \"%s\"", TimeoutOption, value)
+	}
+	return timeout, nil
+}
+
 // Execute the interaction and store the result
 func (interaction *Interaction) Execute(shell *shell.Shell) error {
-	const ExitCodeOption = "shelldocexitcode"
-	const ExitCodeWhatever = "shelldocwhatever"
-	var expectedExitCode int
+	if _, ok := interaction.VerifiedFile(); ok {
+		return interaction.verifyFile(shell)
+	}
+	if err := ValidateDefaultExitCode(interaction.DefaultExitCode); err != nil {
+		return err
+	}
+	expectedExitCode := interaction.DefaultExitCode
+	if len(expectedExitCode) == 0 {
+		expectedExitCode = "0"
+	}
 	if expectedExitCodeOption, ok := interaction.Attributes[ExitCodeOption]; ok {
-		if value, err := strconv.Atoi(expectedExitCodeOption); err == nil {
-			expectedExitCode = value
-		} else {
-			return fmt.Errorf("argument to %s needs to be an integer, got \"%s\"", ExitCodeOption, expectedExitCodeOption)
+		if err := ValidateExitCodes(expectedExitCodeOption); err != nil {
+			return err
 		}
+		expectedExitCode = expectedExitCodeOption
 	}
-	expectedWhatever := false
 	if _, ok := interaction.Attributes[ExitCodeWhatever]; ok {
-		expectedWhatever = true
+		expectedExitCode = ExitCodeAny
+	}
+	if _, err := ParseNormalizers(interaction.Attributes[NormalizeOption]); err != nil {
+		return err
+	}
+	if _, err := interaction.Delay(0); err != nil {
+		return err
+	}
+	if _, err := interaction.Timeout(); err != nil {
+		return err
+	}
+	if err := interaction.ValidatePatterns(); err != nil {
+		return err
+	}
+	responders, err := ParseResponders(interaction.Attributes[RespondOption])
+	if err != nil {
+		return err
 	}
 	// execute the command in the shell
-	output, rc, err := shell.ExecuteCommand(interaction.Cmd)
+	_, noStrict := interaction.Attributes[NoStrictOption]
+	if err := shell.SetStrict(interaction.Strict && !noStrict && expectedExitCode == "0"); err != nil {
+		return err
+	}
+	defer shell.SetStrict(false)
+	shell.SetResponders(append(responders, interaction.Responders...))
+	defer shell.SetResponders(nil)
+	command := substituteVars(interaction.Cmd, interaction.Vars)
+	var output []string
+	rc := -1
+	input, terminal, err := interaction.terminalInput(shell)
+	if err == nil && terminal {
+		output, rc, err = shell.ExecuteInTerminal(command, input)
+	} else if err == nil {
+		output, rc, err = shell.ExecuteCommand(command)
+	}
+	output = redact(output, interaction.Redactions)
 	interaction.Output = output
+	interaction.ErrorOutput = redact(shell.ErrorOutput(), interaction.Redactions)
+	interaction.ExitCode = rc
+	interaction.Usage = shell.Usage()
 	// compare the results
+	interaction.Category = CategoryNone
 	if err != nil {
 		interaction.ResultCode = ResultExecutionError
+		interaction.Category = CategoryShellError
+		if err == errDeadline {
+			interaction.Category = CategoryTimeout
+		}
 		interaction.Comment = err.Error()
 		return fmt.Errorf("unable to execute command: %v", err)
-	} else if expectedWhatever == false && rc != expectedExitCode {
+	}
+	if !matchExitCode(expectedExitCode, rc) {
 		interaction.ResultCode = ResultError
-		interaction.Comment = fmt.Sprintf("command exited with non-zero exit code %d", rc)
-	} else if interaction.evaluateResponse(output) {
+		interaction.Category = CategoryWrongExitCode
+		interaction.Comment = fmt.Sprintf("command exited with exit code %d, expected %s", rc, expectedExitCode)
+	} else if interaction.evaluateResponse(output) && interaction.evaluateErrors(interaction.ErrorOutput) {
 		interaction.ResultCode = ResultMatch
 		interaction.Comment = ""
-	} else if interaction.compareRegex(output) {
+	} else if interaction.compareRegex(output) && interaction.evaluateErrors(interaction.ErrorOutput) {
 		interaction.ResultCode = ResultRegexMatch
+		interaction.Comment = ""
 	} else {
 		interaction.ResultCode = ResultMismatch
+		interaction.Category = CategoryOutputMismatch
 		interaction.Comment = ""
 	}
 	return nil
 }
 
-func (interaction *Interaction) compareRegex(output []string) bool {
-	// match, err := regexp.MatchString(interaction.AlternativeRegEx, output); err
-	return false
-}
-
 func elideString(text string, length int) string {
 	if length > 6 && len(text) > length {
 		return fmt.Sprintf("%s...", text[:length-3])
@@ -0,0 +1,153 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// DefaultLocale is the locale of the result strings in reports, and on the console unless another locale is selected
+const DefaultLocale = "en"
+
+// LocaleAuto selects the locale from the environment ($LC_ALL, $LC_MESSAGES or $LANG), see DetectLocale
+const LocaleAuto = "auto"
+
+// messages contains the result strings by locale and result ID. Results with a comment, like the reason a command
+// was skipped, use the ID with a "-comment" suffix, the comment replaces %s.
+var messages = map[string]map[string]string{
+	"en": {
+		"not-executed":              "not executed",
+		"error-not-evaluated":       "ERROR (result not evaluated)",
+		"pass-execution-successful": "PASS (execution successful)",
+		"pass-match":                "PASS (match)",
+		"pass-regex-match":          "PASS (regex match)",
+		"fail-mismatch":             "FAIL (mismatch)",
+		"fail-execution-failed":     "FAIL (execution failed)",
+		"skipped":                   "SKIPPED",
+		"skipped-comment":           "SKIPPED (%s)",
+		"denied":                    "DENIED",
+		"denied-comment":            "DENIED (%s)",
+		"started":                   "STARTED",
+		"started-comment":           "STARTED (%s)",
+	},
+	"de": {
+		"not-executed":              "nicht ausgeführt",
+		"error-not-evaluated":       "FEHLER (Ergebnis nicht ausgewertet)",
+		"pass-execution-successful": "OK (erfolgreich ausgeführt)",
+		"pass-match":                "OK (Übereinstimmung)",
+		"pass-regex-match":          "OK (Übereinstimmung mit regulärem Ausdruck)",
+		"fail-mismatch":             "FEHLGESCHLAGEN (Abweichung)",
+		"fail-execution-failed":     "FEHLGESCHLAGEN (Ausführung fehlgeschlagen)",
+		"skipped":                   "ÜBERSPRUNGEN",
+		"skipped-comment":           "ÜBERSPRUNGEN (%s)",
+		"denied":                    "ABGELEHNT",
+		"denied-comment":            "ABGELEHNT (%s)",
+		"started":                   "GESTARTET",
+		"started-comment":           "GESTARTET (%s)",
+	},
+}
+
+// Locales returns the supported locales
+func Locales() []string {
+	var locales []string
+	for locale := range messages {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// ParseLocale returns the supported locale for a locale name like de or de_DE.UTF-8. LocaleAuto detects the locale
+// from the environment, and an empty name selects the DefaultLocale.
+func ParseLocale(name string) (string, error) {
+	switch name {
+	case "":
+		return DefaultLocale, nil
+	case LocaleAuto:
+		return DetectLocale(os.Getenv), nil
+	}
+	if locale := language(name); messages[locale] != nil {
+		return locale, nil
+	}
+	return "", fmt.Errorf("unsupported locale \"%s\", expected one of %s or %s", name, strings.Join(Locales(), ", "),
+		LocaleAuto)
+}
+
+// DetectLocale returns the locale selected in the environment read using getenv, or the DefaultLocale if it is not
+// supported
+func DetectLocale(getenv func(string) string) string {
+	for _, variable := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := getenv(variable); len(value) > 0 {
+			if locale := language(value); messages[locale] != nil {
+				return locale
+			}
+			return DefaultLocale
+		}
+	}
+	return DefaultLocale
+}
+
+// language returns the language of a locale name like de_DE.UTF-8
+func language(name string) string {
+	parts := strings.FieldsFunc(name, func(char rune) bool {
+		return char == '_' || char == '-' || char == '.' || char == '@'
+	})
+	if len(parts) == 0 {
+		return ""
+	}
+	return strings.ToLower(parts[0])
+}
+
+// ResultID returns a stable identifier of the result of the interaction, like pass-match or fail-mismatch, that does
+// not depend on the locale, for scripts that parse the output
+func (interaction *Interaction) ResultID() string {
+	switch interaction.ResultCode {
+	case NewInteraction:
+		return "not-executed"
+	case ResultExecutionError:
+		return "error-not-evaluated"
+	case ResultMatch:
+		if len(interaction.Response) == 0 {
+			return "pass-execution-successful"
+		}
+		return "pass-match"
+	case ResultRegexMatch:
+		return "pass-regex-match"
+	case ResultMismatch:
+		return "fail-mismatch"
+	case ResultError:
+		return "fail-execution-failed"
+	case ResultSkipped:
+		return "skipped"
+	case ResultDenied:
+		return "denied"
+	case ResultStarted:
+		return "started"
+	default:
+		return "unknown"
+	}
+}
+
+// LocalizedResult returns a human readable description of the result of the interaction in the locale, see
+// ParseLocale. Unsupported locales use the DefaultLocale.
+func (interaction *Interaction) LocalizedResult(locale string) string {
+	catalog := messages[locale]
+	if catalog == nil {
+		catalog = messages[DefaultLocale]
+	}
+	id := interaction.ResultID()
+	if len(interaction.Comment) > 0 {
+		if format, ok := catalog[id+"-comment"]; ok {
+			return fmt.Sprintf(format, interaction.Comment)
+		}
+	}
+	if message, ok := catalog[id]; ok {
+		return message
+	}
+	return "YOU FOUND A BUG!!11!1!"
+}
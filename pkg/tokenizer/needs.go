@@ -0,0 +1,92 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"strings"
+)
+
+// codeBlock groups the interactions of one code block
+type codeBlock struct {
+	interactions []*Interaction
+	needs        []string
+}
+
+// ParseNeeds returns the names of the code blocks listed in the shelldocneeds attribute (comma separated)
+func ParseNeeds(spec string) []string {
+	var names []string
+	for _, name := range strings.Split(spec, ",") {
+		if name = strings.TrimSpace(name); len(name) > 0 {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// OrderBlocks orders the interactions so that the code blocks named in the shelldocneeds attribute of a code block
+// are executed before it. Code blocks without dependencies keep their order in the document. An error is returned
+// if a code block needs an undefined code block, or if the dependencies contain a cycle.
+func OrderBlocks(interactions []*Interaction) ([]*Interaction, error) {
+	var blocks []*codeBlock
+	named := make(map[string]*codeBlock)
+	for index, interaction := range interactions {
+		if index == 0 || interaction.Block != interactions[index-1].Block {
+			block := &codeBlock{}
+			if len(interaction.Snippet) == 0 {
+				// replayed snippets carry the attributes of their definition, but do not define a name
+				if name := interaction.Attributes[DefineOption]; len(name) > 0 && named[name] == nil {
+					named[name] = block
+				}
+			}
+			block.needs = ParseNeeds(interaction.Attributes[NeedsOption])
+			blocks = append(blocks, block)
+		}
+		last := blocks[len(blocks)-1]
+		last.interactions = append(last.interactions, interaction)
+	}
+	const (
+		visiting = 1
+		done     = 2
+	)
+	state := make(map[*codeBlock]int)
+	var ordered []*Interaction
+	var path []string
+	var visit func(block *codeBlock, name string) error
+	visit = func(block *codeBlock, name string) error {
+		switch state[block] {
+		case done:
+			return nil
+		case visiting:
+			return fmt.Errorf("dependency cycle between code blocks: %s -> %s", strings.Join(path, " -> "), name)
+		}
+		state[block] = visiting
+		path = append(path, name)
+		for _, need := range block.needs {
+			prerequisite, ok := named[need]
+			if !ok {
+				return fmt.Errorf("the code block with \"%s\" needs the undefined code block %s",
+					block.interactions[0].Cmd, need)
+			}
+			if err := visit(prerequisite, need); err != nil {
+				return err
+			}
+		}
+		path = path[:len(path)-1]
+		state[block] = done
+		ordered = append(ordered, block.interactions...)
+		return nil
+	}
+	for _, block := range blocks {
+		name := block.interactions[0].Attributes[DefineOption]
+		if len(name) == 0 {
+			name = fmt.Sprintf("\"%s\"", block.interactions[0].Cmd)
+		}
+		if err := visit(block, name); err != nil {
+			return nil, err
+		}
+	}
+	return ordered, nil
+}
@@ -0,0 +1,120 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// TempDirToken replaces the platform temporary directory in normalized output
+const TempDirToken = "<TMPDIR>"
+
+// tempDirectories returns the temporary directories of the platform, with forward slashes and without trailing slash
+func tempDirectories() []string {
+	candidates := []string{"/tmp", os.TempDir(), os.Getenv("TMPDIR"), os.Getenv("TEMP"), os.Getenv("TMP")}
+	var result []string
+	seen := make(map[string]bool)
+	add := func(directory string) {
+		directory = strings.TrimRight(strings.Replace(directory, "\\", "/", -1), "/")
+		if len(directory) > 0 && !seen[directory] {
+			seen[directory] = true
+			result = append(result, directory)
+		}
+	}
+	for _, candidate := range candidates {
+		if len(candidate) == 0 {
+			continue
+		}
+		add(candidate)
+		// on macOS, $TMPDIR is a symbolic link into /private
+		if resolved, err := filepath.EvalSymlinks(candidate); err == nil {
+			add(resolved)
+		}
+	}
+	// replace longer paths first, so that /tmp does not match a part of /tmp/user
+	sort.SliceStable(result, func(i, j int) bool { return len(result[i]) > len(result[j]) })
+	return result
+}
+
+// newPathNormalizer returns a function that converts backslashes to forward slashes and replaces the temporary
+// directories of the platform with TempDirToken
+func newPathNormalizer() func(string) string {
+	var alternatives []string
+	for _, directory := range tempDirectories() {
+		alternatives = append(alternatives, regexp.QuoteMeta(directory))
+	}
+	flags := ""
+	if runtime.GOOS == "windows" {
+		flags = "(?i)" // paths are not case sensitive on Windows
+	}
+	tempDirRx := regexp.MustCompile(flags + "(" + strings.Join(alternatives, "|") + `)(/|[\s"':,;)\]]|$)`)
+	return func(line string) string {
+		line = strings.Replace(line, "\\", "/", -1)
+		return tempDirRx.ReplaceAllString(line, TempDirToken+"$2")
+	}
+}
+
+// normalizePaths returns the lines with normalized path separators and temporary directories
+func normalizePaths(lines []string) []string {
+	normalize := newPathNormalizer()
+	var result []string
+	for _, line := range lines {
+		result = append(result, normalize(line))
+	}
+	return result
+}
+
+// Normalizers lists the built-in matchers for volatile output that can be selected with NormalizeOption, in the
+// order they are applied
+var Normalizers = []string{"timestamps", "durations", "sizes"}
+
+// normalizers replace volatile values in the output and the expected response with placeholders, so that they
+// compare as equal
+var normalizers = map[string]Redaction{
+	// ISO 8601 timestamps like 2023-05-01T12:30:45.123Z or 2023-05-01 12:30
+	"timestamps": {regexp.MustCompile(`\d{4}-\d{2}-\d{2}[T ]\d{2}:\d{2}(:\d{2}(\.\d+)?)?(Z|[+-]\d{2}:?\d{2})?`), "<TIMESTAMP>"},
+	// durations like 1.23s, 150ms or 1m30s
+	"durations": {regexp.MustCompile(`\b(\d+(\.\d+)?(ns|us|µs|ms|h|m|s))+\b`), "<DURATION>"},
+	// byte sizes like 512 bytes, 1.5 MiB, 20kB or 4.0K
+	"sizes": {regexp.MustCompile(`\b\d+(\.\d+)?( ?([kKMGTPE]i?B|bytes|B)|[KMGTPE])\b`), "<SIZE>"},
+}
+
+// ParseNormalizers parses the comma separated list of normalizers specified with NormalizeOption
+func ParseNormalizers(value string) ([]string, error) {
+	selected := make(map[string]bool)
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if len(name) == 0 {
+			continue
+		}
+		if _, ok := normalizers[name]; !ok {
+			return nil, fmt.Errorf("unknown normalizer \"%s\" in %s, expected one of %s", name, NormalizeOption,
+				strings.Join(Normalizers, ", "))
+		}
+		selected[name] = true
+	}
+	var result []string
+	for _, name := range Normalizers {
+		if selected[name] {
+			result = append(result, name)
+		}
+	}
+	return result, nil
+}
+
+// normalizeValues returns the lines with the volatile values matched by the named normalizers replaced
+func normalizeValues(lines []string, names []string) []string {
+	var redactions []Redaction
+	for _, name := range names {
+		redactions = append(redactions, normalizers[name])
+	}
+	return redact(lines, redactions)
+}
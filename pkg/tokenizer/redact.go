@@ -0,0 +1,33 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"regexp"
+)
+
+// Redaction replaces volatile text like UUIDs, temporary paths or timestamps in the output of commands with a
+// placeholder, before the output is compared to the expected response and stored in the results
+type Redaction struct {
+	// Pattern matches the text to replace
+	Pattern *regexp.Regexp
+	// Replacement is the placeholder, it may refer to submatches like $1
+	Replacement string
+}
+
+// redact returns the lines with all redactions applied in order
+func redact(lines []string, redactions []Redaction) []string {
+	if len(redactions) == 0 {
+		return lines
+	}
+	var result []string
+	for _, line := range lines {
+		for _, redaction := range redactions {
+			line = redaction.Pattern.ReplaceAllString(line, redaction.Replacement)
+		}
+		result = append(result, line)
+	}
+	return result
+}
@@ -0,0 +1,113 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// RegexPrefix marks a line of the expected response as a regular expression, like "re: ^total \d+", in code blocks
+// without the RegexOption
+const RegexPrefix = "re:"
+
+// regexLine returns the regular expression of a line of the expected response with the RegexPrefix
+func regexLine(line string) (string, bool) {
+	if !strings.HasPrefix(line, RegexPrefix) {
+		return "", false
+	}
+	return strings.TrimSpace(strings.TrimPrefix(line, RegexPrefix)), true
+}
+
+// usesRegex returns true if the expected response is matched using regular expressions, because of the RegexOption
+// or because lines use the RegexPrefix
+func (interaction *Interaction) usesRegex() bool {
+	if _, ok := interaction.Attributes[RegexOption]; ok {
+		return true
+	}
+	for _, line := range interaction.Response {
+		if _, ok := regexLine(line); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// patterns compiles the lines of the expected response into regular expressions that match complete lines. With the
+// RegexOption, all lines are regular expressions, otherwise only the lines with the RegexPrefix, and the other lines
+// match literally. The literal lines are redacted and normalized like the expected responses that are compared
+// without regular expressions. ellipsis is true if the response ends with an ellipsis (...), so that the rest of the
+// output is not compared.
+func (interaction *Interaction) patterns() (patterns []*regexp.Regexp, ellipsis bool, err error) {
+	_, all := interaction.Attributes[RegexOption]
+	lines := interaction.expectedLines(interaction.Response)
+	literals := interaction.normalizeExpected(lines)
+	for index, line := range lines {
+		if strings.TrimSpace(line) == "..." {
+			return patterns, true, nil
+		}
+		if expression, ok := regexLine(line); ok {
+			// anchors are optional, the expression always has to match the complete line
+			line = expression
+		} else if !all {
+			line = regexp.QuoteMeta(literals[index])
+		}
+		pattern, err := regexp.Compile("^(?:" + line + ")$")
+		if err != nil {
+			return nil, false, fmt.Errorf("invalid regular expression in expected response: %v", err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, false, nil
+}
+
+// compareRegex matches the output against the expected response as regular expressions, if the RegexOption is set
+// or lines use the RegexPrefix. A line of the output matches if either the line itself or the line normalized like for
+// the comparison without regular expressions matches. The values of named capture groups like (?P<ID>\w+) are stored
+// in Captures.
+func (interaction *Interaction) compareRegex(output []string) bool {
+	if !interaction.usesRegex() {
+		return false
+	}
+	patterns, ellipsis, err := interaction.patterns()
+	if err != nil {
+		return false
+	}
+	if ellipsis && len(output) > len(patterns) {
+		output = output[:len(patterns)]
+	}
+	if len(output) != len(patterns) {
+		return false
+	}
+	normalized := interaction.normalizeOutput(output)
+	captures := make(map[string]string)
+	for index, pattern := range patterns {
+		// captures contain the actual output if possible
+		match := pattern.FindStringSubmatch(output[index])
+		if match == nil {
+			match = pattern.FindStringSubmatch(normalized[index])
+		}
+		if match == nil {
+			return false
+		}
+		for group, name := range pattern.SubexpNames() {
+			if len(name) > 0 {
+				captures[name] = match[group]
+			}
+		}
+	}
+	interaction.Captures = captures
+	return true
+}
+
+// ValidatePatterns returns an error if the expected response of an interaction contains invalid regular expressions
+func (interaction *Interaction) ValidatePatterns() error {
+	if !interaction.usesRegex() {
+		return nil
+	}
+	_, _, err := interaction.patterns()
+	return err
+}
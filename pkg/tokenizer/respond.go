@@ -0,0 +1,42 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+)
+
+// ParseResponders parses the value of the shelldocrespond attribute, a white space separated list of PATTERN=REPLY
+// rules. The pattern is a regular expression, it may be quoted to contain white space or equal signs, like
+// shelldocrespond="'Are you sure\?'=y".
+func ParseResponders(spec string) ([]shell.Responder, error) {
+	var responders []shell.Responder
+	for _, rule := range splitInfoString(spec) {
+		var pattern, reply string
+		if quote := rule[0]; quote == '"' || quote == '\'' {
+			end := strings.IndexByte(rule[1:], quote) + 1
+			if end < 1 || end+1 >= len(rule) || rule[end+1] != '=' {
+				return nil, fmt.Errorf("expected PATTERN=REPLY in %s, got \"%s\"", RespondOption, rule)
+			}
+			pattern, reply = rule[1:end], rule[end+2:]
+		} else {
+			separator := strings.Index(rule, "=")
+			if separator < 1 {
+				return nil, fmt.Errorf("expected PATTERN=REPLY in %s, got \"%s\"", RespondOption, rule)
+			}
+			pattern, reply = rule[:separator], rule[separator+1:]
+		}
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid pattern in %s: %v", RespondOption, err)
+		}
+		responders = append(responders, shell.Responder{Pattern: compiled, Reply: unquote(reply)})
+	}
+	return responders, nil
+}
@@ -0,0 +1,46 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// sortedMatches compares the output lines to the expected lines independent of their order, see SortedOption. With
+// an ellipsis (...) in the expected response, the other expected lines have to occur in the output, but the output
+// may contain more lines. The lines are sorted in copies, so the failure messages show the original order.
+func sortedMatches(expected []string, output []string) bool {
+	var lines []string
+	ellipsis := false
+	for _, line := range expected {
+		if strings.TrimSpace(line) == "..." {
+			ellipsis = true
+			continue
+		}
+		lines = append(lines, line)
+	}
+	if ellipsis {
+		remaining := make(map[string]int)
+		for _, line := range output {
+			remaining[line]++
+		}
+		for _, line := range lines {
+			if remaining[line] == 0 {
+				return false
+			}
+			remaining[line]--
+		}
+		return true
+	}
+	if len(lines) != len(output) {
+		return false
+	}
+	sortedOutput := append([]string(nil), output...)
+	sort.Strings(lines)
+	sort.Strings(sortedOutput)
+	return len(lines) == 0 || reflect.DeepEqual(lines, sortedOutput)
+}
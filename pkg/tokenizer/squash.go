@@ -0,0 +1,56 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+)
+
+// repeatEx matches an expected response line that stands for several identical lines, like "tick (x3)"
+const repeatEx = `^(.*) \(x([0-9]+)\)$`
+
+var repeatRx = regexp.MustCompile(repeatEx)
+
+// expandRepeats replaces lines like "tick (x3)" with the specified number of identical lines
+func expandRepeats(lines []string) []string {
+	var result []string
+	for _, line := range lines {
+		match := repeatRx.FindStringSubmatch(line)
+		if match == nil {
+			result = append(result, line)
+			continue
+		}
+		count, err := strconv.Atoi(match[2])
+		if err != nil {
+			result = append(result, line)
+			continue
+		}
+		for index := 0; index < count; index++ {
+			result = append(result, match[1])
+		}
+	}
+	return result
+}
+
+// SquashRepeats replaces runs of identical lines with a single line like "tick (x3)", the notation that is
+// understood in expected responses of code blocks with the SquashOption
+func SquashRepeats(lines []string) []string {
+	var result []string
+	for index := 0; index < len(lines); {
+		count := 1
+		for index+count < len(lines) && lines[index+count] == lines[index] {
+			count++
+		}
+		if count > 1 {
+			result = append(result, fmt.Sprintf("%s (x%d)", lines[index], count))
+		} else {
+			result = append(result, lines[index])
+		}
+		index += count
+	}
+	return result
+}
@@ -0,0 +1,38 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+)
+
+// terminalInput returns the lines of the file named with TerminalOption, which answer the prompts the command writes
+// to its terminal, and false if the command does not need a terminal. Relative names are resolved in the working
+// directory of the shell, so that the file can be created by the previous commands.
+func (interaction *Interaction) terminalInput(sh *shell.Shell) ([]string, bool, error) {
+	name, ok := interaction.Attributes[TerminalOption]
+	if name = strings.TrimSpace(name); !ok || len(name) == 0 {
+		return nil, ok, nil
+	}
+	path := substituteVars(name, interaction.Vars)
+	if !filepath.IsAbs(path) {
+		directory, err := sh.WorkingDirectory()
+		if err != nil {
+			return nil, true, fmt.Errorf("unable to read the terminal input %s: %v", name, err)
+		}
+		path = filepath.Join(directory, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, true, fmt.Errorf("unable to read the terminal input: %v", err)
+	}
+	text := strings.TrimSuffix(strings.Replace(string(data), "\r\n", "\n", -1), "\n")
+	return strings.Split(text, "\n"), true, nil
+}
@@ -5,9 +5,12 @@ package tokenizer
 // SPDX-License-Identifier: LGPL-3.0
 
 import (
+	"bytes"
+	"fmt"
 	"log"
 	"regexp"
 	"strings"
+	"unicode"
 
 	"github.com/russross/blackfriday/v2"
 )
@@ -20,25 +23,122 @@ type Visitor struct {
 	FencedCodeBlock func(visitor *Visitor, node *blackfriday.Node) blackfriday.WalkStatus
 	// After parsing, Interactions will hold the shell interactions found in the file
 	Interactions []*Interaction
+	// heading is the text of the most recent heading
+	heading string
+	// sections contains the headings of the enclosing sections, outermost first, and levels their heading levels
+	sections []string
+	levels   []int
+	// blocks counts the code blocks encountered so far
+	blocks int
+	// snippets contains the interactions of the code blocks named with shelldocdefine
+	snippets map[string][]*Interaction
+	// inSummary is true while the text of a <summary> element is read
+	inSummary bool
+	// summary contains the text of the most recent <summary> element
+	summary strings.Builder
+	// outputDetails is true inside a <details> element with a summary like "Output"
+	outputDetails bool
+	// Skipped lists the code blocks and directives that were not turned into interactions, with their content, so
+	// that consumers like lint can inspect them as well
+	Skipped []SkippedBlock
+	// next contains the attributes set by directives for the next code block, see takeNext
+	next map[string]string
+	// env contains the environment variables set by env directives so far
+	env map[string]string
+	// defaults contains the attributes inherited by the following code blocks, aliases the language aliases, see
+	// setDefaults
+	defaults map[string]string
+	aliases  map[string]string
+}
+
+const (
+	// SkipNoCommands means that the code block contains no lines with a trigger character ($ or >)
+	SkipNoCommands = "no-commands"
+	// SkipUndefinedSnippet means that a use directive names a code block that has not been defined before
+	SkipUndefinedSnippet = "undefined-snippet"
+)
+
+// SkippedBlock describes a code block or use directive that does not result in any interactions
+type SkippedBlock struct {
+	// Block is the number of the code block, starting at 1 (0 for use directives)
+	Block int
+	// Line is the line number of the first line in the code block or of the use directive, starting at 1 (0 if unknown)
+	Line int
+	// Language is the language specified in the info string of a fenced code block
+	Language string
+	// Attributes contains the shelldoc attributes specified in the info string of a fenced code block
+	Attributes map[string]string
+	// Meta contains other information from the info string of a fenced code block, like title or highlighted lines
+	Meta map[string]string
+	// Content contains the lines of the code block, without the fences, or the text of an invalid directive (empty
+	// for use directives)
+	Content []string
+	// Reason is a machine-readable reason why the block was skipped, like SkipNoCommands
+	Reason string
+	// text is the first line of the block, used to locate it in the input
+	text string
+}
+
+// skipBlock records that the code block with the specified lines did not result in any interactions
+func (visitor *Visitor) skipBlock(skipped SkippedBlock, lines []string) {
+	skipped.Block = visitor.blocks
+	if len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1] // the literal of an indented code block ends with a newline
+	}
+	skipped.Content = append([]string(nil), lines...)
+	for _, line := range lines {
+		if trimmed := strings.TrimSpace(line); len(trimmed) > 0 {
+			skipped.text = trimmed
+			break
+		}
+	}
+	visitor.Skipped = append(visitor.Skipped, skipped)
 }
 
 const cmdEx = "^[\\$>]\\s+(.+)$"
 
+// useEx matches the directive that replays a named code block
+const useEx = `<!--\s*shelldoc:\s*use\s+(\S+)\s*-->`
+
+var useRx = regexp.MustCompile(useEx)
+
+// summaryEx matches a complete <summary> element in an HTML block
+const summaryEx = `(?is)<summary[^>]*>(.*?)</summary>`
+
+var summaryRx = regexp.MustCompile(summaryEx)
+
 // handleCodeBlock parses the interactions in a code block and adds them to the Visitor
 func handleCodeBlock(visitor *Visitor, node *blackfriday.Node) blackfriday.WalkStatus {
 	cmdRx := regexp.MustCompile(cmdEx)
 
 	lines := strings.Split(string(node.Literal), "\n")
+	if visitor.attachDetailsOutput(lines) {
+		return blackfriday.GoToNext
+	}
+	visitor.blocks++
+	specified := visitor.takeNext(nil)
+	attributes := visitor.inherit(specified)
 	var current *Interaction
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	var indentation string
+	for _, raw := range lines {
+		line := strings.TrimSpace(raw)
 		if len(line) == 0 {
 			continue
 		}
+		if current != nil && current.continues() && ContinuationLine(raw, indentation) {
+			current.continueCommand(line)
+			continue
+		}
 		match := cmdRx.FindStringSubmatch(line)
 		if len(match) > 1 {
 			// begin a new command
+			indentation = leadingSpace(raw)
 			current = new(Interaction)
+			current.Attributes = attributes
+			current.Heading = visitor.heading
+			current.Sections = visitor.sections
+			current.Block = visitor.blocks
+			current.Env = visitor.env
 			visitor.Interactions = append(visitor.Interactions, current)
 			cmd := match[1]
 			current.Cmd = cmd
@@ -47,50 +147,115 @@ func handleCodeBlock(visitor *Visitor, node *blackfriday.Node) blackfriday.WalkS
 				log.Printf("no trigger prefix ($ or >), skipping line: %s\n", line)
 				continue
 			}
-			current.Response = append(current.Response, line)
+			current.addResponse(line)
 		}
 	}
+	if current == nil {
+		// the default attributes are not reported, they apply to code blocks without commands as well
+		visitor.skipBlock(SkippedBlock{Attributes: specified, Reason: SkipNoCommands}, lines)
+	}
 	return blackfriday.GoToNext
 }
 
-// parseCodeBlockInfoString "best-faith" parses the info string and returns the language end the attributes
-// if the info string is not written to the shelldoc specifications, both results are empty
-func parseCodeBlockInfoString(infostring string) (string, map[string]string) {
-	const infoStringHeaderEx = "^([.\\S]+)\\s+(.+)$"
-	infoStringHeaderRx := regexp.MustCompile(infoStringHeaderEx)
-	const attributesContentEx = "^.*\\{(.+)\\}.*$"
-	attributesContentRx := regexp.MustCompile(attributesContentEx)
-	const elementEx = "^([A-Za-z0-9]+)=(.+)$"
+// parseCodeBlockInfoString "best-faith" parses the info string and returns the language, the shelldoc attributes
+// and the meta information used by site generators like Docusaurus (title="terminal", {1,3-4} line highlights)
+// if the info string is not written to the shelldoc specifications, the results are empty
+func parseCodeBlockInfoString(infostring string) (string, map[string]string, map[string]string) {
+	const elementEx = "^([A-Za-z0-9_-]+)=(.*)$"
 	elementRx := regexp.MustCompile(elementEx)
+	const highlightEx = "^[0-9]+(-[0-9]+)?(,[0-9]+(-[0-9]+)?)*$"
+	highlightRx := regexp.MustCompile(highlightEx)
 
 	var language string
 	attributes := make(map[string]string)
+	meta := make(map[string]string)
 
-	infostringmatch := infoStringHeaderRx.FindStringSubmatch(infostring)
-	if infostringmatch != nil {
-		language = infostringmatch[1]
-		attributesString := infostringmatch[2]
-		attributesContentMatch := attributesContentRx.FindStringSubmatch(attributesString)
-		if attributesContentMatch != nil {
-			attributesContent := attributesContentMatch[1]
-			elements := strings.Split(attributesContent, " ")
-			for _, element := range elements {
-				if len(element) == 0 || !strings.HasPrefix(element, "shelldoc") {
-					continue
-				}
-				elementmatch := elementRx.FindStringSubmatch(element)
-				key := element
-				value := ""
-				if elementmatch != nil {
-					key = elementmatch[1]
-					value = elementmatch[2]
-				}
-				attributes[key] = value
+	tokens := splitInfoString(infostring)
+	if len(tokens) > 0 && !strings.HasPrefix(tokens[0], "{") {
+		language = tokens[0]
+		tokens = tokens[1:]
+	}
+	for _, token := range tokens {
+		if !strings.HasPrefix(token, "{") {
+			// meta information outside of braces, like title="terminal" or showLineNumbers
+			if elementmatch := elementRx.FindStringSubmatch(token); elementmatch != nil {
+				meta[elementmatch[1]] = unquote(elementmatch[2])
+			} else {
+				meta[token] = ""
+			}
+			continue
+		}
+		content := strings.TrimSuffix(strings.TrimPrefix(token, "{"), "}")
+		if highlightRx.MatchString(strings.Replace(content, " ", "", -1)) {
+			meta["highlight"] = strings.Replace(content, " ", "", -1)
+			continue
+		}
+		for _, element := range splitInfoString(content) {
+			if !strings.HasPrefix(element, "shelldoc") {
+				continue // ignore the rest of the attributes, like .line-numbers
 			}
-		} // else: ignore the rest of the infostring
-	} // else: the info string is empty, treat this similar to a non-fenced code block
+			key := element
+			value := ""
+			if elementmatch := elementRx.FindStringSubmatch(element); elementmatch != nil {
+				key = elementmatch[1]
+				value = unquote(elementmatch[2])
+			}
+			attributes[key] = value
+		}
+	}
+	return language, attributes, meta
+}
 
-	return language, attributes
+// splitInfoString splits an info string at white space, but keeps quoted strings and {...} groups together
+func splitInfoString(infostring string) []string {
+	var tokens []string
+	var current strings.Builder
+	flush := func() {
+		if current.Len() > 0 {
+			tokens = append(tokens, current.String())
+			current.Reset()
+		}
+	}
+	depth := 0
+	var quote rune
+	for _, char := range infostring {
+		switch {
+		case quote != 0:
+			current.WriteRune(char)
+			if char == quote {
+				quote = 0
+			}
+		case char == '"' || char == '\'':
+			quote = char
+			current.WriteRune(char)
+		case char == '{':
+			if depth == 0 {
+				flush()
+			}
+			depth++
+			current.WriteRune(char)
+		case char == '}' && depth > 0:
+			depth--
+			current.WriteRune(char)
+			if depth == 0 {
+				flush()
+			}
+		case unicode.IsSpace(char) && depth == 0:
+			flush()
+		default:
+			current.WriteRune(char)
+		}
+	}
+	flush()
+	return tokens
+}
+
+// unquote removes matching single or double quotes around value
+func unquote(value string) string {
+	if len(value) >= 2 && (value[0] == '"' || value[0] == '\'') && value[len(value)-1] == value[0] {
+		return value[1 : len(value)-1]
+	}
+	return value
 }
 
 // handleFencedCodeBlock parses the interactions in a fenced code block and adds them to the Visitor
@@ -104,23 +269,65 @@ func handleFencedCodeBlock(visitor *Visitor, node *blackfriday.Node) blackfriday
 		log.Printf("encountered a fenced code block with no info string, ignored")
 		return blackfriday.GoToNext
 	}
+	if len(strings.TrimSpace(lines[len(lines)-1])) > 0 {
+		// inline code that spans multiple lines of a paragraph, the content of fenced code blocks ends with a newline
+		return blackfriday.GoToNext
+	}
+	if visitor.attachDetailsOutput(lines[1 : len(lines)-1]) {
+		return blackfriday.GoToNext
+	}
+	visitor.blocks++
 	infostring := lines[0]
-	language, attributes := parseCodeBlockInfoString(infostring) // on error, language and attributes remain empty
+	language, attributes, meta := parseCodeBlockInfoString(infostring) // on error, language, attributes and meta remain empty
+	language = visitor.alias(language)
+	specified := visitor.takeNext(attributes)
+	attributes = visitor.inherit(specified)
 	// closer := lines[len(lines)-1] // closer is not parsed any further
 	lines = lines[1 : len(lines)-1]
+	vars, err := ParseVars(attributes[VarsOption])
+	if err != nil {
+		log.Printf("%v\n", err)
+	}
 
+	first := len(visitor.Interactions)
+	if name, ok := attributes[VerifyFileOption]; ok {
+		// the code block contains the expected contents of a file, not commands
+		verification := newFileVerification(lines, vars)
+		verification.Cmd = "cat " + strings.TrimSpace(name)
+		verification.Language = language
+		verification.Attributes = attributes
+		verification.Meta = meta
+		verification.Heading = visitor.heading
+		verification.Sections = visitor.sections
+		verification.Block = visitor.blocks
+		verification.Env = visitor.env
+		visitor.Interactions = append(visitor.Interactions, verification)
+		return blackfriday.GoToNext
+	}
 	var current *Interaction
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+	var indentation string
+	for _, raw := range lines {
+		raw = substituteVars(raw, vars)
+		line := strings.TrimSpace(raw)
 		if len(line) == 0 {
 			continue
 		}
+		if current != nil && current.continues() && ContinuationLine(raw, indentation) {
+			current.continueCommand(line)
+			continue
+		}
 		match := cmdRx.FindStringSubmatch(line)
 		if len(match) > 1 {
 			// begin a new command
+			indentation = leadingSpace(raw)
 			current = new(Interaction)
 			current.Language = language
 			current.Attributes = attributes
+			current.Meta = meta
+			current.Heading = visitor.heading
+			current.Sections = visitor.sections
+			current.Block = visitor.blocks
+			current.Env = visitor.env
 			visitor.Interactions = append(visitor.Interactions, current)
 			cmd := match[1]
 			current.Cmd = cmd
@@ -129,12 +336,111 @@ func handleFencedCodeBlock(visitor *Visitor, node *blackfriday.Node) blackfriday
 				log.Printf("no trigger prefix ($ or >), skipping: %s\n", line)
 				continue
 			}
-			current.Response = append(current.Response, line)
+			current.addResponse(line)
+		}
+	}
+	if current == nil {
+		visitor.skipBlock(SkippedBlock{Language: language, Attributes: specified, Meta: meta, Reason: SkipNoCommands}, lines)
+	}
+	if _, ok := attributes[TransactionOption]; ok && len(visitor.Interactions)-first > 1 {
+		visitor.Interactions = append(visitor.Interactions[:first], mergeTransaction(visitor.Interactions[first:]))
+	}
+	if name := attributes[DefineOption]; len(name) > 0 {
+		if visitor.snippets == nil {
+			visitor.snippets = make(map[string][]*Interaction)
 		}
+		visitor.snippets[name] = visitor.Interactions[first:]
 	}
 	return blackfriday.GoToNext
 }
 
+// mergeTransaction combines the interactions of a code block with the TransactionOption into one. The commands are
+// executed as one multi-line command, and the expected responses are concatenated.
+func mergeTransaction(interactions []*Interaction) *Interaction {
+	merged := *interactions[0]
+	merged.Response = nil
+	merged.ErrorResponse = nil
+	var commands []string
+	for _, interaction := range interactions {
+		commands = append(commands, interaction.Cmd)
+		merged.Response = append(merged.Response, interaction.Response...)
+		merged.ErrorResponse = append(merged.ErrorResponse, interaction.ErrorResponse...)
+	}
+	merged.Cmd = strings.Join(commands, "\n")
+	merged.Caption = fmt.Sprintf("%s (+%d commands)", interactions[0].Cmd, len(interactions)-1)
+	return &merged
+}
+
+// attachDetailsOutput uses the lines of a code block inside a collapsed <details> section with a summary like
+// "Output" as the expected response of the preceding command. It returns false if the lines should be parsed as a
+// regular code block.
+func (visitor *Visitor) attachDetailsOutput(lines []string) bool {
+	if !visitor.outputDetails || len(visitor.Interactions) == 0 {
+		return false
+	}
+	last := visitor.Interactions[len(visitor.Interactions)-1]
+	if len(last.Response) > 0 {
+		return false
+	}
+	cmdRx := regexp.MustCompile(cmdEx)
+	var response []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		if cmdRx.MatchString(line) {
+			return false // the code block contains commands
+		}
+		response = append(response, line)
+	}
+	last.Response = response
+	visitor.outputDetails = false // only the first code block in the section is used
+	return true
+}
+
+// handleDetails tracks the <details> and <summary> elements that may contain the expected output of a command
+func (visitor *Visitor) handleDetails(html string) {
+	lower := strings.ToLower(html)
+	if strings.Contains(lower, "<details") {
+		visitor.outputDetails = false
+	}
+	if match := summaryRx.FindStringSubmatch(html); match != nil {
+		visitor.outputDetails = strings.Contains(strings.ToLower(match[1]), "output")
+	} else if strings.Contains(lower, "<summary") {
+		visitor.inSummary = true
+		visitor.summary.Reset()
+	} else if strings.Contains(lower, "</summary") && visitor.inSummary {
+		visitor.inSummary = false
+		visitor.outputDetails = strings.Contains(strings.ToLower(visitor.summary.String()), "output")
+	}
+	if strings.Contains(lower, "</details") {
+		visitor.outputDetails = false
+	}
+}
+
+// handleUseDirective replays the interactions of the named code block referenced by a use directive
+func handleUseDirective(visitor *Visitor, name string) {
+	snippet, ok := visitor.snippets[name]
+	if !ok {
+		log.Printf("use of undefined snippet %s, ignored\n", name)
+		visitor.Skipped = append(visitor.Skipped, SkippedBlock{Reason: SkipUndefinedSnippet, text: "use " + name})
+		return
+	}
+	visitor.blocks++
+	for _, original := range snippet {
+		replayed := *original
+		replayed.Response = append([]string(nil), original.Response...)
+		replayed.ErrorResponse = append([]string(nil), original.ErrorResponse...)
+		replayed.Heading = visitor.heading
+		replayed.Sections = visitor.sections
+		replayed.Block = visitor.blocks
+		replayed.Snippet = name
+		replayed.Env = visitor.env
+		visitor.Interactions = append(visitor.Interactions, &replayed)
+	}
+}
+
 // NewInteractionVisitor creates a visitor configured with the default ineraction parser
 func NewInteractionVisitor() *Visitor {
 	visitor := new(Visitor)
@@ -147,6 +453,17 @@ func NewInteractionVisitor() *Visitor {
 // It checks for code blocks and calls the respective handlers.
 func (visitor *Visitor) visit(node *blackfriday.Node, entering bool) blackfriday.WalkStatus {
 	// log.Printf("%v: %s", node.Type, node.Literal)
+	if node.Type == blackfriday.Heading && entering == true {
+		visitor.heading = headingText(node)
+		visitor.enterSection(node.HeadingData.Level, visitor.heading)
+	}
+	if node.Type == blackfriday.Text && entering == true && visitor.inSummary {
+		visitor.summary.Write(node.Literal)
+	}
+	if (node.Type == blackfriday.HTMLBlock || node.Type == blackfriday.HTMLSpan) && entering == true {
+		visitor.handleDetails(string(node.Literal))
+		visitor.handleDirectives(string(node.Literal))
+	}
 	if node.Type == blackfriday.CodeBlock && entering == true {
 		return visitor.CodeBlock(visitor, node)
 	} else if node.Type == blackfriday.Code && entering == true {
@@ -155,10 +472,118 @@ func (visitor *Visitor) visit(node *blackfriday.Node, entering bool) blackfriday
 	return blackfriday.GoToNext
 }
 
+// enterSection starts a section with a heading of the specified level, which ends all sections at the same or a
+// lower level (with a higher level number)
+func (visitor *Visitor) enterSection(level int, heading string) {
+	count := 0
+	for count < len(visitor.levels) && visitor.levels[count] < level {
+		count++
+	}
+	// a new slice, since the sections are shared with the interactions found so far
+	visitor.sections = append(append([]string(nil), visitor.sections[:count]...), heading)
+	visitor.levels = append(visitor.levels[:count], level)
+}
+
+// headingText returns the plain text of a heading node
+func headingText(node *blackfriday.Node) string {
+	var text strings.Builder
+	node.Walk(func(child *blackfriday.Node, entering bool) blackfriday.WalkStatus {
+		if entering && (child.Type == blackfriday.Text || child.Type == blackfriday.Code) {
+			text.Write(child.Literal)
+		}
+		return blackfriday.GoToNext
+	})
+	return strings.TrimSpace(text.String())
+}
+
 // Tokenize parses the data and calls the event handlers on visitor
 func Tokenize(data []byte, visitor *Visitor) error {
+	// files written on Windows use CRLF line endings
+	data = bytes.Replace(data, []byte("\r\n"), []byte("\n"), -1)
+	data, defaults := splitFrontMatter(data)
+	if len(defaults) > 0 {
+		visitor.setDefaults(defaults)
+	}
+	first := len(visitor.Interactions)
 	md := blackfriday.New()
 	om := md.Parse(data)
 	om.Walk(visitor.visit)
+	locateInteractions(data, visitor.Interactions[first:])
+	locateSkippedBlocks(data, visitor.Skipped)
 	return nil
 }
+
+// locateSkippedBlocks finds the source lines of the skipped code blocks and use directives by searching for their
+// first lines in order
+func locateSkippedBlocks(data []byte, skipped []SkippedBlock) {
+	lines := strings.Split(string(data), "\n")
+	cursor := 0
+	for index := range skipped {
+		if skipped[index].Line > 0 || len(skipped[index].text) == 0 {
+			continue
+		}
+		for position := cursor; position < len(lines); position++ {
+			line := lines[position]
+			if match := useRx.FindStringSubmatch(line); match != nil {
+				line = "use " + match[1]
+			} else if match := directiveRx.FindStringSubmatch(line); match != nil {
+				line = match[1]
+			}
+			if strings.TrimSpace(line) == skipped[index].text {
+				skipped[index].Line = position + 1
+				cursor = position + 1
+				break
+			}
+		}
+	}
+}
+
+// locateInteractions finds the source lines of the commands of the interactions
+// The parser does not record positions, so the commands are searched for in the input in order.
+func locateInteractions(data []byte, interactions []*Interaction) {
+	lines := strings.Split(string(data), "\n")
+	cursor := 0
+	for position, interaction := range interactions {
+		if len(interaction.Snippet) > 0 {
+			// replayed interactions are located at their use directive
+			if position > 0 && interactions[position-1].Block == interaction.Block {
+				interaction.Line = interactions[position-1].Line
+				continue
+			}
+			for index := cursor; index < len(lines); index++ {
+				if match := useRx.FindStringSubmatch(lines[index]); match != nil && match[1] == interaction.Snippet {
+					interaction.Line = index + 1
+					cursor = index + 1
+					break
+				}
+			}
+			continue
+		}
+		if _, ok := interaction.VerifiedFile(); ok {
+			// file verifications are located at the opening fence of their code block
+			for index := cursor; index < len(lines); index++ {
+				line := strings.TrimSpace(lines[index])
+				if (strings.HasPrefix(line, "```") || strings.HasPrefix(line, "~~~")) && strings.Contains(line, VerifyFileOption) {
+					interaction.Line = index + 1
+					cursor = index + 1
+					break
+				}
+			}
+			continue
+		}
+		// the command may contain the values of block variables, compare it to the substituted source line
+		// merged transactions contain several commands, the interaction is located at the first one
+		vars, _ := ParseVars(interaction.Attributes[VarsOption])
+		commands := commandLines(interaction.Cmd)
+		for index := cursor; index < len(lines) && len(commands) > 0; index++ {
+			line := strings.TrimSpace(substituteVars(lines[index], vars))
+			if len(line) > 0 && (line[0] == '$' || line[0] == '>') && strings.TrimSpace(line[1:]) == commands[0] {
+				if interaction.Line == 0 {
+					interaction.Line = index + 1
+				}
+				cursor = index + 1
+				commands = commands[1:]
+			}
+		}
+	}
+}
@@ -5,7 +5,10 @@ package tokenizer
 // SPDX-License-Identifier: Apache-2.0
 
 import (
+	"fmt"
 	"io/ioutil"
+	"os"
+	"regexp"
 	"testing"
 
 	blackfriday "github.com/russross/blackfriday/v2"
@@ -22,7 +25,7 @@ func codeBlockHandler(visitor *Visitor, node *blackfriday.Node) blackfriday.Walk
 func TestEchoTrue(t *testing.T) {
 	data, err := ioutil.ReadFile("samples/echotrue.md")
 	require.NoError(t, err, "Unable to read sample data file")
-	visitor := Visitor{codeBlockHandler, codeBlockHandler, nil}
+	visitor := Visitor{CodeBlock: codeBlockHandler, FencedCodeBlock: codeBlockHandler}
 	require.Zero(t, echoTrueCodeBlockCount, "Starting the counter")
 	Tokenize(data, &visitor)
 	require.Equal(t, echoTrueCodeBlockCount, 1, "There is one code block element in the sample file")
@@ -68,3 +71,502 @@ func TestTokenizeFenced(t *testing.T) {
 	require.Empty(t, second.Language, "No language was specified in the second block")
 	require.Empty(t, second.Attributes, "No attributes where specified in the second block")
 }
+
+func TestTokenizeDocusaurusMeta(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/docusaurus.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Equal(t, 2, len(visitor.Interactions), "There are two fenced code blocks in the sample file.")
+	first := visitor.Interactions[0]
+	require.Equal(t, "shell", first.Language, "shell was the specified language for the first code block")
+	require.Equal(t, "1", first.Attributes["shelldocexitcode"], "The shelldoc attributes are found next to the meta information")
+	require.Equal(t, "terminal session", first.Meta["title"], "The quoted title is exposed without quotes")
+	require.Equal(t, "1,3-4", first.Meta["highlight"], "The line highlight ranges are exposed")
+	second := visitor.Interactions[1]
+	require.Equal(t, "bash", second.Language, "bash was the specified language for the second code block")
+	require.Empty(t, second.Attributes, "No attributes where specified in the second block")
+	require.Equal(t, "2", second.Meta["highlight"], "The line highlight ranges are exposed")
+	_, exists := second.Meta["showLineNumbers"]
+	require.True(t, exists, "Flags without values are exposed as meta information")
+}
+
+func TestTokenizeLineNumbers(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/helloworld.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	var lines []int
+	for _, interaction := range visitor.Interactions {
+		lines = append(lines, interaction.Line)
+	}
+	require.Equal(t, []int{5, 6, 11, 16}, lines, "The interactions know the source lines of their commands")
+}
+
+func TestAssignIDs(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/helloworld.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Equal(t, "Test: print \"Hello World\"", visitor.Interactions[0].Heading, "The interactions know their section")
+	AssignIDs("samples/helloworld.md", visitor.Interactions)
+	ids := make(map[string]bool)
+	for _, interaction := range visitor.Interactions {
+		require.Len(t, interaction.ID, 12, "The identifiers are short hashes")
+		ids[interaction.ID] = true
+	}
+	require.Len(t, ids, 4, "The identifiers are unique")
+	// inserting lines does not change the identifiers
+	shifted := NewInteractionVisitor()
+	Tokenize(append([]byte("Some new text.\n\n"), data...), shifted)
+	AssignIDs("./samples/helloworld.md", shifted.Interactions)
+	for index, interaction := range shifted.Interactions {
+		require.Equal(t, visitor.Interactions[index].ID, interaction.ID, "The identifiers are stable")
+	}
+	// identical commands get different identifiers
+	repeated := []*Interaction{{Cmd: "true"}, {Cmd: "true"}}
+	AssignIDs("repeated.md", repeated)
+	require.NotEqual(t, repeated[0].ID, repeated[1].ID, "Repeated commands are told apart by their occurrence")
+}
+
+func TestTokenizeVars(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/vars.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Equal(t, 3, len(visitor.Interactions), "There are three interactions in the sample file.")
+	first := visitor.Interactions[0]
+	require.Equal(t, "echo \"demo server listens on port 8080\"", first.Cmd, "The variables are substituted in the command")
+	require.Equal(t, []string{"demo server listens on port 8080"}, first.Response, "The variables are substituted in the response")
+	require.Equal(t, "echo \"8080 {{UNKNOWN}}\"", visitor.Interactions[1].Cmd, "Unknown placeholders are not modified")
+	require.Equal(t, "echo \"{{PORT}}\"", visitor.Interactions[2].Cmd, "Variables are scoped to their code block")
+	_, err = ParseVars("PORT=8080 NAME")
+	require.Error(t, err, "Assignments without a value are rejected")
+}
+
+func TestTokenizeSnippets(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/snippets.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Equal(t, 6, len(visitor.Interactions), "The setup snippet is replayed once, the missing snippet is ignored.")
+	var commands []string
+	for _, interaction := range visitor.Interactions {
+		commands = append(commands, interaction.Cmd)
+	}
+	require.Equal(t, []string{"export GREETING=Hello", "echo $GREETING", "unset GREETING", "export GREETING=Hello",
+		"echo $GREETING", "echo \"$GREETING World\""}, commands, "The replayed commands are inserted at the directive")
+	replayed := visitor.Interactions[4]
+	require.Equal(t, "setup", replayed.Snippet, "Replayed interactions refer to their snippet")
+	require.Equal(t, "Chapter 2", replayed.Heading, "Replayed interactions belong to the section of the directive")
+	require.Equal(t, 3, replayed.Block, "Replayed interactions form a code block of their own")
+	require.Equal(t, []string{"Hello"}, replayed.Response, "The expected response is replayed")
+	require.Equal(t, 19, replayed.Line, "Replayed interactions are located at the directive")
+	require.Equal(t, 21, visitor.Interactions[5].Line, "Interactions after the directive are located correctly")
+	AssignIDs("samples/snippets.md", visitor.Interactions)
+	require.NotEqual(t, visitor.Interactions[1].ID, replayed.ID, "Replayed interactions have their own ID")
+}
+
+func TestOrderBlocks(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/needs.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	ordered, err := OrderBlocks(visitor.Interactions)
+	require.NoError(t, err, "The dependencies in the sample are valid")
+	require.Equal(t, 2, len(ordered), "No interactions are added or removed")
+	require.Equal(t, "export GREETING=Hello", ordered[0].Cmd, "The needed code block is executed first")
+	require.Equal(t, "echo $GREETING", ordered[1].Cmd, "The dependent code block is executed after its prerequisite")
+
+	block := func(number int, define string, needs string) *Interaction {
+		return &Interaction{Cmd: fmt.Sprintf("true %d", number), Block: number,
+			Attributes: map[string]string{DefineOption: define, NeedsOption: needs}}
+	}
+	_, err = OrderBlocks([]*Interaction{block(1, "a", "b"), block(2, "b", "a")})
+	require.Error(t, err, "Dependency cycles are reported")
+	require.Contains(t, err.Error(), "a -> b -> a", "The cycle is described")
+	_, err = OrderBlocks([]*Interaction{block(1, "a", "missing")})
+	require.Error(t, err, "Undefined code blocks are reported")
+	ordered, err = OrderBlocks([]*Interaction{block(1, "", ""), block(2, "b", ""), block(3, "", "b, b")})
+	require.NoError(t, err, "Code blocks may be needed several times")
+	require.Equal(t, 3, len(ordered), "Code blocks that are already executed are not repeated")
+	require.Equal(t, "true 1", ordered[0].Cmd, "Without dependencies, the document order is kept")
+}
+
+func TestTokenizeDetailsOutput(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/details.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Equal(t, 3, len(visitor.Interactions), "The output blocks do not contain interactions.")
+	require.Equal(t, []string{"one", "two"}, visitor.Interactions[0].Response, "The fenced output block is the expected response")
+	require.Equal(t, []string{"three"}, visitor.Interactions[1].Response, "The indented output block is the expected response")
+	require.Equal(t, []string{"four"}, visitor.Interactions[2].Response, "Other collapsed sections contain regular code blocks")
+	require.Equal(t, 3, visitor.Interactions[2].Block, "Output blocks are not counted as code blocks")
+}
+
+func TestTokenizeCRLF(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/crlf.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Equal(t, 2, len(visitor.Interactions), "There are two interactions in the sample file.")
+	require.Equal(t, "echo Hello", visitor.Interactions[0].Cmd, "Commands do not contain carriage returns")
+	require.Equal(t, []string{"Hello"}, visitor.Interactions[0].Response, "Responses do not contain carriage returns")
+	require.Equal(t, "2", visitor.Interactions[1].Attributes[ExitCodeOption], "Attributes do not contain carriage returns")
+	require.Equal(t, 3, visitor.Interactions[0].Line, "Lines are counted with CRLF line endings")
+}
+
+func TestNormalizePaths(t *testing.T) {
+	normalize := newPathNormalizer()
+	require.Equal(t, "<TMPDIR>/build/out.txt", normalize("/tmp/build/out.txt"), "The temporary directory is replaced")
+	require.Equal(t, "C:/Users/me/file.txt", normalize(`C:\Users\me\file.txt`), "Backslashes are converted")
+	require.Equal(t, "/tmpfiles/x", normalize("/tmpfiles/x"), "Only complete directory names are replaced")
+	require.Equal(t, "cd <TMPDIR>", normalize("cd /tmp"), "The temporary directory itself is replaced")
+	os.Setenv("TEMP", `C:\Users\me\AppData\Local\Temp`)
+	defer os.Unsetenv("TEMP")
+	require.Equal(t, "<TMPDIR>/x.log", newPathNormalizer()(`C:\Users\me\AppData\Local\Temp\x.log`), "%TEMP% is replaced")
+
+	interaction := Interaction{Response: []string{"/tmp/data.txt", "..."}, NormalizePaths: true}
+	require.True(t, interaction.evaluateResponse([]string{os.TempDir() + "/data.txt", "more"}), "Temporary directories match")
+	require.False(t, interaction.evaluateResponse(nil), "Output shorter than the expected response does not match")
+	interaction.NormalizePaths = false
+	require.False(t, interaction.evaluateResponse([]string{`\tmp\data.txt`}), "Without normalization, separators matter")
+}
+
+func TestDirectives(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/directives.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 4)
+	env := map[string]string{"GREETING": "Hello World", "LANGUAGE": "en"}
+	for _, interaction := range visitor.Interactions {
+		require.Equal(t, env, interaction.Env, "env directives apply to all following commands")
+	}
+	require.Equal(t, map[string]string{SkipOption: "needs access to the release server"}, visitor.Interactions[1].Attributes,
+		"skip-next applies to the next code block")
+	require.Equal(t, map[string]string{TimeoutOption: "1s"}, visitor.Interactions[2].Attributes,
+		"timeout applies to the next code block")
+	require.Empty(t, visitor.Interactions[3].Attributes, "Directives only apply to the next code block")
+	require.Equal(t, []SkippedBlock{{Line: 30, Content: []string{"frobnicate"}, Reason: SkipInvalidDirective, text: "frobnicate"}},
+		visitor.Skipped, "Unknown directives are reported")
+}
+
+func TestDefaults(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/defaults.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 3)
+	require.Equal(t, "shell", visitor.Interactions[0].Language, "console is an alias of shell")
+	require.Equal(t, map[string]string{TimeoutOption: "30s", SortedOption: ""}, visitor.Interactions[0].Attributes,
+		"The front matter sets defaults")
+	require.Equal(t, 12, visitor.Interactions[0].Line, "The front matter does not change line numbers")
+	require.Equal(t, map[string]string{TimeoutOption: "1s", SortedOption: ""}, visitor.Interactions[1].Attributes,
+		"The attributes of a code block take precedence")
+	require.Equal(t, map[string]string{TimeoutOption: "30s", SortedOption: "", ExitCodeOption: "nonzero"},
+		visitor.Interactions[2].Attributes, "The defaults directive adds defaults")
+	require.Len(t, visitor.Skipped, 1)
+	require.Empty(t, visitor.Skipped[0].Attributes, "Defaults are not reported for blocks without commands")
+}
+
+func TestSplitFrontMatter(t *testing.T) {
+	data, spec := splitFrontMatter([]byte("---\ntitle: Test\nshelldoc: {shelldocsorted}\n...\n# Test\n"))
+	require.Equal(t, "\n\n\n\n# Test\n", string(data))
+	require.Equal(t, "{shelldocsorted}", spec)
+	data, spec = splitFrontMatter([]byte("# Test\n---\n"))
+	require.Equal(t, "# Test\n---\n", string(data), "Front matter starts at the beginning of the file")
+	require.Empty(t, spec)
+}
+
+func TestContinuation(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/continuation.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 4)
+	require.Equal(t, "printf '%s %s\\n' \\\n\"Hello\" \\\n\"World\"", visitor.Interactions[0].Cmd,
+		"The continuation lines are part of the command")
+	require.Equal(t, []string{"Hello World"}, visitor.Interactions[0].Response)
+	require.Equal(t, 11, visitor.Interactions[1].Line, "The commands are located at their first line")
+	require.Equal(t, "echo one\\\\", visitor.Interactions[2].Cmd, "Escaped backslashes do not continue commands")
+	require.Equal(t, 21, visitor.Interactions[3].Line)
+	require.Equal(t, []string{"echo one \\", "echo two"}, commandLines("echo one \\\ntwo\necho two"))
+	visitor = NewInteractionVisitor()
+	Tokenize([]byte("```shell\n$ echo C:\\\nC:\\\n  $ echo next \\\n  next\n```\n"), visitor)
+	require.Len(t, visitor.Interactions, 2)
+	require.Equal(t, "echo C:\\", visitor.Interactions[0].Cmd, "Lines that are not indented are no continuation")
+	require.Equal(t, []string{"C:\\"}, visitor.Interactions[0].Response, "The response follows the command")
+	require.Equal(t, []string{"next"}, visitor.Interactions[1].Response, "The indentation is relative to the command")
+	visitor = NewInteractionVisitor()
+	Tokenize([]byte("```shell\n$ echo one \\\n> echo two\n$ echo three \\\n  > echo four\n```\n"), visitor)
+	require.Len(t, visitor.Interactions, 4, "Lines with a trigger character are new commands")
+	require.Equal(t, "echo one \\", visitor.Interactions[0].Cmd)
+	require.Equal(t, "echo two", visitor.Interactions[1].Cmd)
+	require.Equal(t, "echo four", visitor.Interactions[3].Cmd)
+}
+
+func TestSkippedBlocks(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/skipped.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 1, "Only the block with a trigger character contains interactions")
+	require.Equal(t, 3, visitor.Interactions[0].Block, "Multi-line inline code is not counted as a code block")
+	require.Equal(t, []SkippedBlock{
+		{Block: 1, Line: 6, Language: "json", Attributes: map[string]string{}, Meta: map[string]string{},
+			Content: []string{"{ \"port\": 8080 }"}, Reason: SkipNoCommands, text: "{ \"port\": 8080 }"},
+		{Block: 2, Line: 11, Content: []string{"server started"}, Reason: SkipNoCommands, text: "server started"},
+		{Line: 13, Reason: SkipUndefinedSnippet, text: "use undefined"},
+	}, visitor.Skipped)
+}
+
+func TestRedact(t *testing.T) {
+	redactions := []Redaction{
+		{Pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2}`), Replacement: "<DATE>"},
+		{Pattern: regexp.MustCompile(`id=(\w+)-\d+`), Replacement: "id=$1-<N>"},
+	}
+	require.Equal(t, []string{"built on <DATE>", "id=job-<N>"}, redact([]string{"built on 2023-05-01", "id=job-42"}, redactions))
+	interaction := Interaction{Response: []string{"built on 1999-12-31"}, Redactions: redactions}
+	require.True(t, interaction.evaluateResponse([]string{"built on <DATE>"}), "The expected response is redacted as well")
+}
+
+func TestNormalizers(t *testing.T) {
+	names, err := ParseNormalizers("sizes, timestamps")
+	require.NoError(t, err)
+	require.Equal(t, []string{"timestamps", "sizes"}, names, "Normalizers are applied in a fixed order")
+	_, err = ParseNormalizers("timestamps,uuids")
+	require.Error(t, err, "Unknown normalizers are rejected")
+	lines := []string{
+		"started 2023-05-01T12:30:45.123+02:00, finished 2023-05-01 12:31",
+		"took 1.23s (150ms user, 1m30s total)",
+		"wrote 512 bytes, 1.5 MiB, 20kB and 4.0K",
+		"version 1.2.3 has 3 files",
+	}
+	require.Equal(t, []string{
+		"started <TIMESTAMP>, finished <TIMESTAMP>",
+		"took <DURATION> (<DURATION> user, <DURATION> total)",
+		"wrote <SIZE>, <SIZE>, <SIZE> and <SIZE>",
+		"version 1.2.3 has 3 files",
+	}, normalizeValues(lines, Normalizers))
+}
+
+func TestParseResponders(t *testing.T) {
+	responders, err := ParseResponders(`'Are you sure\?'=y Password:="not secret"`)
+	require.NoError(t, err, "Quoted patterns and replies are supported")
+	require.Len(t, responders, 2)
+	require.True(t, responders[0].Pattern.MatchString("Are you sure? [y/N] "))
+	require.Equal(t, "y", responders[0].Reply)
+	require.Equal(t, "Password:", responders[1].Pattern.String())
+	require.Equal(t, "not secret", responders[1].Reply)
+	_, err = ParseResponders("noreply")
+	require.Error(t, err, "Rules need a reply")
+	_, err = ParseResponders("[=y")
+	require.Error(t, err, "Patterns need to be valid regular expressions")
+}
+
+func TestTransaction(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/transaction.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 2, "The commands of the transaction are merged into one interaction")
+	transaction := visitor.Interactions[0]
+	require.Equal(t, "cd /tmp\necho Hello\necho World", transaction.Cmd)
+	require.Equal(t, []string{"Hello", "World"}, transaction.Response)
+	require.Equal(t, "cd /tmp (+2 commands)", transaction.Caption)
+	require.Equal(t, 6, transaction.Line, "The transaction is located at its first command")
+	require.Equal(t, 16, visitor.Interactions[1].Line, "Commands in the transaction are not mistaken for later ones")
+}
+
+func TestSections(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/sections.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 4)
+	require.Equal(t, []string{"Manual", "Installation"}, visitor.Interactions[0].Sections)
+	require.Equal(t, []string{"Manual", "Getting Started", "First steps"}, visitor.Interactions[2].Sections)
+	require.Equal(t, []string{"Manual", "Reference"}, visitor.Interactions[3].Sections, "Subsections end with the next section")
+	var selected []string
+	for _, interaction := range visitor.Interactions {
+		if interaction.InSection("Getting Started") {
+			selected = append(selected, interaction.Cmd)
+		}
+	}
+	require.Equal(t, []string{"echo start", "echo first"}, selected, "Sections include their subsections")
+}
+
+func TestSquashRepeats(t *testing.T) {
+	lines := []string{"start", "tick", "tick", "tick", "done"}
+	require.Equal(t, []string{"start", "tick (x3)", "done"}, SquashRepeats(lines))
+	require.Equal(t, lines, expandRepeats(SquashRepeats(lines)), "Squashed lines are expanded again")
+	require.Equal(t, []string{"a (xN)"}, expandRepeats([]string{"a (xN)"}), "Only numbers are counts")
+	interaction := Interaction{Response: []string{"tick (x2)"}, Attributes: map[string]string{SquashOption: ""}}
+	require.True(t, interaction.evaluateResponse([]string{"tick", "tick"}))
+	require.False(t, interaction.evaluateResponse([]string{"tick"}), "The number of lines is verified")
+	interaction.Attributes = nil
+	require.False(t, interaction.evaluateResponse([]string{"tick", "tick"}), "The notation needs the attribute")
+}
+
+func TestErrorResponse(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/stderr.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 3)
+	require.Equal(t, []string{"result"}, visitor.Interactions[0].Response)
+	require.Equal(t, []string{"no such file"}, visitor.Interactions[0].ErrorResponse, "Lines with ! are expected on stderr")
+	interaction := visitor.Interactions[1]
+	require.True(t, interaction.evaluateErrors([]string{"error: deprecated"}))
+	require.False(t, interaction.evaluateErrors([]string{"warning: deprecated"}))
+	require.True(t, visitor.Interactions[2].evaluateErrors([]string{"anything"}), "Without expected lines, stderr is not verified")
+}
+
+func TestVerifyFile(t *testing.T) {
+	data, err := ioutil.ReadFile("samples/verifyfile.md")
+	require.NoError(t, err, "Unable to read sample data file")
+	visitor := NewInteractionVisitor()
+	Tokenize(data, visitor)
+	require.Len(t, visitor.Interactions, 5)
+	verification := visitor.Interactions[1]
+	name, ok := verification.VerifiedFile()
+	require.True(t, ok, "The code block verifies a file")
+	require.Equal(t, "shelldoc-config.yaml", name)
+	require.Equal(t, "cat shelldoc-config.yaml", verification.Cmd)
+	require.Equal(t, []string{"server:", "  port: 8080"}, verification.Response, "Indentation is preserved")
+	require.Equal(t, 11, verification.Line, "The verification is located at the opening fence")
+	require.Equal(t, 30, visitor.Interactions[4].Line, "Commands after verifications are located")
+	require.Empty(t, visitor.Skipped, "Code blocks with file contents are not skipped")
+}
+
+func TestCompareRegex(t *testing.T) {
+	interaction := Interaction{Response: []string{"id: (?P<ID>\\w+)", "..."}, Attributes: map[string]string{RegexOption: ""}}
+	require.True(t, interaction.compareRegex([]string{"id: a1b2", "more output"}))
+	require.Equal(t, map[string]string{"ID": "a1b2"}, interaction.Captures, "Named capture groups are stored")
+	require.False(t, interaction.compareRegex([]string{"the id: a1b2"}), "Patterns match complete lines")
+	interaction.Attributes = nil
+	require.False(t, interaction.compareRegex([]string{"id: a1b2"}), "Regex matching needs to be enabled")
+	invalid := Interaction{Response: []string{"id: (?P<ID"}, Attributes: map[string]string{RegexOption: ""}}
+	require.Error(t, invalid.ValidatePatterns(), "Invalid patterns are reported")
+	lines := Interaction{Response: []string{"total (1+1)", "re: ^total \\d+$"}}
+	require.True(t, lines.compareRegex([]string{"total (1+1)", "total 42"}), "Lines with the re: prefix are patterns")
+	require.False(t, lines.compareRegex([]string{"total 11", "total 42"}), "The other lines match literally")
+	lines.Response = []string{"re: (?P<ID"}
+	require.Error(t, lines.ValidatePatterns(), "Invalid patterns in lines are reported")
+	lines.Response = []string{"re: total \\d+"}
+	require.False(t, lines.compareRegex([]string{"  total 42"}), "Spaces in the output are not trimmed")
+}
+
+func TestCompareRegexNormalized(t *testing.T) {
+	// the literal lines are expanded, redacted and normalized like without regular expressions
+	interaction := Interaction{Response: []string{"tick (x2)", "built on 1999-12-31 in 3s", "re: took \\d+ms"},
+		Attributes: map[string]string{SquashOption: "", NormalizeOption: "durations"},
+		Redactions: []Redaction{{Pattern: regexp.MustCompile(`\d{4}-\d{2}-\d{2}`), Replacement: "<DATE>"}}}
+	require.True(t, interaction.compareRegex([]string{"tick", "tick", "built on <DATE> in 12s", "took 42ms"}))
+	require.False(t, interaction.compareRegex([]string{"tick", "built on <DATE> in 12s", "took 42ms"}),
+		"The repeated lines are expanded")
+	interaction.Response = []string{"re: took <DURATION>"}
+	require.True(t, interaction.compareRegex([]string{"took 42ms"}), "Patterns may match the normalized output")
+	interaction = Interaction{Response: []string{"wrote <TMPDIR>/out.txt", "re: \\d+ files"}, NormalizePaths: true}
+	require.True(t, interaction.compareRegex([]string{"wrote /tmp/out.txt", "3 files"}), "Paths are normalized")
+}
+
+func TestGlobMatches(t *testing.T) {
+	require.True(t, globMatches([]string{"* bytes", "id: ???"}, []string{"1024 bytes", "id: a1b"}))
+	require.False(t, globMatches([]string{"id: ???"}, []string{"id: a1b2"}), "? matches a single character")
+	require.False(t, globMatches([]string{"* bytes"}, []string{"1024 bytes", "done"}), "All lines have to match")
+	require.True(t, globMatches([]string{`100\% (a.b)`}, []string{"100% (a.b)"}), "Other characters match literally")
+	require.False(t, globMatches([]string{`\*`}, []string{"x"}), "Escaped wildcards match literally")
+}
+
+func TestAnchorMatches(t *testing.T) {
+	require.True(t, anchorMatches([]string{"^build", "files$", "^user$", "active"},
+		[]string{"build 1234", "total: 42 files", "user", "status: active"}))
+	require.False(t, anchorMatches([]string{"^build"}, []string{"rebuild"}), "^ anchors at the start of the line")
+	require.False(t, anchorMatches([]string{"files$"}, []string{"files: 42"}), "$ anchors at the end of the line")
+	require.False(t, anchorMatches([]string{"^user$"}, []string{"user: docs"}), "Both anchors match complete lines")
+	require.False(t, anchorMatches([]string{"build"}, []string{"build", "done"}), "All lines have to match")
+	require.True(t, anchorMatches([]string{`\^HOME \$`}, []string{"echo ^HOME $"}), "Escaped anchors match literally")
+}
+
+func TestDescribeWidth(t *testing.T) {
+	interaction := Interaction{Cmd: "curl --silent --show-error https://example.com/api/v1/items", Response: []string{"[]"}}
+	require.Equal(t, "curl --silent --show-error https://ex...  ?  []                       ", interaction.Describe())
+	require.Equal(t, "curl --silent --show-e...  ?  []             ", interaction.DescribeWidth(40))
+	require.Contains(t, interaction.DescribeWidth(0), interaction.Cmd, "Without a width, the command is not elided")
+}
+
+func TestLocalizedResult(t *testing.T) {
+	interaction := Interaction{Response: []string{"Hello"}, ResultCode: ResultMismatch}
+	require.Equal(t, "FAIL (mismatch)", interaction.Result())
+	require.Equal(t, "FEHLGESCHLAGEN (Abweichung)", interaction.LocalizedResult("de"))
+	require.Equal(t, "fail-mismatch", interaction.ResultID(), "The result ID does not depend on the locale")
+	interaction.Skip("requires user nobody")
+	require.Equal(t, "ÜBERSPRUNGEN (requires user nobody)", interaction.LocalizedResult("de"))
+	require.Equal(t, "SKIPPED (requires user nobody)", interaction.LocalizedResult("xx"), "Unknown locales use English")
+	locale, err := ParseLocale("de_DE.UTF-8")
+	require.NoError(t, err)
+	require.Equal(t, "de", locale)
+	_, err = ParseLocale("tlh")
+	require.Error(t, err, "Unsupported locales are rejected")
+	environment := map[string]string{"LANG": "de_AT.UTF-8"}
+	require.Equal(t, "de", DetectLocale(func(name string) string { return environment[name] }))
+	environment["LC_ALL"] = "C"
+	require.Equal(t, DefaultLocale, DetectLocale(func(name string) string { return environment[name] }),
+		"LC_ALL takes precedence")
+}
+
+func TestSortedMatches(t *testing.T) {
+	require.True(t, sortedMatches([]string{"a", "b", "b"}, []string{"b", "a", "b"}))
+	require.False(t, sortedMatches([]string{"a", "b", "b"}, []string{"b", "a", "a"}), "Repeated lines are counted")
+	require.True(t, sortedMatches([]string{"b", "..."}, []string{"c", "b", "a"}), "The ellipsis allows more lines")
+	require.False(t, sortedMatches([]string{"b", "b", "..."}, []string{"c", "b"}))
+	require.True(t, sortedMatches(nil, nil))
+}
+
+func TestMatchExitCode(t *testing.T) {
+	require.True(t, matchExitCode("1-125", 1))
+	require.True(t, matchExitCode("1-125", 125))
+	require.False(t, matchExitCode("1-125", 126))
+	require.True(t, matchExitCode("0, 2,126-127", 127), "Lists may contain white space")
+	require.True(t, matchExitCode("nonzero", -1), "Commands that could not be executed have a nonzero exit code")
+	require.False(t, matchExitCode("nonzero", 0))
+	require.True(t, matchExitCode("any", 0))
+	for _, valid := range []string{"2", "-1", "1-125", "any,0", "nonzero, 0"} {
+		require.NoError(t, ValidateExitCodes(valid), "%s is valid", valid)
+	}
+	for _, invalid := range []string{"", "one", "125-1", "1-", "1,,2"} {
+		require.Error(t, ValidateExitCodes(invalid), "%s is invalid", invalid)
+	}
+}
+
+func TestParserFor(t *testing.T) {
+	commands := func(data []byte, visitor *Visitor) error {
+		visitor.Interactions = append(visitor.Interactions, &Interaction{Cmd: string(data)})
+		return nil
+	}
+	RegisterFormat(commands, ".shelldoc-test", "text/x-shelldoc-test")
+	for _, name := range []string{"doc.SHELLDOC-TEST", `docs\doc.shelldoc-test`, "https://example.com/doc.shelldoc-test?raw=1"} {
+		visitor := NewInteractionVisitor()
+		require.NoError(t, ParserFor(name)([]byte("ls"), visitor))
+		require.Len(t, visitor.Interactions, 1, "The registered parser is used for %s", name)
+	}
+	visitor := NewInteractionVisitor()
+	require.NoError(t, ParserFor("README")([]byte("    $ ls\n"), visitor))
+	require.Equal(t, "ls", visitor.Interactions[0].Cmd, "Markdown is the default")
+}
+
+func TestCategories(t *testing.T) {
+	for _, name := range CategoryNames() {
+		category, err := ParseCategory(name)
+		require.NoError(t, err)
+		require.Equal(t, name, category.String(), "Category names can be parsed")
+	}
+	_, err := ParseCategory("")
+	require.Error(t, err, "Interactions without failures have no category name")
+	interaction := Interaction{}
+	interaction.Deny("matches the deny pattern rm")
+	require.Equal(t, CategoryPolicyViolation, interaction.Category)
+}
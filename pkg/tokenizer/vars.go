@@ -0,0 +1,56 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// placeholderEx matches the {{NAME}} placeholders that are replaced with the values of block variables
+const placeholderEx = `\{\{\s*([A-Za-z_][A-Za-z0-9_]*)\s*\}\}`
+
+var placeholderRx = regexp.MustCompile(placeholderEx)
+
+// ParseVars parses the value of the shelldocvars attribute, a white space separated list of NAME=VALUE
+// assignments. Values may be quoted to contain white space.
+func ParseVars(spec string) (map[string]string, error) {
+	return parseAssignments(spec, VarsOption)
+}
+
+// parseAssignments parses a white space separated list of NAME=VALUE assignments, origin names where they were
+// specified in error messages
+func parseAssignments(spec string, origin string) (map[string]string, error) {
+	nameRx := regexp.MustCompile("^[A-Za-z_][A-Za-z0-9_]*$")
+	vars := make(map[string]string)
+	for _, assignment := range splitInfoString(spec) {
+		separator := strings.Index(assignment, "=")
+		if separator < 0 {
+			return vars, fmt.Errorf("expected NAME=VALUE in %s, got \"%s\"", origin, assignment)
+		}
+		name := assignment[:separator]
+		if !nameRx.MatchString(name) {
+			return vars, fmt.Errorf("invalid variable name \"%s\" in %s", name, origin)
+		}
+		vars[name] = unquote(assignment[separator+1:])
+	}
+	return vars, nil
+}
+
+// substituteVars replaces the {{NAME}} placeholders in line with the values of the variables. Placeholders of
+// unknown variables are not modified.
+func substituteVars(line string, vars map[string]string) string {
+	if len(vars) == 0 {
+		return line
+	}
+	return placeholderRx.ReplaceAllStringFunc(line, func(placeholder string) string {
+		name := placeholderRx.FindStringSubmatch(placeholder)[1]
+		if value, ok := vars[name]; ok {
+			return value
+		}
+		return placeholder
+	})
+}
@@ -0,0 +1,87 @@
+package tokenizer
+
+// This file is part of shelldoc.
+// © 2023, Mirko Boehm <mirko@kde.org> and the shelldoc contributors
+// SPDX-License-Identifier: LGPL-3.0
+
+import (
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+
+	"github.com/mirkoboehm/shelldoc/pkg/shell"
+)
+
+// newFileVerification creates an interaction that compares the file named with VerifyFileOption to the lines of the
+// code block. Leading white space is significant in files like YAML, so only trailing white space is removed.
+func newFileVerification(lines []string, vars map[string]string) *Interaction {
+	interaction := new(Interaction)
+	for _, line := range lines {
+		interaction.Response = append(interaction.Response, strings.TrimRight(substituteVars(line, vars), " \t\r"))
+	}
+	interaction.Response = trimTrailingEmptyLines(interaction.Response)
+	return interaction
+}
+
+// trimTrailingEmptyLines removes the empty lines at the end of lines
+func trimTrailingEmptyLines(lines []string) []string {
+	for len(lines) > 0 && len(lines[len(lines)-1]) == 0 {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
+
+// VerifiedFile returns the name of the file the interaction verifies (see VerifyFileOption), and false if it
+// executes a command
+func (interaction *Interaction) VerifiedFile() (string, bool) {
+	name, ok := interaction.Attributes[VerifyFileOption]
+	return strings.TrimSpace(name), ok
+}
+
+// verifyFile compares the contents of the file named with VerifyFileOption to the expected response. Relative
+// names are resolved in the working directory of the shell, so that the file is found where the previous commands
+// created it.
+func (interaction *Interaction) verifyFile(sh *shell.Shell) error {
+	name, _ := interaction.VerifiedFile()
+	if len(name) == 0 {
+		return fmt.Errorf("%s needs a file name", VerifyFileOption)
+	}
+	path := substituteVars(name, interaction.Vars)
+	if !filepath.IsAbs(path) {
+		directory, err := sh.WorkingDirectory()
+		if err != nil {
+			interaction.ResultCode = ResultExecutionError
+			interaction.Category = CategoryShellError
+			interaction.ExitCode = -1
+			interaction.Comment = err.Error()
+			return fmt.Errorf("unable to verify file %s: %v", name, err)
+		}
+		path = filepath.Join(directory, path)
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		interaction.ResultCode = ResultError
+		interaction.Category = CategoryAssertion
+		interaction.ExitCode = 1
+		interaction.Comment = fmt.Sprintf("unable to read file %s: %v", name, err)
+		return nil
+	}
+	var output []string
+	for _, line := range strings.Split(string(data), "\n") {
+		output = append(output, strings.TrimRight(line, " \t\r"))
+	}
+	interaction.Output = redact(trimTrailingEmptyLines(output), interaction.Redactions)
+	interaction.ErrorOutput = nil
+	interaction.ExitCode = 0
+	if interaction.evaluateResponse(interaction.Output) {
+		interaction.ResultCode = ResultMatch
+		interaction.Category = CategoryNone
+		interaction.Comment = ""
+	} else {
+		interaction.ResultCode = ResultMismatch
+		interaction.Category = CategoryAssertion
+		interaction.Comment = fmt.Sprintf("the contents of file %s differ", name)
+	}
+	return nil
+}